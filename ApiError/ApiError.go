@@ -0,0 +1,112 @@
+// Package apierror is the error model of the HTTP API. Handlers report a
+// failure as an *Error carrying the HTTP status and a stable,
+// machine-readable code; ErrorMiddleware renders it once the handler
+// returns, as RFC 7807 problem details or, on the OAuth endpoints, in the
+// RFC 6749 format.
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The codes clients may rely on. Messages are for people and may change;
+// codes do not.
+const (
+	CodeInvalidRequest           = "invalid_request"
+	CodeValidationFailed         = "validation_failed"
+	CodeWeakPassword             = "weak_password"
+	CodeInvalidLink              = "invalid_link"
+	CodeAuthenticationRequired   = "authentication_required"
+	CodeInvalidToken             = "invalid_token"
+	CodeInvalidCredentials       = "invalid_credentials"
+	CodePasswordLoginUnavailable = "password_login_unavailable"
+	CodeReauthenticationRequired = "reauthentication_required"
+	CodeAccountDisabled          = "account_disabled"
+	CodeLinkBrowserMismatch      = "link_browser_mismatch"
+	CodeForbidden                = "forbidden"
+	CodeNotFound                 = "not_found"
+	CodeFeatureDisabled          = "feature_disabled"
+	CodeUserExists               = "user_exists"
+	CodeInternal                 = "internal_error"
+	CodeNotReady                 = "not_ready"
+
+	// OAuth 2.0 error codes (RFC 6749 section 5.2), used as they are.
+	CodeInvalidClient        = "invalid_client"
+	CodeInvalidGrant         = "invalid_grant"
+	CodeUnauthorizedClient   = "unauthorized_client"
+	CodeUnsupportedGrantType = "unsupported_grant_type"
+	CodeInvalidScope         = "invalid_scope"
+	// The token exchange may not issue tokens for the audience (RFC 8693
+	// section 2.2.2).
+	CodeInvalidTarget = "invalid_target"
+
+	// Device authorization grant errors (RFC 8628 section 3.5).
+	CodeAuthorizationPending = "authorization_pending"
+	CodeSlowDown             = "slow_down"
+	CodeAccessDenied         = "access_denied"
+	CodeExpiredToken         = "expired_token"
+
+	// Dynamic client registration errors (RFC 7591 section 3.2.2).
+	CodeInvalidRedirectUri    = "invalid_redirect_uri"
+	CodeInvalidClientMetadata = "invalid_client_metadata"
+)
+
+// Error is a failure reported to the client. Err is the cause; it is
+// logged for server errors and never sent.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	// Extra holds additional members of the problem, such as the list of
+	// password policy violations.
+	Extra map[string]interface{}
+	Err   error
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *Error   { return New(http.StatusBadRequest, code, message) }
+func Unauthorized(code, message string) *Error { return New(http.StatusUnauthorized, code, message) }
+func Forbidden(code, message string) *Error    { return New(http.StatusForbidden, code, message) }
+func NotFound(message string) *Error           { return New(http.StatusNotFound, CodeNotFound, message) }
+func Conflict(code, message string) *Error     { return New(http.StatusConflict, code, message) }
+
+// Internal reports a server failure. message is shown to the client, err
+// only logged.
+func Internal(message string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With returns a copy of the error with an additional problem member.
+func (e *Error) With(key string, value interface{}) *Error {
+	copied := *e
+	copied.Extra = map[string]interface{}{}
+	for k, v := range e.Extra {
+		copied.Extra[k] = v
+	}
+	copied.Extra[key] = value
+	return &copied
+}
+
+// Abort stops the handler chain with err. It is rendered by
+// ErrorMiddleware after the handlers return.
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,81 @@
+package apierror
+
+import (
+	"net/http"
+
+	logging "go_server/Logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemTypePrefix starts the "type" URI of every problem; the code
+// completes it.
+const ProblemTypePrefix = "urn:goauth:error:"
+
+// WriteProblem renders err as application/problem+json (RFC 7807) with
+// the code and request ID as extension members. "message" repeats
+// "detail" for clients written against the older {status, message} body.
+func WriteProblem(c *gin.Context, err *Error) {
+	body := gin.H{}
+	for key, value := range err.Extra {
+		body[key] = value
+	}
+	body["type"] = ProblemTypePrefix + err.Code
+	body["title"] = http.StatusText(err.Status)
+	body["status"] = err.Status
+	body["detail"] = err.Message
+	body["instance"] = c.Request.URL.Path
+	body["code"] = err.Code
+	body["message"] = err.Message
+	if id := logging.RequestID(c.Request.Context()); id != "" {
+		body["request_id"] = id
+	}
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(err.Status, body)
+}
+
+// WriteOAuth renders err as an OAuth 2.0 error response (RFC 6749 section
+// 5.2). Only server errors keep their status; invalid_client is 401 and
+// every other error 400, as the RFC requires.
+func WriteOAuth(c *gin.Context, err *Error) {
+	code := oauthCode(err)
+	status := http.StatusBadRequest
+	switch {
+	case err.Status >= 500:
+		status = err.Status
+	case code == CodeInvalidClient:
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="goauth"`)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(status, gin.H{
+		"error":             code,
+		"error_description": err.Message,
+	})
+}
+
+// oauthCode maps the API codes onto the closed set RFC 6749 allows,
+// extended by the device grant (RFC 8628), token exchange (RFC 8693) and
+// dynamic client registration (RFC 7591).
+func oauthCode(err *Error) string {
+	switch err.Code {
+	case CodeInvalidRequest, CodeInvalidClient, CodeInvalidGrant, CodeUnauthorizedClient,
+		CodeUnsupportedGrantType, CodeInvalidScope, CodeInvalidTarget,
+		CodeAuthorizationPending, CodeSlowDown, CodeAccessDenied, CodeExpiredToken,
+		CodeInvalidRedirectUri, CodeInvalidClientMetadata:
+		return err.Code
+	case CodeInvalidToken, CodeInvalidCredentials, CodeInvalidLink, CodeNotFound, CodeAccountDisabled:
+		return CodeInvalidGrant
+	case CodeAuthenticationRequired:
+		return CodeInvalidClient
+	case CodeFeatureDisabled:
+		return CodeUnsupportedGrantType
+	case CodeNotReady:
+		return "temporarily_unavailable"
+	}
+	if err.Status >= 500 {
+		return "server_error"
+	}
+	return CodeInvalidRequest
+}
@@ -0,0 +1,56 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteOAuthMapsCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		err    *Error
+		status int
+		code   string
+	}{
+		{BadRequest(CodeInvalidRequest, "Token is required"), 400, "invalid_request"},
+		{Unauthorized(CodeInvalidToken, "Invalid token"), 400, "invalid_grant"},
+		{Unauthorized(CodeAuthenticationRequired, "Client authentication required"), 401, "invalid_client"},
+		{NotFound("Refresh is disabled"), 400, "invalid_grant"},
+		{BadRequest(CodeSlowDown, "Poll less often"), 400, "slow_down"},
+		{BadRequest(CodeInvalidRedirectUri, "redirect_uris must be http(s) URLs"), 400, "invalid_redirect_uri"},
+		{Internal("Error getting the session", errors.New("boom")), 500, "server_error"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodPost, "/token", nil)
+		WriteOAuth(c, tc.err)
+
+		var body struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != tc.status || body.Error != tc.code || body.Description != tc.err.Message {
+			t.Errorf("%s: got %d %+v, want %d %s", tc.err.Code, rec.Code, body, tc.status, tc.code)
+		}
+	}
+}
+
+func TestWithCopiesTheError(t *testing.T) {
+	base := BadRequest(CodeWeakPassword, "Password does not meet the requirements")
+	extended := base.With("errors", []string{"too short"})
+	if base.Extra != nil {
+		t.Fatalf("With modified the original: %+v", base.Extra)
+	}
+	if extended.Extra["errors"] == nil || extended.Code != CodeWeakPassword {
+		t.Fatalf("extended = %+v", extended)
+	}
+}
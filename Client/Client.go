@@ -0,0 +1,204 @@
+// Package client calls the API from Go. The methods, one per route, are
+// generated from the OpenAPI operations in Operations.go; this file holds
+// the transport they share.
+//
+//	c := client.New("https://auth.example.com")
+//	session, err := c.Login(ctx, models.LoginRequest{Email: email, Password: password})
+//	c.Token = session.Token
+package client
+
+//go:generate go run ../OpenApi/ClientGen -o Operations.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Client calls the API at BaseURL. Token, when set, is sent as the bearer
+// token of every request.
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// New returns a client of the server at baseURL using
+// http.DefaultClient.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTP: http.DefaultClient}
+}
+
+// FieldError is an invalid field of a validation failure.
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// Error is an error answered by the server, from a problem document or,
+// on the token endpoints, an OAuth 2.0 error.
+type Error struct {
+	Status    int
+	Code      string
+	Message   string
+	RequestID string
+	Fields    []FieldError
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.Status, e.Code)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// request is one call to the API. body is sent as JSON; upload, if set,
+// is sent as is with contentType.
+type request struct {
+	method      string
+	path        string
+	query       interface{}
+	body        interface{}
+	upload      io.Reader
+	contentType string
+}
+
+// do sends req and decodes the "data" of the envelope into data, unless
+// data is nil.
+func (c *Client) do(ctx context.Context, req request, data interface{}) error {
+	body, err := c.stream(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if data == nil {
+		return nil
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&envelope); err != nil {
+		return fmt.Errorf("decoding the response of %s %s: %w", req.method, req.path, err)
+	}
+	return json.Unmarshal(envelope.Data, data)
+}
+
+// read sends req and returns the whole body of the answer.
+func (c *Client) read(ctx context.Context, req request) ([]byte, error) {
+	body, err := c.stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// stream sends req and returns the body of a successful answer, which the
+// caller closes. Any other answer is returned as an *Error.
+func (c *Client) stream(ctx context.Context, req request) (io.ReadCloser, error) {
+	target := c.BaseURL + req.path
+	if req.query != nil {
+		if query := encodeQuery(req.query); len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch {
+	case req.upload != nil:
+		body, contentType = req.upload, req.contentType
+	case req.body != nil:
+		data, err := json.Marshal(req.body)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp.Body, nil
+}
+
+func decodeError(resp *http.Response) error {
+	var body struct {
+		Code             string       `json:"code"`
+		Detail           string       `json:"detail"`
+		Message          string       `json:"message"`
+		RequestID        string       `json:"request_id"`
+		Errors           []FieldError `json:"errors"`
+		Error            string       `json:"error"`
+		ErrorDescription string       `json:"error_description"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	json.Unmarshal(data, &body)
+
+	err := &Error{Status: resp.StatusCode, Code: body.Code, Message: body.Detail, RequestID: body.RequestID, Fields: body.Errors}
+	if err.Message == "" {
+		err.Message = body.Message
+	}
+	if body.Error != "" {
+		err.Code, err.Message = body.Error, body.ErrorDescription
+	}
+	if err.Code == "" && err.Message == "" {
+		err.Message = strings.TrimSpace(string(data))
+	}
+	return err
+}
+
+// encodeQuery encodes the fields of a query DTO by their form tags,
+// leaving out zero values so the server applies its defaults.
+func encodeQuery(query interface{}) url.Values {
+	values := url.Values{}
+	v := reflect.ValueOf(query)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("form"), ",")
+		field := v.Field(i)
+		if name == "" || name == "-" || field.IsZero() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			values.Set(name, field.String())
+		case reflect.Int, reflect.Int64:
+			values.Set(name, strconv.FormatInt(field.Int(), 10))
+		case reflect.Bool:
+			values.Set(name, strconv.FormatBool(field.Bool()))
+		default:
+			values.Set(name, fmt.Sprint(field.Interface()))
+		}
+	}
+	return values
+}
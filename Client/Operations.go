@@ -0,0 +1,312 @@
+// Code generated by ClientGen from the OpenAPI operations. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	models "go_server/Models"
+)
+
+// SignUp calls POST /api/v1/signup: register a new user.
+func (c *Client) SignUp(ctx context.Context, body models.SignUpRequest) (models.TokenSet, error) {
+	var data models.TokenSet
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/signup", body: body}, &data)
+	return data, err
+}
+
+// Login calls POST /api/v1/login: log in with email and password.
+func (c *Client) Login(ctx context.Context, body models.LoginRequest) (models.TokenSet, error) {
+	var data models.TokenSet
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/login", body: body}, &data)
+	return data, err
+}
+
+// Refresh calls POST /api/v1/refresh: rotate a refresh token.
+func (c *Client) Refresh(ctx context.Context, body models.RefreshRequest) (models.TokenSet, error) {
+	var data models.TokenSet
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/refresh", body: body}, &data)
+	return data, err
+}
+
+// ForgetPassword calls POST /api/v1/forget-password: email a password reset link.
+func (c *Client) ForgetPassword(ctx context.Context, body models.ForgetPasswordRequest) error {
+	return c.do(ctx, request{method: "POST", path: "/api/v1/forget-password", body: body}, nil)
+}
+
+// ResetPassword calls POST /api/v1/reset-password: set a new password with a reset link.
+func (c *Client) ResetPassword(ctx context.Context, body models.ResetPasswordRequest) error {
+	return c.do(ctx, request{method: "POST", path: "/api/v1/reset-password", body: body}, nil)
+}
+
+// GoogleLogin calls POST /api/v1/google-login: log in with a Google access token.
+func (c *Client) GoogleLogin(ctx context.Context, body models.GoogleLoginRequest) (models.TokenSet, error) {
+	var data models.TokenSet
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/google-login", body: body}, &data)
+	return data, err
+}
+
+// RequestMagicLink calls POST /api/v1/magic-link: email a sign-in link.
+func (c *Client) RequestMagicLink(ctx context.Context, body models.MagicLinkRequest) error {
+	return c.do(ctx, request{method: "POST", path: "/api/v1/magic-link", body: body}, nil)
+}
+
+// VerifyMagicLink calls POST /api/v1/magic-link/verify: sign in with an emailed link.
+func (c *Client) VerifyMagicLink(ctx context.Context, body models.VerifyMagicLinkRequest) (models.TokenSet, error) {
+	var data models.TokenSet
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/magic-link/verify", body: body}, &data)
+	return data, err
+}
+
+// Logout calls POST /api/v1/logout: end the session with an app.
+func (c *Client) Logout(ctx context.Context, body models.LogoutRequest) error {
+	return c.do(ctx, request{method: "POST", path: "/api/v1/logout", body: body}, nil)
+}
+
+// ChangePassword calls POST /api/v1/change-password: change the password.
+func (c *Client) ChangePassword(ctx context.Context, body models.ChangePasswordRequest) (models.TokenPair, error) {
+	var data models.TokenPair
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/change-password", body: body}, &data)
+	return data, err
+}
+
+// DeleteAccount calls DELETE /api/v1/account: delete the account.
+func (c *Client) DeleteAccount(ctx context.Context, body models.DeleteAccountRequest) error {
+	return c.do(ctx, request{method: "DELETE", path: "/api/v1/account", body: body}, nil)
+}
+
+// GetDeviceLogin calls GET /api/v1/device: get the device login of a user code.
+func (c *Client) GetDeviceLogin(ctx context.Context, query models.DeviceLoginQuery) (models.DeviceLogin, error) {
+	var data models.DeviceLogin
+	err := c.do(ctx, request{method: "GET", path: "/api/v1/device", query: query}, &data)
+	return data, err
+}
+
+// VerifyDevice calls POST /api/v1/device: approve or deny a device login.
+func (c *Client) VerifyDevice(ctx context.Context, body models.DeviceVerificationRequest) error {
+	return c.do(ctx, request{method: "POST", path: "/api/v1/device", body: body}, nil)
+}
+
+// OpenAPI calls GET /api/v1/openapi.json: get the OpenAPI document of the API.
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	return c.read(ctx, request{method: "GET", path: "/api/v1/openapi.json"})
+}
+
+// GetApp calls GET /api/v1/app/get/{id}: get the public details of an app.
+func (c *Client) GetApp(ctx context.Context, id int) (models.App, error) {
+	var data models.App
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/app/get/%d", id)}, &data)
+	return data, err
+}
+
+// Home calls GET /api/v1/app/: get the dashboard of the logged in user.
+func (c *Client) Home(ctx context.Context) (models.Dashboard, error) {
+	var data models.Dashboard
+	err := c.do(ctx, request{method: "GET", path: "/api/v1/app/"}, &data)
+	return data, err
+}
+
+// CreateApp calls POST /api/v1/app/create: create an app.
+func (c *Client) CreateApp(ctx context.Context, body models.CreateAppRequest) (models.AppSummary, error) {
+	var data models.AppSummary
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/app/create", body: body}, &data)
+	return data, err
+}
+
+// ListApps calls GET /api/v1/app/list: list the apps of the logged in user.
+func (c *Client) ListApps(ctx context.Context) ([]models.App, error) {
+	var data []models.App
+	err := c.do(ctx, request{method: "GET", path: "/api/v1/app/list"}, &data)
+	return data, err
+}
+
+// UpdateApp calls PATCH /api/v1/app/{id}: update an app.
+func (c *Client) UpdateApp(ctx context.Context, id int, body models.UpdateAppRequest) (models.AppSummary, error) {
+	var data models.AppSummary
+	err := c.do(ctx, request{method: "PATCH", path: fmt.Sprintf("/api/v1/app/%d", id), body: body}, &data)
+	return data, err
+}
+
+// DeleteApp calls DELETE /api/v1/app/{id}: delete an app.
+func (c *Client) DeleteApp(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: "DELETE", path: fmt.Sprintf("/api/v1/app/%d", id)}, nil)
+}
+
+// UpdateAppBranding calls PATCH /api/v1/app/{id}/branding: set the email branding of an app.
+func (c *Client) UpdateAppBranding(ctx context.Context, id int, body models.AppBrandingRequest) (models.AppBranding, error) {
+	var data models.AppBranding
+	err := c.do(ctx, request{method: "PATCH", path: fmt.Sprintf("/api/v1/app/%d/branding", id), body: body}, &data)
+	return data, err
+}
+
+// GetAppClient calls GET /api/v1/app/{id}/client: get the OAuth client of an app.
+func (c *Client) GetAppClient(ctx context.Context, id int) (models.AppClientInfo, error) {
+	var data models.AppClientInfo
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/app/%d/client", id)}, &data)
+	return data, err
+}
+
+// UpdateAppClient calls PUT /api/v1/app/{id}/client: set the scopes an app may request for itself.
+func (c *Client) UpdateAppClient(ctx context.Context, id int, body models.AppClientRequest) (models.AppClientInfo, error) {
+	var data models.AppClientInfo
+	err := c.do(ctx, request{method: "PUT", path: fmt.Sprintf("/api/v1/app/%d/client", id), body: body}, &data)
+	return data, err
+}
+
+// ListExchangePolicies calls GET /api/v1/app/{id}/exchange-policies: list the apps that may exchange tokens for tokens of an app.
+func (c *Client) ListExchangePolicies(ctx context.Context, id int) ([]models.ExchangePolicy, error) {
+	var data []models.ExchangePolicy
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/app/%d/exchange-policies", id)}, &data)
+	return data, err
+}
+
+// SetExchangePolicy calls PUT /api/v1/app/{id}/exchange-policies/{sourceId}: let another app exchange tokens for tokens of an app.
+func (c *Client) SetExchangePolicy(ctx context.Context, id, sourceId int, body models.ExchangePolicyRequest) (models.ExchangePolicy, error) {
+	var data models.ExchangePolicy
+	err := c.do(ctx, request{method: "PUT", path: fmt.Sprintf("/api/v1/app/%d/exchange-policies/%d", id, sourceId), body: body}, &data)
+	return data, err
+}
+
+// DeleteExchangePolicy calls DELETE /api/v1/app/{id}/exchange-policies/{sourceId}: stop another app from exchanging tokens for tokens of an app.
+func (c *Client) DeleteExchangePolicy(ctx context.Context, id, sourceId int) error {
+	return c.do(ctx, request{method: "DELETE", path: fmt.Sprintf("/api/v1/app/%d/exchange-policies/%d", id, sourceId)}, nil)
+}
+
+// RotateClientSecret calls POST /api/v1/app/{id}/client/secret: issue a new client secret for an app.
+func (c *Client) RotateClientSecret(ctx context.Context, id int) (models.ClientSecret, error) {
+	var data models.ClientSecret
+	err := c.do(ctx, request{method: "POST", path: fmt.Sprintf("/api/v1/app/%d/client/secret", id)}, &data)
+	return data, err
+}
+
+// CreateWebhook calls POST /api/v1/app/{id}/webhooks: register a webhook endpoint.
+func (c *Client) CreateWebhook(ctx context.Context, id int, body models.CreateWebhookRequest) (models.NewWebhook, error) {
+	var data models.NewWebhook
+	err := c.do(ctx, request{method: "POST", path: fmt.Sprintf("/api/v1/app/%d/webhooks", id), body: body}, &data)
+	return data, err
+}
+
+// ListWebhooks calls GET /api/v1/app/{id}/webhooks: list the webhook endpoints of an app.
+func (c *Client) ListWebhooks(ctx context.Context, id int) ([]models.WebhookEndpoint, error) {
+	var data []models.WebhookEndpoint
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/app/%d/webhooks", id)}, &data)
+	return data, err
+}
+
+// UpdateWebhook calls PATCH /api/v1/app/{id}/webhooks/{webhookId}: update a webhook endpoint.
+func (c *Client) UpdateWebhook(ctx context.Context, id, webhookId int, body models.UpdateWebhookRequest) (models.WebhookEndpoint, error) {
+	var data models.WebhookEndpoint
+	err := c.do(ctx, request{method: "PATCH", path: fmt.Sprintf("/api/v1/app/%d/webhooks/%d", id, webhookId), body: body}, &data)
+	return data, err
+}
+
+// DeleteWebhook calls DELETE /api/v1/app/{id}/webhooks/{webhookId}: delete a webhook endpoint.
+func (c *Client) DeleteWebhook(ctx context.Context, id, webhookId int) error {
+	return c.do(ctx, request{method: "DELETE", path: fmt.Sprintf("/api/v1/app/%d/webhooks/%d", id, webhookId)}, nil)
+}
+
+// RotateWebhookSecret calls POST /api/v1/app/{id}/webhooks/{webhookId}/rotate-secret: replace the signing secret of a webhook endpoint.
+func (c *Client) RotateWebhookSecret(ctx context.Context, id, webhookId int) (models.WebhookSecret, error) {
+	var data models.WebhookSecret
+	err := c.do(ctx, request{method: "POST", path: fmt.Sprintf("/api/v1/app/%d/webhooks/%d/rotate-secret", id, webhookId)}, &data)
+	return data, err
+}
+
+// ListWebhookDeliveries calls GET /api/v1/app/{id}/webhooks/{webhookId}/deliveries: list webhook deliveries.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id, webhookId int, query models.WebhookDeliveriesQuery) ([]models.WebhookDelivery, error) {
+	var data []models.WebhookDelivery
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/app/%d/webhooks/%d/deliveries", id, webhookId), query: query}, &data)
+	return data, err
+}
+
+// SendTestWebhook calls POST /api/v1/app/{id}/webhooks/{webhookId}/test: queue a test event for a webhook endpoint.
+func (c *Client) SendTestWebhook(ctx context.Context, id, webhookId int) (models.WebhookEvent, error) {
+	var data models.WebhookEvent
+	err := c.do(ctx, request{method: "POST", path: fmt.Sprintf("/api/v1/app/%d/webhooks/%d/test", id, webhookId)}, &data)
+	return data, err
+}
+
+// GetPublicKey calls GET /api/v1/key/public: get the PEM encoded key that signs access tokens.
+func (c *Client) GetPublicKey(ctx context.Context) ([]byte, error) {
+	return c.read(ctx, request{method: "GET", path: "/api/v1/key/public"})
+}
+
+// GetToken calls GET /api/v1/key/token/{id}: exchange a token_id for the tokens of an app login.
+func (c *Client) GetToken(ctx context.Context, id int) (models.Token, error) {
+	var data models.Token
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/key/token/%d", id)}, &data)
+	return data, err
+}
+
+// CreateOrganization calls POST /api/v1/org/create: create an organization.
+func (c *Client) CreateOrganization(ctx context.Context, body models.CreateOrganizationRequest) (models.OrganizationSummary, error) {
+	var data models.OrganizationSummary
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/org/create", body: body}, &data)
+	return data, err
+}
+
+// ListOrganizations calls GET /api/v1/org/list: list the organizations of the logged in user.
+func (c *Client) ListOrganizations(ctx context.Context) ([]models.Organization, error) {
+	var data []models.Organization
+	err := c.do(ctx, request{method: "GET", path: "/api/v1/org/list"}, &data)
+	return data, err
+}
+
+// CreateScimToken calls POST /api/v1/org/{id}/scim-token: issue a SCIM token.
+func (c *Client) CreateScimToken(ctx context.Context, id int, body models.CreateScimTokenRequest) (models.NewScimToken, error) {
+	var data models.NewScimToken
+	err := c.do(ctx, request{method: "POST", path: fmt.Sprintf("/api/v1/org/%d/scim-token", id), body: body}, &data)
+	return data, err
+}
+
+// ListScimTokens calls GET /api/v1/org/{id}/scim-token: list the SCIM tokens of an organization.
+func (c *Client) ListScimTokens(ctx context.Context, id int) ([]models.ScimToken, error) {
+	var data []models.ScimToken
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/org/%d/scim-token", id)}, &data)
+	return data, err
+}
+
+// RevokeScimToken calls DELETE /api/v1/org/{id}/scim-token/{tokenId}: revoke a SCIM token.
+func (c *Client) RevokeScimToken(ctx context.Context, id, tokenId int) error {
+	return c.do(ctx, request{method: "DELETE", path: fmt.Sprintf("/api/v1/org/%d/scim-token/%d", id, tokenId)}, nil)
+}
+
+// CreateInitialAccessToken calls POST /api/v1/org/{id}/initial-access-token: issue an initial access token for client registration.
+func (c *Client) CreateInitialAccessToken(ctx context.Context, id int, body models.CreateInitialAccessTokenRequest) (models.NewInitialAccessToken, error) {
+	var data models.NewInitialAccessToken
+	err := c.do(ctx, request{method: "POST", path: fmt.Sprintf("/api/v1/org/%d/initial-access-token", id), body: body}, &data)
+	return data, err
+}
+
+// ListInitialAccessTokens calls GET /api/v1/org/{id}/initial-access-token: list the initial access tokens of an organization.
+func (c *Client) ListInitialAccessTokens(ctx context.Context, id int) ([]models.InitialAccessToken, error) {
+	var data []models.InitialAccessToken
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/org/%d/initial-access-token", id)}, &data)
+	return data, err
+}
+
+// RevokeInitialAccessToken calls DELETE /api/v1/org/{id}/initial-access-token/{tokenId}: revoke an initial access token.
+func (c *Client) RevokeInitialAccessToken(ctx context.Context, id, tokenId int) error {
+	return c.do(ctx, request{method: "DELETE", path: fmt.Sprintf("/api/v1/org/%d/initial-access-token/%d", id, tokenId)}, nil)
+}
+
+// ImportUsers calls POST /api/v1/admin/users/import: import users from CSV or JSON.
+func (c *Client) ImportUsers(ctx context.Context, query models.ImportUsersQuery, contentType string, file io.Reader) (models.ImportReport, error) {
+	var data models.ImportReport
+	err := c.do(ctx, request{method: "POST", path: "/api/v1/admin/users/import", query: query, contentType: contentType, upload: file}, &data)
+	return data, err
+}
+
+// GetUserImport calls GET /api/v1/admin/users/import/{id}: get the progress of an import.
+func (c *Client) GetUserImport(ctx context.Context, id int) (models.UserImport, error) {
+	var data models.UserImport
+	err := c.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/v1/admin/users/import/%d", id)}, &data)
+	return data, err
+}
+
+// ExportUsers calls GET /api/v1/admin/users/export: export all users.
+func (c *Client) ExportUsers(ctx context.Context, query models.ExportUsersQuery) (io.ReadCloser, error) {
+	return c.stream(ctx, request{method: "GET", path: "/api/v1/admin/users/export", query: query})
+}
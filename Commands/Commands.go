@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	database "go_server/Database"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a maintenance task run as "go_server <name> [args]" instead of
+// starting the server.
+type command struct {
+	usage string
+	run   func(ctx context.Context, store database.Store, args []string, out io.Writer) error
+}
+
+var commandList = map[string]command{
+	"import-users": {
+		usage: "import-users [-format csv|json] [-dry-run] [-batch-size n] <file>  import users with existing password hashes",
+		run:   importUsersCommand,
+	},
+	"export-users": {
+		usage: "export-users [-format csv|json] <file>  export all users with password hashes and identities",
+		run:   exportUsersCommand,
+	},
+	"grant-admin": {
+		usage: "grant-admin [-revoke] <email>  give a user access to the admin API",
+		run:   grantAdminCommand,
+	},
+}
+
+// Run executes the command named by args[0].
+func Run(ctx context.Context, store database.Store, args []string) error {
+	cmd, ok := commandList[args[0]]
+	if !ok {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.run(ctx, store, args[1:], os.Stdout)
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "usage: go_server [command]")
+	names := make([]string, 0, len(commandList))
+	for name := range commandList {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(out, "  "+commandList[name].usage)
+	}
+}
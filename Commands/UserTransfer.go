@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	database "go_server/Database"
+	services "go_server/Services"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// transferFormat picks the format from the flag or the file extension.
+func transferFormat(format, path string) string {
+	if format != "" {
+		return format
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return services.TransferFormatJSON
+	}
+	return services.TransferFormatCSV
+}
+
+// importUsersCommand creates accounts from an export of another system.
+// Password hashes are stored as they are and rehashed on first login.
+// Running the same file again after an interruption resumes the import.
+func importUsersCommand(ctx context.Context, store database.Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	flags.SetOutput(out)
+	format := flags.String("format", "", "csv or json (default: from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "validate and report without writing")
+	batchSize := flags.Int("batch-size", 500, "users per transaction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: import-users [-format csv|json] [-dry-run] [-batch-size n] <file>")
+	}
+	path := flags.Arg(0)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	records, err := services.ParseUserImport(data, transferFormat(*format, path))
+	if err != nil {
+		return err
+	}
+
+	report, err := services.ImportUsers(ctx, store, records, services.ImportChecksum(data), services.ImportOptions{
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+		Source:    filepath.Base(path),
+	})
+	for _, issue := range report.Issues {
+		fmt.Fprintf(out, "line %d: %s: %s\n", issue.Line, issue.Email, issue.Reason)
+	}
+	if report.ResumedAt > 0 {
+		fmt.Fprintf(out, "resumed import %d at record %d\n", report.JobId, report.ResumedAt+1)
+	}
+	prefix := ""
+	if *dryRun {
+		prefix = "dry run: would have "
+	}
+	fmt.Fprintf(out, "%simported %d, skipped %d existing, failed %d of %d\n",
+		prefix, report.Imported, report.Skipped, report.Failed, report.Total)
+	if err != nil {
+		return fmt.Errorf("import interrupted, run the same file again to resume: %w", err)
+	}
+	return nil
+}
+
+func exportUsersCommand(ctx context.Context, store database.Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export-users", flag.ContinueOnError)
+	flags.SetOutput(out)
+	format := flags.String("format", "", "csv or json (default: from the file extension)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: export-users [-format csv|json] <file>")
+	}
+	path := flags.Arg(0)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	count, err := services.ExportUsers(ctx, store, file, transferFormat(*format, path))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "exported %d users to %s\n", count, path)
+	return nil
+}
+
+func grantAdminCommand(ctx context.Context, store database.Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("grant-admin", flag.ContinueOnError)
+	flags.SetOutput(out)
+	revoke := flags.Bool("revoke", false, "remove admin access instead")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: grant-admin [-revoke] <email>")
+	}
+	if err := store.SetUserAdmin(ctx, flags.Arg(0), !*revoke); err != nil {
+		return fmt.Errorf("updating %s: %w", flags.Arg(0), err)
+	}
+	fmt.Fprintf(out, "updated %s\n", flags.Arg(0))
+	return nil
+}
@@ -0,0 +1,676 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration. It is built once at startup
+// by Load and handed to the components that need it.
+type Config struct {
+	Server        ServerConfig   `yaml:"server" toml:"server"`
+	Database      DatabaseConfig `yaml:"database" toml:"database"`
+	PublicBaseURL string         `yaml:"public_base_url" toml:"public_base_url"`
+	Auth          AuthConfig     `yaml:"auth" toml:"auth"`
+	CORS          CORSConfig     `yaml:"cors" toml:"cors"`
+	Features      FeatureConfig  `yaml:"features" toml:"features"`
+	Email         EmailConfig    `yaml:"email" toml:"email"`
+	Password      PasswordConfig `yaml:"password" toml:"password"`
+	Webhooks      WebhookConfig  `yaml:"webhooks" toml:"webhooks"`
+	Log           LogConfig      `yaml:"log" toml:"log"`
+	Tracing       TracingConfig  `yaml:"tracing" toml:"tracing"`
+}
+
+// ServerConfig controls the HTTP listeners. ShutdownTimeout is how long
+// in-flight requests may run after SIGTERM or SIGINT.
+type ServerConfig struct {
+	Port              string      `yaml:"port" toml:"port"`
+	ReadTimeout       Duration    `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout Duration    `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      Duration    `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       Duration    `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout   Duration    `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	MaxHeaderBytes    int         `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	TLS               TLSConfig   `yaml:"tls" toml:"tls"`
+	Admin             AdminConfig `yaml:"admin" toml:"admin"`
+}
+
+// TLSConfig enables HTTPS when both files are set. The files are read
+// again on SIGHUP, so renewed certificates need no restart.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file"`
+}
+
+// AdminConfig moves the admin API to its own port when Port is set. With
+// ClientCAFile, that listener only accepts clients presenting a
+// certificate signed by one of the CAs in the file.
+type AdminConfig struct {
+	Port         string `yaml:"port" toml:"port"`
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file"`
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" toml:"url"`
+}
+
+// AuthConfig controls how tokens are signed and how long credentials live.
+// Without a private key the built-in development key is used.
+type AuthConfig struct {
+	PrivateKey       string   `yaml:"private_key" toml:"private_key"`
+	PrivateKeyFile   string   `yaml:"private_key_file" toml:"private_key_file"`
+	Issuer           string   `yaml:"issuer" toml:"issuer"`
+	AccessTokenTTL   Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL  Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+	RecentAuthMaxAge Duration `yaml:"recent_auth_max_age" toml:"recent_auth_max_age"`
+	PasswordResetTTL Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl"`
+	MagicLinkTTL     Duration `yaml:"magic_link_ttl" toml:"magic_link_ttl"`
+	// ClientTokenTTL is the lifetime of tokens issued to apps by the
+	// client credentials grant.
+	ClientTokenTTL Duration `yaml:"client_token_ttl" toml:"client_token_ttl"`
+	// ClientScopes are the scopes apps may be granted for themselves.
+	// Owners pick the scopes of their apps among them; none are granted
+	// while the list is empty.
+	ClientScopes []string `yaml:"client_scopes" toml:"client_scopes"`
+	// DeviceCodeTTL is how long a device login waits for the user, and
+	// DevicePollInterval how often the device may ask in the meantime.
+	DeviceCodeTTL      Duration `yaml:"device_code_ttl" toml:"device_code_ttl"`
+	DevicePollInterval Duration `yaml:"device_poll_interval" toml:"device_poll_interval"`
+}
+
+// CORSConfig lists the dashboard origins trusted on every API route. The
+// origins of registered apps are added to the login routes automatically
+// and re-read from the database every AppOriginCacheTTL. MaxAge is how long
+// browsers may cache a preflight response.
+type CORSConfig struct {
+	AllowedOrigins    []string `yaml:"allowed_origins" toml:"allowed_origins"`
+	MaxAge            Duration `yaml:"max_age" toml:"max_age"`
+	AppOriginCacheTTL Duration `yaml:"app_origin_cache_ttl" toml:"app_origin_cache_ttl"`
+}
+
+// FeatureConfig switches optional login methods on and off.
+type FeatureConfig struct {
+	SignUp      bool `yaml:"signup" toml:"signup"`
+	GoogleLogin bool `yaml:"google_login" toml:"google_login"`
+	MagicLink   bool `yaml:"magic_link" toml:"magic_link"`
+}
+
+// EmailConfig selects the mail transport: "smtp", "file" or "log". When it
+// is empty, SMTP is used if a host is set and the log sink otherwise.
+type EmailConfig struct {
+	Transport    string `yaml:"transport" toml:"transport"`
+	From         string `yaml:"from" toml:"from"`
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host"`
+	SMTPPort     string `yaml:"smtp_port" toml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+	OutboxDir    string `yaml:"outbox_dir" toml:"outbox_dir"`
+	AppName      string `yaml:"app_name" toml:"app_name"`
+}
+
+// PasswordConfig holds the password policy, the breached password list and
+// the hashing parameters. Argon2Memory is in KiB.
+type PasswordConfig struct {
+	MinLength         int    `yaml:"min_length" toml:"min_length"`
+	MaxBytes          int    `yaml:"max_bytes" toml:"max_bytes"`
+	RequireUpper      bool   `yaml:"require_upper" toml:"require_upper"`
+	RequireLower      bool   `yaml:"require_lower" toml:"require_lower"`
+	RequireDigit      bool   `yaml:"require_digit" toml:"require_digit"`
+	RequireSymbol     bool   `yaml:"require_symbol" toml:"require_symbol"`
+	DisallowPersonal  bool   `yaml:"disallow_personal" toml:"disallow_personal"`
+	BreachedPath      string `yaml:"breached_path" toml:"breached_path"`
+	BreachedMinCount  int    `yaml:"breached_min_count" toml:"breached_min_count"`
+	HashAlgorithm     string `yaml:"hash_algorithm" toml:"hash_algorithm"`
+	BcryptCost        int    `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+	Argon2Memory      int    `yaml:"argon2_memory" toml:"argon2_memory"`
+	Argon2Iterations  int    `yaml:"argon2_iterations" toml:"argon2_iterations"`
+	Argon2Parallelism int    `yaml:"argon2_parallelism" toml:"argon2_parallelism"`
+}
+
+// WebhookConfig controls webhook delivery. AllowInsecure accepts http
+// endpoints and delivers to loopback and private addresses; it is meant
+// for local development only.
+type WebhookConfig struct {
+	AllowInsecure bool `yaml:"allow_insecure" toml:"allow_insecure"`
+}
+
+// LogConfig controls the structured log. Components maps a component such
+// as "http" or "webhooks" to its own level. Values under keys that look
+// secret (tokens, passwords, cookies, RedactKeys) are always redacted;
+// RedactEmails masks email addresses as well.
+type LogConfig struct {
+	Level        string            `yaml:"level" toml:"level"`
+	Format       string            `yaml:"format" toml:"format"`
+	Components   map[string]string `yaml:"components" toml:"components"`
+	RedactEmails bool              `yaml:"redact_emails" toml:"redact_emails"`
+	RedactKeys   []string          `yaml:"redact_keys" toml:"redact_keys"`
+}
+
+// TracingConfig controls OpenTelemetry tracing. Exporter is "none", "otlp"
+// to send spans over OTLP/HTTP to Endpoint, or "stdout" to print them for
+// local debugging. SampleRatio is the share of new traces that is recorded;
+// requests arriving with a sampled W3C trace context are always recorded.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// Duration is a time.Duration written as "15m" or "120h" in config files.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:              "8080",
+			ReadTimeout:       Duration{15 * time.Second},
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			WriteTimeout:      Duration{30 * time.Second},
+			IdleTimeout:       Duration{2 * time.Minute},
+			ShutdownTimeout:   Duration{20 * time.Second},
+			MaxHeaderBytes:    1 << 20,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:     Duration{15 * time.Minute},
+			RefreshTokenTTL:    Duration{5 * 24 * time.Hour},
+			RecentAuthMaxAge:   Duration{10 * time.Minute},
+			PasswordResetTTL:   Duration{time.Hour},
+			MagicLinkTTL:       Duration{15 * time.Minute},
+			ClientTokenTTL:     Duration{time.Hour},
+			DeviceCodeTTL:      Duration{10 * time.Minute},
+			DevicePollInterval: Duration{5 * time.Second},
+		},
+		CORS: CORSConfig{
+			MaxAge:            Duration{10 * time.Minute},
+			AppOriginCacheTTL: Duration{time.Minute},
+		},
+		Features: FeatureConfig{SignUp: true, GoogleLogin: true},
+		Email:    EmailConfig{OutboxDir: "mail", AppName: "GoAuth SSO"},
+		Password: PasswordConfig{
+			MinLength:         8,
+			MaxBytes:          72,
+			DisallowPersonal:  true,
+			BreachedMinCount:  1,
+			HashAlgorithm:     "argon2id",
+			BcryptCost:        12,
+			Argon2Memory:      19456,
+			Argon2Iterations:  2,
+			Argon2Parallelism: 1,
+		},
+		Log:     LogConfig{Level: "info", Format: "json", RedactEmails: true},
+		Tracing: TracingConfig{Exporter: "none", ServiceName: "goauth-sso", SampleRatio: 1},
+	}
+}
+
+// Load builds the configuration from, in increasing precedence, the
+// defaults, the config file (-config or CONFIG_FILE), the environment
+// including a .env file, and the command line flags. It returns the
+// arguments left after the flags, i.e. the command to run if any.
+func Load(args []string) (*Config, []string, error) {
+	loadDotEnv()
+
+	flags := newFlagSet()
+	if err := flags.set.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	path := *flags.config
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, nil, err
+	}
+	flags.apply(cfg)
+
+	if err := cfg.resolve(); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.set.Args(), nil
+}
+
+// loadFile reads a YAML (.yaml, .yml) or TOML (.toml) file over the
+// current values. Unknown keys are rejected so typos do not go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(c); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// loadEnv overrides the values whose environment variable is set.
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	env := envReader{lookup: lookup}
+
+	env.str("PORT", &c.Server.Port)
+	env.duration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout)
+	env.duration("SERVER_READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout)
+	env.duration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	env.duration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	env.duration("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	env.integer("SERVER_MAX_HEADER_BYTES", &c.Server.MaxHeaderBytes)
+	env.str("TLS_CERT_FILE", &c.Server.TLS.CertFile)
+	env.str("TLS_KEY_FILE", &c.Server.TLS.KeyFile)
+	env.str("ADMIN_PORT", &c.Server.Admin.Port)
+	env.str("ADMIN_CLIENT_CA_FILE", &c.Server.Admin.ClientCAFile)
+	env.str("DATABASE_URL", &c.Database.URL)
+	env.str("PUBLIC_BASE_URL", &c.PublicBaseURL)
+
+	env.str("RSA_PRIVATE_KEY", &c.Auth.PrivateKey)
+	env.str("RSA_PRIVATE_KEY_FILE", &c.Auth.PrivateKeyFile)
+	env.str("JWT_ISSUER", &c.Auth.Issuer)
+	env.duration("ACCESS_TOKEN_TTL", &c.Auth.AccessTokenTTL)
+	env.duration("REFRESH_TOKEN_TTL", &c.Auth.RefreshTokenTTL)
+	env.duration("RECENT_AUTH_MAX_AGE", &c.Auth.RecentAuthMaxAge)
+	env.duration("PASSWORD_RESET_TTL", &c.Auth.PasswordResetTTL)
+	env.duration("MAGIC_LINK_TTL", &c.Auth.MagicLinkTTL)
+	env.duration("CLIENT_TOKEN_TTL", &c.Auth.ClientTokenTTL)
+	env.list("CLIENT_SCOPES", &c.Auth.ClientScopes)
+	env.duration("DEVICE_CODE_TTL", &c.Auth.DeviceCodeTTL)
+	env.duration("DEVICE_POLL_INTERVAL", &c.Auth.DevicePollInterval)
+
+	env.list("CORS_ALLOWED_ORIGINS", &c.CORS.AllowedOrigins)
+	env.duration("CORS_MAX_AGE", &c.CORS.MaxAge)
+	env.duration("CORS_APP_ORIGIN_CACHE_TTL", &c.CORS.AppOriginCacheTTL)
+
+	env.boolean("SIGNUP_ENABLED", &c.Features.SignUp)
+	env.boolean("GOOGLE_LOGIN_ENABLED", &c.Features.GoogleLogin)
+	env.boolean("MAGIC_LINK_ENABLED", &c.Features.MagicLink)
+
+	env.str("EMAIL_TRANSPORT", &c.Email.Transport)
+	env.str("EMAIL_FROM", &c.Email.From)
+	env.str("SMTP_HOST", &c.Email.SMTPHost)
+	env.str("SMTP_PORT", &c.Email.SMTPPort)
+	env.str("SMTP_USERNAME", &c.Email.SMTPUsername)
+	env.str("EMAIL_PASSWORD", &c.Email.SMTPPassword)
+	env.str("EMAIL_OUTBOX_DIR", &c.Email.OutboxDir)
+	env.str("APP_NAME", &c.Email.AppName)
+
+	env.integer("PASSWORD_MIN_LENGTH", &c.Password.MinLength)
+	env.integer("PASSWORD_MAX_BYTES", &c.Password.MaxBytes)
+	env.boolean("PASSWORD_REQUIRE_UPPER", &c.Password.RequireUpper)
+	env.boolean("PASSWORD_REQUIRE_LOWER", &c.Password.RequireLower)
+	env.boolean("PASSWORD_REQUIRE_DIGIT", &c.Password.RequireDigit)
+	env.boolean("PASSWORD_REQUIRE_SYMBOL", &c.Password.RequireSymbol)
+	env.boolean("PASSWORD_DISALLOW_PERSONAL", &c.Password.DisallowPersonal)
+	env.str("BREACHED_PASSWORDS_PATH", &c.Password.BreachedPath)
+	env.integer("BREACHED_PASSWORDS_MIN_COUNT", &c.Password.BreachedMinCount)
+	env.str("PASSWORD_HASH_ALGORITHM", &c.Password.HashAlgorithm)
+	env.integer("PASSWORD_BCRYPT_COST", &c.Password.BcryptCost)
+	env.integer("PASSWORD_ARGON2_MEMORY", &c.Password.Argon2Memory)
+	env.integer("PASSWORD_ARGON2_ITERATIONS", &c.Password.Argon2Iterations)
+	env.integer("PASSWORD_ARGON2_PARALLELISM", &c.Password.Argon2Parallelism)
+
+	env.boolean("WEBHOOK_ALLOW_INSECURE", &c.Webhooks.AllowInsecure)
+
+	env.str("LOG_LEVEL", &c.Log.Level)
+	env.str("LOG_FORMAT", &c.Log.Format)
+	env.levels("LOG_COMPONENT_LEVELS", &c.Log.Components)
+	env.boolean("LOG_REDACT_EMAILS", &c.Log.RedactEmails)
+	env.list("LOG_REDACT_KEYS", &c.Log.RedactKeys)
+
+	env.str("TRACING_EXPORTER", &c.Tracing.Exporter)
+	env.str("TRACING_OTLP_ENDPOINT", &c.Tracing.Endpoint)
+	env.str("TRACING_SERVICE_NAME", &c.Tracing.ServiceName)
+	env.ratio("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+
+	if len(env.problems) > 0 {
+		return fmt.Errorf("invalid environment:\n  - %s", strings.Join(env.problems, "\n  - "))
+	}
+	return nil
+}
+
+// resolve fills in the values that depend on others.
+func (c *Config) resolve() error {
+	if c.PublicBaseURL == "" {
+		c.PublicBaseURL = "http://localhost:" + c.Server.Port
+	}
+	c.PublicBaseURL = strings.TrimRight(c.PublicBaseURL, "/")
+
+	if c.Auth.PrivateKeyFile != "" {
+		key, err := os.ReadFile(c.Auth.PrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("reading auth.private_key_file: %w", err)
+		}
+		c.Auth.PrivateKey = string(key)
+	}
+
+	if c.Email.Transport == "" {
+		c.Email.Transport = "log"
+		if c.Email.SMTPHost != "" {
+			c.Email.Transport = "smtp"
+		}
+	}
+	if c.Email.SMTPPort == "" {
+		c.Email.SMTPPort = "587"
+	}
+	if c.Email.SMTPUsername == "" {
+		c.Email.SMTPUsername = c.Email.From
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(isPort(c.Server.Port), "server.port must be a port number, got %q", c.Server.Port)
+	check(c.Server.ReadTimeout.Duration >= 0 && c.Server.WriteTimeout.Duration >= 0 && c.Server.IdleTimeout.Duration >= 0,
+		"server timeouts must not be negative")
+	check(c.Server.ReadHeaderTimeout.Duration > 0, "server.read_header_timeout must be positive")
+	check(c.Server.ShutdownTimeout.Duration > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.MaxHeaderBytes >= 4096, "server.max_header_bytes must be at least 4096")
+	check((c.Server.TLS.CertFile == "") == (c.Server.TLS.KeyFile == ""), "server.tls.cert_file and server.tls.key_file must be set together")
+	if c.Server.Admin.Port != "" {
+		check(isPort(c.Server.Admin.Port) && c.Server.Admin.Port != c.Server.Port, "server.admin.port must be a port number other than server.port, got %q", c.Server.Admin.Port)
+	}
+	if c.Server.Admin.ClientCAFile != "" {
+		check(c.Server.Admin.Port != "", "server.admin.client_ca_file requires server.admin.port")
+		check(c.Server.TLS.CertFile != "", "server.admin.client_ca_file requires server.tls")
+	}
+	check(c.Database.URL != "", "database.url (DATABASE_URL) is required")
+	check(isHTTPURL(c.PublicBaseURL), "public_base_url must be an absolute http(s) URL, got %q", c.PublicBaseURL)
+
+	if c.Auth.PrivateKey != "" {
+		_, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(c.Auth.PrivateKey))
+		check(err == nil, "auth.private_key is not a PEM encoded RSA private key: %v", err)
+	}
+	check(c.Auth.AccessTokenTTL.Duration > 0, "auth.access_token_ttl must be positive")
+	check(c.Auth.RefreshTokenTTL.Duration > c.Auth.AccessTokenTTL.Duration, "auth.refresh_token_ttl must be longer than auth.access_token_ttl")
+	check(c.Auth.RecentAuthMaxAge.Duration > 0, "auth.recent_auth_max_age must be positive")
+	check(c.Auth.PasswordResetTTL.Duration > 0, "auth.password_reset_ttl must be positive")
+	check(c.Auth.MagicLinkTTL.Duration > 0, "auth.magic_link_ttl must be positive")
+	check(c.Auth.ClientTokenTTL.Duration > 0, "auth.client_token_ttl must be positive")
+	for _, scope := range c.Auth.ClientScopes {
+		check(isScope(scope), "auth.client_scopes: %q is not a scope", scope)
+	}
+	check(c.Auth.DeviceCodeTTL.Duration > 0, "auth.device_code_ttl must be positive")
+	check(c.Auth.DevicePollInterval.Duration >= time.Second && c.Auth.DevicePollInterval.Duration%time.Second == 0, "auth.device_poll_interval must be a whole number of seconds")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || isOrigin(origin), "cors.allowed_origins: %q is not an origin such as https://app.example.com", origin)
+	}
+	check(c.CORS.MaxAge.Duration >= 0, "cors.max_age must not be negative")
+	check(c.CORS.AppOriginCacheTTL.Duration > 0, "cors.app_origin_cache_ttl must be positive")
+
+	switch c.Email.Transport {
+	case "smtp":
+		check(c.Email.SMTPHost != "", "email.smtp_host is required for the smtp transport")
+		check(c.Email.From != "", "email.from is required for the smtp transport")
+	case "file":
+		check(c.Email.OutboxDir != "", "email.outbox_dir is required for the file transport")
+	case "log":
+	default:
+		check(false, "email.transport must be smtp, file or log, got %q", c.Email.Transport)
+	}
+
+	p := c.Password
+	check(p.MinLength >= 1, "password.min_length must be at least 1")
+	check(p.MaxBytes >= p.MinLength && p.MaxBytes <= 72, "password.max_bytes must be between password.min_length and 72")
+	if p.BreachedPath != "" {
+		// a missing list would refuse every new password
+		_, err := os.Stat(p.BreachedPath)
+		check(err == nil, "password.breached_path cannot be read: %v", err)
+	}
+	check(p.BreachedMinCount >= 1, "password.breached_min_count must be at least 1")
+	check(p.HashAlgorithm == "argon2id" || p.HashAlgorithm == "bcrypt", "password.hash_algorithm must be argon2id or bcrypt, got %q", p.HashAlgorithm)
+	// the upper bounds match the limits stored hashes are verified against
+	check(p.BcryptCost >= 4 && p.BcryptCost <= 16, "password.bcrypt_cost must be between 4 and 16")
+	check(p.Argon2Memory >= 8*p.Argon2Parallelism && p.Argon2Memory <= 1<<20, "password.argon2_memory must be at least 8 KiB per thread and at most 1048576 KiB")
+	check(p.Argon2Iterations >= 1 && p.Argon2Iterations <= 16, "password.argon2_iterations must be between 1 and 16")
+	check(p.Argon2Parallelism >= 1 && p.Argon2Parallelism <= 16, "password.argon2_parallelism must be between 1 and 16")
+
+	check(isLevel(c.Log.Level), "log.level must be debug, info, warn or error, got %q", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format must be json or text, got %q", c.Log.Format)
+	for component, level := range c.Log.Components {
+		check(isLevel(level), "log.components.%s must be debug, info, warn or error, got %q", component, level)
+	}
+
+	t := c.Tracing
+	check(t.Exporter == "none" || t.Exporter == "otlp" || t.Exporter == "stdout", "tracing.exporter must be none, otlp or stdout, got %q", t.Exporter)
+	check(t.Endpoint == "" || isHTTPURL(t.Endpoint), "tracing.endpoint must be an http(s) URL such as http://collector:4318, got %q", t.Endpoint)
+	check(t.ServiceName != "", "tracing.service_name must not be empty")
+	check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+func isHTTPURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func isLevel(value string) bool {
+	var level slog.Level
+	return level.UnmarshalText([]byte(value)) == nil
+}
+
+// isScope reports whether value is a single OAuth scope (RFC 6749 section
+// 3.3): printable ASCII without spaces, quotes or backslashes.
+func isScope(value string) bool {
+	for _, b := range []byte(value) {
+		if b < 0x21 || b > 0x7E || b == '"' || b == '\\' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+func isPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port < 65536
+}
+
+// isOrigin accepts scheme://host[:port] without a path, as sent in the
+// Origin header.
+func isOrigin(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && isHTTPURL(raw) && parsed.Path == "" && parsed.RawQuery == "" && parsed.User == nil
+}
+
+// envReader parses environment variables into config fields and collects
+// the ones it cannot parse.
+type envReader struct {
+	lookup   func(string) (string, bool)
+	problems []string
+}
+
+func (e *envReader) get(name string) (string, bool) {
+	value, ok := e.lookup(name)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+func (e *envReader) str(name string, target *string) {
+	if value, ok := e.get(name); ok {
+		*target = value
+	}
+}
+
+func (e *envReader) integer(name string, target *int) {
+	if value, ok := e.get(name); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			e.problems = append(e.problems, fmt.Sprintf("%s must be a number, got %q", name, value))
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (e *envReader) boolean(name string, target *bool) {
+	if value, ok := e.get(name); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			e.problems = append(e.problems, fmt.Sprintf("%s must be true or false, got %q", name, value))
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (e *envReader) ratio(name string, target *float64) {
+	if value, ok := e.get(name); ok {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.problems = append(e.problems, fmt.Sprintf("%s must be a number such as 0.25, got %q", name, value))
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (e *envReader) duration(name string, target *Duration) {
+	if value, ok := e.get(name); ok {
+		if err := target.UnmarshalText([]byte(value)); err != nil {
+			e.problems = append(e.problems, fmt.Sprintf("%s must be a duration such as 15m, got %q", name, value))
+		}
+	}
+}
+
+// levels reads "component=level" pairs separated by commas.
+func (e *envReader) levels(name string, target *map[string]string) {
+	value, ok := e.get(name)
+	if !ok {
+		return
+	}
+	levels := map[string]string{}
+	for _, pair := range splitList(value) {
+		component, level, found := strings.Cut(pair, "=")
+		if !found {
+			e.problems = append(e.problems, fmt.Sprintf("%s must be a list of component=level, got %q", name, value))
+			return
+		}
+		levels[strings.TrimSpace(component)] = strings.TrimSpace(level)
+	}
+	*target = levels
+}
+
+func (e *envReader) list(name string, target *[]string) {
+	if value, ok := e.get(name); ok {
+		*target = splitList(value)
+	}
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// flagSet holds the command line flags. Only flags that were passed
+// override the other sources.
+type flagSet struct {
+	set           *flag.FlagSet
+	config        *string
+	port          *string
+	databaseURL   *string
+	publicBaseURL *string
+	issuer        *string
+	corsOrigins   *string
+	magicLink     *bool
+}
+
+func newFlagSet() *flagSet {
+	set := flag.NewFlagSet("go_server", flag.ContinueOnError)
+	return &flagSet{
+		set:           set,
+		config:        set.String("config", "", "path to a YAML or TOML config file"),
+		port:          set.String("port", "", "port to listen on"),
+		databaseURL:   set.String("database-url", "", "PostgreSQL connection string"),
+		publicBaseURL: set.String("public-base-url", "", "externally reachable URL used in emailed links"),
+		issuer:        set.String("issuer", "", "iss claim of issued tokens"),
+		corsOrigins:   set.String("cors-allowed-origins", "", "comma separated origins allowed by CORS"),
+		magicLink:     set.Bool("magic-link", false, "enable magic link login"),
+	}
+}
+
+func (f *flagSet) apply(c *Config) {
+	f.set.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "port":
+			c.Server.Port = *f.port
+		case "database-url":
+			c.Database.URL = *f.databaseURL
+		case "public-base-url":
+			c.PublicBaseURL = *f.publicBaseURL
+		case "issuer":
+			c.Auth.Issuer = *f.issuer
+		case "cors-allowed-origins":
+			c.CORS.AllowedOrigins = splitList(*f.corsOrigins)
+		case "magic-link":
+			c.Features.MagicLink = *f.magicLink
+		}
+	})
+}
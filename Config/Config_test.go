@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  port: "9000"
+database:
+  url: postgres://file
+public_base_url: https://file.example.com/
+auth:
+  access_token_ttl: 5m
+  issuer: https://file.example.com
+features:
+  magic_link: true
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("PORT", "9100")
+	t.Setenv("ACCESS_TOKEN_TTL", "")
+	t.Setenv("JWT_ISSUER", "https://env.example.com")
+
+	cfg, args, err := Load([]string{"-port", "9200", "users", "export"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(args, " ") != "users export" {
+		t.Fatalf("args = %q", args)
+	}
+	// flags beat the environment, which beats the file, which beats the defaults
+	if cfg.Server.Port != "9200" {
+		t.Fatalf("port = %q", cfg.Server.Port)
+	}
+	if cfg.Auth.Issuer != "https://env.example.com" {
+		t.Fatalf("issuer = %q", cfg.Auth.Issuer)
+	}
+	if cfg.Database.URL != "postgres://file" || cfg.PublicBaseURL != "https://file.example.com" || !cfg.Features.MagicLink {
+		t.Fatalf("file values = %+v", cfg)
+	}
+	if cfg.Auth.AccessTokenTTL.Duration != 5*time.Minute || cfg.Auth.RefreshTokenTTL.Duration != 5*24*time.Hour {
+		t.Fatalf("ttls = %v %v", cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[database]
+url = "postgres://toml"
+
+[auth]
+refresh_token_ttl = "48h"
+
+[cors]
+allowed_origins = ["https://dashboard.example.com"]
+`)
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("PORT", "")
+	cfg, _, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.URL != "postgres://toml" || cfg.Auth.RefreshTokenTTL.Duration != 48*time.Hour {
+		t.Fatalf("config = %+v", cfg)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "https://dashboard.example.com" {
+		t.Fatalf("origins = %q", cfg.CORS.AllowedOrigins)
+	}
+	if cfg.PublicBaseURL != "http://localhost:8080" || cfg.Email.Transport != "log" {
+		t.Fatalf("resolved values = %q %q", cfg.PublicBaseURL, cfg.Email.Transport)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeFile(t, "config.yaml", "auth:\n  acess_token_ttl: 5m\n")
+	if _, _, err := Load([]string{"-config", path}); err == nil || !strings.Contains(err.Error(), "acess_token_ttl") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLoadReportsBadEnvironment(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("REFRESH_TOKEN_TTL", "five days")
+	t.Setenv("MAGIC_LINK_ENABLED", "maybe")
+	t.Setenv("TRACING_SAMPLE_RATIO", "half")
+	_, _, err := Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "REFRESH_TOKEN_TTL") || !strings.Contains(err.Error(), "MAGIC_LINK_ENABLED") || !strings.Contains(err.Error(), "TRACING_SAMPLE_RATIO") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://db"
+	if err := cfg.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("defaults are invalid: %v", err)
+	}
+
+	cfg.Server.Port = "http"
+	cfg.Auth.RefreshTokenTTL = Duration{time.Minute}
+	cfg.CORS.AllowedOrigins = []string{"https://app.example.com/path"}
+	cfg.Password.HashAlgorithm = "md5"
+	cfg.Auth.PrivateKey = "not a key"
+	cfg.CORS.AppOriginCacheTTL = Duration{}
+	cfg.Server.TLS.CertFile = "cert.pem"
+	cfg.Server.Admin.ClientCAFile = "ca.pem"
+	cfg.Tracing.Exporter = "jaeger"
+	cfg.Tracing.SampleRatio = 2
+	cfg.Password.BreachedPath = filepath.Join(t.TempDir(), "missing.txt")
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid configuration accepted")
+	}
+	for _, key := range []string{"server.port", "auth.refresh_token_ttl", "cors.allowed_origins", "password.hash_algorithm", "auth.private_key", "cors.app_origin_cache_ttl", "server.tls.cert_file", "server.admin.client_ca_file", "tracing.exporter", "tracing.sample_ratio", "password.breached_path"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error does not mention %s: %v", key, err)
+		}
+	}
+}
+
+// The example file documents every key, so it must stay loadable.
+func TestExampleConfig(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://example")
+	cfg, _, err := Load([]string{"-config", "../config.example.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.RefreshTokenTTL != Default().Auth.RefreshTokenTTL || cfg.Password.Argon2Memory != 19456 {
+		t.Fatalf("example config = %+v", cfg)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/joho/godotenv"
 )
 
-func init() {
+// loadDotEnv copies a .env file in the working directory into the
+// environment. Variables that are already set win.
+func loadDotEnv() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Error loading .env file")
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"database/sql"
+	apierror "go_server/ApiError"
+	models "go_server/Models"
+	services "go_server/Services"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportSize = 64 << 20
+	// maxReportedIssues caps the issues returned by the API; the counts
+	// stay exact.
+	maxReportedIssues = 1000
+)
+
+// readImportFile accepts the import as a multipart "file" field or as the
+// raw request body, and works out its format unless one was given.
+func readImportFile(c *gin.Context, format string) ([]byte, string, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", "", err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, "", "", err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if format == "" && strings.EqualFold(filepath.Ext(header.Filename), ".json") {
+			format = services.TransferFormatJSON
+		}
+		if format == "" {
+			format = services.TransferFormatCSV
+		}
+		return data, format, header.Filename, err
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if format == "" {
+		format = services.TransferFormatCSV
+		if c.ContentType() == "application/json" {
+			format = services.TransferFormatJSON
+		}
+	}
+	return data, format, "upload", err
+}
+
+// ImportUsers imports users from CSV or JSON. With dry_run=true nothing is
+// written. Uploading the same file after a failure resumes the import.
+func (h *Handler) ImportUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	var query models.ImportUsersQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	data, format, source, err := readImportFile(c, query.Format)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "A CSV or JSON file is required"))
+		return
+	}
+	records, err := services.ParseUserImport(data, format)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+
+	report, err := services.ImportUsers(ctx, h.Store, records, services.ImportChecksum(data), services.ImportOptions{
+		DryRun:    query.DryRun,
+		BatchSize: query.BatchSize,
+		Source:    source,
+	})
+	issueCount := len(report.Issues)
+	if issueCount > maxReportedIssues {
+		report.Issues = report.Issues[:maxReportedIssues]
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Import interrupted, upload the same file again to resume", err).With("data", report))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":      "success",
+		"data":        report,
+		"issue_count": issueCount,
+	})
+}
+
+func (h *Handler) GetUserImport(c *gin.Context) {
+	ctx := c.Request.Context()
+	importId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid import ID"))
+		return
+	}
+	job, err := h.Store.GetUserImport(ctx, importId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("Import not found"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the import", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// ExportUsers streams every user with password hashes and identities in
+// the import format.
+func (h *Handler) ExportUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	var query models.ExportUsersQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	contentType := "text/csv; charset=utf-8"
+	if query.Format == services.TransferFormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+
+	name := "users-" + time.Now().UTC().Format("20060102-150405") + "." + query.Format
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(200)
+	// the status is already sent, so a failure can only truncate the body
+	if _, err := services.ExportUsers(ctx, h.Store, c.Writer, query.Format); err != nil {
+		h.Log.ErrorContext(c.Request.Context(), "exporting users", "err", err)
+	}
+}
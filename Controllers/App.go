@@ -2,78 +2,62 @@ package controller
 
 import (
 	"database/sql"
-	"fmt"
-	database "go_server/Database"
+	apierror "go_server/ApiError"
+	models "go_server/Models"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CreateApp(c *gin.Context) {
-	name := c.PostForm("name")
-	callback_url := c.PostForm("callback_url")
-
-	// check if the name and callback_url are empty
-	if name == "" || callback_url == "" {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Name and Callback URL are required",
-		})
+func (h *Handler) CreateApp(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.CreateAppRequest
+	if !bind(c, &req) {
 		return
 	}
+	name, callback_url := req.Name, req.CallbackUrl
 
 	// get the user id from the context
 	id, _ := c.Get("id")
 
 	// insert the app into the database
-	appId, err := database.InsertApp(name, callback_url, id.(int))
+	appId, err := h.Store.InsertApp(ctx, name, callback_url, id.(int))
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the app",
-		})
+		apierror.Abort(c, apierror.Internal("Error inserting the app", err))
 		return
 	}
+	h.AppOrigins.Invalidate()
 
 	c.JSON(200, gin.H{
 		"status": "success",
-		"data": gin.H{
-			"id":           appId,
-			"name":         name,
-			"callback_url": callback_url,
+		"data": models.AppSummary{
+			Id:          appId,
+			Name:        name,
+			CallbackUrl: callback_url,
 		},
 	})
 
 }
 
-func GetApp(c *gin.Context) {
+func (h *Handler) GetApp(c *gin.Context) {
+	ctx := c.Request.Context()
 	id := c.Param("id")
 	// parse the id into an integer
 	appId, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Invalid app ID",
-		})
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid app ID"))
 		return
 	}
 
 
 	// get the app from the database
-	app, err := database.GetAppById(appId)
+	app, err := h.Store.GetAppById(ctx, appId)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(404, gin.H{
-				"status":  "error",
-				"message": "App not found",
-			})
+			apierror.Abort(c, apierror.NotFound("App not found"))
 			return
 		}
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error getting the app",
-		})
+		apierror.Abort(c, apierror.Internal("Error getting the app", err))
 		return
 	}
 
@@ -83,15 +67,13 @@ func GetApp(c *gin.Context) {
 	})
 }
 
-func UpdateApp(c *gin.Context){
+func (h *Handler) UpdateApp(c *gin.Context){
+	ctx := c.Request.Context()
 	id := c.Param("id")
 	// parse the id into an integer
 	appId, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Invalid app ID",
-		})
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid app ID"))
 		return
 	}
 
@@ -99,52 +81,40 @@ func UpdateApp(c *gin.Context){
 	userId, _ := c.Get("id")
 	userIdInt := userId.(int)
 
-	name := c.PostForm("name")
-	callback_url := c.PostForm("callback_url")
-
-	// check if the name and callback_url are empty
-	if name == "" && callback_url == "" {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Name or Callback URL are required",
-		})
+	var req models.UpdateAppRequest
+	if !bind(c, &req) {
 		return
 	}
+	name, callback_url := req.Name, req.CallbackUrl
 
-	err = database.UpdateApp(appId,userIdInt, name, callback_url)
+	err = h.Store.UpdateApp(ctx, appId,userIdInt, name, callback_url)
 	if err != nil {
 		if err == sql.ErrNoRows{
-			c.JSON(404, gin.H{
-				"status":  "error",
-				"message": "App not found",
-			})
+			apierror.Abort(c, apierror.NotFound("App not found"))
+			return
 		}
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error updating the app",
-		})
+		apierror.Abort(c, apierror.Internal("Error updating the app", err))
 		return
 	}
+	h.AppOrigins.Invalidate()
 
 	c.JSON(200, gin.H{
 		"status": "success",
-		"data": gin.H{
-			"id":           appId,
-			"name":         name,
-			"callback_url": callback_url,
+		"data": models.AppSummary{
+			Id:          appId,
+			Name:        name,
+			CallbackUrl: callback_url,
 		},
 	})
 }
 
-func DeleteApp(c *gin.Context){
+func (h *Handler) DeleteApp(c *gin.Context){
+	ctx := c.Request.Context()
 	id := c.Param("id")
 	// parse the id into an integer
 	appId, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Invalid app ID",
-		})
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid app ID"))
 		return
 	}
 
@@ -152,25 +122,50 @@ func DeleteApp(c *gin.Context){
 	userId, _ := c.Get("id")
 	userIdInt := userId.(int)
 
-	err = database.DeleteApp(appId,userIdInt)
+	err = h.Store.DeleteApp(ctx, appId,userIdInt)
 	if err != nil {
-		fmt.Println(err)
 		if err == sql.ErrNoRows{
-			c.JSON(404, gin.H{
-				"status":  "error",
-				"message": "App not found",
-			})
+			apierror.Abort(c, apierror.NotFound("App not found"))
 			return
 		}
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error deleting the app",
-		})
+		apierror.Abort(c, apierror.Internal("Error deleting the app", err))
 		return
 	}
+	h.AppOrigins.Invalidate()
 
 	c.JSON(200, gin.H{
 		"status": "success",
 		"message": "App deleted successfully",
 	})
 }
+
+// UpdateAppBranding sets the logo and colour used in emails sent on behalf
+// of the app. Empty values reset to the default branding.
+func (h *Handler) UpdateAppBranding(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+
+	var req models.AppBrandingRequest
+	if !bind(c, &req) {
+		return
+	}
+	logoUrl, brandColor := req.LogoUrl, req.BrandColor
+
+	err := h.Store.UpdateAppBranding(ctx, app.ID, app.UserId, logoUrl, brandColor)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the app", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.AppBranding{
+			Id:         app.ID,
+			LogoUrl:    logoUrl,
+			BrandColor: brandColor,
+		},
+	})
+}
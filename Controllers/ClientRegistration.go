@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "go_server/ApiError"
+	models "go_server/Models"
+	validation "go_server/Validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registrationGrantTypes are the grants every client may use, listed in
+// the registration answers. The binding refuses registrations asking for
+// others.
+var registrationGrantTypes = []string{GrantTypeClientCredentials, GrantTypeDeviceCode, GrantTypeTokenExchange}
+
+// bindClientMetadata binds the metadata of a registration, reporting
+// invalid fields with the errors of RFC 7591 section 3.2.2. Scopes must be
+// among allowedScopes, the server's auth.client_scopes.
+func bindClientMetadata(c *gin.Context, req *models.ClientRegistrationRequest, allowedScopes []string) (models.ClientMetadata, bool) {
+	if err := validation.Bind(c, req); err != nil {
+		apierror.Abort(c, clientMetadataError(err))
+		return models.ClientMetadata{}, false
+	}
+	scopes := []string{}
+	for _, scope := range strings.Fields(req.Scope) {
+		if !validation.ScopeTokenPattern.MatchString(scope) {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidClientMetadata, "scope must not contain quotes or backslashes"))
+			return models.ClientMetadata{}, false
+		}
+		if !slices.Contains(allowedScopes, scope) {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidClientMetadata, "The scope "+scope+" is not allowed on this server"))
+			return models.ClientMetadata{}, false
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return models.ClientMetadata{
+		Name:        req.ClientName,
+		CallbackUrl: req.RedirectUris[0],
+		LogoUrl:     req.LogoUri,
+		Scopes:      scopes,
+	}, true
+}
+
+// clientMetadataError turns the first invalid field into
+// invalid_redirect_uri or invalid_client_metadata.
+func clientMetadataError(err error) error {
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierror.CodeValidationFailed {
+		return err
+	}
+	fields, _ := apiErr.Extra["errors"].([]validation.FieldError)
+	if len(fields) == 0 {
+		return err
+	}
+	code := apierror.CodeInvalidClientMetadata
+	if strings.HasPrefix(fields[0].Field, "redirect_uris") {
+		code = apierror.CodeInvalidRedirectUri
+	}
+	return apierror.BadRequest(code, fields[0].Message)
+}
+
+func (h *Handler) clientRegistration(client models.ClientMetadata, registrationToken string) models.ClientRegistration {
+	clientId := strconv.Itoa(client.AppId)
+	return models.ClientRegistration{
+		ClientId:                clientId,
+		RegistrationAccessToken: registrationToken,
+		RegistrationClientUri:   h.publicBaseURL() + "/oauth/register/" + clientId,
+		ClientName:              client.Name,
+		RedirectUris:            []string{client.CallbackUrl},
+		LogoUri:                 client.LogoUrl,
+		Scope:                   strings.Join(client.Scopes, " "),
+		GrantTypes:              registrationGrantTypes,
+		TokenEndpointAuthMethod: "client_secret_basic",
+	}
+}
+
+func writeClientRegistration(c *gin.Context, status int, resp models.ClientRegistration) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(status, resp)
+}
+
+// RegisterClient registers an OAuth client with an organization's initial
+// access token (RFC 7591 section 3). The client is an app owned by the
+// organization's owner; its secret and registration access token are
+// only shown here.
+func (h *Handler) RegisterClient(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.ClientRegistrationRequest
+	client, ok := bindClientMetadata(c, &req, h.Config.Auth.ClientScopes)
+	if !ok {
+		return
+	}
+	org, err := h.Store.GetOrganizationById(ctx, c.GetInt("org_id"))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the organization", err))
+		return
+	}
+
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the client secret", err))
+		return
+	}
+	registrationToken, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the registration access token", err))
+		return
+	}
+	client.AppId, err = h.Store.InsertRegisteredClient(ctx, org.OwnerId, client, HashOpaqueToken(secret), HashOpaqueToken(registrationToken))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error registering the client", err))
+		return
+	}
+	h.AppOrigins.Invalidate()
+
+	resp := h.clientRegistration(client, registrationToken)
+	resp.ClientSecret = secret
+	resp.ClientIdIssuedAt = time.Now().Unix()
+	writeClientRegistration(c, 201, resp)
+}
+
+// GetClientRegistration reads the metadata of the client whose
+// registration access token is used (RFC 7592 section 2.1).
+func (h *Handler) GetClientRegistration(c *gin.Context) {
+	client, err := h.Store.GetClientMetadata(c.Request.Context(), c.GetInt("app_id"))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the client", err))
+		return
+	}
+	writeClientRegistration(c, 200, h.clientRegistration(client, c.GetString("registration_access_token")))
+}
+
+// UpdateClientRegistration replaces the metadata of the client (RFC 7592
+// section 2.2). Metadata left out is cleared, as with a PUT.
+func (h *Handler) UpdateClientRegistration(c *gin.Context) {
+	appId := c.GetInt("app_id")
+	var req models.ClientRegistrationRequest
+	client, ok := bindClientMetadata(c, &req, h.Config.Auth.ClientScopes)
+	if !ok {
+		return
+	}
+	if req.ClientId != strconv.Itoa(appId) {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "client_id must be the id of the client"))
+		return
+	}
+
+	client.AppId = appId
+	if err := h.Store.UpdateClientMetadata(c.Request.Context(), client); err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the client", err))
+		return
+	}
+	h.AppOrigins.Invalidate()
+	writeClientRegistration(c, 200, h.clientRegistration(client, c.GetString("registration_access_token")))
+}
+
+// DeleteClientRegistration deregisters the client, deleting its app (RFC
+// 7592 section 2.3).
+func (h *Handler) DeleteClientRegistration(c *gin.Context) {
+	if err := h.Store.DeleteClient(c.Request.Context(), c.GetInt("app_id")); err != nil {
+		apierror.Abort(c, apierror.Internal("Error deleting the client", err))
+		return
+	}
+	h.AppOrigins.Invalidate()
+	c.Status(204)
+}
+
+// CreateInitialAccessToken issues a token with which CI jobs and other
+// tooling register clients for the organization.
+func (h *Handler) CreateInitialAccessToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	org, ok := h.getOwnedOrganization(c)
+	if !ok {
+		return
+	}
+	var req models.CreateInitialAccessTokenRequest
+	if !bind(c, &req) {
+		return
+	}
+
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
+		return
+	}
+	tokenId, err := h.Store.InsertInitialAccessToken(ctx, org.ID, HashOpaqueToken(token), req.Description)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error inserting the token", err))
+		return
+	}
+
+	// the plain token is only ever shown in this response
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.NewInitialAccessToken{
+			Id:          tokenId,
+			OrgId:       org.ID,
+			Description: req.Description,
+			Token:       token,
+		},
+	})
+}
+
+func (h *Handler) GetInitialAccessTokens(c *gin.Context) {
+	org, ok := h.getOwnedOrganization(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.Store.GetInitialAccessTokensOfOrg(c.Request.Context(), org.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the tokens", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   tokens,
+	})
+}
+
+// RevokeInitialAccessToken stops the token from registering clients; the
+// clients it registered stay.
+func (h *Handler) RevokeInitialAccessToken(c *gin.Context) {
+	org, ok := h.getOwnedOrganization(c)
+	if !ok {
+		return
+	}
+	tokenId, err := strconv.Atoi(c.Param("tokenId"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid token ID"))
+		return
+	}
+	if err := h.Store.RevokeInitialAccessToken(c.Request.Context(), org.ID, tokenId); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("Token not found"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error revoking the token", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": "Token revoked successfully",
+	})
+}
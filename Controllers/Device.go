@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "go_server/ApiError"
+	database "go_server/Database"
+	metrics "go_server/Metrics"
+	models "go_server/Models"
+	services "go_server/Services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// userCodeAlphabet leaves out vowels, so user codes never spell words, and
+// letters easily confused with digits. Eight of them give 20^8 codes.
+const (
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// slowDownStep is added to the poll interval of a device polling too
+// often (RFC 8628 section 3.5).
+const slowDownStep = 5
+
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	size := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode accepts a user code typed in any case, with or without
+// the dash and spaces.
+func normalizeUserCode(input string) string {
+	var code strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		if r != '-' && r != ' ' {
+			code.WriteRune(r)
+		}
+	}
+	return code.String()
+}
+
+// formatUserCode shows a user code as two groups of four.
+func formatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+// DeviceAuthorization starts the login of a device that cannot open the
+// app's callback, such as a CLI (RFC 8628 section 3.2). The device shows
+// the user code and polls the token endpoint with the device code while
+// the user approves the login on another device.
+func (h *Handler) DeviceAuthorization(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.DeviceAuthorizationRequest
+	if !bind(c, &req) {
+		return
+	}
+	appId, err := strconv.Atoi(req.ClientId)
+	if err == nil {
+		_, err = h.Store.GetAppById(ctx, appId)
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidClient, "Unknown client"))
+		return
+	}
+
+	deviceCode, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the device code", err))
+		return
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the device code", err))
+		return
+	}
+	ttl := h.Config.Auth.DeviceCodeTTL.Duration
+	interval := int(h.Config.Auth.DevicePollInterval.Seconds())
+	err = h.Store.InsertDeviceCode(ctx, HashOpaqueToken(deviceCode), userCode, appId, interval, time.Now().Add(ttl))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error storing the device code", err))
+		return
+	}
+
+	verificationUri := h.publicBaseURL() + "/device"
+	c.Header("Cache-Control", "no-store")
+	c.JSON(200, models.DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationUri:         verificationUri,
+		VerificationUriComplete: verificationUri + "?" + url.Values{"user_code": {formatUserCode(userCode)}}.Encode(),
+		ExpiresIn:               int(ttl.Seconds()),
+		Interval:                interval,
+	})
+}
+
+// GetDeviceLogin shows the logged in user which app a device login is for
+// before they decide on it.
+func (h *Handler) GetDeviceLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	var query models.DeviceLoginQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	code, err := h.Store.GetPendingDeviceCode(ctx, normalizeUserCode(query.UserCode))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("The code is invalid or expired"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the device login", err))
+		return
+	}
+	app, err := h.Store.GetAppById(ctx, code.AppId)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the app", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.DeviceLogin{
+			UserCode:  formatUserCode(code.UserCode),
+			App:       models.AppSummary{Id: app.ID, Name: app.Name, CallbackUrl: app.CallbackUrl},
+			ExpiresAt: code.ExpiresAt,
+		},
+	})
+}
+
+// VerifyDevice approves or denies a device login as the logged in user.
+// Only a recent dashboard login decides: an app holding the user's token
+// could otherwise approve its own device login for any other app.
+func (h *Handler) VerifyDevice(c *gin.Context) {
+	var req models.DeviceVerificationRequest
+	if !bind(c, &req) {
+		return
+	}
+	if !requireDashboardToken(c, "approve device logins") || !h.requireRecentAuth(c, "Please log in again to approve the device") {
+		return
+	}
+	approve := req.Action == "approve"
+	// the device's tokens carry the approver's own login time, not the
+	// time of the approval
+	authTime := c.MustGet("auth_time").(time.Time)
+	err := h.Store.DecideDeviceCode(c.Request.Context(), normalizeUserCode(req.UserCode), c.GetInt("id"), authTime, approve)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("The code is invalid or expired"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error updating the device login", err))
+		return
+	}
+
+	message := "The device login has been denied"
+	if approve {
+		message = "The device has been signed in"
+	}
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": message,
+	})
+}
+
+// deviceCodeGrant answers the polls of a device (RFC 8628 section 3.4):
+// authorization_pending until the user decides, slow_down when it polls
+// more often than its interval, then the tokens of the user, once.
+func (h *Handler) deviceCodeGrant(c *gin.Context, req models.TokenRequest) {
+	ctx := c.Request.Context()
+	clientId := req.ClientId
+	if user, _, ok := c.Request.BasicAuth(); ok && clientId == "" {
+		clientId, _ = url.QueryUnescape(user)
+	}
+	if req.DeviceCode == "" {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "device_code is required"))
+		return
+	}
+
+	invalid := apierror.BadRequest(apierror.CodeInvalidGrant, "Invalid device code")
+	code, err := h.Store.GetDeviceCode(ctx, HashOpaqueToken(req.DeviceCode))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, invalid)
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the device code", err))
+		return
+	}
+	if strconv.Itoa(code.AppId) != clientId || code.Status == database.DeviceCodeConsumed {
+		apierror.Abort(c, invalid)
+		return
+	}
+	now := time.Now()
+	if !code.ExpiresAt.After(now) {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeExpiredToken, "The device code has expired"))
+		return
+	}
+
+	switch code.Status {
+	case database.DeviceCodePending:
+		interval := code.Interval
+		tooSoon := !code.LastPolledAt.IsZero() && now.Sub(code.LastPolledAt) < time.Duration(interval)*time.Second
+		if tooSoon {
+			interval += slowDownStep
+		}
+		if err := h.Store.RecordDevicePoll(ctx, code.ID, interval); err != nil {
+			apierror.Abort(c, apierror.Internal("Error updating the device code", err))
+			return
+		}
+		if tooSoon {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeSlowDown, "Poll every "+strconv.Itoa(interval)+" seconds"))
+			return
+		}
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeAuthorizationPending, "The user has not approved the login yet"))
+		return
+	case database.DeviceCodeDenied:
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeAccessDenied, "The user denied the login"))
+		return
+	}
+
+	// approved: hand out the tokens once
+	if err := h.Store.ConsumeDeviceCode(ctx, code.ID); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, invalid)
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error updating the device code", err))
+		return
+	}
+	user, err := h.Store.GetUserById(ctx, code.UserId)
+	if err != nil || !user.Active {
+		h.loginFailed(ctx, "device", code.AppId)
+		apierror.Abort(c, invalid)
+		return
+	}
+	accessToken, refreshToken, err := h.issueTokens(user, code.AppId, code.AuthTime)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
+		return
+	}
+	if err := h.Store.InsertOrUpdateSession(ctx, user.ID, code.AppId, refreshToken); err != nil {
+		apierror.Abort(c, apierror.Internal("Error inserting the refresh token", err))
+		return
+	}
+	h.emitWebhookEvent(c, []int{code.AppId}, services.EventUserLoggedIn, userEventData(user.ID, user.Email, user.Name, "device"))
+	metrics.Logins.Inc("device", strconv.Itoa(code.AppId), "success")
+
+	writeTokenResponse(c, models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.Config.Auth.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
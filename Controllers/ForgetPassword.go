@@ -1,125 +1,129 @@
 package controller
 
 import (
-	"fmt"
-	database "go_server/Database"
+	"context"
+	"database/sql"
+	apierror "go_server/ApiError"
+	models "go_server/Models"
 	services "go_server/Services"
+	"net/url"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type ForgetPasswordClaim struct {
-	Email string `json:"email"`
-	jwt.RegisteredClaims
+// emailLocale picks the email language from the "locale" field of the
+// request or the Accept-Language header.
+func emailLocale(c *gin.Context, locale string) string {
+	if locale != "" {
+		return services.ResolveEmailLocale(locale)
+	}
+	return services.ResolveEmailLocale(c.GetHeader("Accept-Language"))
 }
 
-func InitiateForgetPassword(c *gin.Context) {
-
-	// get user by email to vertify if user exists
-	email := c.PostForm("email")
-	if email == "" {
-		c.JSON(400, gin.H{
-			"message": "email is required",
-		})
-		return
+func (h *Handler) appBranding(ctx context.Context, appId int) services.EmailBranding {
+	brand := services.DefaultBranding(h.Config.Email.AppName)
+	app, err := h.Store.GetAppById(ctx, appId)
+	if err != nil {
+		return brand
 	}
+	return brand.ForApp(app.Name, app.LogoUrl, app.BrandColor)
+}
 
-	user, err := database.GetUserByEmail(email)
+// publicBaseURL is the externally reachable address of the frontend used in
+// emailed links. It comes from the configuration and never from the
+// request, so a forged Host header cannot redirect links elsewhere.
+func (h *Handler) publicBaseURL() string {
+	return h.Config.PublicBaseURL
+}
 
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "user not found",
-		})
+// InitiateForgetPassword emails a single-use reset link. The answer is the
+// same whether or not the email belongs to an account, so the endpoint
+// cannot be used to discover registered addresses.
+func (h *Handler) InitiateForgetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.ForgetPasswordRequest
+	if !bind(c, &req) {
 		return
 	}
 
-	claim := ForgetPasswordClaim{
-		Email: user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
-			Issuer:    "go_server",
-			Subject:   "forget_password",
-		},
+	user, err := h.Store.GetUserByEmail(ctx, req.Email)
+	if err == nil && user.Active {
+		if err := h.sendPasswordResetLink(c, user.ID, user.Email, req); err != nil {
+			h.Log.ErrorContext(c.Request.Context(), "sending password reset link", "err", err)
+		}
+	} else if err != nil && err != sql.ErrNoRows {
+		h.Log.ErrorContext(c.Request.Context(), "loading user for password reset", "err", err)
 	}
 
-	token, err := GenerateToken(claim)
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": "If the email belongs to an account, a password reset link has been sent",
+	})
+}
 
+func (h *Handler) sendPasswordResetLink(c *gin.Context, userId int, email string, req models.ForgetPasswordRequest) error {
+	ctx := c.Request.Context()
+	token, err := GenerateOpaqueToken()
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "error generating token",
-		})
-		return
+		return err
 	}
-	//insert token to db
-	err = database.InsertForgetPassword(email, token, time.Now().Add(time.Hour))
-	if err!= nil {
-		c.JSON(400, gin.H{
-			"message": "error inserting token",
-		})
-		return
-	}
-	// get the backend url
-	link := c.Request.Host + "/complete-forget-password?email=" + user.Email + "&token=" + token
-
-	err = services.SendForgetPasswordEmail(user.Email, link)
-
+	// only the hash is stored, so a database leak does not expose live links
+	err = h.Store.InsertPasswordResetToken(ctx, HashOpaqueToken(token), userId, time.Now().Add(h.Config.Auth.PasswordResetTTL.Duration))
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "error sending email",
-		})
-		return
+		return err
 	}
-	c.JSON(200, gin.H{
-		"message": "email sent",
-	})
+	link := h.publicBaseURL() + "/complete-forget-password?token=" + url.QueryEscape(token)
+	return services.SendForgetPasswordEmail(ctx, h.Store, email, link, emailLocale(c, req.Locale), h.appBranding(ctx, req.AppId), h.Config.Auth.PasswordResetTTL.Duration)
 }
 
-func CompleteForgetPassword(c *gin.Context) {
-	email := c.PostForm("email")
-	token := c.PostForm("token")
-	newPassword := c.PostForm("password")
-	if email == "" || token == "" || newPassword == "" {
-		c.JSON(400, gin.H{
-			"message": "email ,token and password are required",
-		})
+// CompleteForgetPassword sets a new password with a reset token. The token
+// is consumed, and every session of the user is revoked so whoever may have
+// had access to the account is signed out.
+func (h *Handler) CompleteForgetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.ResetPasswordRequest
+	if !bind(c, &req) {
 		return
 	}
-	claim := &ForgetPasswordClaim{}
-	claim, err := VerifyToken(token, claim)
+	token, newPassword := req.Token, req.Password
+
+	tokenHash := HashOpaqueToken(token)
+	user, err := h.Store.GetPasswordResetUser(ctx, tokenHash)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "error verifying token",
-		})
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidLink, "The reset link is invalid or has expired"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error verifying the reset link", err))
 		return
 	}
-	if claim.Email != email {
-		c.JSON(400, gin.H{
-			"message": "email does not match",
-		})
+	if h.rejectWeakPassword(c, newPassword, user.Email, user.Name) {
 		return
 	}
 
-	// hash the password
-	hashPassword, err := HashPassword(newPassword)
+	hashPassword, err := h.hashPassword(ctx, newPassword)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "error hashing password",
-		})
+		apierror.Abort(c, apierror.Internal("Error hashing the password", err))
 		return
 	}
 
-	// update the password
-	err = database.UpdatePasswordWithEmail(email, hashPassword)
+	user, appIds, err := h.Store.ResetPasswordWithToken(ctx, tokenHash, hashPassword)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(400, gin.H{
-			"message": "error updating password",
-		})
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidLink, "The reset link is invalid or has expired"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error updating the password", err))
 		return
 	}
+
+	h.emitWebhookEvent(c, appIds, services.EventUserPasswordChanged, userEventData(user.ID, user.Email, user.Name, "reset_password"))
+	if err := services.SendPasswordChangedEmail(ctx, h.Store, user.Email, emailLocale(c, req.Locale), h.appBranding(ctx, req.AppId)); err != nil {
+		h.Log.ErrorContext(c.Request.Context(), "queueing password changed email", "err", err)
+	}
 	c.JSON(200, gin.H{
-		"message": "password updated",
+		"status":  "success",
+		"message": "Password updated, please log in again",
 	})
 }
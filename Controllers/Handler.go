@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+
+	apierror "go_server/ApiError"
+	config "go_server/Config"
+	database "go_server/Database"
+	logging "go_server/Logging"
+	models "go_server/Models"
+	services "go_server/Services"
+	validation "go_server/Validation"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("go_server/Controllers")
+
+// GoogleVerifier resolves a Google access token to the account it was
+// issued for.
+type GoogleVerifier func(ctx context.Context, token string) (models.GoogleUser, error)
+
+// Handler holds the dependencies of the HTTP handlers. Routes are bound to
+// its methods, so tests can serve the API from a MemoryStore and a fake
+// Google verifier. Log is the api component logger; Logs hands out the
+// others.
+type Handler struct {
+	Store        database.Store
+	Config       *config.Config
+	Tokens       *Tokens
+	Passwords    *services.Passwords
+	VerifyGoogle GoogleVerifier
+	AppOrigins   *services.AppOrigins
+	Logs         *logging.Loggers
+	Log          *slog.Logger
+}
+
+func NewHandler(store database.Store, cfg *config.Config, logs *logging.Loggers) (*Handler, error) {
+	tokens, err := NewTokens(cfg.Auth.PrivateKey, cfg.Auth.Issuer, logs.For(logging.ComponentAuth))
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		Store:        store,
+		Config:       cfg,
+		Tokens:       tokens,
+		Passwords:    services.NewPasswords(cfg.Password, logs.For(logging.ComponentAuth)),
+		VerifyGoogle: VerifyGoogleToken,
+		AppOrigins:   services.NewAppOrigins(store, cfg.CORS.AppOriginCacheTTL.Duration, logs.For(logging.ComponentAPI)),
+		Logs:         logs,
+		Log:          logs.For(logging.ComponentAPI),
+	}, nil
+}
+
+// emitWebhookEvent queues an event for the apps; failures are logged only.
+func (h *Handler) emitWebhookEvent(c *gin.Context, appIds []int, eventType string, data interface{}) {
+	services.EmitWebhookEventToApps(c.Request.Context(), h.Logs.For(logging.ComponentWebhooks), h.Store, appIds, eventType, data)
+}
+
+// bind reads the JSON or form body into req and validates it. On failure
+// it aborts with the invalid fields and reports false.
+func bind(c *gin.Context, req interface{}) bool {
+	if err := validation.Bind(c, req); err != nil {
+		apierror.Abort(c, err)
+		return false
+	}
+	return true
+}
+
+// bindQuery is bind for the query string.
+func bindQuery(c *gin.Context, req interface{}) bool {
+	if err := validation.BindQuery(c, req); err != nil {
+		apierror.Abort(c, err)
+		return false
+	}
+	return true
+}
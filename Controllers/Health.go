@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	apierror "go_server/ApiError"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// Healthz answers as long as the process serves HTTP, for liveness probes.
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"status": "success",
+	})
+}
+
+// Readyz reports whether the instance can serve traffic: the database
+// answers, its schema is up to date and a signing key is loaded. Every
+// check is listed so a failing probe shows which one broke.
+func (h *Handler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := gin.H{}
+	ready := true
+	fail := func(name string, err error) {
+		h.Log.WarnContext(ctx, "readiness check failed", "check", name, "err", err)
+		checks[name] = "failed"
+		ready = false
+	}
+
+	if err := h.Store.Ping(ctx); err != nil {
+		fail("database", err)
+	} else {
+		checks["database"] = "ok"
+	}
+	if pending, err := h.Store.HasPendingMigrations(ctx); err != nil {
+		fail("migrations", err)
+	} else if pending {
+		fail("migrations", fmt.Errorf("migrations are pending"))
+	} else {
+		checks["migrations"] = "ok"
+	}
+	if h.Tokens == nil || h.Tokens.privateKey == nil {
+		fail("signing_key", fmt.Errorf("no signing key loaded"))
+	} else {
+		checks["signing_key"] = "ok"
+	}
+
+	if !ready {
+		apierror.Abort(c, apierror.New(503, apierror.CodeNotReady, "Not ready").With("checks", checks))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"checks": checks,
+	})
+}
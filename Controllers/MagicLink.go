@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"database/sql"
+	apierror "go_server/ApiError"
+	models "go_server/Models"
+	services "go_server/Services"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	magicLinkBindingCookie = "magic_link_binding"
+	magicLinkCookiePath    = "/api/v1/magic-link"
+)
+
+func (h *Handler) setMagicLinkCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	secure := strings.HasPrefix(h.publicBaseURL(), "https://")
+	c.SetCookie(magicLinkBindingCookie, value, maxAge, magicLinkCookiePath, "", secure, true)
+}
+
+// RequestMagicLink emails a single-use sign-in link. The link is bound to
+// the requesting browser through an HttpOnly cookie, so a leaked or
+// forwarded email cannot be used from another device. The response is the
+// same whether or not the email belongs to an account.
+func (h *Handler) RequestMagicLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !h.Config.Features.MagicLink {
+		apierror.Abort(c, apierror.New(404, apierror.CodeFeatureDisabled, "Magic link login is disabled"))
+		return
+	}
+
+	var req models.MagicLinkRequest
+	if !bind(c, &req) {
+		return
+	}
+	if req.AppId != 0 {
+		if _, err := h.Store.GetAppById(ctx, req.AppId); err != nil {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid app ID"))
+			return
+		}
+	}
+
+	binding, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the link", err))
+		return
+	}
+	// the cookie is set for every request so its presence says nothing
+	// about the account
+	h.setMagicLinkCookie(c, binding, int(h.Config.Auth.MagicLinkTTL.Seconds()))
+
+	user, err := h.Store.GetUserByEmail(ctx, req.Email)
+	if err == nil && user.Active {
+		if err := h.sendMagicLink(c, user.Email, req, binding); err != nil {
+			h.Log.ErrorContext(c.Request.Context(), "sending magic link", "err", err)
+		}
+	} else if err != nil && err != sql.ErrNoRows {
+		h.Log.ErrorContext(c.Request.Context(), "loading user for magic link", "err", err)
+	}
+
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": "If the email belongs to an account, a sign-in link has been sent",
+	})
+}
+
+func (h *Handler) sendMagicLink(c *gin.Context, email string, req models.MagicLinkRequest, binding string) error {
+	ctx := c.Request.Context()
+	appId := req.AppId
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = h.Store.InsertMagicLink(ctx, HashOpaqueToken(token), email, appId, HashOpaqueToken(binding), time.Now().Add(h.Config.Auth.MagicLinkTTL.Duration))
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("token", token)
+	if appId != 0 {
+		query.Set("id", strconv.Itoa(appId))
+	}
+	link := h.publicBaseURL() + "/magic-link?" + query.Encode()
+	return services.SendMagicLinkEmail(ctx, h.Store, email, link, emailLocale(c, req.Locale), h.appBranding(ctx, appId), h.Config.Auth.MagicLinkTTL.Duration)
+}
+
+// VerifyMagicLink consumes a sign-in link and completes the login exactly
+// like a password login, including the token_id for the app callback.
+func (h *Handler) VerifyMagicLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !h.Config.Features.MagicLink {
+		apierror.Abort(c, apierror.New(404, apierror.CodeFeatureDisabled, "Magic link login is disabled"))
+		return
+	}
+
+	var req models.VerifyMagicLinkRequest
+	if !bind(c, &req) {
+		return
+	}
+	binding, err := c.Cookie(magicLinkBindingCookie)
+	if err != nil || binding == "" {
+		apierror.Abort(c, apierror.Forbidden(apierror.CodeLinkBrowserMismatch, "Open the link in the browser where you requested it"))
+		return
+	}
+
+	link, err := h.Store.ConsumeMagicLink(ctx, HashOpaqueToken(req.Token), HashOpaqueToken(binding))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.loginFailed(ctx, "magic_link", 0)
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidLink, "The link is invalid, expired or was opened in another browser"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error verifying the link", err))
+		return
+	}
+	h.setMagicLinkCookie(c, "", -1)
+
+	user, err := h.Store.GetUserByEmail(ctx, link.Email)
+	if err != nil {
+		h.loginFailed(ctx, "magic_link", link.AppId)
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidLink, "The link is invalid, expired or was opened in another browser"))
+		return
+	}
+	if !user.Active {
+		h.loginFailed(ctx, "magic_link", link.AppId)
+		apierror.Abort(c, apierror.Forbidden(apierror.CodeAccountDisabled, "User is deactivated"))
+		return
+	}
+
+	h.completeLogin(c, user, link.AppId, services.EventUserLoggedIn, "magic_link")
+}
@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "go_server/ApiError"
+	metrics "go_server/Metrics"
+	models "go_server/Models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const GrantTypeClientCredentials = "client_credentials"
+
+// ClientTokenClaim is an access token an app holds for itself rather than
+// for a user. It has no user id, so the user routes refuse it.
+type ClientTokenClaim struct {
+	ClientId string `json:"client_id"`
+	AppId    int    `json:"app_id"`
+	Scope    string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Token is the OAuth token endpoint (RFC 6749 section 3.2). Errors are
+// rendered in the OAuth format by the route.
+func (h *Handler) Token(c *gin.Context) {
+	var req models.TokenRequest
+	if !bind(c, &req) {
+		return
+	}
+	switch req.GrantType {
+	case GrantTypeClientCredentials:
+		h.clientCredentialsGrant(c, req)
+	case GrantTypeDeviceCode:
+		h.deviceCodeGrant(c, req)
+	case GrantTypeTokenExchange:
+		h.tokenExchangeGrant(c, req)
+	default:
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeUnsupportedGrantType, "Unsupported grant type"))
+	}
+}
+
+// clientCredentialsGrant issues an access token to an app for itself
+// (RFC 6749 section 4.4). No refresh token is issued; the app asks again.
+// With an audience the token is for calling that app, which must allow
+// the caller with an exchange policy, and gets scopes of that policy.
+func (h *Handler) clientCredentialsGrant(c *gin.Context, req models.TokenRequest) {
+	client, ok := h.authenticateClient(c, req)
+	if !ok {
+		return
+	}
+	clientId := strconv.Itoa(client.AppId)
+
+	// an app gets the scopes it asks for, or all it is allowed without a
+	// scope parameter. Scopes the server no longer allows are not granted
+	// even if the app still lists them.
+	allowed := h.allowedClientScopes(client.Scopes)
+	audience := clientId
+	if req.Audience != "" {
+		policy, ok := h.exchangePolicy(c, client.AppId, req.Audience)
+		if !ok {
+			return
+		}
+		allowed = policy.Scopes
+		audience = strconv.Itoa(policy.TargetAppId)
+	}
+	scopes := strings.Fields(req.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidScope, "The scope "+scope+" is not allowed for this client"))
+			return
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+	scope := strings.Join(scopes, " ")
+
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
+		return
+	}
+	now := time.Now()
+	ttl := h.Config.Auth.ClientTokenTTL.Duration
+	accessToken, err := h.Tokens.GenerateTyped(AccessTokenType, ClientTokenClaim{
+		ClientId: clientId,
+		AppId:    client.AppId,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   clientId,
+			Issuer:    h.Tokens.Issuer(),
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
+		return
+	}
+	metrics.TokensIssued.Inc("access")
+
+	writeTokenResponse(c, models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       scope,
+	})
+}
+
+// allowedClientScopes keeps the scopes of an app that the server still
+// allows apps to be granted.
+func (h *Handler) allowedClientScopes(scopes []string) []string {
+	return slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+		return !slices.Contains(h.Config.Auth.ClientScopes, scope)
+	})
+}
+
+// unapprovedScopeError refuses a scope outside the server's
+// auth.client_scopes, listing those that may be picked.
+func unapprovedScopeError(scope string, allowed []string) *apierror.Error {
+	return apierror.BadRequest(apierror.CodeInvalidRequest, "The scope "+scope+" is not allowed on this server").With("allowed_scopes", append([]string{}, allowed...))
+}
+
+// writeTokenResponse sends a token endpoint answer, which must not be
+// cached (RFC 6749 section 5.1).
+func writeTokenResponse(c *gin.Context, resp models.TokenResponse) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(200, resp)
+}
+
+// authenticateClient checks the credentials of the app calling the token
+// endpoint, sent with HTTP Basic or as client_id and client_secret in the
+// body but not both (RFC 6749 section 2.3.1).
+func (h *Handler) authenticateClient(c *gin.Context, req models.TokenRequest) (models.AppClient, bool) {
+	clientId, secret := req.ClientId, req.ClientSecret
+	if user, pass, ok := c.Request.BasicAuth(); ok {
+		if clientId != "" || secret != "" {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Use only one method to authenticate the client"))
+			return models.AppClient{}, false
+		}
+		// Basic credentials are form encoded before being joined
+		var userErr, passErr error
+		clientId, userErr = url.QueryUnescape(user)
+		secret, passErr = url.QueryUnescape(pass)
+		if userErr != nil || passErr != nil {
+			apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidClient, "Invalid client credentials"))
+			return models.AppClient{}, false
+		}
+	}
+
+	invalid := apierror.Unauthorized(apierror.CodeInvalidClient, "Invalid client credentials")
+	appId, err := strconv.Atoi(clientId)
+	if err != nil || secret == "" {
+		apierror.Abort(c, invalid)
+		return models.AppClient{}, false
+	}
+	client, err := h.Store.GetAppClient(c.Request.Context(), appId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, invalid)
+			return models.AppClient{}, false
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the client", err))
+		return models.AppClient{}, false
+	}
+	if client.SecretHash == "" || subtle.ConstantTimeCompare([]byte(HashOpaqueToken(secret)), []byte(client.SecretHash)) != 1 {
+		apierror.Abort(c, invalid)
+		return models.AppClient{}, false
+	}
+	return client, true
+}
+
+func appClientInfo(client models.AppClient) models.AppClientInfo {
+	scopes := client.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return models.AppClientInfo{
+		ClientId:  strconv.Itoa(client.AppId),
+		Scopes:    scopes,
+		HasSecret: client.SecretHash != "",
+	}
+}
+
+func (h *Handler) GetAppClient(c *gin.Context) {
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	client, err := h.Store.GetAppClient(c.Request.Context(), app.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the client", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   appClientInfo(client),
+	})
+}
+
+// UpdateAppClient sets the scopes the app may request for itself.
+func (h *Handler) UpdateAppClient(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	var req models.AppClientRequest
+	if !bind(c, &req) {
+		return
+	}
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(h.Config.Auth.ClientScopes, scope) {
+			apierror.Abort(c, unapprovedScopeError(scope, h.Config.Auth.ClientScopes))
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if err := h.Store.SetAppScopes(ctx, app.ID, app.UserId, scopes); err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the client", err))
+		return
+	}
+	client, err := h.Store.GetAppClient(ctx, app.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the client", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   appClientInfo(client),
+	})
+}
+
+// RotateClientSecret issues a new client secret for the app, replacing
+// the previous one. Only its hash is stored, so it is shown only here.
+func (h *Handler) RotateClientSecret(c *gin.Context) {
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the secret", err))
+		return
+	}
+	if err := h.Store.SetAppClientSecret(c.Request.Context(), app.ID, app.UserId, HashOpaqueToken(secret)); err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the client", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.ClientSecret{
+			ClientId:     strconv.Itoa(app.ID),
+			ClientSecret: secret,
+		},
+	})
+}
@@ -0,0 +1,18 @@
+package controller
+
+import (
+	apierror "go_server/ApiError"
+	openapi "go_server/OpenApi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPI serves the OpenAPI document of the API.
+func (h *Handler) OpenAPI(c *gin.Context) {
+	doc, err := openapi.JSON()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error building the OpenAPI document", err))
+		return
+	}
+	c.Data(200, "application/json; charset=utf-8", doc)
+}
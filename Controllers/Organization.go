@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"database/sql"
+	apierror "go_server/ApiError"
+	models "go_server/Models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) CreateOrganization(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.CreateOrganizationRequest
+	if !bind(c, &req) {
+		return
+	}
+	name := req.Name
+
+	orgId, err := h.Store.InsertOrganization(ctx, name, c.GetInt("id"))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error inserting the organization", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.OrganizationSummary{
+			Id:   orgId,
+			Name: name,
+		},
+	})
+}
+
+func (h *Handler) GetUserOrganizations(c *gin.Context) {
+	ctx := c.Request.Context()
+	orgs, err := h.Store.GetOrganizationsOfUser(ctx, c.GetInt("id"))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the organizations", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   orgs,
+	})
+}
+
+// getOwnedOrganization loads the organization in the :id path parameter and
+// checks that the logged in user owns it. It writes the error response
+// itself and reports whether the handler may continue.
+func (h *Handler) getOwnedOrganization(c *gin.Context) (models.Organization, bool) {
+	ctx := c.Request.Context()
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid organization ID"))
+		return models.Organization{}, false
+	}
+
+	org, err := h.Store.GetOrganizationById(ctx, orgId)
+	if err != nil || org.OwnerId != c.GetInt("id") {
+		if err != nil && err != sql.ErrNoRows {
+			apierror.Abort(c, apierror.Internal("Error getting the organization", err))
+			return models.Organization{}, false
+		}
+		apierror.Abort(c, apierror.NotFound("Organization not found"))
+		return models.Organization{}, false
+	}
+	return org, true
+}
+
+func (h *Handler) CreateScimToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	org, ok := h.getOwnedOrganization(c)
+	if !ok {
+		return
+	}
+	var req models.CreateScimTokenRequest
+	if !bind(c, &req) {
+		return
+	}
+
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
+		return
+	}
+
+	description := req.Description
+	tokenId, err := h.Store.InsertScimToken(ctx, org.ID, HashOpaqueToken(token), description)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error inserting the token", err))
+		return
+	}
+
+	// the plain token is only ever shown in this response
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.NewScimToken{
+			Id:          tokenId,
+			OrgId:       org.ID,
+			Description: description,
+			Token:       token,
+		},
+	})
+}
+
+func (h *Handler) GetScimTokens(c *gin.Context) {
+	ctx := c.Request.Context()
+	org, ok := h.getOwnedOrganization(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.Store.GetScimTokensOfOrg(ctx, org.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the tokens", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   tokens,
+	})
+}
+
+func (h *Handler) RevokeScimToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	org, ok := h.getOwnedOrganization(c)
+	if !ok {
+		return
+	}
+
+	tokenId, err := strconv.Atoi(c.Param("tokenId"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid token ID"))
+		return
+	}
+
+	err = h.Store.RevokeScimToken(ctx, org.ID, tokenId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("Token not found"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error revoking the token", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": "Token revoked successfully",
+	})
+}
@@ -0,0 +1,855 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	database "go_server/Database"
+	models "go_server/Models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	scimUserSchema     = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema    = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema     = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema    = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimPatchSchema    = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimSPConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+type scimName struct {
+	Formatted  string `json:"formatted"`
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Primary bool   `json:"primary"`
+}
+
+type scimUserRequest struct {
+	UserName    string      `json:"userName"`
+	ExternalId  string      `json:"externalId"`
+	DisplayName string      `json:"displayName"`
+	Name        *scimName   `json:"name"`
+	Emails      []scimEmail `json:"emails"`
+	Active      *bool       `json:"active"`
+	Password    string      `json:"password"`
+}
+
+type scimMemberRef struct {
+	Value string `json:"value"`
+}
+
+type scimGroupRequest struct {
+	DisplayName string          `json:"displayName"`
+	ExternalId  string          `json:"externalId"`
+	Members     []scimMemberRef `json:"members"`
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+// scimPatchError carries the SCIM error type of a rejected PATCH operation.
+type scimPatchError struct {
+	scimType string
+	detail   string
+}
+
+func (e *scimPatchError) Error() string {
+	return e.detail
+}
+
+func invalidScimValue(format string, args ...interface{}) error {
+	return &scimPatchError{scimType: "invalidValue", detail: fmt.Sprintf(format, args...)}
+}
+
+func scimJSON(c *gin.Context, status int, obj interface{}) {
+	c.Header("Content-Type", "application/scim+json; charset=utf-8")
+	c.JSON(status, obj)
+}
+
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	body := gin.H{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	scimJSON(c, status, body)
+}
+
+// scimDatabaseError maps Database errors onto SCIM error responses.
+func (h *Handler) scimDatabaseError(c *gin.Context, err error, resource string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		scimError(c, 404, "", resource+" not found")
+	case errors.Is(err, database.ErrInvalidFilter):
+		scimError(c, 400, "invalidFilter", err.Error())
+	case errors.Is(err, database.ErrVersionMismatch):
+		scimError(c, 412, "", "resource was modified by another request")
+	case errors.Is(err, database.ErrUserExists), errors.Is(err, database.ErrGroupExists):
+		scimError(c, 409, "uniqueness", resource+" already exists")
+	case errors.Is(err, database.ErrInvalidMember):
+		scimError(c, 400, "invalidValue", err.Error())
+	default:
+		h.Log.ErrorContext(c.Request.Context(), "accessing the "+strings.ToLower(resource), "err", err)
+		scimError(c, 500, "", "Error accessing the "+strings.ToLower(resource))
+	}
+}
+
+func scimETag(version int) string {
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+// etagMatches reports whether an If-Match / If-None-Match header lists the
+// given version. Weak and strong forms are compared the same way.
+func etagMatches(header string, version int) bool {
+	want := strconv.Itoa(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		tag = strings.Trim(strings.TrimPrefix(tag, "W/"), `"`)
+		if tag == want {
+			return true
+		}
+	}
+	return false
+}
+
+// scimPreconditionFailed answers 412 when the request carries an If-Match
+// header that does not match the current version of the resource.
+func scimPreconditionFailed(c *gin.Context, version int) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" || etagMatches(ifMatch, version) {
+		return false
+	}
+	scimError(c, 412, "", "If-Match does not match the current version")
+	return true
+}
+
+func scimNotModified(c *gin.Context, version int) bool {
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch == "" || !etagMatches(ifNoneMatch, version) {
+		return false
+	}
+	c.Header("ETag", scimETag(version))
+	c.Status(304)
+	return true
+}
+
+func scimPagination(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scimDefaultCount)))
+	if err != nil || count < 0 {
+		count = scimDefaultCount
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+	return startIndex, count
+}
+
+func scimListResponse(c *gin.Context, resources []gin.H, total, startIndex int) {
+	scimJSON(c, 200, gin.H{
+		"schemas":      []string{scimListSchema},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+func scimUserResource(user models.ScimUser) gin.H {
+	id := strconv.Itoa(user.ID)
+	resource := gin.H{
+		"schemas":     []string{scimUserSchema},
+		"id":          id,
+		"userName":    user.Email,
+		"displayName": user.Name,
+		"name":        gin.H{"formatted": user.Name},
+		"emails":      []gin.H{{"value": user.Email, "type": "work", "primary": true}},
+		"active":      user.Active,
+		"meta": gin.H{
+			"resourceType": "User",
+			"created":      user.CreatedAt.UTC().Format(time.RFC3339),
+			"lastModified": user.UpdatedAt.UTC().Format(time.RFC3339),
+			"location":     "/scim/v2/Users/" + id,
+			"version":      scimETag(user.Version),
+		},
+	}
+	if user.ExternalId != "" {
+		resource["externalId"] = user.ExternalId
+	}
+	return resource
+}
+
+func scimGroupResource(group models.Group) gin.H {
+	id := strconv.Itoa(group.ID)
+	members := make([]gin.H, len(group.Members))
+	for i, member := range group.Members {
+		memberId := strconv.Itoa(member.UserId)
+		members[i] = gin.H{
+			"value":   memberId,
+			"display": member.Name,
+			"type":    "User",
+			"$ref":    "/scim/v2/Users/" + memberId,
+		}
+	}
+	resource := gin.H{
+		"schemas":     []string{scimGroupSchema},
+		"id":          id,
+		"displayName": group.DisplayName,
+		"members":     members,
+		"meta": gin.H{
+			"resourceType": "Group",
+			"created":      group.CreatedAt.UTC().Format(time.RFC3339),
+			"lastModified": group.UpdatedAt.UTC().Format(time.RFC3339),
+			"location":     "/scim/v2/Groups/" + id,
+			"version":      scimETag(group.Version),
+		},
+	}
+	if group.ExternalId != "" {
+		resource["externalId"] = group.ExternalId
+	}
+	return resource
+}
+
+func writeScimUser(c *gin.Context, status int, user models.ScimUser) {
+	c.Header("ETag", scimETag(user.Version))
+	c.Header("Location", "/scim/v2/Users/"+strconv.Itoa(user.ID))
+	scimJSON(c, status, scimUserResource(user))
+}
+
+func writeScimGroup(c *gin.Context, status int, group models.Group) {
+	c.Header("ETag", scimETag(group.Version))
+	c.Header("Location", "/scim/v2/Groups/"+strconv.Itoa(group.ID))
+	scimJSON(c, status, scimGroupResource(group))
+}
+
+// userFromScimRequest applies a full SCIM user representation. userName is
+// the login email of the account.
+func userFromScimRequest(req scimUserRequest, user *models.ScimUser) error {
+	email := req.UserName
+	if !strings.Contains(email, "@") {
+		email = ""
+		for _, candidate := range req.Emails {
+			if candidate.Primary || email == "" {
+				email = candidate.Value
+			}
+		}
+	}
+	if !strings.Contains(email, "@") {
+		return invalidScimValue("userName must be an email address")
+	}
+
+	name := req.DisplayName
+	if name == "" && req.Name != nil {
+		name = req.Name.Formatted
+		if name == "" {
+			name = strings.TrimSpace(req.Name.GivenName + " " + req.Name.FamilyName)
+		}
+	}
+	if name == "" {
+		name = email
+	}
+
+	user.Email = email
+	user.Name = name
+	user.ExternalId = req.ExternalId
+	user.Active = req.Active == nil || *req.Active
+	return nil
+}
+
+func (h *Handler) ScimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, 200, gin.H{
+		"schemas":        []string{scimSPConfigSchema},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": true},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Organization SCIM token issued from the dashboard",
+			"primary":     true,
+		}},
+	})
+}
+
+func (h *Handler) ScimListUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	orgId := c.GetInt("org_id")
+	conds, err := ParseScimFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, 400, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := scimPagination(c)
+
+	users, total, err := h.Store.ListOrgUsers(ctx, orgId, conds, startIndex-1, count)
+	if err != nil {
+		h.scimDatabaseError(c, err, "User")
+		return
+	}
+	resources := make([]gin.H, len(users))
+	for i, user := range users {
+		resources[i] = scimUserResource(user)
+	}
+	scimListResponse(c, resources, total, startIndex)
+}
+
+// loadScimUser fetches the user addressed by the :id path parameter,
+// answering 404 itself when it is not part of the organization.
+func (h *Handler) loadScimUser(c *gin.Context) (models.ScimUser, bool) {
+	ctx := c.Request.Context()
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, 404, "", "User not found")
+		return models.ScimUser{}, false
+	}
+	user, err := h.Store.GetOrgUser(ctx, c.GetInt("org_id"), userId)
+	if err != nil {
+		h.scimDatabaseError(c, err, "User")
+		return models.ScimUser{}, false
+	}
+	return user, true
+}
+
+func (h *Handler) ScimGetUser(c *gin.Context) {
+	user, ok := h.loadScimUser(c)
+	if !ok {
+		return
+	}
+	if scimNotModified(c, user.Version) {
+		return
+	}
+	writeScimUser(c, 200, user)
+}
+
+func (h *Handler) ScimCreateUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req scimUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, 400, "invalidSyntax", "Invalid request body")
+		return
+	}
+	var user models.ScimUser
+	if err := userFromScimRequest(req, &user); err != nil {
+		scimError(c, 400, "invalidValue", err.Error())
+		return
+	}
+
+	// Provisioned accounts without a password can only sign in after a
+	// password reset or through an external identity provider.
+	passwordHash := ""
+	if req.Password != "" {
+		hash, err := h.hashPassword(ctx, req.Password)
+		if err != nil {
+			scimError(c, 500, "", "Error hashing the password")
+			return
+		}
+		passwordHash = hash
+	}
+
+	user, err := h.Store.CreateOrgUser(ctx, c.GetInt("org_id"), user, passwordHash)
+	if err != nil {
+		h.scimDatabaseError(c, err, "User")
+		return
+	}
+	writeScimUser(c, 201, user)
+}
+
+func (h *Handler) ScimReplaceUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := h.loadScimUser(c)
+	if !ok || scimPreconditionFailed(c, user.Version) {
+		return
+	}
+	var req scimUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, 400, "invalidSyntax", "Invalid request body")
+		return
+	}
+	version := user.Version
+	if err := userFromScimRequest(req, &user); err != nil {
+		scimError(c, 400, "invalidValue", err.Error())
+		return
+	}
+	user, err := h.Store.UpdateOrgUser(ctx, c.GetInt("org_id"), user, version)
+	if err != nil {
+		h.scimDatabaseError(c, err, "User")
+		return
+	}
+	writeScimUser(c, 200, user)
+}
+
+// parseScimBool accepts both JSON booleans and the "True"/"False" strings
+// some identity providers send in PATCH operations.
+func parseScimBool(raw json.RawMessage) (bool, error) {
+	var value bool
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if parsed, err := strconv.ParseBool(text); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, invalidScimValue("expected a boolean")
+}
+
+func parseScimString(raw json.RawMessage) (string, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", invalidScimValue("expected a string")
+	}
+	return value, nil
+}
+
+func parseScimEmail(raw json.RawMessage) (string, error) {
+	if value, err := parseScimString(raw); err == nil {
+		return value, nil
+	}
+	var emails []scimEmail
+	if err := json.Unmarshal(raw, &emails); err != nil || len(emails) == 0 {
+		return "", invalidScimValue("expected a list of emails")
+	}
+	email := emails[0].Value
+	for _, candidate := range emails {
+		if candidate.Primary {
+			email = candidate.Value
+		}
+	}
+	return email, nil
+}
+
+func applyScimUserAttribute(user *models.ScimUser, op, attr string, raw json.RawMessage) error {
+	attr = strings.ToLower(attr)
+	if op == "remove" {
+		switch attr {
+		case "externalid":
+			user.ExternalId = ""
+			return nil
+		default:
+			return &scimPatchError{scimType: "mutability", detail: "attribute " + attr + " cannot be removed"}
+		}
+	}
+
+	switch {
+	case attr == "active":
+		active, err := parseScimBool(raw)
+		if err != nil {
+			return err
+		}
+		user.Active = active
+	case attr == "username" || strings.HasPrefix(attr, "emails"):
+		email, err := parseScimEmail(raw)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(email, "@") {
+			return invalidScimValue("userName must be an email address")
+		}
+		user.Email = email
+	case attr == "displayname" || attr == "name.formatted":
+		name, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		user.Name = name
+	case attr == "name":
+		var name scimName
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return invalidScimValue("expected a name object")
+		}
+		if name.Formatted != "" {
+			user.Name = name.Formatted
+		} else if full := strings.TrimSpace(name.GivenName + " " + name.FamilyName); full != "" {
+			user.Name = full
+		}
+	case attr == "name.givenname" || attr == "name.familyname":
+		part, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		given, family, _ := strings.Cut(user.Name, " ")
+		if attr == "name.givenname" {
+			given = part
+		} else {
+			family = part
+		}
+		user.Name = strings.TrimSpace(given + " " + family)
+	case attr == "externalid":
+		externalId, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		user.ExternalId = externalId
+	default:
+		return &scimPatchError{scimType: "invalidPath", detail: "unsupported attribute " + attr}
+	}
+	return nil
+}
+
+func applyScimUserPatch(user *models.ScimUser, operation scimPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return &scimPatchError{scimType: "invalidSyntax", detail: "unsupported op " + operation.Op}
+	}
+	if operation.Path != "" {
+		return applyScimUserAttribute(user, op, operation.Path, operation.Value)
+	}
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+		return &scimPatchError{scimType: "invalidSyntax", detail: "operation without path requires an object value"}
+	}
+	for attr, raw := range attributes {
+		if err := applyScimUserAttribute(user, op, attr, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bindScimPatch(c *gin.Context) (scimPatchRequest, bool) {
+	var req scimPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Operations) == 0 {
+		scimError(c, 400, "invalidSyntax", "Request must contain PatchOp operations")
+		return req, false
+	}
+	for _, schema := range req.Schemas {
+		if schema == scimPatchSchema {
+			return req, true
+		}
+	}
+	scimError(c, 400, "invalidSyntax", "Request must use the "+scimPatchSchema+" schema")
+	return req, false
+}
+
+func writeScimPatchError(c *gin.Context, err error) {
+	var patchErr *scimPatchError
+	if errors.As(err, &patchErr) {
+		scimError(c, 400, patchErr.scimType, patchErr.detail)
+		return
+	}
+	scimError(c, 400, "invalidValue", err.Error())
+}
+
+func (h *Handler) ScimPatchUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := h.loadScimUser(c)
+	if !ok || scimPreconditionFailed(c, user.Version) {
+		return
+	}
+	req, ok := bindScimPatch(c)
+	if !ok {
+		return
+	}
+	version := user.Version
+	for _, operation := range req.Operations {
+		if err := applyScimUserPatch(&user, operation); err != nil {
+			writeScimPatchError(c, err)
+			return
+		}
+	}
+	user, err := h.Store.UpdateOrgUser(ctx, c.GetInt("org_id"), user, version)
+	if err != nil {
+		h.scimDatabaseError(c, err, "User")
+		return
+	}
+	writeScimUser(c, 200, user)
+}
+
+func (h *Handler) ScimDeleteUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := h.loadScimUser(c)
+	if !ok || scimPreconditionFailed(c, user.Version) {
+		return
+	}
+	if err := h.Store.RemoveOrgUser(ctx, c.GetInt("org_id"), user.ID); err != nil {
+		h.scimDatabaseError(c, err, "User")
+		return
+	}
+	c.Status(204)
+}
+
+func (h *Handler) ScimListGroups(c *gin.Context) {
+	ctx := c.Request.Context()
+	conds, err := ParseScimFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, 400, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := scimPagination(c)
+
+	groups, total, err := h.Store.ListGroups(ctx, c.GetInt("org_id"), conds, startIndex-1, count)
+	if err != nil {
+		h.scimDatabaseError(c, err, "Group")
+		return
+	}
+	resources := make([]gin.H, len(groups))
+	for i, group := range groups {
+		resources[i] = scimGroupResource(group)
+	}
+	scimListResponse(c, resources, total, startIndex)
+}
+
+func (h *Handler) loadScimGroup(c *gin.Context) (models.Group, bool) {
+	ctx := c.Request.Context()
+	groupId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, 404, "", "Group not found")
+		return models.Group{}, false
+	}
+	group, err := h.Store.GetGroup(ctx, c.GetInt("org_id"), groupId)
+	if err != nil {
+		h.scimDatabaseError(c, err, "Group")
+		return models.Group{}, false
+	}
+	return group, true
+}
+
+func scimMembers(refs []scimMemberRef) ([]models.GroupMember, error) {
+	members := make([]models.GroupMember, 0, len(refs))
+	for _, ref := range refs {
+		userId, err := strconv.Atoi(ref.Value)
+		if err != nil {
+			return nil, invalidScimValue("invalid member id %q", ref.Value)
+		}
+		members = append(members, models.GroupMember{UserId: userId})
+	}
+	return members, nil
+}
+
+func groupFromScimRequest(req scimGroupRequest, group *models.Group) error {
+	if strings.TrimSpace(req.DisplayName) == "" {
+		return invalidScimValue("displayName is required")
+	}
+	members, err := scimMembers(req.Members)
+	if err != nil {
+		return err
+	}
+	group.DisplayName = req.DisplayName
+	group.ExternalId = req.ExternalId
+	group.Members = members
+	return nil
+}
+
+func (h *Handler) ScimGetGroup(c *gin.Context) {
+	group, ok := h.loadScimGroup(c)
+	if !ok {
+		return
+	}
+	if scimNotModified(c, group.Version) {
+		return
+	}
+	writeScimGroup(c, 200, group)
+}
+
+func (h *Handler) ScimCreateGroup(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req scimGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, 400, "invalidSyntax", "Invalid request body")
+		return
+	}
+	var group models.Group
+	if err := groupFromScimRequest(req, &group); err != nil {
+		writeScimPatchError(c, err)
+		return
+	}
+	group, err := h.Store.CreateGroup(ctx, c.GetInt("org_id"), group)
+	if err != nil {
+		h.scimDatabaseError(c, err, "Group")
+		return
+	}
+	writeScimGroup(c, 201, group)
+}
+
+func (h *Handler) ScimReplaceGroup(c *gin.Context) {
+	ctx := c.Request.Context()
+	group, ok := h.loadScimGroup(c)
+	if !ok || scimPreconditionFailed(c, group.Version) {
+		return
+	}
+	var req scimGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, 400, "invalidSyntax", "Invalid request body")
+		return
+	}
+	version := group.Version
+	if err := groupFromScimRequest(req, &group); err != nil {
+		writeScimPatchError(c, err)
+		return
+	}
+	group, err := h.Store.UpdateGroup(ctx, c.GetInt("org_id"), group, version)
+	if err != nil {
+		h.scimDatabaseError(c, err, "Group")
+		return
+	}
+	writeScimGroup(c, 200, group)
+}
+
+func removeGroupMembers(group *models.Group, userIds map[int]bool) {
+	kept := group.Members[:0]
+	for _, member := range group.Members {
+		if !userIds[member.UserId] {
+			kept = append(kept, member)
+		}
+	}
+	group.Members = kept
+}
+
+func applyScimGroupAttribute(group *models.Group, op, attr string, raw json.RawMessage) error {
+	if userId, ok := parseMemberValuePath(attr); ok {
+		if op != "remove" {
+			return &scimPatchError{scimType: "invalidPath", detail: "member filters are only supported for remove"}
+		}
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			return invalidScimValue("invalid member id %q", userId)
+		}
+		removeGroupMembers(group, map[int]bool{id: true})
+		return nil
+	}
+
+	switch strings.ToLower(attr) {
+	case "displayname":
+		if op == "remove" {
+			return &scimPatchError{scimType: "mutability", detail: "displayName cannot be removed"}
+		}
+		name, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		group.DisplayName = name
+	case "externalid":
+		if op == "remove" {
+			group.ExternalId = ""
+			return nil
+		}
+		externalId, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		group.ExternalId = externalId
+	case "members":
+		var refs []scimMemberRef
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &refs); err != nil {
+				return invalidScimValue("members must be a list")
+			}
+		}
+		members, err := scimMembers(refs)
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "add":
+			group.Members = append(group.Members, members...)
+		case "replace":
+			group.Members = members
+		case "remove":
+			if len(members) == 0 {
+				group.Members = nil
+				return nil
+			}
+			ids := make(map[int]bool, len(members))
+			for _, member := range members {
+				ids[member.UserId] = true
+			}
+			removeGroupMembers(group, ids)
+		}
+	default:
+		return &scimPatchError{scimType: "invalidPath", detail: "unsupported attribute " + attr}
+	}
+	return nil
+}
+
+func applyScimGroupPatch(group *models.Group, operation scimPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return &scimPatchError{scimType: "invalidSyntax", detail: "unsupported op " + operation.Op}
+	}
+	if operation.Path != "" {
+		return applyScimGroupAttribute(group, op, operation.Path, operation.Value)
+	}
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+		return &scimPatchError{scimType: "invalidSyntax", detail: "operation without path requires an object value"}
+	}
+	for attr, raw := range attributes {
+		if err := applyScimGroupAttribute(group, op, attr, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) ScimPatchGroup(c *gin.Context) {
+	ctx := c.Request.Context()
+	group, ok := h.loadScimGroup(c)
+	if !ok || scimPreconditionFailed(c, group.Version) {
+		return
+	}
+	req, ok := bindScimPatch(c)
+	if !ok {
+		return
+	}
+	version := group.Version
+	for _, operation := range req.Operations {
+		if err := applyScimGroupPatch(&group, operation); err != nil {
+			writeScimPatchError(c, err)
+			return
+		}
+	}
+	group, err := h.Store.UpdateGroup(ctx, c.GetInt("org_id"), group, version)
+	if err != nil {
+		h.scimDatabaseError(c, err, "Group")
+		return
+	}
+	writeScimGroup(c, 200, group)
+}
+
+func (h *Handler) ScimDeleteGroup(c *gin.Context) {
+	ctx := c.Request.Context()
+	group, ok := h.loadScimGroup(c)
+	if !ok || scimPreconditionFailed(c, group.Version) {
+		return
+	}
+	if err := h.Store.DeleteGroup(ctx, c.GetInt("org_id"), group.ID); err != nil {
+		h.scimDatabaseError(c, err, "Group")
+		return
+	}
+	c.Status(204)
+}
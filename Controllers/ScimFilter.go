@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"fmt"
+	models "go_server/Models"
+	"strings"
+)
+
+var scimFilterOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+// tokenizeScimFilter splits a filter expression on whitespace while keeping
+// double-quoted strings (with backslash escapes) together as one token.
+func tokenizeScimFilter(filter string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	quoted := false
+
+	for i := 0; i < len(filter); i++ {
+		ch := filter[i]
+		switch {
+		case inQuotes && ch == '\\' && i+1 < len(filter):
+			i++
+			current.WriteByte(filter[i])
+		case ch == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case !inQuotes && (ch == ' ' || ch == '\t'):
+			if current.Len() > 0 || quoted {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				quoted = false
+			}
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated string in filter")
+	}
+	if current.Len() > 0 || quoted {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// ParseScimFilter parses the subset of RFC 7644 filters supported by this
+// server: attribute comparisons joined with "and". Grouping, "or" and "not"
+// are rejected so clients get an explicit invalidFilter error instead of a
+// silently wrong result.
+func ParseScimFilter(filter string) ([]models.FilterCondition, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, nil
+	}
+	tokens, err := tokenizeScimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var conds []models.FilterCondition
+	for i := 0; i < len(tokens); {
+		if len(conds) > 0 {
+			if !strings.EqualFold(tokens[i], "and") {
+				return nil, fmt.Errorf("unsupported logical operator %q", tokens[i])
+			}
+			i++
+		}
+		if i+1 >= len(tokens) {
+			return nil, fmt.Errorf("incomplete filter expression")
+		}
+		attr := tokens[i]
+		if strings.ContainsAny(attr, "()[]") {
+			return nil, fmt.Errorf("grouping is not supported")
+		}
+		op := strings.ToLower(tokens[i+1])
+		if !scimFilterOperators[op] {
+			return nil, fmt.Errorf("unsupported operator %q", tokens[i+1])
+		}
+		if op == "pr" {
+			conds = append(conds, models.FilterCondition{Attr: attr, Op: op})
+			i += 2
+			continue
+		}
+		if i+2 >= len(tokens) {
+			return nil, fmt.Errorf("missing value for %s %s", attr, op)
+		}
+		conds = append(conds, models.FilterCondition{Attr: attr, Op: op, Value: tokens[i+2]})
+		i += 3
+	}
+	return conds, nil
+}
+
+// parseMemberValuePath extracts the user id from a PATCH path such as
+// members[value eq "42"].
+func parseMemberValuePath(path string) (string, bool) {
+	open := strings.Index(path, "[")
+	if open < 0 || !strings.HasSuffix(path, "]") || !strings.EqualFold(path[:open], "members") {
+		return "", false
+	}
+	conds, err := ParseScimFilter(path[open+1 : len(path)-1])
+	if err != nil || len(conds) != 1 || !strings.EqualFold(conds[0].Attr, "value") || conds[0].Op != "eq" {
+		return "", false
+	}
+	return conds[0].Value, true
+}
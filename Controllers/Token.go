@@ -2,37 +2,29 @@ package controller
 
 import (
 	"database/sql"
-	database "go_server/Database"
+	apierror "go_server/ApiError"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetToken(c *gin.Context) {
+func (h *Handler) GetToken(c *gin.Context) {
+	ctx := c.Request.Context()
 	id := c.Param("id")
 	tokenId,err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Invalid token ID",
-		})
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid token ID"))
 		return
 	}
 	// get the token from the database
-	userToken , err := database.GetTokenById(tokenId)
+	userToken , err := h.Store.GetTokenById(ctx, tokenId)
 	if err != nil {
 		if(err == sql.ErrNoRows){
-			c.JSON(404, gin.H{
-				"status":  "error",
-				"message": "Token not found",
-			})
+			apierror.Abort(c, apierror.NotFound("Token not found"))
 			return
 		}
 
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error getting the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error getting the token", err))
 		return
 	}
 	c.JSON(200, gin.H{
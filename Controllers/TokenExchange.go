@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "go_server/ApiError"
+	metrics "go_server/Metrics"
+	models "go_server/Models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// TokenTypeAccessToken is the only token type the exchange takes and
+	// issues.
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// ActorClaim names the app that obtained an exchanged token (RFC 8693
+// section 4.1). When that app itself held an exchanged token, Act names
+// the one before it.
+type ActorClaim struct {
+	Subject string      `json:"sub"`
+	Act     *ActorClaim `json:"act,omitempty"`
+}
+
+// tokenExchangeGrant trades the access token a user holds for the calling
+// app for a token of another app, the audience, so the caller can call it
+// on the user's behalf. The audience must allow the caller with an
+// exchange policy, and the new token only gets scopes of that policy.
+func (h *Handler) tokenExchangeGrant(c *gin.Context, req models.TokenRequest) {
+	ctx := c.Request.Context()
+	client, ok := h.authenticateClient(c, req)
+	if !ok {
+		return
+	}
+	clientId := strconv.Itoa(client.AppId)
+
+	if req.SubjectToken == "" || req.Audience == "" {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "subject_token and audience are required"))
+		return
+	}
+	if req.SubjectTokenType != TokenTypeAccessToken {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Only access tokens can be exchanged"))
+		return
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Only access tokens can be issued"))
+		return
+	}
+
+	// the subject token must be a user's token for the calling app
+	invalidSubject := apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid subject token")
+	subject, err := VerifyTypedToken(h.Tokens, AccessTokenType, req.SubjectToken, &AcessTokenClaim{})
+	if err != nil || subject.Id == 0 || subject.AppId != client.AppId {
+		apierror.Abort(c, invalidSubject)
+		return
+	}
+	if len(subject.Audience) > 0 && !slices.Contains(subject.Audience, clientId) {
+		apierror.Abort(c, invalidSubject)
+		return
+	}
+	changedAt, err := h.Store.GetPasswordChangedAt(ctx, subject.Id)
+	if err != nil || (subject.IssuedAt != nil && subject.IssuedAt.Time.Before(changedAt.Truncate(time.Second))) {
+		apierror.Abort(c, invalidSubject)
+		return
+	}
+
+	policy, ok := h.exchangePolicy(c, client.AppId, req.Audience)
+	if !ok {
+		return
+	}
+	audience := policy.TargetAppId
+
+	// the scopes can only narrow: those of the policy, and of the subject
+	// token when it was exchanged itself
+	allowed := policy.Scopes
+	if subject.Scope != "" {
+		subjectScopes := strings.Fields(subject.Scope)
+		allowed = slices.DeleteFunc(slices.Clone(allowed), func(scope string) bool {
+			return !slices.Contains(subjectScopes, scope)
+		})
+	}
+	scopes := strings.Fields(req.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidScope, "The scope "+scope+" cannot be obtained for the audience"))
+			return
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+	scope := strings.Join(scopes, " ")
+
+	// the new token does not outlive the one it was exchanged for
+	now := time.Now()
+	expiresAt := now.Add(h.Config.Auth.AccessTokenTTL.Duration)
+	if subject.ExpiresAt != nil && subject.ExpiresAt.Time.Before(expiresAt) {
+		expiresAt = subject.ExpiresAt.Time
+	}
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
+		return
+	}
+	accessToken, err := h.Tokens.GenerateTyped(AccessTokenType, AcessTokenClaim{
+		Id:       subject.Id,
+		Name:     subject.Name,
+		Email:    subject.Email,
+		AppId:    audience,
+		AuthTime: subject.AuthTime,
+		Scope:    scope,
+		Act:      &ActorClaim{Subject: clientId, Act: subject.Act},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    h.Tokens.Issuer(),
+			Audience:  jwt.ClaimStrings{strconv.Itoa(audience)},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
+		return
+	}
+	metrics.TokensIssued.Inc("access")
+
+	writeTokenResponse(c, models.TokenResponse{
+		AccessToken:     accessToken,
+		IssuedTokenType: TokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int(expiresAt.Sub(now).Seconds()),
+		Scope:           scope,
+	})
+}
+
+// exchangePolicy loads the policy of the audience allowing the calling
+// app, writing invalid_target when there is none.
+func (h *Handler) exchangePolicy(c *gin.Context, sourceAppId int, audience string) (models.ExchangePolicy, bool) {
+	invalidTarget := apierror.BadRequest(apierror.CodeInvalidTarget, "This client may not obtain tokens for the audience")
+	targetAppId, err := strconv.Atoi(audience)
+	if err != nil {
+		apierror.Abort(c, invalidTarget)
+		return models.ExchangePolicy{}, false
+	}
+	policy, err := h.Store.GetExchangePolicy(c.Request.Context(), sourceAppId, targetAppId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, invalidTarget)
+			return models.ExchangePolicy{}, false
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the exchange policy", err))
+		return models.ExchangePolicy{}, false
+	}
+	return policy, true
+}
+
+// getPolicySource loads the app in the :sourceId path parameter, writing
+// the error response when there is none.
+func (h *Handler) getPolicySource(c *gin.Context) (int, bool) {
+	sourceId, err := strconv.Atoi(c.Param("sourceId"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid app ID"))
+		return 0, false
+	}
+	if _, err := h.Store.GetAppById(c.Request.Context(), sourceId); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("App not found"))
+			return 0, false
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the app", err))
+		return 0, false
+	}
+	return sourceId, true
+}
+
+func (h *Handler) GetExchangePolicies(c *gin.Context) {
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	policies, err := h.Store.GetExchangePolicies(c.Request.Context(), app.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the exchange policies", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   policies,
+	})
+}
+
+// SetExchangePolicy lets another app exchange its users' tokens for
+// tokens of this app with the given scopes.
+func (h *Handler) SetExchangePolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	sourceId, ok := h.getPolicySource(c)
+	if !ok {
+		return
+	}
+	var req models.ExchangePolicyRequest
+	if !bind(c, &req) {
+		return
+	}
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if err := h.Store.SetExchangePolicy(ctx, app.ID, app.UserId, sourceId, scopes); err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the exchange policy", err))
+		return
+	}
+	policy, err := h.Store.GetExchangePolicy(ctx, sourceId, app.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the exchange policy", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   policy,
+	})
+}
+
+func (h *Handler) DeleteExchangePolicy(c *gin.Context) {
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	sourceId, err := strconv.Atoi(c.Param("sourceId"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid app ID"))
+		return
+	}
+	if err := h.Store.DeleteExchangePolicy(c.Request.Context(), app.ID, app.UserId, sourceId); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("Exchange policy not found"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Error deleting the exchange policy", err))
+		return
+	}
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": "Exchange policy deleted",
+	})
+}
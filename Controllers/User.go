@@ -1,19 +1,25 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	database "go_server/Database"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
+	apierror "go_server/ApiError"
+	metrics "go_server/Metrics"
 	models "go_server/Models"
+	services "go_server/Services"
+	tracing "go_server/Tracing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type LoginResponse struct {
@@ -27,301 +33,277 @@ type LoginResponse struct {
 }
 
 type AcessTokenClaim struct {
-	Id                   int    `json:"id"`
-	Name                 string `json:"name"`
-	Email                string `json:"email"`
-	jwt.RegisteredClaims        // This embeds the standard claims like exp, iat, etc.
+	Id                   int              `json:"id"`
+	Name                 string           `json:"name"`
+	Email                string           `json:"email"`
+	AppId                int              `json:"app_id,omitempty"`    // the app the session belongs to, 0 for the dashboard
+	AuthTime             *jwt.NumericDate `json:"auth_time,omitempty"` // when the user last entered credentials
+	Scope                string           `json:"scope,omitempty"`     // the scopes of an exchanged token
+	Act                  *ActorClaim      `json:"act,omitempty"`       // the apps an exchanged token was obtained by
+	jwt.RegisteredClaims                  // This embeds the standard claims like exp, iat, etc.
 }
 
 type RefreshTokenClaim struct {
-	Id                   int `json:"id"`
-	jwt.RegisteredClaims     // This embeds the standard claims like exp, iat, etc.
+	Id                   int              `json:"id"`
+	AuthTime             *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims                  // This embeds the standard claims like exp, iat, etc.
 }
 
-// HashPassword generates a bcrypt hash of the password
-func HashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// issueTokens signs an access and refresh token pair. authTime is when the
+// user last authenticated; refreshing carries it over unchanged so a
+// session cannot pass as recently authenticated by refreshing alone.
+func (h *Handler) issueTokens(user models.User, appId int, authTime time.Time) (string, string, error) {
+	now := time.Now()
+	// the token of an app login is meant for that app, so services of
+	// other apps refuse it; dashboard tokens have no audience
+	var audience jwt.ClaimStrings
+	if appId != 0 {
+		audience = jwt.ClaimStrings{strconv.Itoa(appId)}
+	}
+	accessToken, err := h.Tokens.GenerateTyped(AccessTokenType, AcessTokenClaim{
+		Id:       user.ID,
+		Name:     user.Name,
+		Email:    user.Email,
+		AppId:    appId,
+		AuthTime: jwt.NewNumericDate(authTime),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    h.Tokens.Issuer(),
+			Audience:  audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.Config.Auth.AccessTokenTTL.Duration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	return string(hashedBytes), nil
+	// a random ID keeps a rotated refresh token distinct from the one it
+	// replaces even when both are issued within the same second
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := h.Tokens.Generate(RefreshTokenClaim{
+		Id:       user.ID,
+		AuthTime: jwt.NewNumericDate(authTime),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    h.Tokens.Issuer(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.Config.Auth.RefreshTokenTTL.Duration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	metrics.TokensIssued.Inc("access")
+	metrics.TokensIssued.Inc("refresh")
+	return accessToken, refreshToken, nil
 }
 
-// CheckPasswordHash compares a hashed password with a plain text password
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// hashPassword hashes a new password with the configured algorithm
+func (h *Handler) hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "password.hash")
+	hash, err := h.Passwords.Hasher.Hash(password)
+	tracing.End(span, err)
+	return hash, err
 }
 
-func SignUp(c *gin.Context) {
-
-	// get the email and password from the request
-	email := c.PostForm("email")
-	password := c.PostForm("password")
-	name := c.PostForm("name")
-	appid := c.PostForm("app_id")
-
-	// check if the email and password are empty
-	if email == "" || password == "" {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Email and Password are required",
-		})
-		return
+// rejectWeakPassword checks a new password against the password policy and
+// the breached password list. On failure it answers 400 with every
+// violation so the frontend can show them all at once.
+func (h *Handler) rejectWeakPassword(c *gin.Context, password, email, name string) bool {
+	violations := h.Passwords.CheckNew(password, email, name)
+	if len(violations) == 0 {
+		return false
 	}
+	apierror.Abort(c, apierror.BadRequest(apierror.CodeWeakPassword, "Password does not meet the requirements").With("errors", violations))
+	return true
+}
 
-	// check if the email exists in the database
+// checkPasswordHash compares a stored hash of any supported format with a
+// plain text password
+func (h *Handler) checkPasswordHash(ctx context.Context, password, hash string) bool {
+	_, span := tracer.Start(ctx, "password.verify")
+	ok, err := h.Passwords.Hasher.Verify(password, hash)
+	tracing.End(span, err)
+	return err == nil && ok
+}
 
-	isUser := database.CheckIfUserExists(email)
-	if isUser {
-		c.JSON(404, gin.H{
-			"status":  "error",
-			"message": "user already exists",
-		})
+// rehashPassword upgrades a hash made with an outdated algorithm or cost
+// once the plain text password is known, i.e. right after a login. Failures
+// are only logged; the old hash keeps working.
+func (h *Handler) rehashPassword(ctx context.Context, userId int, password, hash string) {
+	hasher := h.Passwords.Hasher
+	if !hasher.NeedsRehash(hash) {
 		return
 	}
-
-	// hash the password
-	hash, err := HashPassword(password)
+	newHash, err := h.hashPassword(ctx, password)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error hashing the password",
-		})
+		h.Log.ErrorContext(ctx, "rehashing the password", "user_id", userId, "err", err)
 		return
 	}
-
-	// insert the user into the database
-	id, err := database.InsertUser(email, name, hash)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the user",
-		})
-		return
+	if err := h.Store.UpdatePasswordHash(ctx, userId, hash, newHash); err != nil {
+		h.Log.ErrorContext(ctx, "storing the rehashed password", "user_id", userId, "err", err)
 	}
+}
 
-	// generate a jwt token
-
-	AccessClaim := AcessTokenClaim{
-		Id:    id,
-		Name:  name,
-		Email: email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	RefreshClaim := RefreshTokenClaim{
-		Id: id,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 5 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	token, err := GenerateToken(AccessClaim)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
-		return
+// loginFailed counts a rejected login. The app is only used as a label when
+// it exists, so made up app ids cannot flood the metrics with series.
+func (h *Handler) loginFailed(ctx context.Context, method string, appId int) {
+	app := "none"
+	if appId != 0 {
+		if _, err := h.Store.GetAppById(ctx, appId); err == nil {
+			app = strconv.Itoa(appId)
+		}
 	}
-	refreshToken, err := GenerateToken(RefreshClaim)
+	metrics.Logins.Inc(method, app, "failure")
+}
+
+// completeLogin issues an access and refresh token for an authenticated
+// user and writes the login response. When appId is not 0 the tokens
+// are stored for the token_id exchange, a session with the app is opened and
+// the app is notified with the given webhook event.
+func (h *Handler) completeLogin(c *gin.Context, user models.User, appId int, event, method string) {
+	ctx := c.Request.Context()
+	token, refreshToken, err := h.issueTokens(user, appId, time.Now())
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
 		return
 	}
 
-	appIdInt, err := strconv.Atoi(appid)
-	if err != nil {
+	data := models.TokenSet{
+		TokenPair: models.TokenPair{Token: token, RefreshToken: refreshToken},
+		Id:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+	}
+	if appId == 0 {
+		metrics.Logins.Inc(method, "none", "success")
 		c.JSON(200, gin.H{
 			"status": "success",
-			"data": gin.H{
-				"token":         token,
-				"refresh_token": refreshToken,
-				"id":            id,
-				"email":         email,
-			},
+			"data":   data,
 		})
 		return
 	}
 
-	tokenId, err := database.InsertToken(appIdInt, token, refreshToken)
+	data.TokenId, err = h.Store.InsertToken(ctx, appId, token, refreshToken)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error inserting the access token", err))
 		return
 	}
-
-	err = database.InsertOrUpdateSession(id, appIdInt, refreshToken)
+	err = h.Store.InsertOrUpdateSession(ctx, user.ID, appId, refreshToken)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error inserting the refresh token", err))
 		return
 	}
+	h.emitWebhookEvent(c, []int{appId}, event, userEventData(user.ID, user.Email, user.Name, method))
+	metrics.Logins.Inc(method, strconv.Itoa(appId), "success")
 
 	// send the response
 	c.JSON(200, gin.H{
 		"status": "success",
-		"data": gin.H{
-			"token":         token,
-			"refresh_token": refreshToken,
-			"token_id":      tokenId,
-			"id":            id,
-			"email":         email,
-		},
+		"data":   data,
 	})
 }
 
-func Login(c *gin.Context) {
-
-	// get email and password from the request
-	email := c.PostForm("email")
-	password := c.PostForm("password")
-	appId := c.PostForm("app_id")
+func (h *Handler) SignUp(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !h.Config.Features.SignUp {
+		apierror.Abort(c, apierror.New(404, apierror.CodeFeatureDisabled, "Sign up is disabled"))
+		return
+	}
 
-	// check if the email and password are empty
-	if email == "" || password == "" {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Email and Password are required",
-		})
+	var req models.SignUpRequest
+	if !bind(c, &req) {
 		return
 	}
+	email, password, name := req.Email, req.Password, req.Name
 
 	// check if the email exists in the database
-	user, err := database.GetUserByEmail(email)
-	if err != nil {
-		c.JSON(404, gin.H{
-			"status":  "error",
-			"message": "user not found",
-		})
+
+	isUser := h.Store.CheckIfUserExists(ctx, email)
+	if isUser {
+		apierror.Abort(c, apierror.Conflict(apierror.CodeUserExists, "User already exists"))
 		return
 	}
-
-	if user.Password == "GOOGLE" {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "User logged in with google",
-		})
+	if h.rejectWeakPassword(c, password, email, name) {
 		return
 	}
 
-	// check if the password is correct
-	if !CheckPasswordHash(password, user.Password) {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "invalid password",
-		})
+	// hash the password
+	hash, err := h.hashPassword(ctx, password)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error hashing the password", err))
 		return
 	}
 
-	// generate a jwt token
-
-	AccessClaim := AcessTokenClaim{
-		Id:    user.ID,
-		Name:  user.Name,
-		Email: email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	RefreshClaim := RefreshTokenClaim{
-		Id: user.ID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 5 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	token, err := GenerateToken(AccessClaim)
+	// insert the user into the database
+	id, err := h.Store.InsertUser(ctx, email, name, hash)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error inserting the user", err))
 		return
 	}
-	refreshToken, err := GenerateToken(RefreshClaim)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
+
+	user := models.User{ID: id, Name: name, Email: email, Active: true}
+	h.completeLogin(c, user, req.AppId, services.EventUserSignedUp, "password")
+}
+
+func (h *Handler) Login(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.LoginRequest
+	if !bind(c, &req) {
 		return
 	}
-	appIdInt, err := strconv.Atoi(appId)
+	email, password, appId := req.Email, req.Password, req.AppId
+
+	// check if the email exists in the database
+	user, err := h.Store.GetUserByEmail(ctx, email)
 	if err != nil {
-		c.JSON(200, gin.H{
-			"status": "success",
-			"data": gin.H{
-				"token":         token,
-				"refresh_token": refreshToken,
-				"id":            user.ID,
-				"email":         email,
-				"name":          user.Name,
-			},
-		})
+		h.loginFailed(ctx, "password", appId)
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidCredentials, "Invalid email or password"))
 		return
 	}
 
-	tokenId, err := database.InsertToken(appIdInt, token, refreshToken)
-	if err != nil {
-		fmt.Println(err)
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the access token",
-		})
+	if !user.Active {
+		h.loginFailed(ctx, "password", appId)
+		apierror.Abort(c, apierror.Forbidden(apierror.CodeAccountDisabled, "User is deactivated"))
 		return
 	}
-	err = database.InsertOrUpdateSession(user.ID, appIdInt, refreshToken)
-	if err != nil {
-		fmt.Println(err)
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the refresh token",
-		})
+
+	if user.Password == "GOOGLE" {
+		h.loginFailed(ctx, "password", appId)
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodePasswordLoginUnavailable, "This account signs in with Google"))
 		return
 	}
 
-	// send the response
-	c.JSON(200, gin.H{
-		"status": "success",
-		"data": gin.H{
-			"token":         token,
-			"token_id":      tokenId,
-			"refresh_token": refreshToken,
-			"id":            user.ID,
-			"email":         email,
-			"name":          user.Name,
-		},
-	})
+	// check if the password is correct
+	if !h.checkPasswordHash(ctx, password, user.Password) {
+		h.loginFailed(ctx, "password", appId)
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidCredentials, "Invalid email or password"))
+		return
+	}
+	h.rehashPassword(ctx, user.ID, password, user.Password)
 
+	h.completeLogin(c, user, appId, services.EventUserLoggedIn, "password")
 }
 
 // Google token info endpoint
 const googleTokenInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
 
-func VerifyGoogleToken(token string) (models.GoogleUser, error) {
+var googleClient = &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+
+func VerifyGoogleToken(ctx context.Context, token string) (models.GoogleUser, error) {
 	var user models.GoogleUser
 
 	// Make a request to Google's token verification endpoint
-	req, err := http.NewRequest("GET", googleTokenInfoURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", googleTokenInfoURL, nil)
 	if err != nil {
 		return user, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := googleClient.Do(req)
 	if err != nil {
 		return user, fmt.Errorf("failed to verify token: %w", err)
 	}
@@ -345,300 +327,297 @@ func VerifyGoogleToken(token string) (models.GoogleUser, error) {
 	return user, nil
 }
 
-func ContinueWithGoogle(c *gin.Context) {
-	GoogleToken := c.PostForm("google_token")
-	if GoogleToken == "" {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Google token is required",
-		})
+func (h *Handler) ContinueWithGoogle(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !h.Config.Features.GoogleLogin {
+		apierror.Abort(c, apierror.New(404, apierror.CodeFeatureDisabled, "Google login is disabled"))
+		return
+	}
+	var req models.GoogleLoginRequest
+	if !bind(c, &req) {
 		return
 	}
 	// verify the google token
-	googleUser, err := VerifyGoogleToken(GoogleToken)
+	googleUser, err := h.VerifyGoogle(ctx, req.GoogleToken)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Invalid google token",
-		})
+		h.loginFailed(ctx, "google", req.AppId)
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidToken, "Invalid google token"))
 		return
 	}
 	// check if the user exists in the database
-	user, err := database.GetUserByEmail(googleUser.Email)
+	event := services.EventUserLoggedIn
+	user, err := h.Store.GetUserByEmail(ctx, googleUser.Email)
 	if err != nil {
+		event = services.EventUserSignedUp
 		// if the user does not exist, create a new user
-		id, err := database.InsertUser(googleUser.Email, googleUser.Name, "GOOGLE")
+		id, err := h.Store.InsertUser(ctx, googleUser.Email, googleUser.Name, "GOOGLE")
 		if err != nil {
-			c.JSON(500, gin.H{
-				"status":  "error",
-				"message": "Error inserting the user",
-			})
+			apierror.Abort(c, apierror.Internal("Error inserting the user", err))
 			return
 		}
 		user = models.User{
-			ID:    id,
-			Name:  googleUser.Name,
-			Email: googleUser.Email,
+			ID:     id,
+			Name:   googleUser.Name,
+			Email:  googleUser.Email,
+			Active: true,
 		}
 	}
-	// generate a jwt token
-	AccessClaim := AcessTokenClaim{
-		Id:    user.ID,
-		Name:  googleUser.Name,
-		Email: googleUser.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	if !user.Active {
+		h.loginFailed(ctx, "google", req.AppId)
+		apierror.Abort(c, apierror.Forbidden(apierror.CodeAccountDisabled, "User is deactivated"))
+		return
 	}
-	RefreshClaim := RefreshTokenClaim{
-		Id: user.ID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 5 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	user.Name = googleUser.Name
+	user.Email = googleUser.Email
+	h.completeLogin(c, user, req.AppId, event, "google")
+}
+
+// requireDashboardToken refuses access tokens issued to an app. An app
+// holds the user's token to call its own APIs with; it must not act on the
+// account itself. action completes "Tokens issued to an app cannot ...".
+func requireDashboardToken(c *gin.Context, action string) bool {
+	audience, _ := c.Get("audience")
+	if aud, _ := audience.([]string); c.GetInt("app_id") != 0 || len(aud) > 0 {
+		apierror.Abort(c, apierror.Forbidden(apierror.CodeForbidden, "Tokens issued to an app cannot "+action))
+		return false
 	}
-	token, err := GenerateToken(AccessClaim)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
+	return true
+}
+
+// requireRecentAuth asks the user to log in again when they last entered
+// credentials longer than auth.recent_auth_max_age ago.
+func (h *Handler) requireRecentAuth(c *gin.Context, message string) bool {
+	authTime, _ := c.Get("auth_time")
+	if at, ok := authTime.(time.Time); !ok || time.Since(at) > h.Config.Auth.RecentAuthMaxAge.Duration {
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeReauthenticationRequired, message))
+		return false
+	}
+	return true
+}
+
+// ChangePassword changes the password of the logged in user. It requires a
+// recent login and the current password, revokes every other session and
+// returns fresh tokens for the current one.
+func (h *Handler) ChangePassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.GetInt("id")
+	appId := c.GetInt("app_id")
+	var req models.ChangePasswordRequest
+	if !bind(c, &req) {
+		return
+	}
+	oldPassword, newPassword := req.OldPassword, req.NewPassword
+
+	if !h.requireRecentAuth(c, "Please log in again to change your password") {
 		return
 	}
-	refreshToken, err := GenerateToken(RefreshClaim)
+
+	user, err := h.Store.GetUserById(ctx, id)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidToken, "Invalid token"))
+		return
+	}
+	// check if the old password is correct
+	if !h.checkPasswordHash(ctx, oldPassword, user.Password) {
+		apierror.Abort(c, apierror.Forbidden(apierror.CodeInvalidCredentials, "Invalid password"))
 		return
 	}
-	appId := c.PostForm("app_id")
-	appIdInt, err := strconv.Atoi(appId)
+	if h.rejectWeakPassword(c, newPassword, user.Email, user.Name) {
+		return
+	}
+
+	// hash the new password
+	hashedPassword, err := h.hashPassword(ctx, newPassword)
 	if err != nil {
-		c.JSON(200, gin.H{
-			"status": "success",
-			"data": gin.H{
-				"token":         token,
-				"refresh_token": refreshToken,
-				"id":            user.ID,
-				"email":         user.Email,
-				"name":          user.Name,
-			},
-		})
+		apierror.Abort(c, apierror.Internal("Error hashing the password", err))
 		return
 	}
-	tokenId, err := database.InsertToken(appIdInt, token, refreshToken)
+
+	appIds, err := h.Store.GetAppIdsOfUserSessions(ctx, id)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the token",
-		})
+		h.Log.ErrorContext(c.Request.Context(), "loading sessions for webhook event", "err", err)
+	}
+	// update the password and revoke every other session
+	err = h.Store.ChangePassword(ctx, id, appId, hashedPassword)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the password", err))
 		return
 	}
-	err = database.InsertOrUpdateSession(user.ID, appIdInt, refreshToken)
+
+	// tokens issued before the change are rejected from now on, so the
+	// current session continues with a new pair
+	token, refreshToken, err := h.issueTokens(user, appId, time.Now())
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error inserting the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
 		return
 	}
+	if appId != 0 {
+		if err := h.Store.InsertOrUpdateSession(ctx, id, appId, refreshToken); err != nil {
+			apierror.Abort(c, apierror.Internal("Error updating the session", err))
+			return
+		}
+	}
+
+	h.emitWebhookEvent(c, appIds, services.EventUserPasswordChanged, userEventData(user.ID, user.Email, user.Name, "change_password"))
+	if err := services.SendPasswordChangedEmail(ctx, h.Store, user.Email, emailLocale(c, req.Locale), h.appBranding(ctx, appId)); err != nil {
+		h.Log.ErrorContext(c.Request.Context(), "queueing password changed email", "err", err)
+	}
+
 	// send the response
 	c.JSON(200, gin.H{
-		"status": "success",
-		"data": gin.H{
-			"token":         token,
-			"refresh_token": refreshToken,
-			"token_id":      tokenId,
-			"id":            user.ID,
-			"email":         user.Email,
-			"name":          user.Name,
-		},
+		"status":  "success",
+		"message": "Password changed successfully",
+		"data":    models.TokenPair{Token: token, RefreshToken: refreshToken},
 	})
 }
 
-func ChangePassword(c *gin.Context) {
-	// get the token from the request
-	id := c.PostForm("id")
-	oldPassword := c.PostForm("old_password")
-	newPassword := c.PostForm("new_password")
-	// check if the token is valid
-	user, err := database.GetUserById(id)
-	if err != nil {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Invalid token",
-		})
+// userEventData is the payload of user lifecycle webhook events.
+func userEventData(id int, email, name, method string) gin.H {
+	return gin.H{
+		"user_id": id,
+		"email":   email,
+		"name":    name,
+		"method":  method,
+	}
+}
+
+// parseDeleteForm reads an url-encoded body sent with DELETE, which
+// net/http only parses for POST, PUT and PATCH.
+func parseDeleteForm(c *gin.Context) {
+	if c.Request.PostForm != nil || c.ContentType() != binding.MIMEPOSTForm {
 		return
 	}
-	// check if the old password is correct
-	if !CheckPasswordHash(oldPassword, user.Password) {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Invalid password",
-		})
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
 		return
 	}
+	c.Request.PostForm, _ = url.ParseQuery(string(body))
+}
 
-	// hash the new password
-	hashedPassword, err := HashPassword(newPassword)
+// DeleteAccount deletes the logged in user for good. It takes a recent
+// dashboard login, and password accounts confirm with their password.
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	parseDeleteForm(c)
+	var req models.DeleteAccountRequest
+	if !bind(c, &req) {
+		return
+	}
+	if !requireDashboardToken(c, "delete the account") || !h.requireRecentAuth(c, "Please log in again to delete your account") {
+		return
+	}
+	id := c.GetInt("id")
+	user, err := h.Store.GetUserById(ctx, id)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error hashing the password",
-		})
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+
+	// password accounts confirm the deletion with their password
+	if user.Password != "GOOGLE" && !h.checkPasswordHash(ctx, req.Password, user.Password) {
+		apierror.Abort(c, apierror.Forbidden(apierror.CodeInvalidCredentials, "Invalid password"))
 		return
 	}
 
-	// update the password in the database
-	err = database.UpdatePassword(id, hashedPassword)
+	// the sessions are removed together with the user, so collect the apps
+	// to notify first
+	appIds, err := h.Store.GetAppIdsOfUserSessions(ctx, id)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error updating the password",
-		})
+		h.Log.ErrorContext(c.Request.Context(), "loading sessions for webhook event", "err", err)
+	}
+
+	err = h.Store.DeleteUser(ctx, id)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error deleting the user", err))
 		return
 	}
+	h.emitWebhookEvent(c, appIds, services.EventUserDeleted, userEventData(id, user.Email, c.GetString("name"), "delete_account"))
 
-	// send the response
 	c.JSON(200, gin.H{
 		"status":  "success",
-		"message": "Password changed successfully",
+		"message": "Account deleted successfully",
 	})
 }
 
-func Refresh(c *gin.Context) {
-	// get the token from the request
-	token := c.PostForm("token")
-	id := c.PostForm("id")
-
-	idInt, err := strconv.Atoi(id)
-	if err != nil {
-		fmt.Println(err)
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Invalid id",
-		})
-		return
-	}
-	// check if the token is empty
-	if token == "" {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Token is required",
-		})
+func (h *Handler) Refresh(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.RefreshRequest
+	if !bind(c, &req) {
 		return
 	}
+	token, idInt := req.Token, req.Id
 
 	// check if the token is valid
-	claims, err := VerifyToken(token, &RefreshTokenClaim{})
+	claims, err := VerifyToken(h.Tokens, token, &RefreshTokenClaim{})
 	if err != nil {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Invalid token",
-		})
+		metrics.RefreshRotations.Inc("invalid")
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidToken, "Invalid token"))
 		return
 	}
 
-	refreshToken, user, err := database.GetRefreshToken(idInt, claims.Id)
+	refreshToken, user, err := h.Store.GetRefreshToken(ctx, claims.Id, idInt)
 
 	if err != nil {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Invalid token",
-		})
+		metrics.RefreshRotations.Inc("invalid")
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidToken, "Invalid token"))
 		return
 	}
 
 	if refreshToken != token {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Invalid token",
-		})
+		metrics.RefreshRotations.Inc("invalid")
+		apierror.Abort(c, apierror.Unauthorized(apierror.CodeInvalidToken, "Invalid token"))
 		return
 	}
 
-	// generate a new token
-	claim := RefreshTokenClaim{
-		Id: claims.Id,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 5 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	// generate a new token pair, keeping the original authentication time
+	authTime := claims.IssuedAt
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime
 	}
-
-	newToken, err := GenerateToken(claim)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
-		return
+	if authTime == nil {
+		authTime = jwt.NewNumericDate(time.Time{})
 	}
-	newAccessToken, err := GenerateToken(AcessTokenClaim{
-		Id:    claims.Id,
-		Name:  user.Name,
-		Email: user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	})
+	user.ID = claims.Id
+	newAccessToken, newToken, err := h.issueTokens(user, idInt, authTime.Time)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error generating the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error generating the token", err))
 		return
 	}
 
 	// update the token in the database
-	err = database.UpdateRefreshToken(idInt, claims.Id, newToken)
+	err = h.Store.UpdateRefreshToken(ctx, claims.Id, idInt, newToken)
 
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error updating the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error updating the token", err))
 		return
 	}
 
+	metrics.RefreshRotations.Inc("success")
 	// send the response
 	c.JSON(200, gin.H{
 		"status": "success",
-		"data": gin.H{
-			"token":         newAccessToken,
-			"refresh_token": newToken,
-			"id":            claims.Id,
-			"email":         user.Email,
-			"name":          user.Name,
+		"data": models.TokenSet{
+			TokenPair: models.TokenPair{Token: newAccessToken, RefreshToken: newToken},
+			Id:        claims.Id,
+			Email:     user.Email,
+			Name:      user.Name,
 		},
 	})
 }
 
-func Logout(c *gin.Context) {
+func (h *Handler) Logout(c *gin.Context) {
+	ctx := c.Request.Context()
 	// get the user id from the request
 	id := c.GetInt("id")
-	appId := c.PostForm("app_id")
-
-	appIdInt, err := strconv.Atoi(appId)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"status":  "error",
-			"message": "Invalid app_id",
-		})
+	var req models.LogoutRequest
+	if !bind(c, &req) {
 		return
 	}
 
 	// delete the token from the database
-	err = database.DeleteSession(id, appIdInt)
+	err := h.Store.DeleteSession(ctx, id, req.AppId)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error deleting the token",
-		})
+		apierror.Abort(c, apierror.Internal("Error deleting the token", err))
 		return
 	}
 
@@ -650,16 +629,14 @@ func Logout(c *gin.Context) {
 
 }
 
-func GetUserApps(c *gin.Context) {
+func (h *Handler) GetUserApps(c *gin.Context) {
+	ctx := c.Request.Context()
 	// get the user id from the request
 	id := c.GetInt("id")
 	// get all the apps of the user
-	apps, err := database.GetAllAppsOfUser(id)
+	apps, err := h.Store.GetAllAppsOfUser(ctx, id)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error getting the apps",
-		})
+		apierror.Abort(c, apierror.Internal("Error getting the apps", err))
 		return
 	}
 
@@ -670,32 +647,27 @@ func GetUserApps(c *gin.Context) {
 	})
 }
 
-func Home(c *gin.Context) {
+func (h *Handler) Home(c *gin.Context) {
+	ctx := c.Request.Context()
 	id := c.GetInt("id")
-	apps, err := database.GetAllAppsOfUser(id)
+	apps, err := h.Store.GetAllAppsOfUser(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(404, gin.H{
-				"status":  "error",
-				"message": "Apps not found",
-			})
+			apierror.Abort(c, apierror.NotFound("Apps not found"))
 			return
 		}
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "Error getting the apps",
-		})
+		apierror.Abort(c, apierror.Internal("Error getting the apps", err))
 		return
 	}
 
 	c.JSON(200, gin.H{
 		"status": "success",
-		"data": gin.H{
-			"apps": apps,
-			"user": gin.H{
-				"id":    id,
-				"name":  c.GetString("name"),
-				"email": c.GetString("email"),
+		"data": models.Dashboard{
+			Apps: apps,
+			User: models.DashboardUser{
+				Id:    id,
+				Name:  c.GetString("name"),
+				Email: c.GetString("email"),
 			},
 		},
 	})
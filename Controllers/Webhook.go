@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"database/sql"
+	"fmt"
+	apierror "go_server/ApiError"
+	models "go_server/Models"
+	services "go_server/Services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getOwnedApp loads the app in the :id path parameter if it belongs to the
+// logged in user, writing the error response otherwise.
+func (h *Handler) getOwnedApp(c *gin.Context) (models.App, bool) {
+	ctx := c.Request.Context()
+	appId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid app ID"))
+		return models.App{}, false
+	}
+
+	app, err := h.Store.GetAppOfUser(ctx, appId, c.GetInt("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("App not found"))
+			return models.App{}, false
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the app", err))
+		return models.App{}, false
+	}
+	return app, true
+}
+
+func (h *Handler) getAppWebhook(c *gin.Context, app models.App) (models.WebhookEndpoint, bool) {
+	ctx := c.Request.Context()
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "Invalid webhook ID"))
+		return models.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := h.Store.GetWebhookEndpoint(ctx, webhookId, app.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Abort(c, apierror.NotFound("Webhook not found"))
+			return models.WebhookEndpoint{}, false
+		}
+		apierror.Abort(c, apierror.Internal("Error getting the webhook", err))
+		return models.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+// parseWebhookEvents accepts events either as repeated form values or as a
+// single comma separated value.
+func parseWebhookEvents(values []string) ([]string, error) {
+	seen := map[string]bool{}
+	events := []string{}
+	for _, value := range values {
+		for _, event := range strings.Split(value, ",") {
+			event = strings.TrimSpace(event)
+			if event == "" || seen[event] {
+				continue
+			}
+			if !services.IsWebhookEvent(event) {
+				return nil, fmt.Errorf("unknown event %q", event)
+			}
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func newWebhookSecret() (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
+
+// checkWebhookURL aborts when the URL is not https or names a private
+// address, unless insecure webhooks are allowed for development.
+func (h *Handler) checkWebhookURL(c *gin.Context, webhookUrl string) bool {
+	if err := services.CheckWebhookURL(webhookUrl, h.Config.Webhooks.AllowInsecure); err != nil {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "The webhook URL must use https and must not point to a private address"))
+		return false
+	}
+	return true
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if !bind(c, &req) {
+		return
+	}
+	webhookUrl := req.Url
+	if !h.checkWebhookURL(c, webhookUrl) {
+		return
+	}
+	events, err := parseWebhookEvents(req.Events)
+	if err != nil || len(events) == 0 {
+		apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "At least one valid event is required").With("events", services.WebhookEvents))
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the secret", err))
+		return
+	}
+
+	webhookId, err := h.Store.InsertWebhookEndpoint(ctx, app.ID, webhookUrl, secret, events)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error inserting the webhook", err))
+		return
+	}
+
+	// the signing secret is only shown on creation and rotation
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.NewWebhook{
+			Id:     webhookId,
+			AppId:  app.ID,
+			Url:    webhookUrl,
+			Events: events,
+			Active: true,
+			Secret: secret,
+		},
+	})
+}
+
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+
+	endpoints, err := h.Store.GetWebhookEndpointsOfApp(ctx, app.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the webhooks", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   endpoints,
+	})
+}
+
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	endpoint, ok := h.getAppWebhook(c, app)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if !bind(c, &req) {
+		return
+	}
+	if req.Url != nil {
+		if !h.checkWebhookURL(c, *req.Url) {
+			return
+		}
+		endpoint.Url = *req.Url
+	}
+	if req.Events != nil {
+		events, err := parseWebhookEvents(req.Events)
+		if err != nil || len(events) == 0 {
+			apierror.Abort(c, apierror.BadRequest(apierror.CodeInvalidRequest, "At least one valid event is required").With("events", services.WebhookEvents))
+			return
+		}
+		endpoint.Events = events
+	}
+	if req.Active != nil {
+		endpoint.Active = *req.Active
+	}
+
+	if err := h.Store.UpdateWebhookEndpoint(ctx, endpoint); err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the webhook", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   endpoint,
+	})
+}
+
+func (h *Handler) RotateWebhookSecret(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	endpoint, ok := h.getAppWebhook(c, app)
+	if !ok {
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error generating the secret", err))
+		return
+	}
+	endpoint.Secret = secret
+	if err := h.Store.UpdateWebhookEndpoint(ctx, endpoint); err != nil {
+		apierror.Abort(c, apierror.Internal("Error updating the webhook", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data": models.WebhookSecret{
+			Id:     endpoint.ID,
+			Secret: secret,
+		},
+	})
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	endpoint, ok := h.getAppWebhook(c, app)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.DeleteWebhookEndpoint(ctx, endpoint.ID, app.ID); err != nil {
+		apierror.Abort(c, apierror.Internal("Error deleting the webhook", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": "Webhook deleted successfully",
+	})
+}
+
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	endpoint, ok := h.getAppWebhook(c, app)
+	if !ok {
+		return
+	}
+
+	var query models.WebhookDeliveriesQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	deliveries, err := h.Store.GetWebhookDeliveries(ctx, endpoint.ID, query.Limit, query.Offset)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error getting the deliveries", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "success",
+		"data":   deliveries,
+	})
+}
+
+func (h *Handler) SendTestWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	app, ok := h.getOwnedApp(c)
+	if !ok {
+		return
+	}
+	endpoint, ok := h.getAppWebhook(c, app)
+	if !ok {
+		return
+	}
+
+	event, err := services.SendTestWebhookEvent(ctx, h.Store, endpoint)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error queueing the test event", err))
+		return
+	}
+
+	c.JSON(202, gin.H{
+		"status": "success",
+		"data":   event,
+	})
+}
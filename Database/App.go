@@ -1,40 +1,28 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	models "go_server/Models"
 	"strings"
-)
 
-func CreateAppTable() error {
-	query := `CREATE TABLE IF NOT EXISTS apps (
-		id SERIAL PRIMARY KEY,
-		app_name VARCHAR(255) NOT NULL,
-		callback_url TEXT NOT NULL,
-		user_id INT NOT NULL,
-		FOREIGN KEY (user_id) REFERENCES users(id)
-	)`
-	_, err := instance.db.Exec(query)
-	if err != nil {
-		return err
-	}
-	return nil
-}
+	"github.com/lib/pq"
+)
 
-func InsertApp(name, callbackUrl string, userId int) (int, error) {
+func (s *PostgresStore) InsertApp(ctx context.Context, name, callbackUrl string, userId int) (int, error) {
 	querry := `INSERT INTO apps (app_name , callback_url , user_id) VALUES ($1 , $2 , $3) RETURNING id`
 	var pk int
-	err := instance.db.QueryRow(querry, name, callbackUrl, userId).Scan(&pk)
+	err := s.db.QueryRowContext(ctx, querry, name, callbackUrl, userId).Scan(&pk)
 	if err != nil {
 		return 0, err
 	}
 	return pk, nil
 }
 
-func GetAllAppsOfUser(userId int) ([]models.App, error) {
+func (s *PostgresStore) GetAllAppsOfUser(ctx context.Context, userId int) ([]models.App, error) {
 	query := `SELECT id, app_name, callback_url FROM apps WHERE user_id = $1`
 
-	rows, err := instance.db.Query(query, userId)
+	rows, err := s.db.QueryContext(ctx, query, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -51,16 +39,35 @@ func GetAllAppsOfUser(userId int) ([]models.App, error) {
 	return apps, nil
 }
 
-func GetAppById(appId int) (models.App, error) {
-	query := `SELECT id, app_name, callback_url FROM apps WHERE id = $1`
+// GetAppCallbackUrls returns the callback URL of every app. The CORS
+// policy derives the allowed app origins from them.
+func (s *PostgresStore) GetAppCallbackUrls(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT callback_url FROM apps ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	urls := []string{}
+	for rows.Next() {
+		var callbackUrl string
+		if err := rows.Scan(&callbackUrl); err != nil {
+			return nil, err
+		}
+		urls = append(urls, callbackUrl)
+	}
+	return urls, rows.Err()
+}
+
+func (s *PostgresStore) GetAppById(ctx context.Context, appId int) (models.App, error) {
+	query := `SELECT id, app_name, callback_url, logo_url, brand_color FROM apps WHERE id = $1`
 	var app models.App
-	err := instance.db.QueryRow(query, appId).Scan(&app.ID, &app.Name, &app.CallbackUrl)
+	err := s.db.QueryRowContext(ctx, query, appId).Scan(&app.ID, &app.Name, &app.CallbackUrl, &app.LogoUrl, &app.BrandColor)
 	if err != nil {
 		return models.App{}, err
 	}
 	return app, nil
 }
-func UpdateApp(appId, userId int, name, callbackUrl string) error {
+func (s *PostgresStore) UpdateApp(ctx context.Context, appId, userId int, name, callbackUrl string) error {
 
 	var query string
 	var args []interface{}
@@ -68,12 +75,10 @@ func UpdateApp(appId, userId int, name, callbackUrl string) error {
 	// Build dynamic query based on provided fields
 	setParts := make([]string, 0, 2)
 	if name != "" {
-		fmt.Println("name is not empty")
 		setParts = append(setParts, "app_name = $1")
 		args = append(args, name)
 	}
 	if callbackUrl != "" {
-		fmt.Println("callback_url is not empty")
 		setParts = append(setParts, fmt.Sprintf("callback_url = $%d", len(args)+1))
 		args = append(args, callbackUrl)
 	}
@@ -88,14 +93,50 @@ func UpdateApp(appId, userId int, name, callbackUrl string) error {
 
 	// Add WHERE clause parameters
 	args = append(args, appId, userId)
-	fmt.Println(query)
 
-	_, err := instance.db.Exec(query, args...)
-	return err
+	return s.execExpectingRow(ctx, query, args...)
 }
 
-func DeleteApp(appId, userId int) error {
+func (s *PostgresStore) DeleteApp(ctx context.Context, appId, userId int) error {
 	query := `DELETE FROM apps WHERE id = $1 AND user_id = $2`
-	_, err := instance.db.Exec(query, appId, userId)
-	return err
+	return s.execExpectingRow(ctx, query, appId, userId)
+}
+
+// GetAppOfUser returns the app only when it is owned by the given user.
+func (s *PostgresStore) GetAppOfUser(ctx context.Context, appId, userId int) (models.App, error) {
+	query := `SELECT id, app_name, callback_url, user_id, logo_url, brand_color FROM apps WHERE id = $1 AND user_id = $2`
+	var app models.App
+	err := s.db.QueryRowContext(ctx, query, appId, userId).Scan(&app.ID, &app.Name, &app.CallbackUrl, &app.UserId, &app.LogoUrl, &app.BrandColor)
+	if err != nil {
+		return models.App{}, err
+	}
+	return app, nil
+}
+
+func (s *PostgresStore) UpdateAppBranding(ctx context.Context, appId, userId int, logoUrl, brandColor string) error {
+	query := `UPDATE apps SET logo_url = $1, brand_color = $2 WHERE id = $3 AND user_id = $4`
+	return s.execExpectingRow(ctx, query, logoUrl, brandColor, appId, userId)
+}
+
+// GetAppClient returns the client secret hash and scopes of an app.
+func (s *PostgresStore) GetAppClient(ctx context.Context, appId int) (models.AppClient, error) {
+	query := `SELECT id, COALESCE(client_secret_hash, ''), scopes FROM apps WHERE id = $1`
+	var client models.AppClient
+	err := s.db.QueryRowContext(ctx, query, appId).Scan(&client.AppId, &client.SecretHash, pq.Array(&client.Scopes))
+	if err != nil {
+		return models.AppClient{}, err
+	}
+	return client, nil
+}
+
+// SetAppClientSecret replaces the client secret of an app owned by the
+// user, which stops the previous one from working.
+func (s *PostgresStore) SetAppClientSecret(ctx context.Context, appId, userId int, secretHash string) error {
+	query := `UPDATE apps SET client_secret_hash = $1 WHERE id = $2 AND user_id = $3`
+	return s.execExpectingRow(ctx, query, secretHash, appId, userId)
+}
+
+func (s *PostgresStore) SetAppScopes(ctx context.Context, appId, userId int, scopes []string) error {
+	query := `UPDATE apps SET scopes = $1 WHERE id = $2 AND user_id = $3`
+	return s.execExpectingRow(ctx, query, pq.Array(scopes), appId, userId)
 }
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+
+	models "go_server/Models"
+
+	"github.com/lib/pq"
+)
+
+func (s *PostgresStore) InsertInitialAccessToken(ctx context.Context, orgId int, tokenHash, description string) (int, error) {
+	query := `INSERT INTO initial_access_tokens (org_id, token_hash, description) VALUES ($1, $2, $3) RETURNING id`
+	var pk int
+	err := s.db.QueryRowContext(ctx, query, orgId, tokenHash, description).Scan(&pk)
+	if err != nil {
+		return 0, err
+	}
+	return pk, nil
+}
+
+func (s *PostgresStore) GetInitialAccessTokensOfOrg(ctx context.Context, orgId int) ([]models.InitialAccessToken, error) {
+	query := `SELECT id, org_id, description, created_at, last_used_at, revoked_at FROM initial_access_tokens WHERE org_id = $1 ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query, orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []models.InitialAccessToken{}
+	for rows.Next() {
+		var token models.InitialAccessToken
+		err := rows.Scan(&token.ID, &token.OrgId, &token.Description, &token.CreatedAt, &token.LastUsedAt, &token.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeInitialAccessToken returns sql.ErrNoRows when the token does not
+// belong to the organization or was already revoked. The clients
+// registered with it are kept.
+func (s *PostgresStore) RevokeInitialAccessToken(ctx context.Context, orgId, tokenId int) error {
+	query := `UPDATE initial_access_tokens SET revoked_at = NOW() WHERE id = $1 AND org_id = $2 AND revoked_at IS NULL`
+	return s.execExpectingRow(ctx, query, tokenId, orgId)
+}
+
+// GetOrgIdByInitialAccessToken resolves an active initial access token to
+// its organization and records the time it was last used.
+func (s *PostgresStore) GetOrgIdByInitialAccessToken(ctx context.Context, tokenHash string) (int, error) {
+	query := `UPDATE initial_access_tokens SET last_used_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL RETURNING org_id`
+	var orgId int
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&orgId)
+	if err != nil {
+		return 0, err
+	}
+	return orgId, nil
+}
+
+// InsertRegisteredClient creates the app of a dynamically registered
+// client, owned by the user, with its client secret and the registration
+// access token that manages it.
+func (s *PostgresStore) InsertRegisteredClient(ctx context.Context, userId int, client models.ClientMetadata, secretHash, registrationTokenHash string) (int, error) {
+	query := `
+		INSERT INTO apps (app_name, callback_url, user_id, logo_url, scopes, client_secret_hash, registration_token_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+	`
+	var pk int
+	err := s.db.QueryRowContext(ctx, query, client.Name, client.CallbackUrl, userId, client.LogoUrl, pq.Array(client.Scopes), secretHash, registrationTokenHash).Scan(&pk)
+	if err != nil {
+		return 0, err
+	}
+	return pk, nil
+}
+
+func (s *PostgresStore) GetAppIdByRegistrationToken(ctx context.Context, tokenHash string) (int, error) {
+	query := `SELECT id FROM apps WHERE registration_token_hash = $1`
+	var appId int
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&appId)
+	if err != nil {
+		return 0, err
+	}
+	return appId, nil
+}
+
+func (s *PostgresStore) GetClientMetadata(ctx context.Context, appId int) (models.ClientMetadata, error) {
+	query := `SELECT id, app_name, callback_url, logo_url, scopes FROM apps WHERE id = $1`
+	var client models.ClientMetadata
+	err := s.db.QueryRowContext(ctx, query, appId).Scan(&client.AppId, &client.Name, &client.CallbackUrl, &client.LogoUrl, pq.Array(&client.Scopes))
+	if err != nil {
+		return models.ClientMetadata{}, err
+	}
+	return client, nil
+}
+
+// UpdateClientMetadata replaces the metadata of the app, as a client
+// update of RFC 7592 section 2.2 does.
+func (s *PostgresStore) UpdateClientMetadata(ctx context.Context, client models.ClientMetadata) error {
+	query := `UPDATE apps SET app_name = $1, callback_url = $2, logo_url = $3, scopes = $4 WHERE id = $5`
+	return s.execExpectingRow(ctx, query, client.Name, client.CallbackUrl, client.LogoUrl, pq.Array(client.Scopes), client.AppId)
+}
+
+// DeleteClient deletes the app of a client that deregisters itself.
+func (s *PostgresStore) DeleteClient(ctx context.Context, appId int) error {
+	return s.execExpectingRow(ctx, `DELETE FROM apps WHERE id = $1`, appId)
+}
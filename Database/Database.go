@@ -1,67 +1,125 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
-	"sync"
+	"strings"
 	"github.com/pressly/goose/v3"
 	_ "github.com/lib/pq"
 )
 
-var (
-	instance *Database
-	once     sync.Once
-)
+// The migrations are compiled into the binary so it does not depend on the
+// working directory it is started from.
+//
+//go:embed Migrations/*.sql
+var migrations embed.FS
 
-type Database struct {
-	db *sql.DB
+// PostgresStore implements Store on a PostgreSQL database.
+type PostgresStore struct {
+	db tracedDB
 }
 
-func init() {
-	GetInstance()
-}
+var _ Store = (*PostgresStore)(nil)
 
-func GetInstance() *Database {
-	once.Do(func() {
-		db := connectDB()
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: tracedDB{db}}
+}
 
-		// Run migrations
-		err := runMigrations(db)
-		if err!= nil {
-			panic(fmt.Sprintf("Error running migrations: %v", err))
-		}
-		instance = &Database{
-			db: db,
-		}
-	})
-	return instance
+// DB exposes the connection pool, e.g. for health checks and shutdown.
+func (s *PostgresStore) DB() *sql.DB {
+	return s.db.DB
 }
 
-func connectDB() *sql.DB {
-	connStr := os.Getenv("DATABASE_URL")
+// Connect opens the database at connStr and brings its schema up to date.
+func Connect(connStr string, log *slog.Logger) (*sql.DB, error) {
 	if connStr == "" {
-		panic("DATABASE_URL is not set")
+		return nil, fmt.Errorf("DATABASE_URL is not set")
 	}
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
 	}
-	fmt.Println("Connected to the database")
-	return db
+	log.Info("connected to the database")
+
+	// Run migrations
+	if err := RunMigrations(db, log); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Error running migrations: %v", err)
+	}
+	return db, nil
 }
 
-func runMigrations(db *sql.DB) error {
+func RunMigrations(db *sql.DB, log *slog.Logger) error {
     // Set dialect to postgres
     if err := goose.SetDialect("postgres"); err != nil {
         return err
     }
     
     // Run migrations from the migrations directory
-    if err := goose.Up(db, "Database/Migrations"); err != nil {
+    goose.SetBaseFS(migrations)
+    goose.SetLogger(gooseLogger{log})
+    if err := goose.Up(db, "Migrations"); err != nil {
         return err
     }
     
-    fmt.Println("Database migrations completed successfully")
+    log.Info("database migrations completed")
     return nil
-}
\ No newline at end of file
+}
+
+// gooseLogger sends goose's progress messages to the store log.
+type gooseLogger struct {
+	log *slog.Logger
+}
+
+func (l gooseLogger) Printf(format string, v ...interface{}) {
+	l.log.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l gooseLogger) Fatalf(format string, v ...interface{}) {
+	l.log.Error(strings.TrimSpace(fmt.Sprintf(format, v...)))
+	os.Exit(1)
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// HasPendingMigrations reports whether the schema is behind the migrations
+// compiled into the binary, e.g. while another instance is still migrating.
+func (s *PostgresStore) HasPendingMigrations(ctx context.Context) (bool, error) {
+	fsys, err := fs.Sub(migrations, "Migrations")
+	if err != nil {
+		return false, err
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, s.db.DB, fsys)
+	if err != nil {
+		return false, err
+	}
+	return provider.HasPending(ctx)
+}
+
+// execExpectingRow runs a write statement and reports sql.ErrNoRows when it
+// did not affect any row, so callers can answer with a 404.
+func (s *PostgresStore) execExpectingRow(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "go_server/Models"
+)
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+	DeviceCodeConsumed = "consumed"
+)
+
+// deviceCodeRetention is how long expired device codes are kept, so a
+// device polling late still learns that its code expired.
+const deviceCodeRetention = 24 * time.Hour
+
+const deviceCodeColumns = `id, app_id, COALESCE(user_id, 0), user_code, status, poll_interval, expires_at, last_polled_at, decided_at, auth_time`
+
+func scanDeviceCode(row interface{ Scan(...interface{}) error }) (models.DeviceCode, error) {
+	var code models.DeviceCode
+	var lastPolledAt, decidedAt, authTime sql.NullTime
+	err := row.Scan(&code.ID, &code.AppId, &code.UserId, &code.UserCode, &code.Status, &code.Interval, &code.ExpiresAt, &lastPolledAt, &decidedAt, &authTime)
+	if err != nil {
+		return models.DeviceCode{}, err
+	}
+	code.LastPolledAt = lastPolledAt.Time
+	code.DecidedAt = decidedAt.Time
+	code.AuthTime = authTime.Time
+	return code, nil
+}
+
+// InsertDeviceCode stores a device login by the hash of its device code.
+// Codes that expired over a day ago are cleaned up on the way, which also
+// frees their user codes.
+func (s *PostgresStore) InsertDeviceCode(ctx context.Context, deviceCodeHash, userCode string, appId, interval int, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM device_codes WHERE expires_at < $1`, time.Now().Add(-deviceCodeRetention))
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO device_codes (device_code_hash, user_code, app_id, poll_interval, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err = s.db.ExecContext(ctx, query, deviceCodeHash, userCode, appId, interval, expiresAt)
+	return err
+}
+
+// GetDeviceCode returns the device login of a device code in any state,
+// expired ones included.
+func (s *PostgresStore) GetDeviceCode(ctx context.Context, deviceCodeHash string) (models.DeviceCode, error) {
+	query := `SELECT ` + deviceCodeColumns + ` FROM device_codes WHERE device_code_hash = $1`
+	return scanDeviceCode(s.db.QueryRowContext(ctx, query, deviceCodeHash))
+}
+
+// GetPendingDeviceCode returns the device login of a user code while it
+// still waits for a decision.
+func (s *PostgresStore) GetPendingDeviceCode(ctx context.Context, userCode string) (models.DeviceCode, error) {
+	query := `SELECT ` + deviceCodeColumns + ` FROM device_codes WHERE user_code = $1 AND status = $2 AND expires_at > NOW()`
+	return scanDeviceCode(s.db.QueryRowContext(ctx, query, userCode, DeviceCodePending))
+}
+
+// DecideDeviceCode approves or denies a pending, unexpired device login
+// for the user, who last entered credentials at authTime; sql.ErrNoRows is
+// returned for any other.
+func (s *PostgresStore) DecideDeviceCode(ctx context.Context, userCode string, userId int, authTime time.Time, approve bool) error {
+	status := DeviceCodeDenied
+	if approve {
+		status = DeviceCodeApproved
+	}
+	query := `
+		UPDATE device_codes SET status = $3, user_id = $2, decided_at = NOW(), auth_time = $5
+		WHERE user_code = $1 AND status = $4 AND expires_at > NOW()
+	`
+	return s.execExpectingRow(ctx, query, userCode, userId, status, DeviceCodePending, authTime)
+}
+
+// RecordDevicePoll notes that the device polled now, and the interval it
+// must wait before the next poll.
+func (s *PostgresStore) RecordDevicePoll(ctx context.Context, id, interval int) error {
+	return s.execExpectingRow(ctx, `UPDATE device_codes SET last_polled_at = NOW(), poll_interval = $2 WHERE id = $1`, id, interval)
+}
+
+// ConsumeDeviceCode marks an approved, unexpired device login as used.
+// The single UPDATE makes sure the tokens are handed out only once;
+// sql.ErrNoRows is returned otherwise.
+func (s *PostgresStore) ConsumeDeviceCode(ctx context.Context, id int) error {
+	query := `UPDATE device_codes SET status = $2 WHERE id = $1 AND status = $3 AND expires_at > NOW()`
+	return s.execExpectingRow(ctx, query, id, DeviceCodeConsumed, DeviceCodeApproved)
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	models "go_server/Models"
+	"time"
+)
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusDead    = "dead"
+)
+
+func (s *PostgresStore) InsertOutboxEmail(ctx context.Context, recipient, subject, textBody, htmlBody string) (int64, error) {
+	query := `INSERT INTO email_outbox (recipient, subject, text_body, html_body) VALUES ($1, $2, $3, $4) RETURNING id`
+	var pk int64
+	err := s.db.QueryRowContext(ctx, query, recipient, subject, textBody, htmlBody).Scan(&pk)
+	if err != nil {
+		return 0, err
+	}
+	return pk, nil
+}
+
+// ClaimDueOutboxEmails leases up to limit due emails to the caller, the same
+// way ClaimDueWebhookDeliveries does for webhooks.
+func (s *PostgresStore) ClaimDueOutboxEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE email_outbox o
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due
+		WHERE o.id = due.id
+		RETURNING o.id, o.recipient, o.subject, o.text_body, o.html_body, o.attempts, o.created_at
+	`
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var emails []models.OutboxEmail
+	for rows.Next() {
+		var email models.OutboxEmail
+		err := rows.Scan(&email.ID, &email.Recipient, &email.Subject, &email.TextBody,
+			&email.HTMLBody, &email.Attempts, &email.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		email.Status = EmailStatusPending
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// GetOutboxEmail returns a queued email whatever its status.
+func (s *PostgresStore) GetOutboxEmail(ctx context.Context, emailId int64) (models.OutboxEmail, error) {
+	query := `SELECT id, recipient, subject, text_body, html_body, status, attempts, created_at FROM email_outbox WHERE id = $1`
+	var email models.OutboxEmail
+	err := s.db.QueryRowContext(ctx, query, emailId).Scan(&email.ID, &email.Recipient, &email.Subject, &email.TextBody,
+		&email.HTMLBody, &email.Status, &email.Attempts, &email.CreatedAt)
+	if err != nil {
+		return models.OutboxEmail{}, err
+	}
+	return email, nil
+}
+
+// MarkOutboxEmailSent also clears the bodies: they hold the reset and
+// magic links, which must not stay readable once the email is out.
+func (s *PostgresStore) MarkOutboxEmailSent(ctx context.Context, emailId int64, attempts int) error {
+	query := `UPDATE email_outbox SET status = 'sent', attempts = $2, last_error = '', sent_at = NOW(), text_body = '', html_body = '' WHERE id = $1`
+	_, err := s.db.ExecContext(ctx, query, emailId, attempts)
+	return err
+}
+
+// MarkOutboxEmailFailed schedules another attempt, or gives up on a dead
+// email and clears its bodies as MarkOutboxEmailSent does.
+func (s *PostgresStore) MarkOutboxEmailFailed(ctx context.Context, emailId int64, attempts int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	var query string
+	if dead {
+		query = `UPDATE email_outbox SET status = 'dead', attempts = $2, last_error = $3, next_attempt_at = $4, text_body = '', html_body = '' WHERE id = $1`
+	} else {
+		query = `UPDATE email_outbox SET status = 'pending', attempts = $2, last_error = $3, next_attempt_at = $4 WHERE id = $1`
+	}
+	_, err := s.db.ExecContext(ctx, query, emailId, attempts, lastError, nextAttemptAt)
+	return err
+}
+
+// PurgeOutboxEmails deletes the sent and dead emails queued before the
+// cutoff and returns how many were deleted. Pending emails are kept.
+func (s *PostgresStore) PurgeOutboxEmails(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM email_outbox WHERE status <> 'pending' AND created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+
+	models "go_server/Models"
+
+	"github.com/lib/pq"
+)
+
+// SetExchangePolicy lets the source app exchange user tokens for tokens of
+// the target app owned by the user, replacing the scopes of an existing
+// policy. sql.ErrNoRows is returned when the user does not own the target.
+func (s *PostgresStore) SetExchangePolicy(ctx context.Context, targetAppId, userId, sourceAppId int, scopes []string) error {
+	query := `
+		INSERT INTO exchange_policies (target_app_id, source_app_id, scopes)
+		SELECT id, $3, $4 FROM apps WHERE id = $1 AND user_id = $2
+		ON CONFLICT (target_app_id, source_app_id) DO UPDATE SET scopes = EXCLUDED.scopes
+	`
+	return s.execExpectingRow(ctx, query, targetAppId, userId, sourceAppId, pq.Array(scopes))
+}
+
+func (s *PostgresStore) GetExchangePolicies(ctx context.Context, targetAppId int) ([]models.ExchangePolicy, error) {
+	query := `SELECT target_app_id, source_app_id, scopes, created_at FROM exchange_policies WHERE target_app_id = $1 ORDER BY source_app_id`
+	rows, err := s.db.QueryContext(ctx, query, targetAppId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []models.ExchangePolicy{}
+	for rows.Next() {
+		var policy models.ExchangePolicy
+		if err := rows.Scan(&policy.TargetAppId, &policy.SourceAppId, pq.Array(&policy.Scopes), &policy.CreatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+func (s *PostgresStore) GetExchangePolicy(ctx context.Context, sourceAppId, targetAppId int) (models.ExchangePolicy, error) {
+	query := `SELECT target_app_id, source_app_id, scopes, created_at FROM exchange_policies WHERE source_app_id = $1 AND target_app_id = $2`
+	var policy models.ExchangePolicy
+	err := s.db.QueryRowContext(ctx, query, sourceAppId, targetAppId).Scan(&policy.TargetAppId, &policy.SourceAppId, pq.Array(&policy.Scopes), &policy.CreatedAt)
+	if err != nil {
+		return models.ExchangePolicy{}, err
+	}
+	return policy, nil
+}
+
+func (s *PostgresStore) DeleteExchangePolicy(ctx context.Context, targetAppId, userId, sourceAppId int) error {
+	query := `
+		DELETE FROM exchange_policies p USING apps a
+		WHERE p.target_app_id = a.id AND a.id = $1 AND a.user_id = $2 AND p.source_app_id = $3
+	`
+	return s.execExpectingRow(ctx, query, targetAppId, userId, sourceAppId)
+}
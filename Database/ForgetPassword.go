@@ -1,40 +1,99 @@
 package database
 
 import (
+	"context"
 	models "go_server/Models"
 	"time"
 )
 
-func CreateForgetPasswordTable() error {
+// InsertPasswordResetToken stores a reset token by its hash. Earlier tokens
+// of the user are discarded so only the most recent email works.
+func (s *PostgresStore) InsertPasswordResetToken(ctx context.Context, tokenHash string, userId int, expiresAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, userId); err != nil {
+		return err
+	}
+	query := `INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, tokenHash, userId, expiresAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPasswordResetUser returns the active user a still valid reset token
+// belongs to without consuming the token.
+func (s *PostgresStore) GetPasswordResetUser(ctx context.Context, tokenHash string) (models.User, error) {
 	query := `
-	CREATE TABLE IF NOT EXISTS forget_password (
-	email VARCHAR(255) NOT NULL UNIQUE PRIMARY KEY,
-	token TEXT NOT NULL,
-	expired_at TIMESTAMP NOT NULL
-	)
+		SELECT users.id, users.name, users.email, users.active
+		FROM password_reset_tokens
+		INNER JOIN users ON password_reset_tokens.user_id = users.id
+		WHERE password_reset_tokens.token_hash = $1 AND password_reset_tokens.used_at IS NULL
+		AND password_reset_tokens.expires_at > NOW() AND users.active
 	`
-	_, err := instance.db.Exec(query)
+	var user models.User
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&user.ID, &user.Name, &user.Email, &user.Active)
 	if err != nil {
-		return err
+		return models.User{}, err
 	}
-	return nil
+	return user, nil
 }
 
-func InsertForgetPassword(email string, token string, expired_at time.Time) error {
-	query := `INSERT INTO forget_password (email, token, expired_at) VALUES ($1, $2, $3) ON CONFLICT (email) DO UPDATE SET token = $2, expired_at = $3`
-	_, err := instance.db.Exec(query, email, token, expired_at)
+// ResetPasswordWithToken consumes an unused, unexpired reset token and sets
+// the new password of its (active) user in one transaction. All outstanding
+// reset tokens and sessions of the user are revoked; the apps that had a
+// session are returned so they can be notified. sql.ErrNoRows means the
+// token is not valid.
+func (s *PostgresStore) ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (models.User, []int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return models.User{}, nil, err
 	}
-	return nil
+	defer tx.Rollback()
+
+	var user models.User
+	query := `
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+	if err := tx.QueryRowContext(ctx, query, tokenHash).Scan(&user.ID); err != nil {
+		return models.User{}, nil, err
+	}
+	query = `UPDATE users SET password = $1, password_changed_at = NOW(), updated_at = NOW() WHERE id = $2 AND active RETURNING name, email, active`
+	if err := tx.QueryRowContext(ctx, query, passwordHash, user.ID).Scan(&user.Name, &user.Email, &user.Active); err != nil {
+		return models.User{}, nil, err
+	}
+
+	appIds, err := revokeUserSessions(ctx, tx, user.ID)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, user.ID); err != nil {
+		return models.User{}, nil, err
+	}
+	return user, appIds, tx.Commit()
 }
 
-func GetTokenByEmail(email string) (models.ForgetPassword, error) {
-	query := `SELECT email, token, expired_at FROM forget_password WHERE email = $1`
-	var token models.ForgetPassword
-	err := instance.db.QueryRow(query, email).Scan(&token.Email, &token.Token, &token.ExpiredAt)
-	if err!= nil {
-		return models.ForgetPassword{}, err
+// revokeUserSessions deletes every session of the user and returns the apps
+// they belonged to.
+func revokeUserSessions(ctx context.Context, tx *tracedTx, userId int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `DELETE FROM sessions WHERE user_id = $1 RETURNING app_id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var appIds []int
+	for rows.Next() {
+		var appId int
+		if err := rows.Scan(&appId); err != nil {
+			return nil, err
+		}
+		appIds = append(appIds, appId)
 	}
-	return token, nil
+	return appIds, rows.Err()
 }
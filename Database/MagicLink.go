@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	models "go_server/Models"
+	"time"
+)
+
+// InsertMagicLink stores a sign-in link by the hash of its token. appId is
+// 0 when the link logs into the dashboard rather than an app. Expired links
+// of the same email are cleaned up on the way.
+func (s *PostgresStore) InsertMagicLink(ctx context.Context, tokenHash, email string, appId int, bindingHash string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM magic_links WHERE email = $1 AND (expires_at < NOW() OR used_at IS NOT NULL)`, email)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO magic_links (token_hash, email, app_id, binding_hash, expires_at) VALUES ($1, $2, NULLIF($3, 0), $4, $5)`
+	_, err = s.db.ExecContext(ctx, query, tokenHash, email, appId, bindingHash, expiresAt)
+	return err
+}
+
+// ConsumeMagicLink marks the link used and returns it. The link must be
+// unused, unexpired and opened from the browser holding the binding, and
+// the single UPDATE makes sure only one request can ever consume it;
+// sql.ErrNoRows is returned otherwise.
+func (s *PostgresStore) ConsumeMagicLink(ctx context.Context, tokenHash, bindingHash string) (models.MagicLink, error) {
+	query := `
+		UPDATE magic_links SET used_at = NOW()
+		WHERE token_hash = $1 AND binding_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING email, COALESCE(app_id, 0), expires_at
+	`
+	var link models.MagicLink
+	err := s.db.QueryRowContext(ctx, query, tokenHash, bindingHash).Scan(&link.Email, &link.AppId, &link.ExpiresAt)
+	if err != nil {
+		return models.MagicLink{}, err
+	}
+	return link, nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "go_server/Models"
+)
+
+type memInitialToken struct {
+	models.InitialAccessToken
+	tokenHash string
+}
+
+func (s *MemoryStore) InsertInitialAccessToken(ctx context.Context, orgId int, tokenHash, description string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.organizations[orgId]; !ok {
+		return 0, errMissingReference
+	}
+	for _, token := range s.initialTokens {
+		if token.tokenHash == tokenHash {
+			return 0, errDuplicateKey
+		}
+	}
+	token := &memInitialToken{
+		InitialAccessToken: models.InitialAccessToken{ID: int(s.nextId("initial_access_tokens")), OrgId: orgId, Description: description, CreatedAt: time.Now()},
+		tokenHash:          tokenHash,
+	}
+	s.initialTokens[token.ID] = token
+	return token.ID, nil
+}
+
+func (s *MemoryStore) GetInitialAccessTokensOfOrg(ctx context.Context, orgId int) ([]models.InitialAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := []models.InitialAccessToken{}
+	for _, id := range sortedIds(s.initialTokens) {
+		if token := s.initialTokens[id]; token.OrgId == orgId {
+			tokens = append(tokens, token.InitialAccessToken)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *MemoryStore) RevokeInitialAccessToken(ctx context.Context, orgId, tokenId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.initialTokens[tokenId]
+	if !ok || token.OrgId != orgId || token.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	return nil
+}
+
+func (s *MemoryStore) GetOrgIdByInitialAccessToken(ctx context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.initialTokens {
+		if token.tokenHash == tokenHash && token.RevokedAt == nil {
+			now := time.Now()
+			token.LastUsedAt = &now
+			return token.OrgId, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *MemoryStore) InsertRegisteredClient(ctx context.Context, userId int, client models.ClientMetadata, secretHash, registrationTokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userId]; !ok {
+		return 0, errMissingReference
+	}
+	if _, ok := s.registrations[registrationTokenHash]; ok {
+		return 0, errDuplicateKey
+	}
+	app := &models.App{ID: int(s.nextId("apps")), Name: client.Name, CallbackUrl: client.CallbackUrl, UserId: userId, LogoUrl: client.LogoUrl}
+	s.apps[app.ID] = app
+	s.appClients[app.ID] = &models.AppClient{AppId: app.ID, SecretHash: secretHash, Scopes: append([]string{}, client.Scopes...)}
+	s.registrations[registrationTokenHash] = app.ID
+	return app.ID, nil
+}
+
+func (s *MemoryStore) GetAppIdByRegistrationToken(ctx context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	appId, ok := s.registrations[tokenHash]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return appId, nil
+}
+
+func (s *MemoryStore) GetClientMetadata(ctx context.Context, appId int) (models.ClientMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[appId]
+	if !ok {
+		return models.ClientMetadata{}, sql.ErrNoRows
+	}
+	client := models.ClientMetadata{AppId: app.ID, Name: app.Name, CallbackUrl: app.CallbackUrl, LogoUrl: app.LogoUrl, Scopes: []string{}}
+	if stored, ok := s.appClients[appId]; ok {
+		client.Scopes = append(client.Scopes, stored.Scopes...)
+	}
+	return client, nil
+}
+
+func (s *MemoryStore) UpdateClientMetadata(ctx context.Context, client models.ClientMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[client.AppId]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	app.Name = client.Name
+	app.CallbackUrl = client.CallbackUrl
+	app.LogoUrl = client.LogoUrl
+	stored, ok := s.appClients[app.ID]
+	if !ok {
+		stored = &models.AppClient{AppId: app.ID}
+		s.appClients[app.ID] = stored
+	}
+	stored.Scopes = append([]string{}, client.Scopes...)
+	return nil
+}
+
+func (s *MemoryStore) DeleteClient(ctx context.Context, appId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[appId]; !ok {
+		return sql.ErrNoRows
+	}
+	s.deleteApp(appId)
+	return nil
+}
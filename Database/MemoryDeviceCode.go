@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "go_server/Models"
+)
+
+type memDeviceCode struct {
+	models.DeviceCode
+	deviceCodeHash string
+}
+
+func (s *MemoryStore) InsertDeviceCode(ctx context.Context, deviceCodeHash, userCode string, appId, interval int, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := time.Now().Add(-deviceCodeRetention)
+	for id, code := range s.deviceCodes {
+		if code.ExpiresAt.Before(cutoff) {
+			delete(s.deviceCodes, id)
+		}
+	}
+	for _, code := range s.deviceCodes {
+		if code.deviceCodeHash == deviceCodeHash || code.UserCode == userCode {
+			return errDuplicateKey
+		}
+	}
+	if _, ok := s.apps[appId]; !ok {
+		return errMissingReference
+	}
+	id := int(s.nextId("device_codes"))
+	s.deviceCodes[id] = &memDeviceCode{
+		DeviceCode: models.DeviceCode{
+			ID:        id,
+			AppId:     appId,
+			UserCode:  userCode,
+			Status:    DeviceCodePending,
+			Interval:  interval,
+			ExpiresAt: expiresAt,
+		},
+		deviceCodeHash: deviceCodeHash,
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetDeviceCode(ctx context.Context, deviceCodeHash string) (models.DeviceCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, code := range s.deviceCodes {
+		if code.deviceCodeHash == deviceCodeHash {
+			return code.DeviceCode, nil
+		}
+	}
+	return models.DeviceCode{}, sql.ErrNoRows
+}
+
+// pendingDeviceCode returns the device code of userCode that still waits
+// for a decision.
+func (s *MemoryStore) pendingDeviceCode(userCode string) (*memDeviceCode, bool) {
+	now := time.Now()
+	for _, code := range s.deviceCodes {
+		if code.UserCode == userCode && code.Status == DeviceCodePending && code.ExpiresAt.After(now) {
+			return code, true
+		}
+	}
+	return nil, false
+}
+
+func (s *MemoryStore) GetPendingDeviceCode(ctx context.Context, userCode string) (models.DeviceCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.pendingDeviceCode(userCode)
+	if !ok {
+		return models.DeviceCode{}, sql.ErrNoRows
+	}
+	return code.DeviceCode, nil
+}
+
+func (s *MemoryStore) DecideDeviceCode(ctx context.Context, userCode string, userId int, authTime time.Time, approve bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.pendingDeviceCode(userCode)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := s.users[userId]; !ok {
+		return errMissingReference
+	}
+	code.Status = DeviceCodeDenied
+	if approve {
+		code.Status = DeviceCodeApproved
+	}
+	code.UserId = userId
+	code.DecidedAt = time.Now()
+	code.AuthTime = authTime
+	return nil
+}
+
+func (s *MemoryStore) RecordDevicePoll(ctx context.Context, id, interval int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.deviceCodes[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	code.LastPolledAt = time.Now()
+	code.Interval = interval
+	return nil
+}
+
+func (s *MemoryStore) ConsumeDeviceCode(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.deviceCodes[id]
+	if !ok || code.Status != DeviceCodeApproved || !code.ExpiresAt.After(time.Now()) {
+		return sql.ErrNoRows
+	}
+	code.Status = DeviceCodeConsumed
+	return nil
+}
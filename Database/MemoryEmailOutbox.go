@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	models "go_server/Models"
+)
+
+type memOutboxEmail struct {
+	models.OutboxEmail
+	nextAttemptAt time.Time
+	lastError     string
+}
+
+func (s *MemoryStore) InsertOutboxEmail(ctx context.Context, recipient, subject, textBody, htmlBody string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	email := &memOutboxEmail{
+		OutboxEmail: models.OutboxEmail{
+			ID:        s.nextId("email_outbox"),
+			Recipient: recipient,
+			Subject:   subject,
+			TextBody:  textBody,
+			HTMLBody:  htmlBody,
+			Status:    EmailStatusPending,
+			CreatedAt: now,
+		},
+		nextAttemptAt: now,
+	}
+	s.outbox[email.ID] = email
+	return email.ID, nil
+}
+
+func (s *MemoryStore) ClaimDueOutboxEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var due []*memOutboxEmail
+	for _, email := range s.outbox {
+		if email.Status == EmailStatusPending && !email.nextAttemptAt.After(now) {
+			due = append(due, email)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].nextAttemptAt.Equal(due[j].nextAttemptAt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].nextAttemptAt.Before(due[j].nextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var emails []models.OutboxEmail
+	for _, email := range due {
+		email.nextAttemptAt = now.Add(lease)
+		emails = append(emails, email.OutboxEmail)
+	}
+	return emails, nil
+}
+
+func (s *MemoryStore) MarkOutboxEmailSent(ctx context.Context, emailId int64, attempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if email, ok := s.outbox[emailId]; ok {
+		email.Status = EmailStatusSent
+		email.Attempts = attempts
+		email.lastError = ""
+		email.TextBody = ""
+		email.HTMLBody = ""
+	}
+	return nil
+}
+
+func (s *MemoryStore) MarkOutboxEmailFailed(ctx context.Context, emailId int64, attempts int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if email, ok := s.outbox[emailId]; ok {
+		email.Status = EmailStatusPending
+		if dead {
+			email.Status = EmailStatusDead
+			email.TextBody = ""
+			email.HTMLBody = ""
+		}
+		email.Attempts = attempts
+		email.lastError = lastError
+		email.nextAttemptAt = nextAttemptAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetOutboxEmail(ctx context.Context, emailId int64) (models.OutboxEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	email, ok := s.outbox[emailId]
+	if !ok {
+		return models.OutboxEmail{}, sql.ErrNoRows
+	}
+	return email.OutboxEmail, nil
+}
+
+func (s *MemoryStore) PurgeOutboxEmails(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged int64
+	for id, email := range s.outbox {
+		if email.Status != EmailStatusPending && email.CreatedAt.Before(before) {
+			delete(s.outbox, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
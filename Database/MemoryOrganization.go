@@ -0,0 +1,476 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	models "go_server/Models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type orgUserKey struct {
+	orgId  int
+	userId int
+}
+
+type memScimToken struct {
+	models.ScimToken
+	tokenHash string
+}
+
+type memGroup struct {
+	models.Group
+	members map[int]bool
+}
+
+func (s *MemoryStore) InsertOrganization(ctx context.Context, name string, ownerId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[ownerId]; !ok {
+		return 0, errMissingReference
+	}
+	org := &models.Organization{ID: int(s.nextId("organizations")), Name: name, OwnerId: ownerId, CreatedAt: time.Now()}
+	s.organizations[org.ID] = org
+	return org.ID, nil
+}
+
+func (s *MemoryStore) deleteOrganization(orgId int) {
+	delete(s.organizations, orgId)
+	for key := range s.orgUsers {
+		if key.orgId == orgId {
+			delete(s.orgUsers, key)
+		}
+	}
+	for id, token := range s.scimTokens {
+		if token.OrgId == orgId {
+			delete(s.scimTokens, id)
+		}
+	}
+	for id, token := range s.initialTokens {
+		if token.OrgId == orgId {
+			delete(s.initialTokens, id)
+		}
+	}
+	for id, group := range s.groups {
+		if group.OrgId == orgId {
+			delete(s.groups, id)
+		}
+	}
+}
+
+func (s *MemoryStore) GetOrganizationById(ctx context.Context, orgId int) (models.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	org, ok := s.organizations[orgId]
+	if !ok {
+		return models.Organization{}, sql.ErrNoRows
+	}
+	return *org, nil
+}
+
+func (s *MemoryStore) GetOrganizationsOfUser(ctx context.Context, userId int) ([]models.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orgs := []models.Organization{}
+	for _, id := range sortedIds(s.organizations) {
+		if org := s.organizations[id]; org.OwnerId == userId {
+			orgs = append(orgs, *org)
+		}
+	}
+	return orgs, nil
+}
+
+func (s *MemoryStore) InsertScimToken(ctx context.Context, orgId int, tokenHash, description string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.organizations[orgId]; !ok {
+		return 0, errMissingReference
+	}
+	for _, token := range s.scimTokens {
+		if token.tokenHash == tokenHash {
+			return 0, errDuplicateKey
+		}
+	}
+	token := &memScimToken{
+		ScimToken: models.ScimToken{ID: int(s.nextId("scim_tokens")), OrgId: orgId, Description: description, CreatedAt: time.Now()},
+		tokenHash: tokenHash,
+	}
+	s.scimTokens[token.ID] = token
+	return token.ID, nil
+}
+
+func (s *MemoryStore) GetScimTokensOfOrg(ctx context.Context, orgId int) ([]models.ScimToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := []models.ScimToken{}
+	for _, id := range sortedIds(s.scimTokens) {
+		if token := s.scimTokens[id]; token.OrgId == orgId {
+			tokens = append(tokens, token.ScimToken)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *MemoryStore) RevokeScimToken(ctx context.Context, orgId, tokenId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.scimTokens[tokenId]
+	if !ok || token.OrgId != orgId || token.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	return nil
+}
+
+func (s *MemoryStore) GetOrgIdByScimToken(ctx context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.scimTokens {
+		if token.tokenHash == tokenHash && token.RevokedAt == nil {
+			now := time.Now()
+			token.LastUsedAt = &now
+			return token.OrgId, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+// matchFilter evaluates conditions that buildFilter has already accepted
+// against one row. field returns the value of a column expression, nil
+// standing for NULL; isMember answers the "members" attribute of groups.
+func matchFilter(columns map[string]filterColumn, conds []models.FilterCondition, field func(expr string) interface{}, isMember func(userId int) bool) bool {
+	for _, cond := range conds {
+		col := columns[strings.ToLower(cond.Attr)]
+		op := strings.ToLower(cond.Op)
+		value := field(col.expr)
+		if op == "pr" {
+			if value == nil {
+				return false
+			}
+			continue
+		}
+		if value == nil {
+			// comparisons with NULL are never true
+			return false
+		}
+
+		var matched bool
+		switch col.kind {
+		case "bool":
+			expected, _ := strconv.ParseBool(cond.Value)
+			matched = value.(bool) == expected
+			if op == "ne" {
+				matched = !matched
+			}
+		case "int":
+			expected, _ := strconv.Atoi(cond.Value)
+			matched = value.(int) == expected
+		case "member":
+			expected, _ := strconv.Atoi(cond.Value)
+			matched = isMember(expected)
+		default:
+			actual := strings.ToLower(value.(string))
+			expected := strings.ToLower(cond.Value)
+			switch op {
+			case "eq":
+				matched = actual == expected
+			case "ne":
+				matched = actual != expected
+			case "co":
+				matched = strings.Contains(actual, expected)
+			case "sw":
+				matched = strings.HasPrefix(actual, expected)
+			case "ew":
+				matched = strings.HasSuffix(actual, expected)
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// page applies OFFSET and LIMIT to the number of matching rows.
+func page(total, offset, limit int) (int, int) {
+	start := min(max(offset, 0), total)
+	end := min(start+max(limit, 0), total)
+	return start, end
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func (s *MemoryStore) scimUser(orgId int, user *memUser) models.ScimUser {
+	return models.ScimUser{
+		ID:         user.ID,
+		Email:      user.Email,
+		Name:       user.Name,
+		Active:     user.Active,
+		ExternalId: s.orgUsers[orgUserKey{orgId, user.ID}],
+		Version:    user.version,
+		CreatedAt:  user.createdAt,
+		UpdatedAt:  user.updatedAt,
+	}
+}
+
+func (s *MemoryStore) ListOrgUsers(ctx context.Context, orgId int, conds []models.FilterCondition, offset, limit int) ([]models.ScimUser, int, error) {
+	if _, _, err := buildFilter(scimUserColumns, conds, nil); err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := []models.ScimUser{}
+	for _, id := range sortedIds(s.users) {
+		externalId, ok := s.orgUsers[orgUserKey{orgId, id}]
+		if !ok {
+			continue
+		}
+		user := s.users[id]
+		field := func(expr string) interface{} {
+			switch expr {
+			case "users.id":
+				return user.ID
+			case "users.email":
+				return user.Email
+			case "users.name":
+				return user.Name
+			case "users.active":
+				return user.Active
+			default:
+				return nullIfEmpty(externalId)
+			}
+		}
+		if matchFilter(scimUserColumns, conds, field, nil) {
+			matches = append(matches, s.scimUser(orgId, user))
+		}
+	}
+	start, end := page(len(matches), offset, limit)
+	return matches[start:end], len(matches), nil
+}
+
+func (s *MemoryStore) GetOrgUser(ctx context.Context, orgId, userId int) (models.ScimUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userId]
+	if _, member := s.orgUsers[orgUserKey{orgId, userId}]; !ok || !member {
+		return models.ScimUser{}, sql.ErrNoRows
+	}
+	return s.scimUser(orgId, user), nil
+}
+
+func (s *MemoryStore) CreateOrgUser(ctx context.Context, orgId int, user models.ScimUser, passwordHash string) (models.ScimUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findUserByEmail(user.Email, true) != nil {
+		return models.ScimUser{}, ErrUserExists
+	}
+	if _, ok := s.organizations[orgId]; !ok {
+		return models.ScimUser{}, errMissingReference
+	}
+	created := s.insertUser(user.Email, user.Name, passwordHash, user.Active)
+	s.orgUsers[orgUserKey{orgId, created.ID}] = user.ExternalId
+	return s.scimUser(orgId, created), nil
+}
+
+func (s *MemoryStore) UpdateOrgUser(ctx context.Context, orgId int, user models.ScimUser, expectedVersion int) (models.ScimUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.users {
+		if other.ID != user.ID && strings.EqualFold(other.Email, user.Email) {
+			return models.ScimUser{}, ErrUserExists
+		}
+	}
+	existing, ok := s.users[user.ID]
+	if _, member := s.orgUsers[orgUserKey{orgId, user.ID}]; !ok || !member || existing.version != expectedVersion {
+		return models.ScimUser{}, ErrVersionMismatch
+	}
+	existing.Email = user.Email
+	existing.Name = user.Name
+	existing.Active = user.Active
+	existing.version++
+	existing.updatedAt = time.Now()
+	s.orgUsers[orgUserKey{orgId, user.ID}] = user.ExternalId
+
+	if !user.Active {
+		for key := range s.sessions {
+			if key.userId == user.ID {
+				delete(s.sessions, key)
+			}
+		}
+	}
+	return s.scimUser(orgId, existing), nil
+}
+
+func (s *MemoryStore) RemoveOrgUser(ctx context.Context, orgId, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := orgUserKey{orgId, userId}
+	if _, ok := s.orgUsers[key]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.orgUsers, key)
+	if user, ok := s.users[userId]; ok {
+		user.Active = false
+		user.version++
+		user.updatedAt = time.Now()
+	}
+	for key := range s.sessions {
+		if key.userId == userId {
+			delete(s.sessions, key)
+		}
+	}
+	for _, group := range s.groups {
+		if group.OrgId == orgId {
+			delete(group.members, userId)
+		}
+	}
+	return nil
+}
+
+// group returns a copy of the group with its members ordered by user id.
+func (s *MemoryStore) group(group *memGroup) models.Group {
+	result := group.Group
+	result.Members = []models.GroupMember{}
+	for _, userId := range sortedIds(group.members) {
+		if user, ok := s.users[userId]; ok {
+			result.Members = append(result.Members, models.GroupMember{UserId: userId, Name: user.Name})
+		}
+	}
+	return result
+}
+
+func (s *MemoryStore) ListGroups(ctx context.Context, orgId int, conds []models.FilterCondition, offset, limit int) ([]models.Group, int, error) {
+	if _, _, err := buildFilter(scimGroupColumns, conds, nil); err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := []models.Group{}
+	for _, id := range sortedIds(s.groups) {
+		group := s.groups[id]
+		if group.OrgId != orgId {
+			continue
+		}
+		field := func(expr string) interface{} {
+			switch expr {
+			case "groups.id":
+				return group.ID
+			case "groups.display_name":
+				return group.DisplayName
+			default:
+				return nullIfEmpty(group.ExternalId)
+			}
+		}
+		isMember := func(userId int) bool {
+			return group.members[userId]
+		}
+		if matchFilter(scimGroupColumns, conds, field, isMember) {
+			matches = append(matches, s.group(group))
+		}
+	}
+	start, end := page(len(matches), offset, limit)
+	return matches[start:end], len(matches), nil
+}
+
+func (s *MemoryStore) GetGroup(ctx context.Context, orgId, groupId int) (models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	group, ok := s.groups[groupId]
+	if !ok || group.OrgId != orgId {
+		return models.Group{}, sql.ErrNoRows
+	}
+	return s.group(group), nil
+}
+
+// groupMembers validates the requested membership like replaceGroupMembers.
+func (s *MemoryStore) groupMembers(orgId int, group models.Group) (map[int]bool, error) {
+	members := map[int]bool{}
+	for _, userId := range memberIds(group) {
+		if _, ok := s.orgUsers[orgUserKey{orgId, userId}]; !ok {
+			return nil, ErrInvalidMember
+		}
+		members[userId] = true
+	}
+	return members, nil
+}
+
+func (s *MemoryStore) groupNameTaken(orgId, groupId int, displayName string) bool {
+	for _, other := range s.groups {
+		if other.OrgId == orgId && other.ID != groupId && other.DisplayName == displayName {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) CreateGroup(ctx context.Context, orgId int, group models.Group) (models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.organizations[orgId]; !ok {
+		return models.Group{}, errMissingReference
+	}
+	if s.groupNameTaken(orgId, 0, group.DisplayName) {
+		return models.Group{}, ErrGroupExists
+	}
+	members, err := s.groupMembers(orgId, group)
+	if err != nil {
+		return models.Group{}, err
+	}
+	now := time.Now()
+	created := &memGroup{
+		Group: models.Group{
+			ID:          int(s.nextId("groups")),
+			OrgId:       orgId,
+			DisplayName: group.DisplayName,
+			ExternalId:  group.ExternalId,
+			Version:     1,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		members: members,
+	}
+	s.groups[created.ID] = created
+	return s.group(created), nil
+}
+
+func (s *MemoryStore) UpdateGroup(ctx context.Context, orgId int, group models.Group, expectedVersion int) (models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.groups[group.ID]
+	if !ok || existing.OrgId != orgId || existing.Version != expectedVersion {
+		return models.Group{}, ErrVersionMismatch
+	}
+	if s.groupNameTaken(orgId, group.ID, group.DisplayName) {
+		return models.Group{}, ErrGroupExists
+	}
+	members, err := s.groupMembers(orgId, group)
+	if err != nil {
+		return models.Group{}, err
+	}
+	existing.DisplayName = group.DisplayName
+	existing.ExternalId = group.ExternalId
+	existing.Version++
+	existing.UpdatedAt = time.Now()
+	existing.members = members
+	return s.group(existing), nil
+}
+
+func (s *MemoryStore) DeleteGroup(ctx context.Context, orgId, groupId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	group, ok := s.groups[groupId]
+	if !ok || group.OrgId != orgId {
+		return sql.ErrNoRows
+	}
+	delete(s.groups, groupId)
+	return nil
+}
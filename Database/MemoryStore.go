@@ -0,0 +1,740 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	models "go_server/Models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errMissingReference is what MemoryStore reports where PostgreSQL would
+// reject a write with a foreign key or primary key violation.
+var errMissingReference = errors.New("referenced row does not exist")
+var errDuplicateKey = errors.New("duplicate key")
+
+// MemoryStore implements Store in memory. It follows the semantics of the
+// PostgreSQL schema, including cascading deletes, so it can stand in for
+// PostgresStore in tests; both are checked by the same conformance suite
+// in Database/storetest.
+type MemoryStore struct {
+	mu  sync.Mutex
+	ids map[string]int64
+
+	users       map[int]*memUser
+	apps        map[int]*models.App
+	appClients  map[int]*models.AppClient
+	exchanges   map[exchangeKey]*models.ExchangePolicy
+	sessions    map[sessionKey]string
+	tokens      map[int]*models.Token
+	magicLinks  map[string]*memMagicLink
+	resetTokens map[string]*memResetToken
+	deviceCodes map[int]*memDeviceCode
+	// registrations maps the registration access token hashes of
+	// dynamically registered clients to their apps
+	registrations map[string]int
+
+	organizations map[int]*models.Organization
+	orgUsers      map[orgUserKey]string
+	scimTokens    map[int]*memScimToken
+	initialTokens map[int]*memInitialToken
+	groups        map[int]*memGroup
+
+	webhookEndpoints  map[int]*models.WebhookEndpoint
+	webhookDeliveries map[int64]*models.WebhookDelivery
+	outbox            map[int64]*memOutboxEmail
+
+	identities  map[models.UserIdentity]int
+	userImports map[int]*models.UserImport
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type memUser struct {
+	models.User
+	version           int
+	verified          bool
+	admin             bool
+	passwordChangedAt time.Time
+	createdAt         time.Time
+	updatedAt         time.Time
+}
+
+type sessionKey struct {
+	userId int
+	appId  int
+}
+
+type exchangeKey struct {
+	targetAppId int
+	sourceAppId int
+}
+
+type memMagicLink struct {
+	email       string
+	appId       int
+	bindingHash string
+	expiresAt   time.Time
+	used        bool
+}
+
+type memResetToken struct {
+	userId    int
+	expiresAt time.Time
+	used      bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		ids:               map[string]int64{},
+		users:             map[int]*memUser{},
+		apps:              map[int]*models.App{},
+		appClients:        map[int]*models.AppClient{},
+		exchanges:         map[exchangeKey]*models.ExchangePolicy{},
+		sessions:          map[sessionKey]string{},
+		tokens:            map[int]*models.Token{},
+		magicLinks:        map[string]*memMagicLink{},
+		resetTokens:       map[string]*memResetToken{},
+		deviceCodes:       map[int]*memDeviceCode{},
+		registrations:     map[string]int{},
+		organizations:     map[int]*models.Organization{},
+		orgUsers:          map[orgUserKey]string{},
+		scimTokens:        map[int]*memScimToken{},
+		initialTokens:     map[int]*memInitialToken{},
+		groups:            map[int]*memGroup{},
+		webhookEndpoints:  map[int]*models.WebhookEndpoint{},
+		webhookDeliveries: map[int64]*models.WebhookDelivery{},
+		outbox:            map[int64]*memOutboxEmail{},
+		identities:        map[models.UserIdentity]int{},
+		userImports:       map[int]*models.UserImport{},
+	}
+}
+
+// nextId hands out ids per table the way a SERIAL column does.
+func (s *MemoryStore) nextId(table string) int64 {
+	s.ids[table]++
+	return s.ids[table]
+}
+
+func sortedIds[T any](rows map[int]T) []int {
+	ids := make([]int, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// findUserByEmail returns the user with the lowest id whose email matches,
+// case-insensitively when fold is set.
+func (s *MemoryStore) findUserByEmail(email string, fold bool) *memUser {
+	for _, id := range sortedIds(s.users) {
+		user := s.users[id]
+		if user.Email == email || (fold && strings.EqualFold(user.Email, email)) {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) insertUser(email, name, password string, active bool) *memUser {
+	now := time.Now()
+	user := &memUser{
+		User:      models.User{ID: int(s.nextId("users")), Name: name, Email: email, Password: password, Active: active},
+		version:   1,
+		createdAt: now,
+		updatedAt: now,
+	}
+	s.users[user.ID] = user
+	return user
+}
+
+func (s *MemoryStore) InsertUser(ctx context.Context, email, name, password string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insertUser(email, name, password, true).ID, nil
+}
+
+func (s *MemoryStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.findUserByEmail(email, false)
+	if user == nil {
+		return models.User{}, sql.ErrNoRows
+	}
+	return user.User, nil
+}
+
+func (s *MemoryStore) GetUserById(ctx context.Context, id int) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+	return user.User, nil
+}
+
+func (s *MemoryStore) CheckIfUserExists(ctx context.Context, email string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.findUserByEmail(email, false) != nil
+}
+
+func (s *MemoryStore) ChangePassword(ctx context.Context, userId, keepAppId int, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.users[userId]; ok {
+		now := time.Now()
+		user.Password = password
+		user.passwordChangedAt = now
+		user.updatedAt = now
+	}
+	s.deleteResetTokensOf(userId)
+	for key := range s.sessions {
+		if key.userId == userId && key.appId != keepAppId {
+			delete(s.sessions, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdatePasswordHash(ctx context.Context, userId int, oldHash, newHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.users[userId]; ok && user.Password == oldHash {
+		user.Password = newHash
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetPasswordChangedAt(ctx context.Context, userId int) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userId]
+	if !ok || !user.Active {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return user.passwordChangedAt, nil
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	s.deleteUser(id)
+	return nil
+}
+
+// deleteUser removes a user and everything that references it, like the
+// ON DELETE CASCADE foreign keys do.
+func (s *MemoryStore) deleteUser(id int) {
+	delete(s.users, id)
+	for appId, app := range s.apps {
+		if app.UserId == id {
+			s.deleteApp(appId)
+		}
+	}
+	for orgId, org := range s.organizations {
+		if org.OwnerId == id {
+			s.deleteOrganization(orgId)
+		}
+	}
+	for key := range s.sessions {
+		if key.userId == id {
+			delete(s.sessions, key)
+		}
+	}
+	for key := range s.orgUsers {
+		if key.userId == id {
+			delete(s.orgUsers, key)
+		}
+	}
+	for _, group := range s.groups {
+		delete(group.members, id)
+	}
+	for identity, userId := range s.identities {
+		if userId == id {
+			delete(s.identities, identity)
+		}
+	}
+	s.deleteResetTokensOf(id)
+	for codeId, code := range s.deviceCodes {
+		if code.UserId == id {
+			delete(s.deviceCodes, codeId)
+		}
+	}
+}
+
+func (s *MemoryStore) SetUserAdmin(ctx context.Context, email string, admin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			user.admin = admin
+			found = true
+		}
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *MemoryStore) IsUserAdmin(ctx context.Context, userId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userId]
+	if !ok || !user.Active {
+		return false, nil
+	}
+	return user.admin, nil
+}
+
+func (s *MemoryStore) InsertApp(ctx context.Context, name, callbackUrl string, userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userId]; !ok {
+		return 0, errMissingReference
+	}
+	app := &models.App{ID: int(s.nextId("apps")), Name: name, CallbackUrl: callbackUrl, UserId: userId}
+	s.apps[app.ID] = app
+	return app.ID, nil
+}
+
+func (s *MemoryStore) GetAllAppsOfUser(ctx context.Context, userId int) ([]models.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var apps []models.App
+	for _, id := range sortedIds(s.apps) {
+		app := s.apps[id]
+		if app.UserId == userId {
+			apps = append(apps, models.App{ID: app.ID, Name: app.Name, CallbackUrl: app.CallbackUrl})
+		}
+	}
+	return apps, nil
+}
+
+func (s *MemoryStore) GetAppCallbackUrls(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls := []string{}
+	for _, id := range sortedIds(s.apps) {
+		urls = append(urls, s.apps[id].CallbackUrl)
+	}
+	return urls, nil
+}
+
+func (s *MemoryStore) GetAppById(ctx context.Context, appId int) (models.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[appId]
+	if !ok {
+		return models.App{}, sql.ErrNoRows
+	}
+	result := *app
+	result.UserId = 0
+	return result, nil
+}
+
+func (s *MemoryStore) UpdateApp(ctx context.Context, appId, userId int, name, callbackUrl string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name == "" && callbackUrl == "" {
+		return errors.New("no app fields to update")
+	}
+	app, ok := s.apps[appId]
+	if !ok || app.UserId != userId {
+		return sql.ErrNoRows
+	}
+	if name != "" {
+		app.Name = name
+	}
+	if callbackUrl != "" {
+		app.CallbackUrl = callbackUrl
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteApp(ctx context.Context, appId, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[appId]
+	if !ok || app.UserId != userId {
+		return sql.ErrNoRows
+	}
+	s.deleteApp(appId)
+	return nil
+}
+
+func (s *MemoryStore) deleteApp(appId int) {
+	delete(s.apps, appId)
+	delete(s.appClients, appId)
+	for hash, id := range s.registrations {
+		if id == appId {
+			delete(s.registrations, hash)
+		}
+	}
+	for key := range s.exchanges {
+		if key.targetAppId == appId || key.sourceAppId == appId {
+			delete(s.exchanges, key)
+		}
+	}
+	for key := range s.sessions {
+		if key.appId == appId {
+			delete(s.sessions, key)
+		}
+	}
+	for id, token := range s.tokens {
+		if token.AppId == appId {
+			delete(s.tokens, id)
+		}
+	}
+	for hash, link := range s.magicLinks {
+		if link.appId == appId {
+			delete(s.magicLinks, hash)
+		}
+	}
+	for id, code := range s.deviceCodes {
+		if code.AppId == appId {
+			delete(s.deviceCodes, id)
+		}
+	}
+	for id, endpoint := range s.webhookEndpoints {
+		if endpoint.AppId == appId {
+			s.deleteWebhookEndpoint(id)
+		}
+	}
+}
+
+func (s *MemoryStore) GetAppOfUser(ctx context.Context, appId, userId int) (models.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[appId]
+	if !ok || app.UserId != userId {
+		return models.App{}, sql.ErrNoRows
+	}
+	return *app, nil
+}
+
+func (s *MemoryStore) UpdateAppBranding(ctx context.Context, appId, userId int, logoUrl, brandColor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[appId]
+	if !ok || app.UserId != userId {
+		return sql.ErrNoRows
+	}
+	app.LogoUrl = logoUrl
+	app.BrandColor = brandColor
+	return nil
+}
+
+func (s *MemoryStore) GetAppClient(ctx context.Context, appId int) (models.AppClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[appId]; !ok {
+		return models.AppClient{}, sql.ErrNoRows
+	}
+	client := models.AppClient{AppId: appId, Scopes: []string{}}
+	if stored, ok := s.appClients[appId]; ok {
+		client.SecretHash = stored.SecretHash
+		client.Scopes = append(client.Scopes, stored.Scopes...)
+	}
+	return client, nil
+}
+
+func (s *MemoryStore) SetAppClientSecret(ctx context.Context, appId, userId int, secretHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client, err := s.ownedAppClient(appId, userId)
+	if err != nil {
+		return err
+	}
+	client.SecretHash = secretHash
+	return nil
+}
+
+func (s *MemoryStore) SetAppScopes(ctx context.Context, appId, userId int, scopes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client, err := s.ownedAppClient(appId, userId)
+	if err != nil {
+		return err
+	}
+	client.Scopes = append([]string{}, scopes...)
+	return nil
+}
+
+func (s *MemoryStore) ownedAppClient(appId, userId int) (*models.AppClient, error) {
+	app, ok := s.apps[appId]
+	if !ok || app.UserId != userId {
+		return nil, sql.ErrNoRows
+	}
+	client, ok := s.appClients[appId]
+	if !ok {
+		client = &models.AppClient{AppId: appId}
+		s.appClients[appId] = client
+	}
+	return client, nil
+}
+
+func copyExchangePolicy(policy *models.ExchangePolicy) models.ExchangePolicy {
+	result := *policy
+	result.Scopes = append([]string{}, policy.Scopes...)
+	return result
+}
+
+func (s *MemoryStore) SetExchangePolicy(ctx context.Context, targetAppId, userId, sourceAppId int, scopes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if app, ok := s.apps[targetAppId]; !ok || app.UserId != userId {
+		return sql.ErrNoRows
+	}
+	if _, ok := s.apps[sourceAppId]; !ok {
+		return errMissingReference
+	}
+	key := exchangeKey{targetAppId, sourceAppId}
+	if policy, ok := s.exchanges[key]; ok {
+		policy.Scopes = append([]string{}, scopes...)
+		return nil
+	}
+	s.exchanges[key] = &models.ExchangePolicy{
+		TargetAppId: targetAppId,
+		SourceAppId: sourceAppId,
+		Scopes:      append([]string{}, scopes...),
+		CreatedAt:   time.Now(),
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetExchangePolicies(ctx context.Context, targetAppId int) ([]models.ExchangePolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policies := []models.ExchangePolicy{}
+	for key, policy := range s.exchanges {
+		if key.targetAppId == targetAppId {
+			policies = append(policies, copyExchangePolicy(policy))
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].SourceAppId < policies[j].SourceAppId })
+	return policies, nil
+}
+
+func (s *MemoryStore) GetExchangePolicy(ctx context.Context, sourceAppId, targetAppId int) (models.ExchangePolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy, ok := s.exchanges[exchangeKey{targetAppId, sourceAppId}]
+	if !ok {
+		return models.ExchangePolicy{}, sql.ErrNoRows
+	}
+	return copyExchangePolicy(policy), nil
+}
+
+func (s *MemoryStore) DeleteExchangePolicy(ctx context.Context, targetAppId, userId, sourceAppId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := exchangeKey{targetAppId, sourceAppId}
+	if app, ok := s.apps[targetAppId]; !ok || app.UserId != userId || s.exchanges[key] == nil {
+		return sql.ErrNoRows
+	}
+	delete(s.exchanges, key)
+	return nil
+}
+
+func (s *MemoryStore) UpdateRefreshToken(ctx context.Context, userId, appId int, refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sessionKey{userId, appId}
+	if _, ok := s.sessions[key]; ok {
+		s.sessions[key] = refreshToken
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteSession(ctx context.Context, userId, appId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionKey{userId, appId})
+	return nil
+}
+
+func (s *MemoryStore) InsertOrUpdateSession(ctx context.Context, userId, appId int, refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userId]; !ok {
+		return errMissingReference
+	}
+	if _, ok := s.apps[appId]; !ok {
+		return errMissingReference
+	}
+	s.sessions[sessionKey{userId, appId}] = refreshToken
+	return nil
+}
+
+func (s *MemoryStore) GetRefreshToken(ctx context.Context, userId, appId int) (string, models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refreshToken, ok := s.sessions[sessionKey{userId, appId}]
+	user, found := s.users[userId]
+	if !ok || !found || !user.Active {
+		return "", models.User{}, sql.ErrNoRows
+	}
+	return refreshToken, models.User{Name: user.Name, Email: user.Email}, nil
+}
+
+func (s *MemoryStore) GetAppIdsOfUserSessions(ctx context.Context, userId int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var appIds []int
+	for key := range s.sessions {
+		if key.userId == userId {
+			appIds = append(appIds, key.appId)
+		}
+	}
+	sort.Ints(appIds)
+	return appIds, nil
+}
+
+func (s *MemoryStore) InsertToken(ctx context.Context, appId int, token, refreshToken string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[appId]; !ok {
+		return 0, errMissingReference
+	}
+	row := &models.Token{ID: int(s.nextId("tokens")), AppId: appId, Token: token, RefreshToken: refreshToken}
+	s.tokens[row.ID] = row
+	return row.ID, nil
+}
+
+func (s *MemoryStore) GetTokenById(ctx context.Context, tokenId int) (models.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[tokenId]
+	if !ok {
+		return models.Token{}, sql.ErrNoRows
+	}
+	return *token, nil
+}
+
+func (s *MemoryStore) DeleteToken(ctx context.Context, tokenId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, tokenId)
+	return nil
+}
+
+func (s *MemoryStore) InsertMagicLink(ctx context.Context, tokenHash, email string, appId int, bindingHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for hash, link := range s.magicLinks {
+		if link.email == email && (link.expiresAt.Before(now) || link.used) {
+			delete(s.magicLinks, hash)
+		}
+	}
+	if _, ok := s.magicLinks[tokenHash]; ok {
+		return errDuplicateKey
+	}
+	if _, ok := s.apps[appId]; appId != 0 && !ok {
+		return errMissingReference
+	}
+	s.magicLinks[tokenHash] = &memMagicLink{email: email, appId: appId, bindingHash: bindingHash, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryStore) ConsumeMagicLink(ctx context.Context, tokenHash, bindingHash string) (models.MagicLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.magicLinks[tokenHash]
+	if !ok || link.bindingHash != bindingHash || link.used || !link.expiresAt.After(time.Now()) {
+		return models.MagicLink{}, sql.ErrNoRows
+	}
+	link.used = true
+	return models.MagicLink{Email: link.email, AppId: link.appId, ExpiresAt: link.expiresAt}, nil
+}
+
+func (s *MemoryStore) deleteResetTokensOf(userId int) {
+	for hash, token := range s.resetTokens {
+		if token.userId == userId {
+			delete(s.resetTokens, hash)
+		}
+	}
+}
+
+func (s *MemoryStore) InsertPasswordResetToken(ctx context.Context, tokenHash string, userId int, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userId]; !ok {
+		return errMissingReference
+	}
+	s.deleteResetTokensOf(userId)
+	if _, ok := s.resetTokens[tokenHash]; ok {
+		return errDuplicateKey
+	}
+	s.resetTokens[tokenHash] = &memResetToken{userId: userId, expiresAt: expiresAt}
+	return nil
+}
+
+// validResetToken returns the token and its active user when the token can
+// still be used.
+func (s *MemoryStore) validResetToken(tokenHash string) (*memResetToken, *memUser) {
+	token, ok := s.resetTokens[tokenHash]
+	if !ok || token.used || !token.expiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	user, ok := s.users[token.userId]
+	if !ok || !user.Active {
+		return nil, nil
+	}
+	return token, user
+}
+
+func (s *MemoryStore) GetPasswordResetUser(ctx context.Context, tokenHash string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, user := s.validResetToken(tokenHash)
+	if user == nil {
+		return models.User{}, sql.ErrNoRows
+	}
+	return models.User{ID: user.ID, Name: user.Name, Email: user.Email, Active: user.Active}, nil
+}
+
+func (s *MemoryStore) ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (models.User, []int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, user := s.validResetToken(tokenHash)
+	if user == nil {
+		return models.User{}, nil, sql.ErrNoRows
+	}
+	now := time.Now()
+	user.Password = passwordHash
+	user.passwordChangedAt = now
+	user.updatedAt = now
+
+	var appIds []int
+	for key := range s.sessions {
+		if key.userId == user.ID {
+			appIds = append(appIds, key.appId)
+			delete(s.sessions, key)
+		}
+	}
+	sort.Ints(appIds)
+	s.deleteResetTokensOf(user.ID)
+	return models.User{ID: user.ID, Name: user.Name, Email: user.Email, Active: user.Active}, appIds, nil
+}
+
+// Ping always succeeds; there is no connection to lose.
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// HasPendingMigrations is always false as the in-memory schema is the code.
+func (s *MemoryStore) HasPendingMigrations(ctx context.Context) (bool, error) {
+	return false, nil
+}
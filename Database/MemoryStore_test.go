@@ -0,0 +1,13 @@
+package database_test
+
+import (
+	database "go_server/Database"
+	"go_server/Database/storetest"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return database.NewMemoryStore()
+	})
+}
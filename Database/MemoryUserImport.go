@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	models "go_server/Models"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (s *MemoryStore) InsertUserImport(ctx context.Context, checksum, source string, total int) (models.UserImport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	job := &models.UserImport{
+		ID:        int(s.nextId("user_imports")),
+		Checksum:  checksum,
+		Source:    source,
+		Total:     total,
+		Status:    UserImportRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.userImports[job.ID] = job
+	return *job, nil
+}
+
+func (s *MemoryStore) GetUserImport(ctx context.Context, id int) (models.UserImport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.userImports[id]
+	if !ok {
+		return models.UserImport{}, sql.ErrNoRows
+	}
+	return *job, nil
+}
+
+func (s *MemoryStore) GetRunningUserImport(ctx context.Context, checksum string) (models.UserImport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := sortedIds(s.userImports)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if job := s.userImports[ids[i]]; job.Checksum == checksum && job.Status == UserImportRunning {
+			return *job, nil
+		}
+	}
+	return models.UserImport{}, sql.ErrNoRows
+}
+
+func (s *MemoryStore) FinishUserImport(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.userImports[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	job.Status = UserImportFinished
+	job.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) GetExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wanted := map[string]bool{}
+	for _, email := range emails {
+		wanted[strings.ToLower(email)] = true
+	}
+	existing := map[string]bool{}
+	for _, user := range s.users {
+		if email := strings.ToLower(user.Email); wanted[email] {
+			existing[email] = true
+		}
+	}
+	return existing, nil
+}
+
+func (s *MemoryStore) GetLinkedIdentities(ctx context.Context, identities []models.UserIdentity) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	linked := map[string]bool{}
+	for _, identity := range identities {
+		if _, ok := s.identities[identity]; ok {
+			linked[identity.Provider+":"+identity.Subject] = true
+		}
+	}
+	return linked, nil
+}
+
+func (s *MemoryStore) ImportUserBatch(ctx context.Context, jobId int, users []models.TransferUser, nextRecord, skipped, failed int) ([]UserImportOutcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	outcomes := make([]UserImportOutcome, len(users))
+	imported := 0
+	for i, user := range users {
+		if s.findUserByEmail(user.Email, true) != nil {
+			skipped++
+			continue
+		}
+		created := s.insertUser(user.Email, user.Name, user.PasswordHash, user.Active)
+		created.verified = user.Verified
+		outcomes[i].Inserted = true
+		imported++
+
+		for _, identity := range user.Identities {
+			if _, ok := s.identities[identity]; ok {
+				outcomes[i].IdentityConflicts = append(outcomes[i].IdentityConflicts, identity)
+				continue
+			}
+			s.identities[identity] = created.ID
+		}
+	}
+
+	if job, ok := s.userImports[jobId]; ok {
+		job.NextRecord = nextRecord
+		job.Imported += imported
+		job.Skipped += skipped
+		job.Failed += failed
+		job.UpdatedAt = time.Now()
+	}
+	return outcomes, nil
+}
+
+func (s *MemoryStore) GetTransferUsers(ctx context.Context, afterId, limit int) ([]models.TransferUser, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := []models.TransferUser{}
+	lastId := afterId
+	for _, id := range sortedIds(s.users) {
+		if id <= afterId || len(users) == limit {
+			continue
+		}
+		user := s.users[id]
+		identities := []models.UserIdentity{}
+		for identity, userId := range s.identities {
+			if userId == id {
+				identities = append(identities, identity)
+			}
+		}
+		sort.Slice(identities, func(i, j int) bool {
+			if identities[i].Provider == identities[j].Provider {
+				return identities[i].Subject < identities[j].Subject
+			}
+			return identities[i].Provider < identities[j].Provider
+		})
+		users = append(users, models.TransferUser{
+			Name:         user.Name,
+			Email:        user.Email,
+			PasswordHash: user.Password,
+			Verified:     user.verified,
+			Active:       user.Active,
+			Identities:   identities,
+		})
+		lastId = id
+	}
+	return users, lastId, nil
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	models "go_server/Models"
+	"sort"
+	"time"
+)
+
+var errInvalidJSON = errors.New("invalid input syntax for type json")
+
+func copyEndpoint(endpoint *models.WebhookEndpoint) models.WebhookEndpoint {
+	result := *endpoint
+	result.Events = append([]string{}, endpoint.Events...)
+	return result
+}
+
+func (s *MemoryStore) InsertWebhookEndpoint(ctx context.Context, appId int, url, secret string, events []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[appId]; !ok {
+		return 0, errMissingReference
+	}
+	endpoint := &models.WebhookEndpoint{
+		ID:        int(s.nextId("webhook_endpoints")),
+		AppId:     appId,
+		Url:       url,
+		Secret:    secret,
+		Events:    append([]string{}, events...),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	s.webhookEndpoints[endpoint.ID] = endpoint
+	return endpoint.ID, nil
+}
+
+func (s *MemoryStore) GetWebhookEndpointsOfApp(ctx context.Context, appId int) ([]models.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoints := []models.WebhookEndpoint{}
+	for _, id := range sortedIds(s.webhookEndpoints) {
+		if endpoint := s.webhookEndpoints[id]; endpoint.AppId == appId {
+			endpoints = append(endpoints, copyEndpoint(endpoint))
+		}
+	}
+	return endpoints, nil
+}
+
+func (s *MemoryStore) GetWebhookEndpoint(ctx context.Context, endpointId, appId int) (models.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint, ok := s.webhookEndpoints[endpointId]
+	if !ok || endpoint.AppId != appId {
+		return models.WebhookEndpoint{}, sql.ErrNoRows
+	}
+	return copyEndpoint(endpoint), nil
+}
+
+func (s *MemoryStore) UpdateWebhookEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.webhookEndpoints[endpoint.ID]
+	if !ok || existing.AppId != endpoint.AppId {
+		return sql.ErrNoRows
+	}
+	existing.Url = endpoint.Url
+	existing.Secret = endpoint.Secret
+	existing.Events = append([]string{}, endpoint.Events...)
+	existing.Active = endpoint.Active
+	return nil
+}
+
+func (s *MemoryStore) DeleteWebhookEndpoint(ctx context.Context, endpointId, appId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint, ok := s.webhookEndpoints[endpointId]
+	if !ok || endpoint.AppId != appId {
+		return sql.ErrNoRows
+	}
+	s.deleteWebhookEndpoint(endpointId)
+	return nil
+}
+
+func (s *MemoryStore) deleteWebhookEndpoint(endpointId int) {
+	delete(s.webhookEndpoints, endpointId)
+	for id, delivery := range s.webhookDeliveries {
+		if delivery.EndpointId == endpointId {
+			delete(s.webhookDeliveries, id)
+		}
+	}
+}
+
+func (s *MemoryStore) insertWebhookDelivery(endpointId int, eventId, eventType string, payload []byte) (int64, error) {
+	// the payload column is JSONB, which refuses anything but JSON
+	if !json.Valid(payload) {
+		return 0, errInvalidJSON
+	}
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		ID:            s.nextId("webhook_deliveries"),
+		EndpointId:    endpointId,
+		EventId:       eventId,
+		EventType:     eventType,
+		Payload:       append(json.RawMessage{}, payload...),
+		Status:        WebhookStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	s.webhookDeliveries[delivery.ID] = delivery
+	return delivery.ID, nil
+}
+
+func (s *MemoryStore) InsertWebhookDeliveries(ctx context.Context, appId int, eventId, eventType string, payload []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, id := range sortedIds(s.webhookEndpoints) {
+		endpoint := s.webhookEndpoints[id]
+		if endpoint.AppId != appId || !endpoint.Active || !contains(endpoint.Events, eventType) {
+			continue
+		}
+		if _, err := s.insertWebhookDelivery(id, eventId, eventType, payload); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) InsertWebhookDelivery(ctx context.Context, endpointId int, eventId, eventType string, payload []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.webhookEndpoints[endpointId]; !ok {
+		return 0, errMissingReference
+	}
+	return s.insertWebhookDelivery(endpointId, eventId, eventType, payload)
+}
+
+func (s *MemoryStore) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var due []*models.WebhookDelivery
+	for _, delivery := range s.webhookDeliveries {
+		if delivery.Status == WebhookStatusPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range due {
+		delivery.NextAttemptAt = now.Add(lease)
+		endpoint := s.webhookEndpoints[delivery.EndpointId]
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:         delivery.ID,
+			EndpointId: delivery.EndpointId,
+			EventId:    delivery.EventId,
+			EventType:  delivery.EventType,
+			Payload:    append(json.RawMessage{}, delivery.Payload...),
+			Status:     WebhookStatusPending,
+			Attempts:   delivery.Attempts,
+			Url:        endpoint.Url,
+			Secret:     endpoint.Secret,
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *MemoryStore) MarkWebhookDeliverySucceeded(ctx context.Context, deliveryId int64, attempts, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if delivery, ok := s.webhookDeliveries[deliveryId]; ok {
+		now := time.Now()
+		delivery.Status = WebhookStatusSucceeded
+		delivery.Attempts = attempts
+		delivery.LastStatusCode = &statusCode
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	}
+	return nil
+}
+
+func (s *MemoryStore) MarkWebhookDeliveryFailed(ctx context.Context, deliveryId int64, attempts, statusCode int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if delivery, ok := s.webhookDeliveries[deliveryId]; ok {
+		delivery.Status = WebhookStatusPending
+		if dead {
+			delivery.Status = WebhookStatusDead
+		}
+		delivery.Attempts = attempts
+		delivery.LastStatusCode = nil
+		if statusCode != 0 {
+			delivery.LastStatusCode = &statusCode
+		}
+		delivery.LastError = lastError
+		delivery.NextAttemptAt = nextAttemptAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetWebhookDeliveries(ctx context.Context, endpointId, limit, offset int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matches []*models.WebhookDelivery
+	for _, delivery := range s.webhookDeliveries {
+		if delivery.EndpointId == endpointId {
+			matches = append(matches, delivery)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].ID > matches[j].ID
+		}
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+	start, end := page(len(matches), offset, limit)
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range matches[start:end] {
+		result := *delivery
+		result.Payload = append(json.RawMessage{}, delivery.Payload...)
+		deliveries = append(deliveries, result)
+	}
+	return deliveries, nil
+}
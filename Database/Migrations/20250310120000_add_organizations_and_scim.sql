@@ -0,0 +1,70 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE,
+	ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1,
+	ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE TABLE IF NOT EXISTS organizations (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	owner_id INT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+CREATE TABLE IF NOT EXISTS organization_users (
+	org_id INT NOT NULL,
+	user_id INT NOT NULL,
+	external_id VARCHAR(255),
+	PRIMARY KEY (org_id, user_id),
+	FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+CREATE TABLE IF NOT EXISTS scim_tokens (
+	id SERIAL PRIMARY KEY,
+	org_id INT NOT NULL,
+	token_hash VARCHAR(64) NOT NULL UNIQUE,
+	description VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+	);
+
+CREATE TABLE IF NOT EXISTS groups (
+	id SERIAL PRIMARY KEY,
+	org_id INT NOT NULL,
+	display_name VARCHAR(255) NOT NULL,
+	external_id VARCHAR(255),
+	version INT NOT NULL DEFAULT 1,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	UNIQUE (org_id, display_name),
+	FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+	);
+
+CREATE TABLE IF NOT EXISTS group_members (
+	group_id INT NOT NULL,
+	user_id INT NOT NULL,
+	PRIMARY KEY (group_id, user_id),
+	FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS scim_tokens;
+DROP TABLE IF EXISTS organization_users;
+DROP TABLE IF EXISTS organizations;
+ALTER TABLE users
+	DROP COLUMN IF EXISTS active,
+	DROP COLUMN IF EXISTS version,
+	DROP COLUMN IF EXISTS created_at,
+	DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
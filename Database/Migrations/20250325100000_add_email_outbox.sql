@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS email_outbox (
+	id BIGSERIAL PRIMARY KEY,
+	recipient VARCHAR(255) NOT NULL,
+	subject TEXT NOT NULL,
+	text_body TEXT NOT NULL,
+	html_body TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	sent_at TIMESTAMP
+	);
+
+CREATE INDEX IF NOT EXISTS email_outbox_due_idx ON email_outbox (next_attempt_at) WHERE status = 'pending';
+
+ALTER TABLE apps
+	ADD COLUMN IF NOT EXISTS logo_url TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS brand_color VARCHAR(16) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE apps
+	DROP COLUMN IF EXISTS logo_url,
+	DROP COLUMN IF EXISTS brand_color;
+DROP TABLE IF EXISTS email_outbox;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS magic_links (
+	token_hash CHAR(64) PRIMARY KEY,
+	email VARCHAR(255) NOT NULL,
+	app_id INT REFERENCES apps(id) ON DELETE CASCADE,
+	binding_hash CHAR(64) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+CREATE INDEX IF NOT EXISTS magic_links_email_idx ON magic_links (email);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS magic_links;
+-- +goose StatementEnd
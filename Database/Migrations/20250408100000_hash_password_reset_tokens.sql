@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+DROP TABLE IF EXISTS forget_password;
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_idx ON password_reset_tokens (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS password_reset_tokens;
+
+CREATE TABLE IF NOT EXISTS forget_password (
+	email VARCHAR(255) NOT NULL UNIQUE PRIMARY KEY,
+	token TEXT NOT NULL,
+	expired_at TIMESTAMP NOT NULL
+	);
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+	ALTER COLUMN password TYPE TEXT,
+	ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS user_identities (
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	provider VARCHAR(64) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (provider, subject)
+	);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_idx ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS user_imports (
+	id SERIAL PRIMARY KEY,
+	checksum CHAR(64) NOT NULL,
+	source VARCHAR(255) NOT NULL DEFAULT '',
+	total INT NOT NULL,
+	next_record INT NOT NULL DEFAULT 0,
+	imported INT NOT NULL DEFAULT 0,
+	skipped INT NOT NULL DEFAULT 0,
+	failed INT NOT NULL DEFAULT 0,
+	status VARCHAR(16) NOT NULL DEFAULT 'running',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+CREATE INDEX IF NOT EXISTS user_imports_checksum_idx ON user_imports (checksum) WHERE status = 'running';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_imports;
+DROP TABLE IF EXISTS user_identities;
+ALTER TABLE users
+	DROP COLUMN IF EXISTS email_verified,
+	DROP COLUMN IF EXISTS is_admin;
+-- +goose StatementEnd
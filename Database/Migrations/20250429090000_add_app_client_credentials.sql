@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE apps
+	ADD COLUMN IF NOT EXISTS client_secret_hash CHAR(64),
+	ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE apps
+	DROP COLUMN IF EXISTS client_secret_hash,
+	DROP COLUMN IF EXISTS scopes;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS device_codes (
+	id SERIAL PRIMARY KEY,
+	device_code_hash CHAR(64) NOT NULL UNIQUE,
+	user_code VARCHAR(16) NOT NULL UNIQUE,
+	app_id INT NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+	user_id INT REFERENCES users(id) ON DELETE CASCADE,
+	status VARCHAR(10) NOT NULL DEFAULT 'pending',
+	poll_interval INT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	last_polled_at TIMESTAMP,
+	decided_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+CREATE INDEX IF NOT EXISTS device_codes_expires_at_idx ON device_codes (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS device_codes;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS exchange_policies (
+	target_app_id INT NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+	source_app_id INT NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+	scopes TEXT[] NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (target_app_id, source_app_id)
+	);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exchange_policies;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS initial_access_tokens (
+	id SERIAL PRIMARY KEY,
+	org_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	description VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+	);
+
+ALTER TABLE apps
+	ADD COLUMN IF NOT EXISTS registration_token_hash CHAR(64) UNIQUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE apps
+	DROP COLUMN IF EXISTS registration_token_hash;
+DROP TABLE IF EXISTS initial_access_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the bodies of sent and dead emails hold reset and magic links; they are
+-- cleared as emails leave the queue from now on, and here for the old ones
+UPDATE email_outbox SET text_body = '', html_body = '' WHERE status <> 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- the cleared bodies cannot be restored
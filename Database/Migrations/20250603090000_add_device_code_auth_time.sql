@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- when the approving user last entered credentials, carried into the
+-- tokens of the device
+ALTER TABLE device_codes ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE device_codes DROP COLUMN IF EXISTS auth_time;
+-- +goose StatementEnd
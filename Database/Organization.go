@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	models "go_server/Models"
+)
+
+func (s *PostgresStore) InsertOrganization(ctx context.Context, name string, ownerId int) (int, error) {
+	query := `INSERT INTO organizations (name, owner_id) VALUES ($1, $2) RETURNING id`
+	var pk int
+	err := s.db.QueryRowContext(ctx, query, name, ownerId).Scan(&pk)
+	if err != nil {
+		return 0, err
+	}
+	return pk, nil
+}
+
+func (s *PostgresStore) GetOrganizationById(ctx context.Context, orgId int) (models.Organization, error) {
+	query := `SELECT id, name, owner_id, created_at FROM organizations WHERE id = $1`
+	var org models.Organization
+	err := s.db.QueryRowContext(ctx, query, orgId).Scan(&org.ID, &org.Name, &org.OwnerId, &org.CreatedAt)
+	if err != nil {
+		return models.Organization{}, err
+	}
+	return org, nil
+}
+
+func (s *PostgresStore) GetOrganizationsOfUser(ctx context.Context, userId int) ([]models.Organization, error) {
+	query := `SELECT id, name, owner_id, created_at FROM organizations WHERE owner_id = $1 ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.OwnerId, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+func (s *PostgresStore) InsertScimToken(ctx context.Context, orgId int, tokenHash, description string) (int, error) {
+	query := `INSERT INTO scim_tokens (org_id, token_hash, description) VALUES ($1, $2, $3) RETURNING id`
+	var pk int
+	err := s.db.QueryRowContext(ctx, query, orgId, tokenHash, description).Scan(&pk)
+	if err != nil {
+		return 0, err
+	}
+	return pk, nil
+}
+
+func (s *PostgresStore) GetScimTokensOfOrg(ctx context.Context, orgId int) ([]models.ScimToken, error) {
+	query := `SELECT id, org_id, description, created_at, last_used_at, revoked_at FROM scim_tokens WHERE org_id = $1 ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query, orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []models.ScimToken{}
+	for rows.Next() {
+		var token models.ScimToken
+		err := rows.Scan(&token.ID, &token.OrgId, &token.Description, &token.CreatedAt, &token.LastUsedAt, &token.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeScimToken returns sql.ErrNoRows when the token does not belong to the
+// organization or was already revoked.
+func (s *PostgresStore) RevokeScimToken(ctx context.Context, orgId, tokenId int) error {
+	query := `UPDATE scim_tokens SET revoked_at = NOW() WHERE id = $1 AND org_id = $2 AND revoked_at IS NULL`
+	return s.execExpectingRow(ctx, query, tokenId, orgId)
+}
+
+// GetOrgIdByScimToken resolves an active SCIM bearer token to its
+// organization and records the time it was last used.
+func (s *PostgresStore) GetOrgIdByScimToken(ctx context.Context, tokenHash string) (int, error) {
+	query := `UPDATE scim_tokens SET last_used_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL RETURNING org_id`
+	var orgId int
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&orgId)
+	if err != nil {
+		return 0, err
+	}
+	return orgId, nil
+}
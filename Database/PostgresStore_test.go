@@ -0,0 +1,35 @@
+package database_test
+
+import (
+	database "go_server/Database"
+	"go_server/Database/storetest"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+// TestPostgresStore runs the conformance suite against the database in
+// TEST_DATABASE_URL. Every table is emptied before each test, so never
+// point it at a database whose data matters.
+func TestPostgresStore(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := database.Connect(url, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	storetest.Run(t, func(t *testing.T) database.Store {
+		_, err := db.Exec(`TRUNCATE users, apps, sessions, tokens, organizations, organization_users, scim_tokens,
+			groups, group_members, webhook_endpoints, webhook_deliveries, email_outbox, magic_links,
+			password_reset_tokens, user_identities, user_imports RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database.NewPostgresStore(db)
+	})
+}
@@ -0,0 +1,505 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	models "go_server/Models"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidFilter   = errors.New("invalid filter")
+	ErrVersionMismatch = errors.New("resource version does not match")
+	ErrUserExists      = errors.New("user already exists")
+	ErrGroupExists     = errors.New("group already exists")
+	ErrInvalidMember   = errors.New("group member is not a user of the organization")
+)
+
+type filterColumn struct {
+	expr string
+	kind string
+}
+
+// SCIM attribute names are case-insensitive, so the maps are keyed by the
+// lower-cased attribute path.
+var scimUserColumns = map[string]filterColumn{
+	"id":             {"users.id", "int"},
+	"username":       {"users.email", "string"},
+	"emails":         {"users.email", "string"},
+	"emails.value":   {"users.email", "string"},
+	"displayname":    {"users.name", "string"},
+	"name.formatted": {"users.name", "string"},
+	"externalid":     {"ou.external_id", "string"},
+	"active":         {"users.active", "bool"},
+}
+
+var scimGroupColumns = map[string]filterColumn{
+	"id":            {"groups.id", "int"},
+	"displayname":   {"groups.display_name", "string"},
+	"externalid":    {"groups.external_id", "string"},
+	"members":       {"groups.id", "member"},
+	"members.value": {"groups.id", "member"},
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
+
+// buildFilter turns parsed SCIM filter conditions into a SQL boolean
+// expression. Placeholders continue numbering after the given args.
+func buildFilter(columns map[string]filterColumn, conds []models.FilterCondition, args []interface{}) (string, []interface{}, error) {
+	clauses := make([]string, 0, len(conds))
+	for _, cond := range conds {
+		col, ok := columns[strings.ToLower(cond.Attr)]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unsupported attribute %q", ErrInvalidFilter, cond.Attr)
+		}
+		op := strings.ToLower(cond.Op)
+		if op == "pr" {
+			clauses = append(clauses, fmt.Sprintf("(%s IS NOT NULL)", col.expr))
+			continue
+		}
+		placeholder := fmt.Sprintf("$%d", len(args)+1)
+
+		switch col.kind {
+		case "bool":
+			value, err := strconv.ParseBool(cond.Value)
+			if err != nil || (op != "eq" && op != "ne") {
+				return "", nil, fmt.Errorf("%w: %s %s %s", ErrInvalidFilter, cond.Attr, cond.Op, cond.Value)
+			}
+			sqlOp := "="
+			if op == "ne" {
+				sqlOp = "<>"
+			}
+			clauses = append(clauses, fmt.Sprintf("%s %s %s", col.expr, sqlOp, placeholder))
+			args = append(args, value)
+		case "int", "member":
+			value, err := strconv.Atoi(cond.Value)
+			if err != nil || op != "eq" {
+				return "", nil, fmt.Errorf("%w: %s %s %s", ErrInvalidFilter, cond.Attr, cond.Op, cond.Value)
+			}
+			if col.kind == "member" {
+				clauses = append(clauses, fmt.Sprintf("EXISTS (SELECT 1 FROM group_members gm WHERE gm.group_id = %s AND gm.user_id = %s)", col.expr, placeholder))
+			} else {
+				clauses = append(clauses, fmt.Sprintf("%s = %s", col.expr, placeholder))
+			}
+			args = append(args, value)
+		default:
+			expr := fmt.Sprintf("LOWER(%s)", col.expr)
+			value := strings.ToLower(cond.Value)
+			switch op {
+			case "eq":
+				clauses = append(clauses, fmt.Sprintf("%s = %s", expr, placeholder))
+			case "ne":
+				clauses = append(clauses, fmt.Sprintf("%s <> %s", expr, placeholder))
+			case "co":
+				clauses = append(clauses, fmt.Sprintf("%s LIKE %s", expr, placeholder))
+				value = "%" + escapeLike(value) + "%"
+			case "sw":
+				clauses = append(clauses, fmt.Sprintf("%s LIKE %s", expr, placeholder))
+				value = escapeLike(value) + "%"
+			case "ew":
+				clauses = append(clauses, fmt.Sprintf("%s LIKE %s", expr, placeholder))
+				value = "%" + escapeLike(value)
+			default:
+				return "", nil, fmt.Errorf("%w: unsupported operator %q", ErrInvalidFilter, cond.Op)
+			}
+			args = append(args, value)
+		}
+	}
+	if len(clauses) == 0 {
+		return "TRUE", args, nil
+	}
+	return strings.Join(clauses, " AND "), args, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+const scimUserSelect = `
+	SELECT users.id, users.email, users.name, users.active, COALESCE(ou.external_id, ''),
+		users.version, users.created_at, users.updated_at
+	FROM users
+	INNER JOIN organization_users ou ON ou.user_id = users.id
+`
+
+func scanScimUser(row interface{ Scan(...interface{}) error }) (models.ScimUser, error) {
+	var user models.ScimUser
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Active, &user.ExternalId,
+		&user.Version, &user.CreatedAt, &user.UpdatedAt)
+	return user, err
+}
+
+// ListOrgUsers returns one page of the organization's users matching the
+// filter together with the total number of matches.
+func (s *PostgresStore) ListOrgUsers(ctx context.Context, orgId int, conds []models.FilterCondition, offset, limit int) ([]models.ScimUser, int, error) {
+	where, args, err := buildFilter(scimUserColumns, conds, []interface{}{orgId})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM users INNER JOIN organization_users ou ON ou.user_id = users.id WHERE ou.org_id = $1 AND ` + where
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf("%s WHERE ou.org_id = $1 AND %s ORDER BY users.id OFFSET $%d LIMIT $%d",
+		scimUserSelect, where, len(args)+1, len(args)+2)
+	rows, err := s.db.QueryContext(ctx, query, append(args, offset, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	users := []models.ScimUser{}
+	for rows.Next() {
+		user, err := scanScimUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *PostgresStore) GetOrgUser(ctx context.Context, orgId, userId int) (models.ScimUser, error) {
+	query := scimUserSelect + ` WHERE ou.org_id = $1 AND users.id = $2`
+	user, err := scanScimUser(s.db.QueryRowContext(ctx, query, orgId, userId))
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	return user, nil
+}
+
+// CreateOrgUser creates a user account and links it to the organization.
+// Accounts that already exist are never linked implicitly, as that would
+// hand control of a self-registered account to the organization.
+func (s *PostgresStore) CreateOrgUser(ctx context.Context, orgId int, user models.ScimUser, passwordHash string) (models.ScimUser, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE LOWER(email) = LOWER($1)`, user.Email).Scan(&existing)
+	if err == nil {
+		return models.ScimUser{}, ErrUserExists
+	}
+	if err != sql.ErrNoRows {
+		return models.ScimUser{}, err
+	}
+
+	query := `INSERT INTO users (email, password, name, active) VALUES ($1, $2, $3, $4)
+		RETURNING id, version, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, user.Email, passwordHash, user.Name, user.Active).
+		Scan(&user.ID, &user.Version, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_users (org_id, user_id, external_id) VALUES ($1, $2, NULLIF($3, ''))`,
+		orgId, user.ID, user.ExternalId)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	return user, tx.Commit()
+}
+
+// UpdateOrgUser replaces the mutable attributes of an organization user.
+// The update only applies while the stored version still equals
+// expectedVersion; otherwise ErrVersionMismatch is returned. Deactivating a
+// user revokes all of their sessions.
+func (s *PostgresStore) UpdateOrgUser(ctx context.Context, orgId int, user models.ScimUser, expectedVersion int) (models.ScimUser, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE LOWER(email) = LOWER($1) AND id <> $2`, user.Email, user.ID).Scan(&existing)
+	if err == nil {
+		return models.ScimUser{}, ErrUserExists
+	}
+	if err != sql.ErrNoRows {
+		return models.ScimUser{}, err
+	}
+
+	query := `
+		UPDATE users SET email = $1, name = $2, active = $3, version = version + 1, updated_at = NOW()
+		WHERE id = $4 AND version = $5
+		AND EXISTS (SELECT 1 FROM organization_users WHERE org_id = $6 AND user_id = $4)
+		RETURNING version, created_at, updated_at
+	`
+	err = tx.QueryRowContext(ctx, query, user.Email, user.Name, user.Active, user.ID, expectedVersion, orgId).
+		Scan(&user.Version, &user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return models.ScimUser{}, ErrVersionMismatch
+	}
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE organization_users SET external_id = NULLIF($1, '') WHERE org_id = $2 AND user_id = $3`,
+		user.ExternalId, orgId, user.ID)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+
+	if !user.Active {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, user.ID); err != nil {
+			return models.ScimUser{}, err
+		}
+	}
+	return user, tx.Commit()
+}
+
+// RemoveOrgUser deprovisions a user: the account is deactivated, its
+// sessions revoked and it is detached from the organization and its groups.
+func (s *PostgresStore) RemoveOrgUser(ctx context.Context, orgId, userId int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM organization_users WHERE org_id = $1 AND user_id = $2`, orgId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	statements := []string{
+		`UPDATE users SET active = FALSE, version = version + 1, updated_at = NOW() WHERE id = $1`,
+		`DELETE FROM sessions WHERE user_id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, userId); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM group_members WHERE user_id = $1 AND group_id IN (SELECT id FROM groups WHERE org_id = $2)`,
+		userId, orgId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const scimGroupSelect = `
+	SELECT groups.id, groups.org_id, groups.display_name, COALESCE(groups.external_id, ''),
+		groups.version, groups.created_at, groups.updated_at
+	FROM groups
+`
+
+func scanGroup(row interface{ Scan(...interface{}) error }) (models.Group, error) {
+	var group models.Group
+	err := row.Scan(&group.ID, &group.OrgId, &group.DisplayName, &group.ExternalId,
+		&group.Version, &group.CreatedAt, &group.UpdatedAt)
+	return group, err
+}
+
+// loadGroupMembers fills in the members of the given groups with one query.
+func (s *PostgresStore) loadGroupMembers(ctx context.Context, groups []models.Group) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(groups))
+	index := make(map[int]int, len(groups))
+	for i, group := range groups {
+		ids[i] = int64(group.ID)
+		index[group.ID] = i
+		groups[i].Members = []models.GroupMember{}
+	}
+	query := `
+		SELECT gm.group_id, users.id, users.name
+		FROM group_members gm
+		INNER JOIN users ON users.id = gm.user_id
+		WHERE gm.group_id = ANY($1)
+		ORDER BY users.id
+	`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var groupId int
+		var member models.GroupMember
+		if err := rows.Scan(&groupId, &member.UserId, &member.Name); err != nil {
+			return err
+		}
+		i := index[groupId]
+		groups[i].Members = append(groups[i].Members, member)
+	}
+	return rows.Err()
+}
+
+func (s *PostgresStore) ListGroups(ctx context.Context, orgId int, conds []models.FilterCondition, offset, limit int) ([]models.Group, int, error) {
+	where, args, err := buildFilter(scimGroupColumns, conds, []interface{}{orgId})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM groups WHERE groups.org_id = $1 AND ` + where
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf("%s WHERE groups.org_id = $1 AND %s ORDER BY groups.id OFFSET $%d LIMIT $%d",
+		scimGroupSelect, where, len(args)+1, len(args)+2)
+	rows, err := s.db.QueryContext(ctx, query, append(args, offset, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	groups := []models.Group{}
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := s.loadGroupMembers(ctx, groups); err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+func (s *PostgresStore) GetGroup(ctx context.Context, orgId, groupId int) (models.Group, error) {
+	query := scimGroupSelect + ` WHERE groups.org_id = $1 AND groups.id = $2`
+	group, err := scanGroup(s.db.QueryRowContext(ctx, query, orgId, groupId))
+	if err != nil {
+		return models.Group{}, err
+	}
+	groups := []models.Group{group}
+	if err := s.loadGroupMembers(ctx, groups); err != nil {
+		return models.Group{}, err
+	}
+	return groups[0], nil
+}
+
+// replaceGroupMembers sets the membership of a group to exactly memberIds,
+// rejecting ids that are not users of the organization.
+func replaceGroupMembers(ctx context.Context, tx *tracedTx, orgId, groupId int, memberIds []int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM group_members WHERE group_id = $1`, groupId); err != nil {
+		return err
+	}
+	unique := map[int]bool{}
+	ids := make([]int64, 0, len(memberIds))
+	for _, id := range memberIds {
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `
+		INSERT INTO group_members (group_id, user_id)
+		SELECT $1, user_id FROM organization_users WHERE org_id = $2 AND user_id = ANY($3)
+	`
+	result, err := tx.ExecContext(ctx, query, groupId, orgId, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(affected) != len(ids) {
+		return ErrInvalidMember
+	}
+	return nil
+}
+
+func memberIds(group models.Group) []int {
+	ids := make([]int, len(group.Members))
+	for i, member := range group.Members {
+		ids[i] = member.UserId
+	}
+	return ids
+}
+
+func (s *PostgresStore) CreateGroup(ctx context.Context, orgId int, group models.Group) (models.Group, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Group{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO groups (org_id, display_name, external_id) VALUES ($1, $2, NULLIF($3, '')) RETURNING id`
+	var groupId int
+	err = tx.QueryRowContext(ctx, query, orgId, group.DisplayName, group.ExternalId).Scan(&groupId)
+	if isUniqueViolation(err) {
+		return models.Group{}, ErrGroupExists
+	}
+	if err != nil {
+		return models.Group{}, err
+	}
+	if err := replaceGroupMembers(ctx, tx, orgId, groupId, memberIds(group)); err != nil {
+		return models.Group{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Group{}, err
+	}
+	return s.GetGroup(ctx, orgId, groupId)
+}
+
+// UpdateGroup replaces a group's attributes and membership, guarded by the
+// same optimistic version check as UpdateOrgUser.
+func (s *PostgresStore) UpdateGroup(ctx context.Context, orgId int, group models.Group, expectedVersion int) (models.Group, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Group{}, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE groups SET display_name = $1, external_id = NULLIF($2, ''), version = version + 1, updated_at = NOW()
+		WHERE id = $3 AND org_id = $4 AND version = $5
+	`
+	result, err := tx.ExecContext(ctx, query, group.DisplayName, group.ExternalId, group.ID, orgId, expectedVersion)
+	if isUniqueViolation(err) {
+		return models.Group{}, ErrGroupExists
+	}
+	if err != nil {
+		return models.Group{}, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return models.Group{}, err
+	} else if affected == 0 {
+		return models.Group{}, ErrVersionMismatch
+	}
+	if err := replaceGroupMembers(ctx, tx, orgId, group.ID, memberIds(group)); err != nil {
+		return models.Group{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Group{}, err
+	}
+	return s.GetGroup(ctx, orgId, group.ID)
+}
+
+func (s *PostgresStore) DeleteGroup(ctx context.Context, orgId, groupId int) error {
+	query := `DELETE FROM groups WHERE id = $1 AND org_id = $2`
+	return s.execExpectingRow(ctx, query, groupId, orgId)
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"go_server/Models"
+	"time"
+)
+
+// The store interfaces describe everything the handlers, services and
+// workers need from persistence. PostgresStore is the production
+// implementation; MemoryStore backs tests and local experiments. Lookups
+// that find nothing return sql.ErrNoRows, as database/sql does, so callers
+// keep a single way of detecting a missing row.
+
+type UserStore interface {
+	InsertUser(ctx context.Context, email, name, password string) (int, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	GetUserById(ctx context.Context, id int) (models.User, error)
+	CheckIfUserExists(ctx context.Context, email string) bool
+	ChangePassword(ctx context.Context, userId, keepAppId int, password string) error
+	UpdatePasswordHash(ctx context.Context, userId int, oldHash, newHash string) error
+	GetPasswordChangedAt(ctx context.Context, userId int) (time.Time, error)
+	DeleteUser(ctx context.Context, id int) error
+	SetUserAdmin(ctx context.Context, email string, admin bool) error
+	IsUserAdmin(ctx context.Context, userId int) (bool, error)
+}
+
+type AppStore interface {
+	InsertApp(ctx context.Context, name, callbackUrl string, userId int) (int, error)
+	GetAllAppsOfUser(ctx context.Context, userId int) ([]models.App, error)
+	GetAppById(ctx context.Context, appId int) (models.App, error)
+	GetAppCallbackUrls(ctx context.Context) ([]string, error)
+	UpdateApp(ctx context.Context, appId, userId int, name, callbackUrl string) error
+	DeleteApp(ctx context.Context, appId, userId int) error
+	GetAppOfUser(ctx context.Context, appId, userId int) (models.App, error)
+	UpdateAppBranding(ctx context.Context, appId, userId int, logoUrl, brandColor string) error
+	GetAppClient(ctx context.Context, appId int) (models.AppClient, error)
+	SetAppClientSecret(ctx context.Context, appId, userId int, secretHash string) error
+	SetAppScopes(ctx context.Context, appId, userId int, scopes []string) error
+	SetExchangePolicy(ctx context.Context, targetAppId, userId, sourceAppId int, scopes []string) error
+	GetExchangePolicies(ctx context.Context, targetAppId int) ([]models.ExchangePolicy, error)
+	GetExchangePolicy(ctx context.Context, sourceAppId, targetAppId int) (models.ExchangePolicy, error)
+	DeleteExchangePolicy(ctx context.Context, targetAppId, userId, sourceAppId int) error
+	InsertRegisteredClient(ctx context.Context, userId int, client models.ClientMetadata, secretHash, registrationTokenHash string) (int, error)
+	GetAppIdByRegistrationToken(ctx context.Context, tokenHash string) (int, error)
+	GetClientMetadata(ctx context.Context, appId int) (models.ClientMetadata, error)
+	UpdateClientMetadata(ctx context.Context, client models.ClientMetadata) error
+	DeleteClient(ctx context.Context, appId int) error
+}
+
+type SessionStore interface {
+	UpdateRefreshToken(ctx context.Context, userId, appId int, refreshToken string) error
+	DeleteSession(ctx context.Context, userId, appId int) error
+	InsertOrUpdateSession(ctx context.Context, userId, appId int, refreshToken string) error
+	GetRefreshToken(ctx context.Context, userId, appId int) (string, models.User, error)
+	GetAppIdsOfUserSessions(ctx context.Context, userId int) ([]int, error)
+}
+
+// TokenStore holds the short-lived, single-use credentials handed to apps
+// and users: the token exchange records, magic links and device codes.
+type TokenStore interface {
+	InsertToken(ctx context.Context, appId int, token, refreshToken string) (int, error)
+	GetTokenById(ctx context.Context, tokenId int) (models.Token, error)
+	DeleteToken(ctx context.Context, tokenId int) error
+	InsertMagicLink(ctx context.Context, tokenHash, email string, appId int, bindingHash string, expiresAt time.Time) error
+	ConsumeMagicLink(ctx context.Context, tokenHash, bindingHash string) (models.MagicLink, error)
+	InsertDeviceCode(ctx context.Context, deviceCodeHash, userCode string, appId, interval int, expiresAt time.Time) error
+	GetDeviceCode(ctx context.Context, deviceCodeHash string) (models.DeviceCode, error)
+	GetPendingDeviceCode(ctx context.Context, userCode string) (models.DeviceCode, error)
+	DecideDeviceCode(ctx context.Context, userCode string, userId int, authTime time.Time, approve bool) error
+	RecordDevicePoll(ctx context.Context, id, interval int) error
+	ConsumeDeviceCode(ctx context.Context, id int) error
+}
+
+type ResetStore interface {
+	InsertPasswordResetToken(ctx context.Context, tokenHash string, userId int, expiresAt time.Time) error
+	GetPasswordResetUser(ctx context.Context, tokenHash string) (models.User, error)
+	ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (models.User, []int, error)
+}
+
+type OrganizationStore interface {
+	InsertOrganization(ctx context.Context, name string, ownerId int) (int, error)
+	GetOrganizationById(ctx context.Context, orgId int) (models.Organization, error)
+	GetOrganizationsOfUser(ctx context.Context, userId int) ([]models.Organization, error)
+	InsertScimToken(ctx context.Context, orgId int, tokenHash, description string) (int, error)
+	GetScimTokensOfOrg(ctx context.Context, orgId int) ([]models.ScimToken, error)
+	RevokeScimToken(ctx context.Context, orgId, tokenId int) error
+	GetOrgIdByScimToken(ctx context.Context, tokenHash string) (int, error)
+	InsertInitialAccessToken(ctx context.Context, orgId int, tokenHash, description string) (int, error)
+	GetInitialAccessTokensOfOrg(ctx context.Context, orgId int) ([]models.InitialAccessToken, error)
+	RevokeInitialAccessToken(ctx context.Context, orgId, tokenId int) error
+	GetOrgIdByInitialAccessToken(ctx context.Context, tokenHash string) (int, error)
+}
+
+type ScimStore interface {
+	ListOrgUsers(ctx context.Context, orgId int, conds []models.FilterCondition, offset, limit int) ([]models.ScimUser, int, error)
+	GetOrgUser(ctx context.Context, orgId, userId int) (models.ScimUser, error)
+	CreateOrgUser(ctx context.Context, orgId int, user models.ScimUser, passwordHash string) (models.ScimUser, error)
+	UpdateOrgUser(ctx context.Context, orgId int, user models.ScimUser, expectedVersion int) (models.ScimUser, error)
+	RemoveOrgUser(ctx context.Context, orgId, userId int) error
+	ListGroups(ctx context.Context, orgId int, conds []models.FilterCondition, offset, limit int) ([]models.Group, int, error)
+	GetGroup(ctx context.Context, orgId, groupId int) (models.Group, error)
+	CreateGroup(ctx context.Context, orgId int, group models.Group) (models.Group, error)
+	UpdateGroup(ctx context.Context, orgId int, group models.Group, expectedVersion int) (models.Group, error)
+	DeleteGroup(ctx context.Context, orgId, groupId int) error
+}
+
+type WebhookStore interface {
+	InsertWebhookEndpoint(ctx context.Context, appId int, url, secret string, events []string) (int, error)
+	GetWebhookEndpointsOfApp(ctx context.Context, appId int) ([]models.WebhookEndpoint, error)
+	GetWebhookEndpoint(ctx context.Context, endpointId, appId int) (models.WebhookEndpoint, error)
+	UpdateWebhookEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) error
+	DeleteWebhookEndpoint(ctx context.Context, endpointId, appId int) error
+	InsertWebhookDeliveries(ctx context.Context, appId int, eventId, eventType string, payload []byte) (int, error)
+	InsertWebhookDelivery(ctx context.Context, endpointId int, eventId, eventType string, payload []byte) (int64, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkWebhookDeliverySucceeded(ctx context.Context, deliveryId int64, attempts, statusCode int) error
+	MarkWebhookDeliveryFailed(ctx context.Context, deliveryId int64, attempts, statusCode int, lastError string, nextAttemptAt time.Time, dead bool) error
+	GetWebhookDeliveries(ctx context.Context, endpointId, limit, offset int) ([]models.WebhookDelivery, error)
+}
+
+type OutboxStore interface {
+	InsertOutboxEmail(ctx context.Context, recipient, subject, textBody, htmlBody string) (int64, error)
+	ClaimDueOutboxEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error)
+	MarkOutboxEmailSent(ctx context.Context, emailId int64, attempts int) error
+	MarkOutboxEmailFailed(ctx context.Context, emailId int64, attempts int, lastError string, nextAttemptAt time.Time, dead bool) error
+	GetOutboxEmail(ctx context.Context, emailId int64) (models.OutboxEmail, error)
+	PurgeOutboxEmails(ctx context.Context, before time.Time) (int64, error)
+}
+
+type ImportStore interface {
+	InsertUserImport(ctx context.Context, checksum, source string, total int) (models.UserImport, error)
+	GetUserImport(ctx context.Context, id int) (models.UserImport, error)
+	GetRunningUserImport(ctx context.Context, checksum string) (models.UserImport, error)
+	FinishUserImport(ctx context.Context, id int) error
+	GetExistingEmails(ctx context.Context, emails []string) (map[string]bool, error)
+	GetLinkedIdentities(ctx context.Context, identities []models.UserIdentity) (map[string]bool, error)
+	ImportUserBatch(ctx context.Context, jobId int, users []models.TransferUser, nextRecord, skipped, failed int) ([]UserImportOutcome, error)
+	GetTransferUsers(ctx context.Context, afterId, limit int) ([]models.TransferUser, int, error)
+}
+
+// HealthStore reports whether the database can serve requests.
+type HealthStore interface {
+	Ping(ctx context.Context) error
+	HasPendingMigrations(ctx context.Context) (bool, error)
+}
+
+// Store is the full persistence layer the server runs on.
+type Store interface {
+	UserStore
+	AppStore
+	SessionStore
+	TokenStore
+	ResetStore
+	OrganizationStore
+	ScimStore
+	WebhookStore
+	OutboxStore
+	ImportStore
+	HealthStore
+}
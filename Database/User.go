@@ -1,86 +1,95 @@
 package database
 
-import models "go_server/Models"
+import (
+	"context"
+	"database/sql"
+	models "go_server/Models"
+	"time"
+)
 
-func CreateUserTable() error {
-	query := `CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		email VARCHAR(255) NOT NULL,
-		password VARCHAR(255) NOT NULL
-	)`
-	_, err := instance.db.Exec(query)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func InsertUser(email, name,password string) (int, error) {
+func (s *PostgresStore) InsertUser(ctx context.Context, email, name,password string) (int, error) {
 	query := `INSERT INTO users (email, password , name) VALUES ($1, $2, $3) RETURNING id`
 	var pk int
-	err := instance.db.QueryRow(query, email, password,name).Scan(&pk)
+	err := s.db.QueryRowContext(ctx, query, email, password,name).Scan(&pk)
 	if err != nil {
 		return 0, err
 	}
 	return pk, nil
 }
 
-func GetAllUsers() ([]models.User, error) {
-	query := `SELECT id, email, password FROM users`
-	rows, err := instance.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.ID, &user.Email, &user.Password)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-	return users, nil
-}
-
-func GetUserByEmail(email string) (models.User, error) {
-	query := `SELECT id, email, password FROM users WHERE email = $1`
+func (s *PostgresStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	query := `SELECT id, name, email, password, active FROM users WHERE email = $1`
 	var user models.User
-	err := instance.db.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.Password)
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active)
 	if err != nil {
 		return models.User{}, err
 	}
 	return user, nil
 }
 
-func GetUserById(id string) (models.User , error) {
-	query := `SELECT id, email, password FROM users WHERE id = $1`
+func (s *PostgresStore) GetUserById(ctx context.Context, id int) (models.User, error) {
+	query := `SELECT id, name, email, password, active FROM users WHERE id = $1`
 	var user models.User
-	err := instance.db.QueryRow(query, id).Scan(&user.ID, &user.Email, &user.Password)
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active)
 	if err!= nil {
 		return models.User{}, err
 	}
 	return user, nil
 }
 
-func CheckIfUserExists(email string) bool {
+func (s *PostgresStore) CheckIfUserExists(ctx context.Context, email string) bool {
 	query := `SELECT id FROM users WHERE email = $1`
 	var id int
-	err := instance.db.QueryRow(query, email).Scan(&id)
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&id)
 	return err == nil
 }
 
+// ChangePassword sets a new password hash, invalidates outstanding reset
+// links and revokes every session of the user except the one with keepAppId
+// (0 keeps none). password_changed_at is recorded so access tokens issued
+// before the change stop being accepted.
+func (s *PostgresStore) ChangePassword(ctx context.Context, userId, keepAppId int, password string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET password = $1, password_changed_at = NOW(), updated_at = NOW() WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, query, password, userId); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, userId); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1 AND app_id <> $2`, userId, keepAppId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-func UpdatePassword(id, password string) error {
-	query := `UPDATE users SET password = $1 WHERE id = $2`
-	_, err := instance.db.Exec(query, password, id)
+// UpdatePasswordHash swaps the stored hash for a rehash of the same
+// password. It only applies while oldHash is still current, so a password
+// change racing with a login is never overwritten, and it does not count as
+// a password change.
+func (s *PostgresStore) UpdatePasswordHash(ctx context.Context, userId int, oldHash, newHash string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`, newHash, userId, oldHash)
 	return err
 }
 
-func UpdatePasswordWithEmail(email,password string) error {
-	query := `UPDATE users SET password = $1 WHERE email = $2`
-	_, err := instance.db.Exec(query,password,email)
-	return err
-}
\ No newline at end of file
+// GetPasswordChangedAt returns when an active user last changed their
+// password; the zero time means never. sql.ErrNoRows is returned for
+// deleted or deactivated users.
+func (s *PostgresStore) GetPasswordChangedAt(ctx context.Context, userId int) (time.Time, error) {
+	query := `SELECT password_changed_at FROM users WHERE id = $1 AND active`
+	var changedAt sql.NullTime
+	if err := s.db.QueryRowContext(ctx, query, userId).Scan(&changedAt); err != nil {
+		return time.Time{}, err
+	}
+	return changedAt.Time, nil
+}
+
+func (s *PostgresStore) DeleteUser(ctx context.Context, id int) error {
+	query := `DELETE FROM users WHERE id = $1`
+	return s.execExpectingRow(ctx, query, id)
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	models "go_server/Models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	UserImportRunning  = "running"
+	UserImportFinished = "finished"
+)
+
+// UserImportOutcome is what happened to one record of an import batch.
+type UserImportOutcome struct {
+	Inserted bool
+	// identities that were already linked to another account
+	IdentityConflicts []models.UserIdentity
+}
+
+func scanUserImport(row interface{ Scan(...interface{}) error }) (models.UserImport, error) {
+	var job models.UserImport
+	err := row.Scan(&job.ID, &job.Checksum, &job.Source, &job.Total, &job.NextRecord, &job.Imported,
+		&job.Skipped, &job.Failed, &job.Status, &job.CreatedAt, &job.UpdatedAt)
+	return job, err
+}
+
+const userImportColumns = `id, checksum, source, total, next_record, imported, skipped, failed, status, created_at, updated_at`
+
+func (s *PostgresStore) InsertUserImport(ctx context.Context, checksum, source string, total int) (models.UserImport, error) {
+	query := `INSERT INTO user_imports (checksum, source, total) VALUES ($1, $2, $3) RETURNING ` + userImportColumns
+	return scanUserImport(s.db.QueryRowContext(ctx, query, checksum, source, total))
+}
+
+func (s *PostgresStore) GetUserImport(ctx context.Context, id int) (models.UserImport, error) {
+	return scanUserImport(s.db.QueryRowContext(ctx, `SELECT `+userImportColumns+` FROM user_imports WHERE id = $1`, id))
+}
+
+// GetRunningUserImport finds an interrupted import of the same file so it
+// can continue where it stopped.
+func (s *PostgresStore) GetRunningUserImport(ctx context.Context, checksum string) (models.UserImport, error) {
+	query := `SELECT ` + userImportColumns + ` FROM user_imports WHERE checksum = $1 AND status = $2 ORDER BY id DESC LIMIT 1`
+	return scanUserImport(s.db.QueryRowContext(ctx, query, checksum, UserImportRunning))
+}
+
+func (s *PostgresStore) FinishUserImport(ctx context.Context, id int) error {
+	return s.execExpectingRow(ctx, `UPDATE user_imports SET status = $1, updated_at = NOW() WHERE id = $2`, UserImportFinished, id)
+}
+
+// GetExistingEmails returns which of the emails already belong to an
+// account, compared case-insensitively and keyed in lower case.
+func (s *PostgresStore) GetExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(email)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT LOWER(email) FROM users WHERE LOWER(email) = ANY($1)`, pq.Array(lowered))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	existing := map[string]bool{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		existing[email] = true
+	}
+	return existing, rows.Err()
+}
+
+// GetLinkedIdentities returns which of the identities already belong to an
+// account, keyed as "provider:subject".
+func (s *PostgresStore) GetLinkedIdentities(ctx context.Context, identities []models.UserIdentity) (map[string]bool, error) {
+	providers := make([]string, len(identities))
+	subjects := make([]string, len(identities))
+	for i, identity := range identities {
+		providers[i] = identity.Provider
+		subjects[i] = identity.Subject
+	}
+	query := `
+		SELECT provider, subject FROM user_identities
+		WHERE (provider, subject) IN (SELECT * FROM UNNEST($1::text[], $2::text[]))
+	`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(providers), pq.Array(subjects))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	linked := map[string]bool{}
+	for rows.Next() {
+		var provider, subject string
+		if err := rows.Scan(&provider, &subject); err != nil {
+			return nil, err
+		}
+		linked[provider+":"+subject] = true
+	}
+	return linked, rows.Err()
+}
+
+// ImportUserBatch inserts a batch of users in one transaction and records
+// the progress of the import job in the same transaction, so an interrupted
+// import resumes exactly after the last committed batch. Users whose email
+// is already registered are left untouched.
+func (s *PostgresStore) ImportUserBatch(ctx context.Context, jobId int, users []models.TransferUser, nextRecord, skipped, failed int) ([]UserImportOutcome, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	outcomes := make([]UserImportOutcome, len(users))
+	imported := 0
+	for i, user := range users {
+		query := `
+			INSERT INTO users (name, email, password, email_verified, active)
+			SELECT $1, $2, $3, $4, $5
+			WHERE NOT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = LOWER($2))
+			RETURNING id
+		`
+		var userId int
+		err := tx.QueryRowContext(ctx, query, user.Name, user.Email, user.PasswordHash, user.Verified, user.Active).Scan(&userId)
+		if err == sql.ErrNoRows {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		outcomes[i].Inserted = true
+		imported++
+
+		for _, identity := range user.Identities {
+			result, err := tx.ExecContext(ctx, `INSERT INTO user_identities (user_id, provider, subject) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+				userId, identity.Provider, identity.Subject)
+			if err != nil {
+				return nil, err
+			}
+			if affected, err := result.RowsAffected(); err != nil {
+				return nil, err
+			} else if affected == 0 {
+				outcomes[i].IdentityConflicts = append(outcomes[i].IdentityConflicts, identity)
+			}
+		}
+	}
+
+	query := `
+		UPDATE user_imports SET next_record = $1, imported = imported + $2, skipped = skipped + $3,
+		failed = failed + $4, updated_at = NOW()
+		WHERE id = $5
+	`
+	if _, err := tx.ExecContext(ctx, query, nextRecord, imported, skipped, failed, jobId); err != nil {
+		return nil, err
+	}
+	return outcomes, tx.Commit()
+}
+
+// GetTransferUsers returns up to limit users with an id above afterId,
+// including their external identities, for exports. The last id is
+// returned for the next page.
+func (s *PostgresStore) GetTransferUsers(ctx context.Context, afterId, limit int) ([]models.TransferUser, int, error) {
+	query := `
+		SELECT users.id, users.name, users.email, users.password, users.email_verified, users.active,
+		COALESCE(ARRAY_AGG(user_identities.provider) FILTER (WHERE user_identities.provider IS NOT NULL), '{}'),
+		COALESCE(ARRAY_AGG(user_identities.subject) FILTER (WHERE user_identities.subject IS NOT NULL), '{}')
+		FROM users
+		LEFT JOIN user_identities ON user_identities.user_id = users.id
+		WHERE users.id > $1
+		GROUP BY users.id
+		ORDER BY users.id
+		LIMIT $2
+	`
+	rows, err := s.db.QueryContext(ctx, query, afterId, limit)
+	if err != nil {
+		return nil, afterId, err
+	}
+	defer rows.Close()
+	users := []models.TransferUser{}
+	lastId := afterId
+	for rows.Next() {
+		var user models.TransferUser
+		var providers, subjects []string
+		err := rows.Scan(&lastId, &user.Name, &user.Email, &user.PasswordHash, &user.Verified, &user.Active,
+			pq.Array(&providers), pq.Array(&subjects))
+		if err != nil {
+			return nil, afterId, err
+		}
+		user.Identities = []models.UserIdentity{}
+		for i := range providers {
+			user.Identities = append(user.Identities, models.UserIdentity{Provider: providers[i], Subject: subjects[i]})
+		}
+		users = append(users, user)
+	}
+	return users, lastId, rows.Err()
+}
+
+// SetUserAdmin grants or revokes access to the admin API.
+func (s *PostgresStore) SetUserAdmin(ctx context.Context, email string, admin bool) error {
+	return s.execExpectingRow(ctx, `UPDATE users SET is_admin = $1 WHERE LOWER(email) = LOWER($2)`, admin, email)
+}
+
+func (s *PostgresStore) IsUserAdmin(ctx context.Context, userId int) (bool, error) {
+	var admin bool
+	err := s.db.QueryRowContext(ctx, `SELECT is_admin FROM users WHERE id = $1 AND active`, userId).Scan(&admin)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return admin, err
+}
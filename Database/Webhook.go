@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	models "go_server/Models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	WebhookStatusPending   = "pending"
+	WebhookStatusSucceeded = "succeeded"
+	WebhookStatusDead      = "dead"
+)
+
+func (s *PostgresStore) InsertWebhookEndpoint(ctx context.Context, appId int, url, secret string, events []string) (int, error) {
+	query := `INSERT INTO webhook_endpoints (app_id, url, secret, events) VALUES ($1, $2, $3, $4) RETURNING id`
+	var pk int
+	err := s.db.QueryRowContext(ctx, query, appId, url, secret, pq.Array(events)).Scan(&pk)
+	if err != nil {
+		return 0, err
+	}
+	return pk, nil
+}
+
+const webhookEndpointSelect = `SELECT id, app_id, url, secret, events, active, created_at FROM webhook_endpoints`
+
+func scanWebhookEndpoint(row interface{ Scan(...interface{}) error }) (models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := row.Scan(&endpoint.ID, &endpoint.AppId, &endpoint.Url, &endpoint.Secret,
+		pq.Array(&endpoint.Events), &endpoint.Active, &endpoint.CreatedAt)
+	return endpoint, err
+}
+
+func (s *PostgresStore) GetWebhookEndpointsOfApp(ctx context.Context, appId int) ([]models.WebhookEndpoint, error) {
+	rows, err := s.db.QueryContext(ctx, webhookEndpointSelect+` WHERE app_id = $1 ORDER BY id`, appId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	endpoints := []models.WebhookEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (s *PostgresStore) GetWebhookEndpoint(ctx context.Context, endpointId, appId int) (models.WebhookEndpoint, error) {
+	row := s.db.QueryRowContext(ctx, webhookEndpointSelect+` WHERE id = $1 AND app_id = $2`, endpointId, appId)
+	endpoint, err := scanWebhookEndpoint(row)
+	if err != nil {
+		return models.WebhookEndpoint{}, err
+	}
+	return endpoint, nil
+}
+
+func (s *PostgresStore) UpdateWebhookEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) error {
+	query := `UPDATE webhook_endpoints SET url = $1, secret = $2, events = $3, active = $4 WHERE id = $5 AND app_id = $6`
+	return s.execExpectingRow(ctx, query, endpoint.Url, endpoint.Secret, pq.Array(endpoint.Events),
+		endpoint.Active, endpoint.ID, endpoint.AppId)
+}
+
+func (s *PostgresStore) DeleteWebhookEndpoint(ctx context.Context, endpointId, appId int) error {
+	query := `DELETE FROM webhook_endpoints WHERE id = $1 AND app_id = $2`
+	return s.execExpectingRow(ctx, query, endpointId, appId)
+}
+
+// InsertWebhookDeliveries queues an event for every active endpoint of the
+// app that subscribed to its type and returns the number of deliveries.
+func (s *PostgresStore) InsertWebhookDeliveries(ctx context.Context, appId int, eventId, eventType string, payload []byte) (int, error) {
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		SELECT id, $2, $3, $4 FROM webhook_endpoints
+		WHERE app_id = $1 AND active AND $3 = ANY(events)
+	`
+	result, err := s.db.ExecContext(ctx, query, appId, eventId, eventType, payload)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// InsertWebhookDelivery queues an event for a single endpoint regardless of
+// its subscriptions; it is used for test events.
+func (s *PostgresStore) InsertWebhookDelivery(ctx context.Context, endpointId int, eventId, eventType string, payload []byte) (int64, error) {
+	query := `INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload) VALUES ($1, $2, $3, $4) RETURNING id`
+	var pk int64
+	err := s.db.QueryRowContext(ctx, query, endpointId, eventId, eventType, payload).Scan(&pk)
+	if err != nil {
+		return 0, err
+	}
+	return pk, nil
+}
+
+// ClaimDueWebhookDeliveries picks up to limit pending deliveries whose next
+// attempt is due and pushes their next attempt lease into the future, so
+// concurrent workers (or instances) never send the same delivery twice. If
+// the worker dies mid-delivery the lease expires and it is retried.
+func (s *PostgresStore) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, webhook_endpoints e
+		WHERE d.id = due.id AND e.id = d.endpoint_id
+		RETURNING d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.attempts, e.url, e.secret
+	`
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(&delivery.ID, &delivery.EndpointId, &delivery.EventId, &delivery.EventType,
+			&delivery.Payload, &delivery.Attempts, &delivery.Url, &delivery.Secret)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Status = WebhookStatusPending
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *PostgresStore) MarkWebhookDeliverySucceeded(ctx context.Context, deliveryId int64, attempts, statusCode int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'succeeded', attempts = $2, last_status_code = $3, last_error = '', delivered_at = NOW()
+		WHERE id = $1
+	`
+	_, err := s.db.ExecContext(ctx, query, deliveryId, attempts, statusCode)
+	return err
+}
+
+// MarkWebhookDeliveryFailed records a failed attempt. A zero statusCode means
+// no HTTP response was received. When dead is true the delivery is moved to
+// the dead letter state and no longer retried.
+func (s *PostgresStore) MarkWebhookDeliveryFailed(ctx context.Context, deliveryId int64, attempts, statusCode int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := WebhookStatusPending
+	if dead {
+		status = WebhookStatusDead
+	}
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, last_status_code = NULLIF($4, 0), last_error = $5, next_attempt_at = $6
+		WHERE id = $1
+	`
+	_, err := s.db.ExecContext(ctx, query, deliveryId, status, attempts, statusCode, lastError, nextAttemptAt)
+	return err
+}
+
+func (s *PostgresStore) GetWebhookDeliveries(ctx context.Context, endpointId, limit, offset int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			last_status_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := s.db.QueryContext(ctx, query, endpointId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(&delivery.ID, &delivery.EndpointId, &delivery.EventId, &delivery.EventType,
+			&delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+			&delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
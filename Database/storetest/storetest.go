@@ -0,0 +1,1079 @@
+// Package storetest is the conformance suite every database.Store
+// implementation has to pass. Tests never assume particular ids, so the
+// suite can run against a real database as well as the in-memory store.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	database "go_server/Database"
+	models "go_server/Models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ctx is passed to every store call; the suite has no deadlines to test.
+var ctx = context.Background()
+
+// Run runs the suite. newStore must return an empty store for every call.
+func Run(t *testing.T, newStore func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s database.Store)
+	}{
+		{"Users", testUsers},
+		{"ChangePassword", testChangePassword},
+		{"DeleteUserCascades", testDeleteUserCascades},
+		{"Admins", testAdmins},
+		{"Apps", testApps},
+		{"AppClients", testAppClients},
+		{"ExchangePolicies", testExchangePolicies},
+		{"Sessions", testSessions},
+		{"Tokens", testTokens},
+		{"MagicLinks", testMagicLinks},
+		{"DeviceCodes", testDeviceCodes},
+		{"PasswordReset", testPasswordReset},
+		{"Organizations", testOrganizations},
+		{"ClientRegistration", testClientRegistration},
+		{"ScimUsers", testScimUsers},
+		{"Groups", testGroups},
+		{"Webhooks", testWebhooks},
+		{"Outbox", testOutbox},
+		{"UserImport", testUserImport},
+		{"Health", testHealth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustId unwraps the (id, error) result of an insert.
+func mustId(t *testing.T) func(id int, err error) int {
+	return func(id int, err error) int {
+		t.Helper()
+		must(t, err)
+		return id
+	}
+}
+
+func expectNoRows(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func expectError(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+}
+
+func testUsers(t *testing.T, s database.Store) {
+	id := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash-1"))
+
+	user, err := s.GetUserByEmail(ctx, "ada@example.com")
+	must(t, err)
+	if user.ID != id || user.Name != "Ada" || user.Password != "hash-1" || !user.Active {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	byId, err := s.GetUserById(ctx, id)
+	must(t, err)
+	if byId != user {
+		t.Fatalf("GetUserById = %+v, want %+v", byId, user)
+	}
+	if !s.CheckIfUserExists(ctx, "ada@example.com") || s.CheckIfUserExists(ctx, "bob@example.com") {
+		t.Fatal("CheckIfUserExists disagrees with the inserted users")
+	}
+	_, err = s.GetUserByEmail(ctx, "bob@example.com")
+	expectNoRows(t, err)
+	_, err = s.GetUserById(ctx, id+1000)
+	expectNoRows(t, err)
+
+	changedAt, err := s.GetPasswordChangedAt(ctx, id)
+	must(t, err)
+	if !changedAt.IsZero() {
+		t.Fatalf("new user has password_changed_at %v", changedAt)
+	}
+
+	// a rehash only applies while the old hash is current
+	must(t, s.UpdatePasswordHash(ctx, id, "stale", "hash-2"))
+	must(t, s.UpdatePasswordHash(ctx, id, "hash-1", "hash-3"))
+	user, err = s.GetUserById(ctx, id)
+	must(t, err)
+	if user.Password != "hash-3" {
+		t.Fatalf("password = %q, want hash-3", user.Password)
+	}
+	changedAt, err = s.GetPasswordChangedAt(ctx, id)
+	must(t, err)
+	if !changedAt.IsZero() {
+		t.Fatal("a rehash must not count as a password change")
+	}
+
+	must(t, s.DeleteUser(ctx, id))
+	expectNoRows(t, s.DeleteUser(ctx, id))
+	_, err = s.GetUserById(ctx, id)
+	expectNoRows(t, err)
+	_, err = s.GetPasswordChangedAt(ctx, id)
+	expectNoRows(t, err)
+}
+
+func testChangePassword(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "old"))
+	keep := mustId(t)(s.InsertApp(ctx, "keep", "https://keep.example.com", userId))
+	other := mustId(t)(s.InsertApp(ctx, "other", "https://other.example.com", userId))
+	must(t, s.InsertOrUpdateSession(ctx, userId, keep, "r1"))
+	must(t, s.InsertOrUpdateSession(ctx, userId, other, "r2"))
+	must(t, s.InsertPasswordResetToken(ctx, hash("reset"), userId, time.Now().Add(time.Hour)))
+
+	before := time.Now().Add(-time.Minute)
+	must(t, s.ChangePassword(ctx, userId, keep, "new"))
+
+	user, err := s.GetUserById(ctx, userId)
+	must(t, err)
+	if user.Password != "new" {
+		t.Fatalf("password = %q, want new", user.Password)
+	}
+	changedAt, err := s.GetPasswordChangedAt(ctx, userId)
+	must(t, err)
+	if changedAt.Before(before) {
+		t.Fatalf("password_changed_at %v was not updated", changedAt)
+	}
+	appIds, err := s.GetAppIdsOfUserSessions(ctx, userId)
+	must(t, err)
+	if len(appIds) != 1 || appIds[0] != keep {
+		t.Fatalf("sessions after change = %v, want only %d", appIds, keep)
+	}
+	_, err = s.GetPasswordResetUser(ctx, hash("reset"))
+	expectNoRows(t, err)
+}
+
+func testDeleteUserCascades(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com", userId))
+	must(t, s.InsertOrUpdateSession(ctx, userId, appId, "refresh"))
+	tokenId := mustId(t)(s.InsertToken(ctx, appId, "access", "refresh"))
+	orgId := mustId(t)(s.InsertOrganization(ctx, "Acme", userId))
+	endpointId := mustId(t)(s.InsertWebhookEndpoint(ctx, appId, "https://hooks.example.com", "secret", []string{"user.login"}))
+
+	must(t, s.DeleteUser(ctx, userId))
+
+	_, err := s.GetAppById(ctx, appId)
+	expectNoRows(t, err)
+	_, err = s.GetTokenById(ctx, tokenId)
+	expectNoRows(t, err)
+	_, _, err = s.GetRefreshToken(ctx, userId, appId)
+	expectNoRows(t, err)
+	_, err = s.GetOrganizationById(ctx, orgId)
+	expectNoRows(t, err)
+	_, err = s.GetWebhookEndpoint(ctx, endpointId, appId)
+	expectNoRows(t, err)
+}
+
+func testAdmins(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	admin, err := s.IsUserAdmin(ctx, userId)
+	must(t, err)
+	if admin {
+		t.Fatal("new users must not be admins")
+	}
+	must(t, s.SetUserAdmin(ctx, "ADA@example.com", true))
+	admin, err = s.IsUserAdmin(ctx, userId)
+	must(t, err)
+	if !admin {
+		t.Fatal("SetUserAdmin did not grant admin")
+	}
+	must(t, s.SetUserAdmin(ctx, "ada@example.com", false))
+	admin, err = s.IsUserAdmin(ctx, userId)
+	must(t, err)
+	if admin {
+		t.Fatal("SetUserAdmin did not revoke admin")
+	}
+	expectNoRows(t, s.SetUserAdmin(ctx, "nobody@example.com", true))
+
+	admin, err = s.IsUserAdmin(ctx, userId+1000)
+	if err != nil || admin {
+		t.Fatalf("IsUserAdmin of a missing user = %v, %v", admin, err)
+	}
+}
+
+func testApps(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	stranger := mustId(t)(s.InsertUser(ctx, "bob@example.com", "Bob", "hash"))
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com/cb", owner))
+
+	apps, err := s.GetAllAppsOfUser(ctx, owner)
+	must(t, err)
+	if len(apps) != 1 || apps[0].ID != appId || apps[0].Name != "app" || apps[0].CallbackUrl != "https://app.example.com/cb" {
+		t.Fatalf("GetAllAppsOfUser = %+v", apps)
+	}
+	apps, err = s.GetAllAppsOfUser(ctx, stranger)
+	must(t, err)
+	if len(apps) != 0 {
+		t.Fatalf("stranger owns %+v", apps)
+	}
+	urls, err := s.GetAppCallbackUrls(ctx)
+	must(t, err)
+	if len(urls) != 1 || urls[0] != "https://app.example.com/cb" {
+		t.Fatalf("GetAppCallbackUrls = %q", urls)
+	}
+
+	// only the fields that are set change, and only for the owner
+	must(t, s.UpdateApp(ctx, appId, owner, "renamed", ""))
+	expectNoRows(t, s.UpdateApp(ctx, appId, stranger, "stolen", "https://evil.example.com"))
+	app, err := s.GetAppOfUser(ctx, appId, owner)
+	must(t, err)
+	if app.Name != "renamed" || app.CallbackUrl != "https://app.example.com/cb" || app.UserId != owner {
+		t.Fatalf("app after update = %+v", app)
+	}
+	must(t, s.UpdateApp(ctx, appId, owner, "", "https://app.example.com/new"))
+	app, err = s.GetAppById(ctx, appId)
+	must(t, err)
+	if app.Name != "renamed" || app.CallbackUrl != "https://app.example.com/new" {
+		t.Fatalf("app after update = %+v", app)
+	}
+	_, err = s.GetAppOfUser(ctx, appId, stranger)
+	expectNoRows(t, err)
+
+	must(t, s.UpdateAppBranding(ctx, appId, owner, "https://app.example.com/logo.png", "#112233"))
+	expectNoRows(t, s.UpdateAppBranding(ctx, appId, stranger, "", ""))
+	app, err = s.GetAppById(ctx, appId)
+	must(t, err)
+	if app.LogoUrl != "https://app.example.com/logo.png" || app.BrandColor != "#112233" {
+		t.Fatalf("branding = %+v", app)
+	}
+
+	// deleting someone else's app leaves it in place
+	expectNoRows(t, s.DeleteApp(ctx, appId, stranger))
+	_, err = s.GetAppById(ctx, appId)
+	must(t, err)
+	must(t, s.DeleteApp(ctx, appId, owner))
+	_, err = s.GetAppById(ctx, appId)
+	expectNoRows(t, err)
+
+	if _, err := s.InsertApp(ctx, "orphan", "https://orphan.example.com", owner+stranger+1000); err == nil {
+		t.Fatal("InsertApp accepted a missing owner")
+	}
+}
+
+func testAppClients(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	stranger := mustId(t)(s.InsertUser(ctx, "bob@example.com", "Bob", "hash"))
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com/cb", owner))
+
+	// a new app has no secret and no scopes
+	client, err := s.GetAppClient(ctx, appId)
+	must(t, err)
+	if client.AppId != appId || client.SecretHash != "" || len(client.Scopes) != 0 {
+		t.Fatalf("new client = %+v", client)
+	}
+
+	must(t, s.SetAppClientSecret(ctx, appId, owner, hash("secret")))
+	must(t, s.SetAppScopes(ctx, appId, owner, []string{"reports:read", "reports:write"}))
+	expectNoRows(t, s.SetAppClientSecret(ctx, appId, stranger, hash("stolen")))
+	expectNoRows(t, s.SetAppScopes(ctx, appId, stranger, []string{"admin"}))
+	client, err = s.GetAppClient(ctx, appId)
+	must(t, err)
+	if client.SecretHash != hash("secret") || strings.Join(client.Scopes, " ") != "reports:read reports:write" {
+		t.Fatalf("client = %+v", client)
+	}
+
+	must(t, s.SetAppScopes(ctx, appId, owner, []string{}))
+	client, err = s.GetAppClient(ctx, appId)
+	must(t, err)
+	if len(client.Scopes) != 0 || client.SecretHash != hash("secret") {
+		t.Fatalf("client after clearing scopes = %+v", client)
+	}
+
+	must(t, s.DeleteApp(ctx, appId, owner))
+	_, err = s.GetAppClient(ctx, appId)
+	expectNoRows(t, err)
+}
+
+func testExchangePolicies(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "owner@example.com", "Owner", "hash"))
+	stranger := mustId(t)(s.InsertUser(ctx, "stranger@example.com", "Stranger", "hash"))
+	gateway := mustId(t)(s.InsertApp(ctx, "gateway", "https://gateway.example.com", stranger))
+	reports := mustId(t)(s.InsertApp(ctx, "reports", "https://reports.example.com", owner))
+	billing := mustId(t)(s.InsertApp(ctx, "billing", "https://billing.example.com", owner))
+
+	_, err := s.GetExchangePolicy(ctx, gateway, reports)
+	expectNoRows(t, err)
+
+	// only the owner of the target app sets its policies
+	expectNoRows(t, s.SetExchangePolicy(ctx, reports, stranger, gateway, []string{"reports:read"}))
+	must(t, s.SetExchangePolicy(ctx, reports, owner, gateway, []string{"reports:read"}))
+	must(t, s.SetExchangePolicy(ctx, reports, owner, billing, nil))
+	must(t, s.SetExchangePolicy(ctx, reports, owner, gateway, []string{"reports:read", "reports:write"}))
+
+	policy, err := s.GetExchangePolicy(ctx, gateway, reports)
+	must(t, err)
+	if policy.TargetAppId != reports || policy.SourceAppId != gateway || strings.Join(policy.Scopes, " ") != "reports:read reports:write" || policy.CreatedAt.IsZero() {
+		t.Fatalf("GetExchangePolicy = %+v", policy)
+	}
+	// policies are one way
+	_, err = s.GetExchangePolicy(ctx, reports, gateway)
+	expectNoRows(t, err)
+
+	policies, err := s.GetExchangePolicies(ctx, reports)
+	must(t, err)
+	if len(policies) != 2 || policies[0].SourceAppId != gateway || policies[1].SourceAppId != billing || len(policies[1].Scopes) != 0 {
+		t.Fatalf("GetExchangePolicies = %+v", policies)
+	}
+
+	expectNoRows(t, s.DeleteExchangePolicy(ctx, reports, stranger, gateway))
+	must(t, s.DeleteExchangePolicy(ctx, reports, owner, gateway))
+	expectNoRows(t, s.DeleteExchangePolicy(ctx, reports, owner, gateway))
+
+	// the policies go with either app
+	must(t, s.DeleteApp(ctx, billing, owner))
+	policies, err = s.GetExchangePolicies(ctx, reports)
+	must(t, err)
+	if len(policies) != 0 {
+		t.Fatalf("policies after deleting the source app = %+v", policies)
+	}
+}
+
+func testSessions(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	first := mustId(t)(s.InsertApp(ctx, "first", "https://first.example.com", userId))
+	second := mustId(t)(s.InsertApp(ctx, "second", "https://second.example.com", userId))
+
+	_, _, err := s.GetRefreshToken(ctx, userId, first)
+	expectNoRows(t, err)
+
+	must(t, s.InsertOrUpdateSession(ctx, userId, first, "r1"))
+	must(t, s.InsertOrUpdateSession(ctx, userId, first, "r2"))
+	must(t, s.InsertOrUpdateSession(ctx, userId, second, "r3"))
+	refreshToken, user, err := s.GetRefreshToken(ctx, userId, first)
+	must(t, err)
+	if refreshToken != "r2" || user.Email != "ada@example.com" || user.Name != "Ada" {
+		t.Fatalf("GetRefreshToken = %q, %+v", refreshToken, user)
+	}
+
+	must(t, s.UpdateRefreshToken(ctx, userId, first, "r4"))
+	refreshToken, _, err = s.GetRefreshToken(ctx, userId, first)
+	must(t, err)
+	if refreshToken != "r4" {
+		t.Fatalf("refresh token = %q, want r4", refreshToken)
+	}
+
+	appIds, err := s.GetAppIdsOfUserSessions(ctx, userId)
+	must(t, err)
+	if len(appIds) != 2 || appIds[0] != min(first, second) || appIds[1] != max(first, second) {
+		t.Fatalf("GetAppIdsOfUserSessions = %v", appIds)
+	}
+
+	must(t, s.DeleteSession(ctx, userId, first))
+	_, _, err = s.GetRefreshToken(ctx, userId, first)
+	expectNoRows(t, err)
+	// updating a revoked session must not bring it back
+	must(t, s.UpdateRefreshToken(ctx, userId, first, "r5"))
+	_, _, err = s.GetRefreshToken(ctx, userId, first)
+	expectNoRows(t, err)
+
+	if err := s.InsertOrUpdateSession(ctx, userId, first+second+1000, "r6"); err == nil {
+		t.Fatal("InsertOrUpdateSession accepted a missing app")
+	}
+}
+
+func testTokens(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com", userId))
+
+	tokenId := mustId(t)(s.InsertToken(ctx, appId, "access", "refresh"))
+	token, err := s.GetTokenById(ctx, tokenId)
+	must(t, err)
+	if token.ID != tokenId || token.AppId != appId || token.Token != "access" || token.RefreshToken != "refresh" {
+		t.Fatalf("GetTokenById = %+v", token)
+	}
+	must(t, s.DeleteToken(ctx, tokenId))
+	_, err = s.GetTokenById(ctx, tokenId)
+	expectNoRows(t, err)
+	must(t, s.DeleteToken(ctx, tokenId))
+
+	if _, err := s.InsertToken(ctx, appId+1000, "access", "refresh"); err == nil {
+		t.Fatal("InsertToken accepted a missing app")
+	}
+}
+
+// hash returns a 64 character stand-in for a SHA-256 hex digest.
+func hash(seed string) string {
+	out := []byte(seed)
+	for len(out) < 64 {
+		out = append(out, '0')
+	}
+	return string(out[:64])
+}
+
+func testMagicLinks(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com", userId))
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	must(t, s.InsertMagicLink(ctx, hash("dashboard"), "ada@example.com", 0, hash("binding"), expiresAt))
+	must(t, s.InsertMagicLink(ctx, hash("app"), "ada@example.com", appId, hash("binding"), expiresAt))
+	must(t, s.InsertMagicLink(ctx, hash("expired"), "ada@example.com", 0, hash("binding"), time.Now().Add(-time.Minute)))
+
+	// a link only works from the browser holding the binding
+	_, err := s.ConsumeMagicLink(ctx, hash("dashboard"), hash("other"))
+	expectNoRows(t, err)
+
+	link, err := s.ConsumeMagicLink(ctx, hash("dashboard"), hash("binding"))
+	must(t, err)
+	if link.Email != "ada@example.com" || link.AppId != 0 {
+		t.Fatalf("ConsumeMagicLink = %+v", link)
+	}
+	_, err = s.ConsumeMagicLink(ctx, hash("dashboard"), hash("binding"))
+	expectNoRows(t, err)
+
+	link, err = s.ConsumeMagicLink(ctx, hash("app"), hash("binding"))
+	must(t, err)
+	if link.AppId != appId {
+		t.Fatalf("link app = %d, want %d", link.AppId, appId)
+	}
+
+	_, err = s.ConsumeMagicLink(ctx, hash("expired"), hash("binding"))
+	expectNoRows(t, err)
+	_, err = s.ConsumeMagicLink(ctx, hash("missing"), hash("binding"))
+	expectNoRows(t, err)
+
+	// used and expired links are cleaned up, so their hashes can be reused
+	must(t, s.InsertMagicLink(ctx, hash("dashboard"), "ada@example.com", 0, hash("binding"), expiresAt))
+}
+
+func testDeviceCodes(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	appId := mustId(t)(s.InsertApp(ctx, "cli", "https://cli.example.com", userId))
+	expiresAt := time.Now().Add(10 * time.Minute)
+
+	must(t, s.InsertDeviceCode(ctx, hash("approve"), "BCDFGHJK", appId, 5, expiresAt))
+	must(t, s.InsertDeviceCode(ctx, hash("deny"), "LMNPQRST", appId, 5, expiresAt))
+	must(t, s.InsertDeviceCode(ctx, hash("expired"), "VWXZBCDF", appId, 5, time.Now().Add(-time.Minute)))
+	if err := s.InsertDeviceCode(ctx, hash("other"), "BCDFGHJK", appId, 5, expiresAt); err == nil {
+		t.Fatal("a user code was issued twice")
+	}
+
+	code, err := s.GetPendingDeviceCode(ctx, "BCDFGHJK")
+	must(t, err)
+	if code.AppId != appId || code.UserId != 0 || code.Status != database.DeviceCodePending || code.Interval != 5 || !code.LastPolledAt.IsZero() {
+		t.Fatalf("GetPendingDeviceCode = %+v", code)
+	}
+	_, err = s.GetPendingDeviceCode(ctx, "VWXZBCDF")
+	expectNoRows(t, err)
+	// the approver logged in an hour before deciding
+	authTime := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	expectNoRows(t, s.DecideDeviceCode(ctx, "VWXZBCDF", userId, authTime, true))
+
+	// polls are recorded with the interval the device must keep
+	must(t, s.RecordDevicePoll(ctx, code.ID, 10))
+	code, err = s.GetDeviceCode(ctx, hash("approve"))
+	must(t, err)
+	if code.Interval != 10 || code.LastPolledAt.IsZero() {
+		t.Fatalf("after a poll = %+v", code)
+	}
+	expectNoRows(t, s.ConsumeDeviceCode(ctx, code.ID))
+
+	must(t, s.DecideDeviceCode(ctx, "BCDFGHJK", userId, authTime, true))
+	expectNoRows(t, s.DecideDeviceCode(ctx, "BCDFGHJK", userId, authTime, false))
+	must(t, s.DecideDeviceCode(ctx, "LMNPQRST", userId, authTime, false))
+	code, err = s.GetDeviceCode(ctx, hash("approve"))
+	must(t, err)
+	if code.Status != database.DeviceCodeApproved || code.UserId != userId || code.DecidedAt.IsZero() || code.AuthTime.Unix() != authTime.Unix() {
+		t.Fatalf("approved = %+v", code)
+	}
+	denied, err := s.GetDeviceCode(ctx, hash("deny"))
+	must(t, err)
+	if denied.Status != database.DeviceCodeDenied || denied.UserId != userId {
+		t.Fatalf("denied = %+v", denied)
+	}
+	expectNoRows(t, s.ConsumeDeviceCode(ctx, denied.ID))
+
+	// an approved login is handed out once
+	must(t, s.ConsumeDeviceCode(ctx, code.ID))
+	expectNoRows(t, s.ConsumeDeviceCode(ctx, code.ID))
+
+	// expired codes are still found, so the device learns they expired
+	expired, err := s.GetDeviceCode(ctx, hash("expired"))
+	must(t, err)
+	if expired.Status != database.DeviceCodePending || !expired.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("expired = %+v", expired)
+	}
+	_, err = s.GetDeviceCode(ctx, hash("missing"))
+	expectNoRows(t, err)
+
+	// the codes go with the app
+	must(t, s.DeleteApp(ctx, appId, userId))
+	_, err = s.GetDeviceCode(ctx, hash("deny"))
+	expectNoRows(t, err)
+}
+
+func testPasswordReset(t *testing.T, s database.Store) {
+	userId := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "old"))
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com", userId))
+	must(t, s.InsertOrUpdateSession(ctx, userId, appId, "refresh"))
+
+	must(t, s.InsertPasswordResetToken(ctx, hash("first"), userId, time.Now().Add(time.Hour)))
+	must(t, s.InsertPasswordResetToken(ctx, hash("second"), userId, time.Now().Add(time.Hour)))
+
+	// only the most recent token works
+	_, err := s.GetPasswordResetUser(ctx, hash("first"))
+	expectNoRows(t, err)
+	user, err := s.GetPasswordResetUser(ctx, hash("second"))
+	must(t, err)
+	if user.ID != userId || user.Email != "ada@example.com" || !user.Active {
+		t.Fatalf("GetPasswordResetUser = %+v", user)
+	}
+
+	user, appIds, err := s.ResetPasswordWithToken(ctx, hash("second"), "new")
+	must(t, err)
+	if user.ID != userId || user.Name != "Ada" || len(appIds) != 1 || appIds[0] != appId {
+		t.Fatalf("ResetPasswordWithToken = %+v, %v", user, appIds)
+	}
+	stored, err := s.GetUserById(ctx, userId)
+	must(t, err)
+	if stored.Password != "new" {
+		t.Fatalf("password = %q, want new", stored.Password)
+	}
+	_, _, err = s.GetRefreshToken(ctx, userId, appId)
+	expectNoRows(t, err)
+	changedAt, err := s.GetPasswordChangedAt(ctx, userId)
+	must(t, err)
+	if changedAt.IsZero() {
+		t.Fatal("password_changed_at was not set")
+	}
+
+	// tokens are single use
+	_, _, err = s.ResetPasswordWithToken(ctx, hash("second"), "again")
+	expectNoRows(t, err)
+
+	must(t, s.InsertPasswordResetToken(ctx, hash("expired"), userId, time.Now().Add(-time.Minute)))
+	_, err = s.GetPasswordResetUser(ctx, hash("expired"))
+	expectNoRows(t, err)
+	_, _, err = s.ResetPasswordWithToken(ctx, hash("expired"), "again")
+	expectNoRows(t, err)
+}
+
+func testOrganizations(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	orgId := mustId(t)(s.InsertOrganization(ctx, "Acme", owner))
+
+	org, err := s.GetOrganizationById(ctx, orgId)
+	must(t, err)
+	if org.Name != "Acme" || org.OwnerId != owner {
+		t.Fatalf("GetOrganizationById = %+v", org)
+	}
+	orgs, err := s.GetOrganizationsOfUser(ctx, owner)
+	must(t, err)
+	if len(orgs) != 1 || orgs[0].ID != orgId {
+		t.Fatalf("GetOrganizationsOfUser = %+v", orgs)
+	}
+
+	tokenId := mustId(t)(s.InsertScimToken(ctx, orgId, hash("scim"), "okta"))
+	resolved, err := s.GetOrgIdByScimToken(ctx, hash("scim"))
+	must(t, err)
+	if resolved != orgId {
+		t.Fatalf("GetOrgIdByScimToken = %d, want %d", resolved, orgId)
+	}
+	tokens, err := s.GetScimTokensOfOrg(ctx, orgId)
+	must(t, err)
+	if len(tokens) != 1 || tokens[0].ID != tokenId || tokens[0].Description != "okta" || tokens[0].LastUsedAt == nil {
+		t.Fatalf("GetScimTokensOfOrg = %+v", tokens)
+	}
+
+	expectNoRows(t, s.RevokeScimToken(ctx, orgId+1000, tokenId))
+	must(t, s.RevokeScimToken(ctx, orgId, tokenId))
+	expectNoRows(t, s.RevokeScimToken(ctx, orgId, tokenId))
+	_, err = s.GetOrgIdByScimToken(ctx, hash("scim"))
+	expectNoRows(t, err)
+}
+
+func testClientRegistration(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	orgId := mustId(t)(s.InsertOrganization(ctx, "Acme", owner))
+
+	tokenId := mustId(t)(s.InsertInitialAccessToken(ctx, orgId, hash("initial"), "ci"))
+	resolved, err := s.GetOrgIdByInitialAccessToken(ctx, hash("initial"))
+	must(t, err)
+	if resolved != orgId {
+		t.Fatalf("GetOrgIdByInitialAccessToken = %d, want %d", resolved, orgId)
+	}
+	// SCIM tokens and initial access tokens are not interchangeable
+	_, err = s.GetOrgIdByScimToken(ctx, hash("initial"))
+	expectNoRows(t, err)
+	tokens, err := s.GetInitialAccessTokensOfOrg(ctx, orgId)
+	must(t, err)
+	if len(tokens) != 1 || tokens[0].ID != tokenId || tokens[0].Description != "ci" || tokens[0].LastUsedAt == nil {
+		t.Fatalf("GetInitialAccessTokensOfOrg = %+v", tokens)
+	}
+
+	client := models.ClientMetadata{Name: "preview-42", CallbackUrl: "https://pr-42.example.com/cb", LogoUrl: "https://pr-42.example.com/logo.png", Scopes: []string{"reports:read"}}
+	appId := mustId(t)(s.InsertRegisteredClient(ctx, owner, client, hash("secret"), hash("registration")))
+	_, err = s.InsertRegisteredClient(ctx, owner, client, hash("secret"), hash("registration"))
+	if err == nil {
+		t.Fatal("a registration access token was issued twice")
+	}
+	resolved, err = s.GetAppIdByRegistrationToken(ctx, hash("registration"))
+	must(t, err)
+	if resolved != appId {
+		t.Fatalf("GetAppIdByRegistrationToken = %d, want %d", resolved, appId)
+	}
+	_, err = s.GetAppIdByRegistrationToken(ctx, hash("secret"))
+	expectNoRows(t, err)
+
+	// the client is an app of the owner, usable with the client credentials
+	app, err := s.GetAppOfUser(ctx, appId, owner)
+	must(t, err)
+	if app.Name != "preview-42" || app.CallbackUrl != client.CallbackUrl || app.LogoUrl != client.LogoUrl {
+		t.Fatalf("registered app = %+v", app)
+	}
+	appClient, err := s.GetAppClient(ctx, appId)
+	must(t, err)
+	if appClient.SecretHash != hash("secret") || strings.Join(appClient.Scopes, " ") != "reports:read" {
+		t.Fatalf("registered client = %+v", appClient)
+	}
+
+	// updates replace the metadata
+	must(t, s.UpdateClientMetadata(ctx, models.ClientMetadata{AppId: appId, Name: "preview-43", CallbackUrl: "https://pr-43.example.com/cb", Scopes: []string{}}))
+	metadata, err := s.GetClientMetadata(ctx, appId)
+	must(t, err)
+	if metadata.AppId != appId || metadata.Name != "preview-43" || metadata.CallbackUrl != "https://pr-43.example.com/cb" || metadata.LogoUrl != "" || len(metadata.Scopes) != 0 {
+		t.Fatalf("GetClientMetadata = %+v", metadata)
+	}
+	expectNoRows(t, s.UpdateClientMetadata(ctx, models.ClientMetadata{AppId: appId + 1000, Name: "missing", CallbackUrl: "https://missing.example.com"}))
+
+	must(t, s.DeleteClient(ctx, appId))
+	expectNoRows(t, s.DeleteClient(ctx, appId))
+	_, err = s.GetAppIdByRegistrationToken(ctx, hash("registration"))
+	expectNoRows(t, err)
+	_, err = s.GetClientMetadata(ctx, appId)
+	expectNoRows(t, err)
+
+	expectNoRows(t, s.RevokeInitialAccessToken(ctx, orgId+1000, tokenId))
+	must(t, s.RevokeInitialAccessToken(ctx, orgId, tokenId))
+	expectNoRows(t, s.RevokeInitialAccessToken(ctx, orgId, tokenId))
+	_, err = s.GetOrgIdByInitialAccessToken(ctx, hash("initial"))
+	expectNoRows(t, err)
+}
+
+func testScimUsers(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "owner@example.com", "Owner", "hash"))
+	orgId := mustId(t)(s.InsertOrganization(ctx, "Acme", owner))
+	otherOrg := mustId(t)(s.InsertOrganization(ctx, "Other", owner))
+
+	ada, err := s.CreateOrgUser(ctx, orgId, models.ScimUser{Email: "ada@example.com", Name: "Ada Lovelace", Active: true, ExternalId: "ext-ada"}, "hash")
+	must(t, err)
+	if ada.ID == 0 || ada.Version != 1 {
+		t.Fatalf("CreateOrgUser = %+v", ada)
+	}
+	bob, err := s.CreateOrgUser(ctx, orgId, models.ScimUser{Email: "bob@example.com", Name: "Bob", Active: true}, "hash")
+	must(t, err)
+
+	// existing accounts are never linked implicitly
+	_, err = s.CreateOrgUser(ctx, orgId, models.ScimUser{Email: "OWNER@example.com", Name: "Owner", Active: true}, "hash")
+	expectError(t, err, database.ErrUserExists)
+
+	users, total, err := s.ListOrgUsers(ctx, orgId, nil, 0, 10)
+	must(t, err)
+	if total != 2 || len(users) != 2 || users[0].ID != min(ada.ID, bob.ID) {
+		t.Fatalf("ListOrgUsers = %+v, %d", users, total)
+	}
+	users, total, err = s.ListOrgUsers(ctx, orgId, nil, 1, 10)
+	must(t, err)
+	if total != 2 || len(users) != 1 {
+		t.Fatalf("second page = %+v, %d", users, total)
+	}
+
+	filters := []struct {
+		conds []models.FilterCondition
+		want  int
+	}{
+		{[]models.FilterCondition{{Attr: "userName", Op: "eq", Value: "ADA@example.com"}}, ada.ID},
+		{[]models.FilterCondition{{Attr: "displayName", Op: "co", Value: "love"}}, ada.ID},
+		{[]models.FilterCondition{{Attr: "emails.value", Op: "sw", Value: "bob"}}, bob.ID},
+		{[]models.FilterCondition{{Attr: "externalId", Op: "pr"}}, ada.ID},
+		{[]models.FilterCondition{{Attr: "externalId", Op: "eq", Value: "ext-ada"}, {Attr: "active", Op: "eq", Value: "true"}}, ada.ID},
+		{[]models.FilterCondition{{Attr: "id", Op: "eq", Value: strconv.Itoa(bob.ID)}}, bob.ID},
+	}
+	for _, f := range filters {
+		users, total, err := s.ListOrgUsers(ctx, orgId, f.conds, 0, 10)
+		must(t, err)
+		if total != 1 || len(users) != 1 || users[0].ID != f.want {
+			t.Fatalf("filter %+v = %+v", f.conds, users)
+		}
+	}
+	_, _, err = s.ListOrgUsers(ctx, orgId, []models.FilterCondition{{Attr: "password", Op: "eq", Value: "x"}}, 0, 10)
+	expectError(t, err, database.ErrInvalidFilter)
+	_, _, err = s.ListOrgUsers(ctx, orgId, []models.FilterCondition{{Attr: "active", Op: "co", Value: "true"}}, 0, 10)
+	expectError(t, err, database.ErrInvalidFilter)
+
+	_, err = s.GetOrgUser(ctx, otherOrg, ada.ID)
+	expectNoRows(t, err)
+
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com", owner))
+	must(t, s.InsertOrUpdateSession(ctx, ada.ID, appId, "refresh"))
+
+	ada.Name = "Ada King"
+	ada.Active = false
+	updated, err := s.UpdateOrgUser(ctx, orgId, ada, 1)
+	must(t, err)
+	if updated.Version != 2 || updated.Name != "Ada King" || updated.Active {
+		t.Fatalf("UpdateOrgUser = %+v", updated)
+	}
+	// deactivating a user revokes their sessions
+	_, _, err = s.GetRefreshToken(ctx, ada.ID, appId)
+	expectNoRows(t, err)
+	_, err = s.UpdateOrgUser(ctx, orgId, ada, 1)
+	expectError(t, err, database.ErrVersionMismatch)
+	_, err = s.UpdateOrgUser(ctx, otherOrg, ada, 2)
+	expectError(t, err, database.ErrVersionMismatch)
+	ada.Email = "bob@example.com"
+	_, err = s.UpdateOrgUser(ctx, orgId, ada, 2)
+	expectError(t, err, database.ErrUserExists)
+
+	must(t, s.RemoveOrgUser(ctx, orgId, bob.ID))
+	expectNoRows(t, s.RemoveOrgUser(ctx, orgId, bob.ID))
+	_, err = s.GetOrgUser(ctx, orgId, bob.ID)
+	expectNoRows(t, err)
+	removed, err := s.GetUserById(ctx, bob.ID)
+	must(t, err)
+	if removed.Active {
+		t.Fatal("a removed user must be deactivated")
+	}
+}
+
+func testGroups(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "owner@example.com", "Owner", "hash"))
+	orgId := mustId(t)(s.InsertOrganization(ctx, "Acme", owner))
+	ada, err := s.CreateOrgUser(ctx, orgId, models.ScimUser{Email: "ada@example.com", Name: "Ada", Active: true}, "hash")
+	must(t, err)
+	bob, err := s.CreateOrgUser(ctx, orgId, models.ScimUser{Email: "bob@example.com", Name: "Bob", Active: true}, "hash")
+	must(t, err)
+
+	group, err := s.CreateGroup(ctx, orgId, models.Group{DisplayName: "Admins", Members: []models.GroupMember{{UserId: ada.ID}}})
+	must(t, err)
+	if group.Version != 1 || len(group.Members) != 1 || group.Members[0].Name != "Ada" {
+		t.Fatalf("CreateGroup = %+v", group)
+	}
+	_, err = s.CreateGroup(ctx, orgId, models.Group{DisplayName: "Admins"})
+	expectError(t, err, database.ErrGroupExists)
+	_, err = s.CreateGroup(ctx, orgId, models.Group{DisplayName: "Outsiders", Members: []models.GroupMember{{UserId: owner}}})
+	expectError(t, err, database.ErrInvalidMember)
+
+	_, err = s.CreateGroup(ctx, orgId, models.Group{DisplayName: "Everyone", ExternalId: "all"})
+	must(t, err)
+
+	groups, total, err := s.ListGroups(ctx, orgId, []models.FilterCondition{{Attr: "members", Op: "eq", Value: strconv.Itoa(ada.ID)}}, 0, 10)
+	must(t, err)
+	if total != 1 || groups[0].ID != group.ID {
+		t.Fatalf("member filter = %+v", groups)
+	}
+	groups, total, err = s.ListGroups(ctx, orgId, []models.FilterCondition{{Attr: "externalId", Op: "pr"}}, 0, 10)
+	must(t, err)
+	if total != 1 || groups[0].DisplayName != "Everyone" {
+		t.Fatalf("externalId filter = %+v", groups)
+	}
+
+	group.Members = []models.GroupMember{{UserId: bob.ID}, {UserId: ada.ID}, {UserId: bob.ID}}
+	group.DisplayName = "Operators"
+	updated, err := s.UpdateGroup(ctx, orgId, group, 1)
+	must(t, err)
+	if updated.Version != 2 || updated.DisplayName != "Operators" || len(updated.Members) != 2 || updated.Members[0].UserId != min(ada.ID, bob.ID) {
+		t.Fatalf("UpdateGroup = %+v", updated)
+	}
+	_, err = s.UpdateGroup(ctx, orgId, group, 1)
+	expectError(t, err, database.ErrVersionMismatch)
+	group.DisplayName = "Everyone"
+	_, err = s.UpdateGroup(ctx, orgId, group, 2)
+	expectError(t, err, database.ErrGroupExists)
+
+	// removing a user from the organization drops their memberships
+	must(t, s.RemoveOrgUser(ctx, orgId, bob.ID))
+	fetched, err := s.GetGroup(ctx, orgId, group.ID)
+	must(t, err)
+	if len(fetched.Members) != 1 || fetched.Members[0].UserId != ada.ID {
+		t.Fatalf("members after removal = %+v", fetched.Members)
+	}
+
+	must(t, s.DeleteGroup(ctx, orgId, group.ID))
+	expectNoRows(t, s.DeleteGroup(ctx, orgId, group.ID))
+	_, err = s.GetGroup(ctx, orgId, group.ID)
+	expectNoRows(t, err)
+}
+
+func testWebhooks(t *testing.T, s database.Store) {
+	owner := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+	appId := mustId(t)(s.InsertApp(ctx, "app", "https://app.example.com", owner))
+	logins := mustId(t)(s.InsertWebhookEndpoint(ctx, appId, "https://hooks.example.com/login", "s1", []string{"user.login"}))
+	everything := mustId(t)(s.InsertWebhookEndpoint(ctx, appId, "https://hooks.example.com/all", "s2", []string{"user.login", "user.signup"}))
+
+	endpoints, err := s.GetWebhookEndpointsOfApp(ctx, appId)
+	must(t, err)
+	if len(endpoints) != 2 || !endpoints[0].Active || endpoints[0].Secret != "s1" {
+		t.Fatalf("GetWebhookEndpointsOfApp = %+v", endpoints)
+	}
+	_, err = s.GetWebhookEndpoint(ctx, logins, appId+1000)
+	expectNoRows(t, err)
+
+	payload := []byte(`{"type":"user.signup"}`)
+	count, err := s.InsertWebhookDeliveries(ctx, appId, "evt_1", "user.signup", payload)
+	must(t, err)
+	if count != 1 {
+		t.Fatalf("signup fan-out = %d, want 1", count)
+	}
+
+	// inactive endpoints get nothing
+	endpoint, err := s.GetWebhookEndpoint(ctx, logins, appId)
+	must(t, err)
+	endpoint.Active = false
+	must(t, s.UpdateWebhookEndpoint(ctx, endpoint))
+	count, err = s.InsertWebhookDeliveries(ctx, appId, "evt_2", "user.login", []byte(`{}`))
+	must(t, err)
+	if count != 1 {
+		t.Fatalf("login fan-out = %d, want 1", count)
+	}
+
+	claimed, err := s.ClaimDueWebhookDeliveries(ctx, 10, time.Minute)
+	must(t, err)
+	if len(claimed) != 2 {
+		t.Fatalf("ClaimDueWebhookDeliveries = %+v", claimed)
+	}
+	// the claim order is not specified, so put the signup first
+	if claimed[0].EventId != "evt_1" {
+		claimed[0], claimed[1] = claimed[1], claimed[0]
+	}
+	if claimed[0].Url != "https://hooks.example.com/all" || claimed[0].Secret != "s2" {
+		t.Fatalf("claimed delivery = %+v", claimed[0])
+	}
+	var got map[string]string
+	must(t, json.Unmarshal(claimed[0].Payload, &got))
+	if got["type"] != "user.signup" {
+		t.Fatalf("payload = %v", got)
+	}
+	// leased deliveries are not handed out twice
+	again, err := s.ClaimDueWebhookDeliveries(ctx, 10, time.Minute)
+	must(t, err)
+	if len(again) != 0 {
+		t.Fatalf("claimed leased deliveries %+v", again)
+	}
+
+	must(t, s.MarkWebhookDeliverySucceeded(ctx, claimed[0].ID, 1, 200))
+	must(t, s.MarkWebhookDeliveryFailed(ctx, claimed[1].ID, 1, 0, "timeout", time.Now().Add(-time.Second), false))
+	retry, err := s.ClaimDueWebhookDeliveries(ctx, 10, time.Minute)
+	must(t, err)
+	if len(retry) != 1 || retry[0].ID != claimed[1].ID || retry[0].Attempts != 1 {
+		t.Fatalf("retry = %+v", retry)
+	}
+	must(t, s.MarkWebhookDeliveryFailed(ctx, retry[0].ID, 2, 500, "server error", time.Now().Add(-time.Second), true))
+	dead, err := s.ClaimDueWebhookDeliveries(ctx, 10, time.Minute)
+	must(t, err)
+	if len(dead) != 0 {
+		t.Fatalf("dead deliveries were claimed: %+v", dead)
+	}
+
+	history, err := s.GetWebhookDeliveries(ctx, everything, 10, 0)
+	must(t, err)
+	if len(history) != 2 {
+		t.Fatalf("GetWebhookDeliveries = %+v", history)
+	}
+	for _, delivery := range history {
+		switch delivery.ID {
+		case claimed[0].ID:
+			if delivery.Status != database.WebhookStatusSucceeded || delivery.LastStatusCode == nil || *delivery.LastStatusCode != 200 || delivery.DeliveredAt == nil {
+				t.Fatalf("succeeded delivery = %+v", delivery)
+			}
+		case claimed[1].ID:
+			if delivery.Status != database.WebhookStatusDead || delivery.Attempts != 2 || delivery.LastError != "server error" {
+				t.Fatalf("dead delivery = %+v", delivery)
+			}
+		}
+	}
+	page, err := s.GetWebhookDeliveries(ctx, everything, 1, 1)
+	must(t, err)
+	if len(page) != 1 {
+		t.Fatalf("second page = %+v", page)
+	}
+
+	testId, err := s.InsertWebhookDelivery(ctx, logins, "evt_test", "ping", []byte(`{}`))
+	must(t, err)
+	if testId == 0 {
+		t.Fatal("InsertWebhookDelivery returned no id")
+	}
+
+	must(t, s.DeleteWebhookEndpoint(ctx, everything, appId))
+	expectNoRows(t, s.DeleteWebhookEndpoint(ctx, everything, appId))
+	history, err = s.GetWebhookDeliveries(ctx, everything, 10, 0)
+	must(t, err)
+	if len(history) != 0 {
+		t.Fatal("deliveries outlived their endpoint")
+	}
+	expectNoRows(t, s.UpdateWebhookEndpoint(ctx, models.WebhookEndpoint{ID: everything, AppId: appId}))
+}
+
+func testOutbox(t *testing.T, s database.Store) {
+	first, err := s.InsertOutboxEmail(ctx, "ada@example.com", "Hello", "text", "<p>html</p>")
+	must(t, err)
+	second, err := s.InsertOutboxEmail(ctx, "bob@example.com", "Hi", "text", "<p>html</p>")
+	must(t, err)
+
+	claimed, err := s.ClaimDueOutboxEmails(ctx, 1, time.Minute)
+	must(t, err)
+	if len(claimed) != 1 {
+		t.Fatalf("ClaimDueOutboxEmails = %+v", claimed)
+	}
+	rest, err := s.ClaimDueOutboxEmails(ctx, 10, time.Minute)
+	must(t, err)
+	if len(rest) != 1 || rest[0].ID == claimed[0].ID {
+		t.Fatalf("second claim = %+v", rest)
+	}
+	for _, email := range append(claimed, rest...) {
+		if email.ID != first && email.ID != second {
+			t.Fatalf("claimed unknown email %+v", email)
+		}
+		if email.ID == first && (email.Recipient != "ada@example.com" || email.Subject != "Hello" || email.HTMLBody != "<p>html</p>") {
+			t.Fatalf("claimed email = %+v", email)
+		}
+	}
+
+	must(t, s.MarkOutboxEmailSent(ctx, first, 1))
+	must(t, s.MarkOutboxEmailFailed(ctx, second, 1, "refused", time.Now().Add(-time.Second), false))
+	retry, err := s.ClaimDueOutboxEmails(ctx, 10, time.Minute)
+	must(t, err)
+	if len(retry) != 1 || retry[0].ID != second || retry[0].Attempts != 1 {
+		t.Fatalf("retry = %+v", retry)
+	}
+	must(t, s.MarkOutboxEmailFailed(ctx, second, 2, "refused", time.Now().Add(-time.Second), true))
+	retry, err = s.ClaimDueOutboxEmails(ctx, 10, time.Minute)
+	must(t, err)
+	if len(retry) != 0 {
+		t.Fatalf("dead or sent emails were claimed: %+v", retry)
+	}
+
+	// the bodies hold reset and magic links and do not outlive the queue
+	for id, status := range map[int64]string{first: database.EmailStatusSent, second: database.EmailStatusDead} {
+		email, err := s.GetOutboxEmail(ctx, id)
+		must(t, err)
+		if email.Status != status || email.TextBody != "" || email.HTMLBody != "" || email.Subject == "" {
+			t.Fatalf("GetOutboxEmail(%d) = %+v", id, email)
+		}
+	}
+	pending, err := s.InsertOutboxEmail(ctx, "cy@example.com", "Reset", "https://auth.example.com/reset?token=secret", "<p>html</p>")
+	must(t, err)
+	must(t, s.MarkOutboxEmailFailed(ctx, pending, 1, "refused", time.Now().Add(time.Hour), false))
+	if email, err := s.GetOutboxEmail(ctx, pending); err != nil || email.TextBody == "" {
+		t.Fatalf("retried email = %+v, %v", email, err)
+	}
+
+	purged, err := s.PurgeOutboxEmails(ctx, time.Now().Add(-time.Hour))
+	must(t, err)
+	if purged != 0 {
+		t.Fatalf("purged %d emails newer than the cutoff", purged)
+	}
+	purged, err = s.PurgeOutboxEmails(ctx, time.Now().Add(time.Hour))
+	must(t, err)
+	if purged != 2 {
+		t.Fatalf("PurgeOutboxEmails = %d", purged)
+	}
+	_, err = s.GetOutboxEmail(ctx, first)
+	expectNoRows(t, err)
+	_, err = s.GetOutboxEmail(ctx, second)
+	expectNoRows(t, err)
+	if _, err := s.GetOutboxEmail(ctx, pending); err != nil {
+		t.Fatalf("pending email was purged: %v", err)
+	}
+}
+
+func testUserImport(t *testing.T, s database.Store) {
+	existing := mustId(t)(s.InsertUser(ctx, "ada@example.com", "Ada", "hash"))
+
+	job, err := s.InsertUserImport(ctx, hash("checksum"), "users.csv", 3)
+	must(t, err)
+	if job.Status != database.UserImportRunning || job.Total != 3 || job.NextRecord != 0 {
+		t.Fatalf("InsertUserImport = %+v", job)
+	}
+	running, err := s.GetRunningUserImport(ctx, hash("checksum"))
+	must(t, err)
+	if running.ID != job.ID {
+		t.Fatalf("GetRunningUserImport = %+v", running)
+	}
+
+	emails, err := s.GetExistingEmails(ctx, []string{"ADA@example.com", "new@example.com"})
+	must(t, err)
+	if !emails["ada@example.com"] || emails["new@example.com"] {
+		t.Fatalf("GetExistingEmails = %v", emails)
+	}
+
+	google := models.UserIdentity{Provider: "google", Subject: "123"}
+	users := []models.TransferUser{
+		{Name: "Ada", Email: "Ada@Example.com", PasswordHash: "hash", Active: true},
+		{Name: "Grace", Email: "grace@example.com", PasswordHash: "hash", Verified: true, Active: true, Identities: []models.UserIdentity{google}},
+		{Name: "Linus", Email: "linus@example.com", PasswordHash: "hash", Active: false, Identities: []models.UserIdentity{google}},
+	}
+	outcomes, err := s.ImportUserBatch(ctx, job.ID, users, 3, 0, 1)
+	must(t, err)
+	if outcomes[0].Inserted || !outcomes[1].Inserted || !outcomes[2].Inserted {
+		t.Fatalf("outcomes = %+v", outcomes)
+	}
+	if len(outcomes[1].IdentityConflicts) != 0 || len(outcomes[2].IdentityConflicts) != 1 {
+		t.Fatalf("identity conflicts = %+v", outcomes)
+	}
+
+	job, err = s.GetUserImport(ctx, job.ID)
+	must(t, err)
+	if job.NextRecord != 3 || job.Imported != 2 || job.Skipped != 1 || job.Failed != 1 {
+		t.Fatalf("job progress = %+v", job)
+	}
+	linked, err := s.GetLinkedIdentities(ctx, []models.UserIdentity{google, {Provider: "google", Subject: "456"}})
+	must(t, err)
+	if !linked["google:123"] || linked["google:456"] {
+		t.Fatalf("GetLinkedIdentities = %v", linked)
+	}
+
+	must(t, s.FinishUserImport(ctx, job.ID))
+	_, err = s.GetRunningUserImport(ctx, hash("checksum"))
+	expectNoRows(t, err)
+	expectNoRows(t, s.FinishUserImport(ctx, job.ID+1000))
+
+	exported, lastId, err := s.GetTransferUsers(ctx, 0, 2)
+	must(t, err)
+	if len(exported) != 2 || exported[0].Email != "ada@example.com" || lastId <= existing {
+		t.Fatalf("first export page = %+v, %d", exported, lastId)
+	}
+	if exported[1].Email != "grace@example.com" || !exported[1].Verified || len(exported[1].Identities) != 1 || exported[1].Identities[0] != google {
+		t.Fatalf("exported user = %+v", exported[1])
+	}
+	exported, lastId, err = s.GetTransferUsers(ctx, lastId, 2)
+	must(t, err)
+	if len(exported) != 1 || exported[0].Active || len(exported[0].Identities) != 0 {
+		t.Fatalf("second export page = %+v", exported)
+	}
+	exported, _, err = s.GetTransferUsers(ctx, lastId, 2)
+	must(t, err)
+	if len(exported) != 0 {
+		t.Fatalf("third export page = %+v", exported)
+	}
+}
+
+// testHealth expects a freshly set up store to be ready.
+func testHealth(t *testing.T, s database.Store) {
+	must(t, s.Ping(ctx))
+	pending, err := s.HasPendingMigrations(ctx)
+	must(t, err)
+	if pending {
+		t.Fatal("migrations pending on a migrated store")
+	}
+}
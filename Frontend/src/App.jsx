@@ -3,6 +3,7 @@ import AuthPage from "./Pages/AuthPage";
 import Dashboard from "./Pages/Home";
 import PasswordResetForm from "./Pages/PasswordResetForm";
 import ForgotPasswordPage from "./Pages/ForgetPassword";
+import MagicLinkPage from "./Pages/MagicLink";
 
 export default function App() {
   return (
@@ -12,6 +13,7 @@ export default function App() {
         <Route path="/dashboard" element={<Dashboard />} />
         <Route path="/forget-password" element={<ForgotPasswordPage/>}/>
         <Route path="/complete-forget-password" element={<PasswordResetForm/>}/>
+        <Route path="/magic-link" element={<MagicLinkPage/>}/>
       </Routes>
     </div>
   );
@@ -10,6 +10,7 @@ const AuthPage = () => {
     const [password, setPassword] = useState('');
     const [name, setName] = useState('');
     const [companyData, setCompanyData] = useState(null);
+    const [passwordErrors, setPasswordErrors] = useState([]);
 
     const BACKEND_URI = import.meta.env.VITE_BACKEND_URI??"";
 
@@ -70,8 +71,12 @@ const AuthPage = () => {
         });
   
         if (!response.ok) {
+          const data = await response.json();
+          // the password policy reports every failed rule
+          setPasswordErrors(data.errors ? data.errors.map((e) => e.message) : []);
           throw new Error('Network response was not ok');
         }
+        setPasswordErrors([]);
   
         const data = await response.json();
         console.log('Signup successful:', data);
@@ -244,9 +249,21 @@ const AuthPage = () => {
                 onChange={(e) => setPassword(e.target.value)}
                 className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm focus:ring-blue-500 focus:border-blue-500"
               />
+              {!isLogin && passwordErrors.length > 0 && (
+                <ul className="mt-2 text-sm text-red-600 list-disc list-inside">
+                  {passwordErrors.map((message) => (
+                    <li key={message}>{message}</li>
+                  ))}
+                </ul>
+              )}
             </div>
             {isLogin && (
-              <div className="flex items-center justify-end">
+              <div className="flex items-center justify-between">
+                <Link to={id != null ? "/magic-link?id="+id : "/magic-link"}>
+                  <button className="text-sm text-blue-600 hover:text-blue-500 cursor-pointer">
+                    Email me a sign-in link
+                  </button>
+                </Link>
                 <Link to={"/forget-password"}>
                   <button className="text-sm text-blue-600 hover:text-blue-500 cursor-pointer">
                     Forgot password?
@@ -0,0 +1,151 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { Link, useLocation, useNavigate } from 'react-router-dom';
+
+const MagicLinkPage = () => {
+  const [email, setEmail] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [message, setMessage] = useState('');
+  const [error, setError] = useState('');
+  const verified = useRef(false);
+  const navigate = useNavigate();
+
+  const BACKEND_URI = import.meta.env.VITE_BACKEND_URI??"";
+  const params = new URLSearchParams(useLocation().search);
+  const token = params.get('token');
+  const id = params.get('id');
+
+  // the link only works in the browser holding the binding cookie set when it was requested
+  useEffect(() => {
+    if (token == null || verified.current) {
+      return;
+    }
+    verified.current = true;
+    const verify = async () => {
+      setLoading(true);
+      try {
+        const formData = new FormData();
+        formData.append('token', token);
+        const response = await fetch(BACKEND_URI+'/api/v1/magic-link/verify', {
+          method: 'POST',
+          body: formData,
+          credentials: 'include',
+        });
+        const data = await response.json();
+        if (!response.ok) {
+          setError(data.message || 'This sign-in link is no longer valid.');
+          return;
+        }
+        if (id != null) {
+          const appResponse = await fetch(BACKEND_URI+'/api/v1/app/get/'+id);
+          const app = await appResponse.json();
+          window.location.href = app.data.callback_url+"?email="+data.data.email+"&name="+data.data.name+'&token_id='+data.data.token_id;
+          return;
+        }
+        localStorage.setItem('token', data.data.token);
+        navigate('/dashboard');
+      } catch (err) {
+        setError('An error occurred. Please try again later.');
+        console.error('Magic link error:', err);
+      } finally {
+        setLoading(false);
+      }
+    };
+    verify();
+  }, []);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError('');
+    setMessage('');
+    setLoading(true);
+
+    try {
+      const formData = new FormData();
+      formData.append('email', email);
+      if (id != null) {
+        formData.append('app_id', id);
+      }
+      const response = await fetch(BACKEND_URI+'/api/v1/magic-link', {
+        method: 'POST',
+        body: formData,
+        credentials: 'include',
+      });
+      const data = await response.json();
+      if (response.ok) {
+        setMessage('Check your inbox and open the link in this browser to sign in');
+        setEmail('');
+      } else {
+        setError(data.message || 'Failed to send the sign-in link. Please try again.');
+      }
+    } catch (err) {
+      setError('An error occurred. Please try again later.');
+      console.error('Magic link error:', err);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="flex items-center justify-center min-h-screen bg-gray-100">
+      <div className="w-full max-w-md p-6 bg-white rounded-lg shadow-md">
+        <h2 className="mb-6 text-2xl font-bold text-center text-gray-800">Sign in with Email</h2>
+
+        {message && (
+          <div className="p-3 mb-4 text-sm text-green-700 bg-green-100 rounded">
+            {message}
+          </div>
+        )}
+
+        {error && (
+          <div className="p-3 mb-4 text-sm text-red-700 bg-red-100 rounded">
+            {error}
+          </div>
+        )}
+
+        {token != null ? (
+          <p className="mb-6 text-center text-gray-600">
+            {loading ? 'Signing you in...' : ''}
+          </p>
+        ) : (
+          <form onSubmit={handleSubmit}>
+            <p className="mb-6 text-center text-gray-600">
+              We'll email you a link that signs you in without a password.
+            </p>
+            <div className="mb-4">
+              <label className="block mb-2 text-sm font-medium text-gray-700" htmlFor="email">
+                Email Address
+              </label>
+              <input
+                type="email"
+                id="email"
+                className="w-full px-3 py-2 border border-gray-300 rounded-md"
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                placeholder="your-email@example.com"
+                required
+              />
+            </div>
+
+            <button
+              type="submit"
+              className="w-full px-4 py-2 text-white bg-blue-600 rounded-md hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:ring-offset-2 disabled:opacity-50"
+              disabled={loading}
+            >
+              {loading ? 'Sending...' : 'Send Sign-in Link'}
+            </button>
+          </form>
+        )}
+
+        <div className="mt-6 text-center">
+          <Link to={id != null ? "/?id="+id : "/"}>
+            <p className="text-sm text-blue-600 hover:underline">
+              Back to Login
+            </p>
+          </Link>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default MagicLinkPage;
@@ -4,7 +4,6 @@ import { Eye, EyeOff } from 'lucide-react';
 
 const PasswordResetForm = () => {
   const [searchParams] = useSearchParams();
-  const [email, setEmail] = useState('');
   const [token, setToken] = useState('');
   const [password, setPassword] = useState('');
   const [confirmPassword, setConfirmPassword] = useState('');
@@ -16,11 +15,9 @@ const PasswordResetForm = () => {
   const [confirmError, setConfirmError] = useState('');
 
   useEffect(() => {
-    // Get email and token from URL parameters
-    const emailParam = searchParams.get('email');
+    // Get the reset token from URL parameters
     const tokenParam = searchParams.get('token');
     
-    if (emailParam) setEmail(emailParam);
     if (tokenParam) setToken(tokenParam);
   }, [searchParams]);
 
@@ -61,7 +58,6 @@ const PasswordResetForm = () => {
     try {
       const formData = new FormData();
       formData.append('password', password);
-      formData.append('email', email);
       formData.append('token', token);
       
       const response = await fetch(BACKEND_URI+`/api/v1/reset-password`, {
@@ -76,7 +72,7 @@ const PasswordResetForm = () => {
         setPassword('');
         setConfirmPassword('');
       } else {
-        setError(data.message || 'Failed to reset password. Please try again.');
+        setError(data.errors ? data.errors.map((e) => e.message).join('. ') : data.message || 'Failed to reset password. Please try again.');
       }
     } catch (err) {
       setError('An error occurred. Please try again later.');
@@ -112,19 +108,6 @@ const PasswordResetForm = () => {
         )}
         
         <form onSubmit={handleSubmit}>
-          <div className="mb-4">
-            <label className="block mb-2 text-sm font-medium text-gray-700" htmlFor="email">
-              Email
-            </label>
-            <input
-              type="email"
-              id="email"
-              className="w-full px-3 py-2 border border-gray-300 rounded-md bg-gray-100"
-              value={email}
-              disabled
-            />
-          </div>
-          
           <div className="mb-4">
             <label className="block mb-2 text-sm font-medium text-gray-700" htmlFor="password">
               New Password
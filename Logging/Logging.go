@@ -0,0 +1,115 @@
+// Package logging builds the structured loggers used across the server.
+// Every component logs through its own *slog.Logger so its level can be
+// tuned separately, and all output passes through the redaction in
+// Redact.go before it is written.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	config "go_server/Config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The components that log. Their levels can be set in log.components.
+const (
+	ComponentAPI      = "api"
+	ComponentAuth     = "auth"
+	ComponentHTTP     = "http"
+	ComponentStore    = "store"
+	ComponentWebhooks = "webhooks"
+	ComponentEmail    = "email"
+	ComponentServer   = "server"
+)
+
+// Loggers hands out the per-component loggers.
+type Loggers struct {
+	handler slog.Handler
+	level   slog.Level
+	levels  map[string]slog.Level
+}
+
+// New writes logs to w in the configured format. cfg must be validated.
+func New(cfg config.LogConfig, w io.Writer) *Loggers {
+	// the component handlers filter by level, the output handler takes all
+	opts := &slog.HandlerOptions{Level: slog.Level(-8)}
+	var out slog.Handler
+	if cfg.Format == "text" {
+		out = slog.NewTextHandler(w, opts)
+	} else {
+		out = slog.NewJSONHandler(w, opts)
+	}
+
+	l := &Loggers{
+		handler: &redactingHandler{inner: out, redactor: newRedactor(cfg.RedactEmails, cfg.RedactKeys)},
+		levels:  map[string]slog.Level{},
+	}
+	l.level.UnmarshalText([]byte(cfg.Level))
+	for component, level := range cfg.Components {
+		var parsed slog.Level
+		if parsed.UnmarshalText([]byte(level)) == nil {
+			l.levels[component] = parsed
+		}
+	}
+	return l
+}
+
+// For returns the logger of a component, tagged with its name.
+func (l *Loggers) For(component string) *slog.Logger {
+	level, ok := l.levels[component]
+	if !ok {
+		level = l.level
+	}
+	return slog.New(&componentHandler{inner: l.handler, level: level}).With("component", component)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID stores the request ID in ctx. Records logged with that
+// context carry it as request_id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// componentHandler applies a component's level and adds the request ID
+// and, inside a traced operation, the trace and span IDs.
+type componentHandler struct {
+	inner slog.Handler
+	level slog.Level
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *componentHandler) Handle(ctx context.Context, record slog.Record) error {
+	id := RequestID(ctx)
+	span := trace.SpanContextFromContext(ctx)
+	if id != "" || span.IsValid() {
+		record = record.Clone()
+	}
+	if id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &componentHandler{inner: h.inner.WithAttrs(attrs), level: h.level}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{inner: h.inner.WithGroup(name), level: h.level}
+}
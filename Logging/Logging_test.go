@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	config "go_server/Config"
+)
+
+func TestRedaction(t *testing.T) {
+	var out bytes.Buffer
+	cfg := config.Default().Log
+	cfg.RedactKeys = []string{"ssn"}
+	log := New(cfg, &out).For(ComponentAuth)
+
+	jwt := "eyJhbGciOiJSUzI1NiJ9.eyJpZCI6MX0.c2lnbmF0dXJl"
+	log.Info("login for ada@example.com with Bearer "+jwt,
+		"authorization", "Bearer abc",
+		"refresh_token", "opaque",
+		"ssn", "123",
+		"url", "/reset?token=abc123&app=1",
+		"dsn", "postgres://app:hunter2@db:5432/app",
+		"err", errors.New("password=hunter2 rejected for grace@example.org"),
+	)
+	line := out.String()
+	for _, secret := range []string{jwt, "abc", "opaque", "123", "hunter2", "ada@", "grace@"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("log line contains %q: %s", secret, line)
+		}
+	}
+	for _, kept := range []string{"a***@example.com", "g***@example.org", `"component":"auth"`, "app=1", "db:5432"} {
+		if !strings.Contains(line, kept) {
+			t.Errorf("log line lacks %q: %s", kept, line)
+		}
+	}
+}
+
+func TestEmailsCanBeLogged(t *testing.T) {
+	var out bytes.Buffer
+	cfg := config.Default().Log
+	cfg.RedactEmails = false
+	New(cfg, &out).For(ComponentEmail).Info("sent", "to", "ada@example.com")
+	if !strings.Contains(out.String(), "ada@example.com") {
+		t.Fatalf("email redacted: %s", out.String())
+	}
+}
+
+func TestComponentLevelsAndRequestID(t *testing.T) {
+	var out bytes.Buffer
+	cfg := config.Default().Log
+	cfg.Components = map[string]string{ComponentHTTP: "warn", ComponentWebhooks: "debug"}
+	loggers := New(cfg, &out)
+
+	loggers.For(ComponentHTTP).Info("hidden")
+	loggers.For(ComponentWebhooks).Debug("shown")
+	loggers.For(ComponentAPI).Debug("hidden too")
+	loggers.For(ComponentAPI).InfoContext(WithRequestID(context.Background(), "req-1"), "with id")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "shown") || !strings.Contains(lines[1], `"request_id":"req-1"`) {
+		t.Fatalf("output = %s", out.String())
+	}
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute name fragments whose values are never
+// logged, whatever they contain.
+var sensitiveKeys = []string{"token", "password", "secret", "authorization", "cookie", "api_key", "private_key", "credential"}
+
+var (
+	jwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	schemePattern     = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+	assignmentPattern = regexp.MustCompile(`(?i)(\b(?:[a-z_]*token|password|secret|[a-z]+_code)["']?\s*[:=]\s*["']?|[?&]code=)[^\s"'&,;]+`)
+	urlUserPattern    = regexp.MustCompile(`(://[^:/@\s]+:)[^@/\s]+@`)
+	emailPattern      = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,})`)
+)
+
+// redactor removes credentials, and optionally email addresses, from
+// messages and attribute values.
+type redactor struct {
+	keys   []string
+	emails bool
+}
+
+func newRedactor(emails bool, extraKeys []string) *redactor {
+	keys := append([]string(nil), sensitiveKeys...)
+	for _, key := range extraKeys {
+		keys = append(keys, strings.ToLower(key))
+	}
+	return &redactor{keys: keys, emails: emails}
+}
+
+func (r *redactor) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range r.keys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// String redacts tokens, credentials in URLs and key=value pairs, and
+// email addresses when enabled.
+func (r *redactor) String(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = schemePattern.ReplaceAllString(s, "$1 "+redacted)
+	s = assignmentPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = urlUserPattern.ReplaceAllString(s, "${1}"+redacted+"@")
+	if r.emails {
+		s = emailPattern.ReplaceAllString(s, "$1***@$2")
+	}
+	return s
+}
+
+func (r *redactor) attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redactedGroup := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redactedGroup[i] = r.attr(member)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redactedGroup...)}
+	}
+	if r.sensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.String(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.String(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.String(v.String()))
+		case []string:
+			values := make([]string, len(v))
+			for i, value := range v {
+				values[i] = r.String(value)
+			}
+			return slog.Any(a.Key, values)
+		}
+	}
+	return a
+}
+
+// redactingHandler passes every record through a redactor before the
+// output handler sees it.
+type redactingHandler struct {
+	inner    slog.Handler
+	redactor *redactor
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	clean := slog.NewRecord(record.Time, record.Level, h.redactor.String(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(h.redactor.attr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, clean)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = h.redactor.attr(a)
+	}
+	return &redactingHandler{inner: h.inner.WithAttrs(clean), redactor: h.redactor}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{inner: h.inner.WithGroup(name), redactor: h.redactor}
+}
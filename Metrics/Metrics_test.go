@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextFormat(t *testing.T) {
+	counter := NewCounter("test_events_total", "Events seen.", "kind")
+	counter.Inc("b")
+	counter.Add(2, `quote"d`)
+	histogram := NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(3, "/a")
+	NewGaugeFunc("test_gauge", "A gauge.", func() float64 { return 7 })
+
+	var out strings.Builder
+	Write(&out)
+	for _, line := range []string{
+		"# TYPE test_events_total counter",
+		`test_events_total{kind="b"} 1`,
+		`test_events_total{kind="quote\"d"} 2`,
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{route="/a",le="0.1"} 1`,
+		`test_duration_seconds_bucket{route="/a",le="1"} 2`,
+		`test_duration_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`test_duration_seconds_sum{route="/a"} 3.55`,
+		`test_duration_seconds_count{route="/a"} 3`,
+		"# TYPE test_gauge gauge\ntest_gauge 7",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output lacks %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestLabelCountIsChecked(t *testing.T) {
+	counter := NewCounter("test_checked_total", "Checked.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wrong number of label values accepted")
+		}
+	}()
+	counter.Inc("only one")
+}
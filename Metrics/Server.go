@@ -0,0 +1,41 @@
+package metrics
+
+import "database/sql"
+
+// The metrics the server exports. Label values are kept to small, known
+// sets: routes are the registered patterns and apps are only named once
+// they are known to exist.
+var (
+	HTTPRequestDuration = NewHistogram("http_request_duration_seconds",
+		"Time taken to serve HTTP requests by route.", DefaultBuckets, "method", "route", "status")
+	Logins = NewCounter("auth_logins_total",
+		"Login attempts by method (password, google, magic_link, device), app and result.", "method", "app", "result")
+	TokensIssued = NewCounter("auth_tokens_issued_total",
+		"Access and refresh tokens signed.", "type")
+	RefreshRotations = NewCounter("auth_refresh_rotations_total",
+		"Refresh token rotations by result.", "result")
+	EmailSends = NewCounter("email_sends_total",
+		"Email delivery attempts by outcome: sent, retry or dead.", "result")
+)
+
+// RegisterDBStats exports the statistics of the database connection pool.
+func RegisterDBStats(db *sql.DB) {
+	NewGaugeFunc("db_pool_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+		return float64(db.Stats().MaxOpenConnections)
+	})
+	NewGaugeFunc("db_pool_open_connections", "Established connections, both in use and idle.", func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	NewGaugeFunc("db_pool_in_use_connections", "Connections currently in use.", func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	NewGaugeFunc("db_pool_idle_connections", "Idle connections.", func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	NewCounterFunc("db_pool_wait_count_total", "Connections waited for.", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	NewCounterFunc("db_pool_wait_seconds_total", "Time spent waiting for a connection.", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	apierror "go_server/ApiError"
+	database "go_server/Database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets administrators through. It must run after
+// JWTAuthMiddleware.
+func AdminMiddleware(store database.UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin, err := store.IsUserAdmin(c.Request.Context(), c.GetInt("id"))
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Error checking permissions", err))
+			return
+		}
+		if !admin {
+			apierror.Abort(c, apierror.Forbidden(apierror.CodeForbidden, "Admin access required"))
+			return
+		}
+		c.Next()
+	}
+}
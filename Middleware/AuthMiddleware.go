@@ -1,40 +1,66 @@
 package middleware
 
 import (
-	"fmt"
+	"errors"
+	apierror "go_server/ApiError"
 	controller "go_server/Controllers"
+	database "go_server/Database"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func JWTAuthMiddleware() gin.HandlerFunc {
-	return VerifyUserToken
+func JWTAuthMiddleware(store database.UserStore, tokens *controller.Tokens, log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		VerifyUserToken(c, store, tokens, log)
+	}
 }
-func VerifyUserToken(c *gin.Context) {
+func VerifyUserToken(c *gin.Context, store database.UserStore, tokens *controller.Tokens, log *slog.Logger) {
+	ctx := c.Request.Context()
 	tokenString := c.GetHeader("Authorization")
-	fmt.Println(tokenString)
 	if tokenString == "" {
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Authorization header is required",
-		})
-		c.Abort()
+		rejectBearer(c, apierror.Unauthorized(apierror.CodeAuthenticationRequired, "Authorization header is required"))
 		return
 	}
-	jwtToken := strings.Split(tokenString, " ")[1]
-	userClaim, err := controller.VerifyToken(jwtToken,&controller.AcessTokenClaim{})
+	// a header without the "Bearer " prefix is rejected like a bad token
+	_, jwtToken, _ := strings.Cut(tokenString, " ")
+	// only access tokens are accepted: refresh tokens are signed with the
+	// same key but carry no typ header
+	userClaim, err := controller.VerifyTypedToken(tokens, controller.AccessTokenType, jwtToken, &controller.AcessTokenClaim{})
+	// tokens of an app acting for itself carry no user, and exchanged
+	// tokens are for their audience only
+	if err == nil && userClaim.Id == 0 {
+		err = errors.New("the token has no user")
+	}
+	if err == nil && userClaim.Act != nil {
+		err = errors.New("the token was exchanged for another app")
+	}
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(401, gin.H{
-			"status":  "error",
-			"message": "Invalid token",
-		})
-		c.Abort()
+		log.DebugContext(c.Request.Context(), "rejected access token", "err", err)
+		rejectBearer(c, apierror.Unauthorized(apierror.CodeInvalidToken, "Invalid token"))
+		return
+	}
+	// tokens issued before the last password change have been revoked
+	changedAt, err := store.GetPasswordChangedAt(ctx, userClaim.Id)
+	if err != nil || (userClaim.IssuedAt != nil && userClaim.IssuedAt.Time.Before(changedAt.Truncate(time.Second))) {
+		rejectBearer(c, apierror.Unauthorized(apierror.CodeInvalidToken, "Invalid token"))
 		return
 	}
 	c.Set("id", userClaim.Id)
 	c.Set("name", userClaim.Name)
 	c.Set("email", userClaim.Email)
+	c.Set("app_id", userClaim.AppId)
+	c.Set("audience", []string(userClaim.Audience))
+	if userClaim.AuthTime != nil {
+		c.Set("auth_time", userClaim.AuthTime.Time)
+	}
 	c.Next()
 }
+
+// rejectBearer aborts with err and the challenge RFC 6750 asks for.
+func rejectBearer(c *gin.Context, err *apierror.Error) {
+	c.Header("WWW-Authenticate", `Bearer realm="goauth"`)
+	apierror.Abort(c, err)
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CorsPolicy decides which browser origins may call a group of routes.
+// Origins lists explicit origins, where "*" allows any origin. AllowOrigin,
+// when set, is asked about origins not in the list. Credentials are only
+// ever allowed for an explicitly matched origin, never for "*".
+type CorsPolicy struct {
+	Origins          []string
+	AllowOrigin      func(origin string) bool
+	AllowCredentials bool
+	Methods          []string
+	Headers          []string
+	MaxAge           time.Duration
+}
+
+// CorsRule applies Policy to the paths starting with Prefix. A nil Policy
+// disables CORS for those paths.
+type CorsRule struct {
+	Prefix string
+	Policy *CorsPolicy
+}
+
+// CorsMiddleware applies the first rule whose prefix matches the request
+// path. Preflight requests are answered directly with 204 and a Max-Age so
+// browsers can cache them. Paths no rule matches get no CORS headers.
+func CorsMiddleware(rules []CorsRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := matchCorsRule(rules, c.Request.URL.Path)
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if policy == nil {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		explicit, allowed := policy.allows(origin)
+		if !allowed {
+			if preflight {
+				c.AbortWithStatus(403)
+				return
+			}
+			c.Next()
+			return
+		}
+		if explicit {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if policy.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", strings.Join(policy.Methods, ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+			if policy.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+			}
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Next()
+	}
+}
+
+func matchCorsRule(rules []CorsRule, path string) *CorsPolicy {
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule.Policy
+		}
+	}
+	return nil
+}
+
+// allows reports whether origin may call the routes, and whether it was
+// matched explicitly rather than through "*".
+func (p *CorsPolicy) allows(origin string) (explicit bool, allowed bool) {
+	wildcard := false
+	for _, o := range p.Origins {
+		if o == "*" {
+			wildcard = true
+		} else if strings.EqualFold(o, origin) {
+			return true, true
+		}
+	}
+	if p.AllowOrigin != nil && p.AllowOrigin(strings.ToLower(origin)) {
+		return true, true
+	}
+	return false, wildcard
+}
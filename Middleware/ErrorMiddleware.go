@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+	apierror "go_server/ApiError"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+const oauthErrorsKey = "oauth_errors"
+
+// ErrorMiddleware renders the error a handler aborted with, unless a
+// response was already written. Errors other than *apierror.Error are
+// treated as internal errors, and the causes of server errors are logged
+// here rather than at every call site.
+func ErrorMiddleware(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+		var apiErr *apierror.Error
+		if !errors.As(last.Err, &apiErr) {
+			apiErr = apierror.Internal("Internal server error", last.Err)
+		}
+		if apiErr.Status >= 500 && apiErr.Err != nil {
+			log.ErrorContext(c.Request.Context(), apiErr.Message, "err", apiErr.Err, "route", c.FullPath())
+		}
+		if c.GetBool(oauthErrorsKey) {
+			apierror.WriteOAuth(c, apiErr)
+			return
+		}
+		apierror.WriteProblem(c, apiErr)
+	}
+}
+
+// OAuthErrorFormat makes ErrorMiddleware answer in the OAuth 2.0 error
+// format on the routes it is added to, such as the token endpoints.
+func OAuthErrorFormat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(oauthErrorsKey, true)
+	}
+}
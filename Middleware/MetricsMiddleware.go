@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	metrics "go_server/Metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var knownMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// MetricsMiddleware records how long each request took, labelled with the
+// route pattern rather than the path so ids do not create new series.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		method, route := c.Request.Method, c.FullPath()
+		if route == "" {
+			route = "unmatched"
+			if !knownMethods[method] {
+				method = "other"
+			}
+		}
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), method, route, strconv.Itoa(c.Writer.Status()))
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	controller "go_server/Controllers"
+	database "go_server/Database"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InitialAccessTokenMiddleware authenticates client registrations with an
+// organization's initial access token (RFC 7591 section 3) and stores the
+// organization id in the context as "org_id".
+func InitialAccessTokenMiddleware(store database.OrganizationStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			invalidBearerToken(c, "An initial access token is required")
+			return
+		}
+		orgId, err := store.GetOrgIdByInitialAccessToken(c.Request.Context(), controller.HashOpaqueToken(token))
+		if err != nil {
+			invalidBearerToken(c, "An initial access token is required")
+			return
+		}
+		c.Set("org_id", orgId)
+		c.Next()
+	}
+}
+
+// RegistrationTokenMiddleware authenticates the client configuration
+// endpoint (RFC 7592 section 2) with the registration access token of the
+// client in the :clientId path parameter. Unknown clients get the same
+// answer as wrong tokens. The app id is stored in the context as "app_id"
+// and the token as "registration_access_token".
+func RegistrationTokenMiddleware(store database.AppStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			invalidBearerToken(c, "The registration access token of the client is required")
+			return
+		}
+		appId, err := store.GetAppIdByRegistrationToken(c.Request.Context(), controller.HashOpaqueToken(token))
+		if err != nil || strconv.Itoa(appId) != c.Param("clientId") {
+			invalidBearerToken(c, "The registration access token of the client is required")
+			return
+		}
+		c.Set("app_id", appId)
+		c.Set("registration_access_token", token)
+		c.Next()
+	}
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// invalidBearerToken answers as a protected resource does (RFC 6750
+// section 3).
+func invalidBearerToken(c *gin.Context, description string) {
+	c.Header("WWW-Authenticate", `Bearer realm="goauth", error="invalid_token"`)
+	c.Header("Cache-Control", "no-store")
+	c.AbortWithStatusJSON(401, gin.H{
+		"error":             "invalid_token",
+		"error_description": description,
+	})
+}
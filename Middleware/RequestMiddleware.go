@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	apierror "go_server/ApiError"
+	logging "go_server/Logging"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDPattern accepts the IDs proxies commonly generate, such as
+// UUIDs, and nothing that could smuggle content into logs or headers.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestIDMiddleware takes the X-Request-ID of the incoming request, or
+// makes one up, echoes it in the response and stores it in the request
+// context so everything logged for the request carries it.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Header("X-Request-ID", id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestLogMiddleware logs one line per request. Only the path is logged,
+// never the query string, which may carry tokens.
+func RequestLogMiddleware(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+		log.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// RecoveryMiddleware turns a panic into a 500 response and logs it with
+// the stack, replacing gin's recovery which prints the request headers.
+func RecoveryMiddleware(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				log.ErrorContext(c.Request.Context(), "panic serving request", "panic", recovered, "stack", string(debug.Stack()))
+				// logged above with the stack, so the error carries no cause
+				apierror.Abort(c, apierror.Internal("Internal server error", nil))
+			}
+		}()
+		c.Next()
+	}
+}
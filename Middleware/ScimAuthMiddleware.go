@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	controller "go_server/Controllers"
+	database "go_server/Database"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ScimAuthMiddleware(store database.OrganizationStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		VerifyScimToken(c, store)
+	}
+}
+
+// VerifyScimToken authenticates SCIM clients with an organization bearer
+// token and stores the organization id in the context as "org_id".
+func VerifyScimToken(c *gin.Context, store database.OrganizationStore) {
+	ctx := c.Request.Context()
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		scimUnauthorized(c)
+		return
+	}
+
+	orgId, err := store.GetOrgIdByScimToken(ctx, controller.HashOpaqueToken(token))
+	if err != nil {
+		scimUnauthorized(c)
+		return
+	}
+	c.Set("org_id", orgId)
+	c.Next()
+}
+
+func scimUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+	c.Header("Content-Type", "application/scim+json; charset=utf-8")
+	c.AbortWithStatusJSON(401, gin.H{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+		"status":  "401",
+		"detail":  "A valid SCIM bearer token is required",
+	})
+}
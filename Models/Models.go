@@ -1,10 +1,16 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type User struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Active   bool   `json:"active"`
 }
 
 type App struct {
@@ -12,6 +18,37 @@ type App struct {
 	Name        string `json:"name"`
 	CallbackUrl string `json:"callback_url"`
 	UserId      int    `json:"user_id"`
+	LogoUrl     string `json:"logo_url"`
+	BrandColor  string `json:"brand_color"`
+}
+
+// ExchangePolicy lets the source app exchange the tokens its users hold
+// for tokens of the target app, limited to Scopes (RFC 8693).
+type ExchangePolicy struct {
+	TargetAppId int       `json:"target_app_id"`
+	SourceAppId int       `json:"source_app_id"`
+	Scopes      []string  `json:"scopes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ClientMetadata is an app as seen by dynamic client registration (RFC
+// 7591 section 2): its name, single redirect URI, logo and the scopes it
+// may request for itself.
+type ClientMetadata struct {
+	AppId       int
+	Name        string
+	CallbackUrl string
+	LogoUrl     string
+	Scopes      []string
+}
+
+// AppClient is an app as an OAuth client. SecretHash is empty until the
+// owner issues a client secret; Scopes are the ones the app may request
+// with the client credentials grant.
+type AppClient struct {
+	AppId      int
+	SecretHash string
+	Scopes     []string
 }
 
 type Token struct {
@@ -28,12 +65,6 @@ type Session struct {
 	AppId    int    `json:"app_id"`
 }
 
-type ForgetPassword struct {
-	Email string `json:"email"`
-	Token string `json:"token"`
-	ExpiredAt string `json:"expired_at"`
-}
-
 
 type GoogleUser struct {
     Iss            string `json:"iss"`
@@ -47,4 +78,189 @@ type GoogleUser struct {
     GivenName      string `json:"given_name"`
     FamilyName     string `json:"family_name"`
     Locale         string `json:"locale"`
-}
\ No newline at end of file
+}
+
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	OwnerId   int       `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ScimToken struct {
+	ID          int        `json:"id"`
+	OrgId       int        `json:"org_id"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// InitialAccessToken lets its holder register OAuth clients owned by the
+// organization's owner (RFC 7591 section 3).
+type InitialAccessToken struct {
+	ID          int        `json:"id"`
+	OrgId       int        `json:"org_id"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// ScimUser is a user as seen through an organization's SCIM endpoint.
+type ScimUser struct {
+	ID         int       `json:"id"`
+	Email      string    `json:"email"`
+	Name       string    `json:"name"`
+	Active     bool      `json:"active"`
+	ExternalId string    `json:"external_id"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type GroupMember struct {
+	UserId int    `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+type Group struct {
+	ID          int           `json:"id"`
+	OrgId       int           `json:"org_id"`
+	DisplayName string        `json:"display_name"`
+	ExternalId  string        `json:"external_id"`
+	Version     int           `json:"version"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Members     []GroupMember `json:"members"`
+}
+
+// FilterCondition is a single "attr op value" clause of a SCIM filter.
+// Conditions in a slice are combined with AND.
+type FilterCondition struct {
+	Attr  string
+	Op    string
+	Value string
+}
+
+type WebhookEndpoint struct {
+	ID        int       `json:"id"`
+	AppId     int       `json:"app_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookEvent is the body of a webhook request.
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	AppId     int         `json:"app_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointId     int             `json:"endpoint_id"`
+	EventId        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+
+	// Url and Secret are the target of the delivery, filled in when the
+	// delivery is claimed by the worker.
+	Url    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type OutboxEmail struct {
+	ID        int64     `json:"id"`
+	Recipient string    `json:"recipient"`
+	Subject   string    `json:"subject"`
+	TextBody  string    `json:"text_body"`
+	HTMLBody  string    `json:"html_body"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MagicLink struct {
+	Email     string    `json:"email"`
+	AppId     int       `json:"app_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DeviceCode is a pending login of a device that cannot open the app's
+// callback (RFC 8628). UserId is 0 until a user decides on it; Interval is
+// the least number of seconds between two polls of the device.
+type DeviceCode struct {
+	ID           int
+	AppId        int
+	UserId       int
+	UserCode     string
+	Status       string
+	Interval     int
+	ExpiresAt    time.Time
+	LastPolledAt time.Time
+	DecidedAt    time.Time
+	// AuthTime is when the approving user last entered credentials.
+	AuthTime time.Time
+}
+
+// UserIdentity links an account to a user at an external identity provider.
+type UserIdentity struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+}
+
+// TransferUser is the shape of a user in bulk imports and exports.
+type TransferUser struct {
+	Name         string         `json:"name"`
+	Email        string         `json:"email"`
+	PasswordHash string         `json:"password_hash"`
+	Verified     bool           `json:"verified"`
+	Active       bool           `json:"active"`
+	Identities   []UserIdentity `json:"identities"`
+}
+
+type UserImport struct {
+	ID         int       `json:"id"`
+	Checksum   string    `json:"checksum"`
+	Source     string    `json:"source"`
+	Total      int       `json:"total"`
+	NextRecord int       `json:"next_record"`
+	Imported   int       `json:"imported"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type ImportIssue struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport summarises an import. Skipped counts users whose email was
+// already registered; Failed counts records that did not validate.
+type ImportReport struct {
+	JobId     int           `json:"job_id,omitempty"`
+	DryRun    bool          `json:"dry_run"`
+	Total     int           `json:"total"`
+	ResumedAt int           `json:"resumed_at"`
+	Imported  int           `json:"imported"`
+	Skipped   int           `json:"skipped"`
+	Failed    int           `json:"failed"`
+	Issues    []ImportIssue `json:"issues"`
+}
@@ -0,0 +1,186 @@
+package models
+
+// Request bodies of the API. Each is accepted as JSON or as a form; the
+// binding tags are the validation rules and also produce the schemas of
+// the OpenAPI document. An app_id of 0 means the dashboard.
+
+type SignUpRequest struct {
+	Email    string `json:"email" form:"email" binding:"required,email,max=254"`
+	Password string `json:"password" form:"password" binding:"required,max=1024"`
+	Name     string `json:"name" form:"name" binding:"max=100"`
+	AppId    int    `json:"app_id" form:"app_id" binding:"min=0"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" form:"email" binding:"required,email,max=254"`
+	Password string `json:"password" form:"password" binding:"required,max=1024"`
+	AppId    int    `json:"app_id" form:"app_id" binding:"min=0"`
+}
+
+type GoogleLoginRequest struct {
+	GoogleToken string `json:"google_token" form:"google_token" binding:"required,max=4096"`
+	AppId       int    `json:"app_id" form:"app_id" binding:"min=0"`
+}
+
+type RefreshRequest struct {
+	Token string `json:"token" form:"token" binding:"required,max=4096"`
+	// Id is the app of the session
+	Id int `json:"id" form:"id" binding:"min=0"`
+}
+
+type LogoutRequest struct {
+	AppId int `json:"app_id" form:"app_id" binding:"required,min=1"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" form:"old_password" binding:"required,max=1024"`
+	NewPassword string `json:"new_password" form:"new_password" binding:"required,max=1024"`
+	Locale      string `json:"locale" form:"locale" binding:"max=35"`
+}
+
+// DeleteAccountRequest needs the password for password accounts only.
+type DeleteAccountRequest struct {
+	Password string `json:"password" form:"password" binding:"max=1024"`
+}
+
+type ForgetPasswordRequest struct {
+	Email  string `json:"email" form:"email" binding:"required,email,max=254"`
+	AppId  int    `json:"app_id" form:"app_id" binding:"min=0"`
+	Locale string `json:"locale" form:"locale" binding:"max=35"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" form:"token" binding:"required,max=256"`
+	Password string `json:"password" form:"password" binding:"required,max=1024"`
+	AppId    int    `json:"app_id" form:"app_id" binding:"min=0"`
+	Locale   string `json:"locale" form:"locale" binding:"max=35"`
+}
+
+type MagicLinkRequest struct {
+	Email  string `json:"email" form:"email" binding:"required,email,max=254"`
+	AppId  int    `json:"app_id" form:"app_id" binding:"min=0"`
+	Locale string `json:"locale" form:"locale" binding:"max=35"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" form:"token" binding:"required,max=256"`
+}
+
+type CreateAppRequest struct {
+	Name        string `json:"name" form:"name" binding:"required,max=100"`
+	CallbackUrl string `json:"callback_url" form:"callback_url" binding:"required,http_url,max=2048"`
+}
+
+// UpdateAppRequest changes the fields that are set; at least one is
+// required.
+type UpdateAppRequest struct {
+	Name        string `json:"name" form:"name" binding:"required_without=CallbackUrl,max=100"`
+	CallbackUrl string `json:"callback_url" form:"callback_url" binding:"omitempty,http_url,max=2048"`
+}
+
+// AppBrandingRequest sets the email branding; empty values reset it.
+type AppBrandingRequest struct {
+	LogoUrl    string `json:"logo_url" form:"logo_url" binding:"omitempty,https_url,max=2048"`
+	BrandColor string `json:"brand_color" form:"brand_color" binding:"omitempty,hex_color"`
+}
+
+// AppClientRequest sets the scopes an app may request for itself.
+type AppClientRequest struct {
+	Scopes []string `json:"scopes" form:"scopes" binding:"max=50,dive,scope_token,max=100"`
+}
+
+// TokenRequest is a request to the OAuth token endpoint. The client may
+// authenticate with HTTP Basic instead of client_id and client_secret.
+type TokenRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type" binding:"required,max=100"`
+	Scope        string `json:"scope" form:"scope" binding:"max=2000"`
+	ClientId     string `json:"client_id" form:"client_id" binding:"max=100"`
+	ClientSecret string `json:"client_secret" form:"client_secret" binding:"max=200"`
+	DeviceCode   string `json:"device_code" form:"device_code" binding:"max=100"`
+	// token exchange (RFC 8693)
+	SubjectToken       string `json:"subject_token" form:"subject_token" binding:"max=4096"`
+	SubjectTokenType   string `json:"subject_token_type" form:"subject_token_type" binding:"max=200"`
+	RequestedTokenType string `json:"requested_token_type" form:"requested_token_type" binding:"max=200"`
+	// also the app a client credentials token is for
+	Audience string `json:"audience" form:"audience" binding:"max=100"`
+}
+
+// ExchangePolicyRequest sets the scopes another app may obtain for its
+// users' tokens with the token exchange.
+type ExchangePolicyRequest struct {
+	Scopes []string `json:"scopes" form:"scopes" binding:"max=50,dive,scope_token,max=100"`
+}
+
+// ClientRegistrationRequest is the metadata of a dynamically registered
+// client (RFC 7591 section 2); other metadata is ignored. An app has one
+// callback URL, so exactly one redirect URI is taken. An update (RFC 7592
+// section 2.2) sends all of it again with the client_id.
+type ClientRegistrationRequest struct {
+	ClientId                string   `json:"client_id" form:"client_id" binding:"max=100"`
+	ClientName              string   `json:"client_name" form:"client_name" binding:"required,max=100"`
+	RedirectUris            []string `json:"redirect_uris" form:"redirect_uris" binding:"required,min=1,max=1,dive,http_url,max=2048"`
+	LogoUri                 string   `json:"logo_uri" form:"logo_uri" binding:"omitempty,https_url,max=2048"`
+	Scope                   string   `json:"scope" form:"scope" binding:"max=2000"`
+	GrantTypes              []string `json:"grant_types" form:"grant_types" binding:"max=10,dive,oneof=client_credentials urn:ietf:params:oauth:grant-type:device_code urn:ietf:params:oauth:grant-type:token-exchange"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method" form:"token_endpoint_auth_method" binding:"omitempty,oneof=client_secret_basic client_secret_post"`
+}
+
+// DeviceAuthorizationRequest starts the login of a device (RFC 8628
+// section 3.1).
+type DeviceAuthorizationRequest struct {
+	ClientId string `json:"client_id" form:"client_id" binding:"required,max=100"`
+}
+
+// DeviceLoginQuery looks up a device login by the code the device shows.
+type DeviceLoginQuery struct {
+	UserCode string `form:"user_code" binding:"required,max=20"`
+}
+
+// DeviceVerificationRequest approves or denies the login of a device.
+type DeviceVerificationRequest struct {
+	UserCode string `json:"user_code" form:"user_code" binding:"required,max=20"`
+	Action   string `json:"action" form:"action" binding:"required,oneof=approve deny"`
+}
+
+// CreateWebhookRequest takes the events as an array, as repeated form
+// values or as a single comma separated value.
+type CreateWebhookRequest struct {
+	Url    string   `json:"url" form:"url" binding:"required,http_url,max=2048"`
+	Events []string `json:"events" form:"events" binding:"required,max=20"`
+}
+
+// UpdateWebhookRequest changes the fields that are present.
+type UpdateWebhookRequest struct {
+	Url    *string  `json:"url" form:"url" binding:"omitempty,http_url,max=2048"`
+	Events []string `json:"events" form:"events" binding:"omitempty,max=20"`
+	Active *bool    `json:"active" form:"active"`
+}
+
+type WebhookDeliveriesQuery struct {
+	Limit  int `form:"limit,default=50" binding:"min=1,max=200"`
+	Offset int `form:"offset" binding:"min=0"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" form:"name" binding:"required,max=100"`
+}
+
+type CreateScimTokenRequest struct {
+	Description string `json:"description" form:"description" binding:"max=200"`
+}
+
+type CreateInitialAccessTokenRequest struct {
+	Description string `json:"description" form:"description" binding:"max=200"`
+}
+
+// ImportUsersQuery goes with the file of an import, which is either a
+// multipart "file" field or the raw body.
+type ImportUsersQuery struct {
+	Format    string `form:"format" binding:"omitempty,oneof=csv json"`
+	DryRun    bool   `form:"dry_run"`
+	BatchSize int    `form:"batch_size" binding:"omitempty,min=1,max=5000"`
+}
+
+type ExportUsersQuery struct {
+	Format string `form:"format,default=csv" binding:"oneof=csv json"`
+}
@@ -0,0 +1,148 @@
+package models
+
+import "time"
+
+// Data of the API responses. Successful responses wrap it in an envelope,
+// {"status": "success", "message": ..., "data": ...}.
+
+// TokenPair is a new access and refresh token.
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenSet answers a login, sign up or refresh. TokenId is set when the
+// login was for an app and exchanges for the tokens at
+// /api/v1/key/token/{id}.
+type TokenSet struct {
+	TokenPair
+	TokenId int    `json:"token_id,omitempty"`
+	Id      int    `json:"id"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+}
+
+type AppSummary struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	CallbackUrl string `json:"callback_url"`
+}
+
+type AppBranding struct {
+	Id         int    `json:"id"`
+	LogoUrl    string `json:"logo_url"`
+	BrandColor string `json:"brand_color"`
+}
+
+type DashboardUser struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Dashboard is the data of the dashboard home page.
+type Dashboard struct {
+	Apps []App         `json:"apps"`
+	User DashboardUser `json:"user"`
+}
+
+// NewWebhook is a created webhook endpoint with its signing secret, which
+// is only ever shown on creation.
+type NewWebhook struct {
+	Id     int      `json:"id"`
+	AppId  int      `json:"app_id"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	Secret string   `json:"secret"`
+}
+
+type WebhookSecret struct {
+	Id     int    `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// AppClientInfo describes an app as an OAuth client.
+type AppClientInfo struct {
+	ClientId  string   `json:"client_id"`
+	Scopes    []string `json:"scopes"`
+	HasSecret bool     `json:"has_secret"`
+}
+
+// ClientSecret is a newly issued client secret, only ever shown here.
+type ClientSecret struct {
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// TokenResponse is the answer of the OAuth token endpoint (RFC 6749
+// section 5.1). It is not wrapped in the envelope.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	// RefreshToken is only issued with tokens acting for a user.
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// IssuedTokenType answers a token exchange (RFC 8693 section 2.2.1).
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// ClientRegistration is the answer of the client registration endpoints
+// (RFC 7591 section 3.2.1, RFC 7592 section 3). It is not wrapped in the
+// envelope. The client secret is only shown on registration.
+type ClientRegistration struct {
+	ClientId                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientIdIssuedAt        int64    `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt   int64    `json:"client_secret_expires_at"`
+	RegistrationAccessToken string   `json:"registration_access_token"`
+	RegistrationClientUri   string   `json:"registration_client_uri"`
+	ClientName              string   `json:"client_name"`
+	RedirectUris            []string `json:"redirect_uris"`
+	LogoUri                 string   `json:"logo_uri,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+}
+
+// DeviceAuthorization is the answer of the device authorization endpoint
+// (RFC 8628 section 3.2). It is not wrapped in the envelope.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceLogin is a device login waiting for the user's decision.
+type DeviceLogin struct {
+	UserCode  string     `json:"user_code"`
+	App       AppSummary `json:"app"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+type OrganizationSummary struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// NewScimToken is an issued SCIM token; the plain token is only ever shown
+// here.
+type NewScimToken struct {
+	Id          int    `json:"id"`
+	OrgId       int    `json:"org_id"`
+	Description string `json:"description"`
+	Token       string `json:"token"`
+}
+
+// NewInitialAccessToken is an issued initial access token; the plain token
+// is only ever shown here.
+type NewInitialAccessToken struct {
+	Id          int    `json:"id"`
+	OrgId       int    `json:"org_id"`
+	Description string `json:"description"`
+	Token       string `json:"token"`
+}
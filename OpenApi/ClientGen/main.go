@@ -0,0 +1,24 @@
+// ClientGen writes the operations of the Go client from the OpenAPI
+// operations. Run it with go generate ./Client after changing a route.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	openapi "go_server/OpenApi"
+)
+
+func main() {
+	out := flag.String("o", "Operations.go", "file to write")
+	flag.Parse()
+
+	source, err := openapi.GenerateClient(openapi.Operations)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,159 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const modelsPath = "go_server/Models"
+
+// method is an operation as a method of the generated client.
+type method struct {
+	Operation
+	Doc     string
+	Params  string
+	Request string
+	Returns string
+	Data    string
+}
+
+var clientTemplate = template.Must(template.New("client").Parse(`// Code generated by ClientGen from the OpenAPI operations. DO NOT EDIT.
+
+package client
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range .Methods}}
+// {{.Doc}}
+func (c *Client) {{.ID}}({{.Params}}) {{.Returns}} {
+{{- if .Stream}}
+	return c.stream(ctx, {{.Request}})
+{{- else if .Produces}}
+	return c.read(ctx, {{.Request}})
+{{- else if .Data}}
+	var data {{.Data}}
+	err := c.do(ctx, {{.Request}}, &data)
+	return data, err
+{{- else}}
+	return c.do(ctx, {{.Request}}, nil)
+{{- end}}
+}
+{{end}}`))
+
+// GenerateClient writes the methods of the Go client in Client, one per
+// operation, in the order of operations.
+func GenerateClient(operations []Operation) ([]byte, error) {
+	methods := []method{}
+	source := ""
+	for _, op := range operations {
+		m, err := clientMethod(op)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Method, op.Path, err)
+		}
+		methods = append(methods, m)
+		source += m.Params + m.Request + m.Returns
+	}
+	// only what the methods use, as an unused import does not compile
+	imports := []string{`"context"`}
+	for _, pkg := range []struct{ use, spec string }{
+		{"fmt.", `"fmt"`},
+		{"io.", `"io"`},
+		{"models.", "\n\tmodels \"go_server/Models\""},
+	} {
+		if strings.Contains(source, pkg.use) {
+			imports = append(imports, pkg.spec)
+		}
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		Imports []string
+		Methods []method
+	}{imports, methods}
+	if err := clientTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func clientMethod(op Operation) (method, error) {
+	if op.ID == "" {
+		return method{}, fmt.Errorf("no ID")
+	}
+	m := method{Operation: op}
+	summary := []rune(op.Summary)
+	if len(summary) > 0 {
+		summary[0] = unicode.ToLower(summary[0])
+	}
+	m.Doc = fmt.Sprintf("%s calls %s %s: %s.", op.ID, op.Method, op.Path, string(summary))
+
+	params := []string{"ctx context.Context"}
+	path := strconv.Quote(op.Path)
+	if names := PathParams(op.Path); len(names) > 0 {
+		format := pathParamPattern.ReplaceAllString(op.Path, "%d")
+		path = fmt.Sprintf("fmt.Sprintf(%q, %s)", format, strings.Join(names, ", "))
+		params = append(params, strings.Join(names, ", ")+" int")
+	}
+	fields := []string{"method: " + strconv.Quote(op.Method), "path: " + path}
+	if op.Query != nil {
+		name, err := goType(reflect.TypeOf(op.Query))
+		if err != nil {
+			return method{}, err
+		}
+		params = append(params, "query "+name)
+		fields = append(fields, "query: query")
+	}
+	switch {
+	case op.Upload:
+		params = append(params, "contentType string", "file io.Reader")
+		fields = append(fields, "contentType: contentType", "upload: file")
+	case op.Body != nil:
+		name, err := goType(reflect.TypeOf(op.Body))
+		if err != nil {
+			return method{}, err
+		}
+		params = append(params, "body "+name)
+		fields = append(fields, "body: body")
+	}
+	m.Params = strings.Join(params, ", ")
+	m.Request = "request{" + strings.Join(fields, ", ") + "}"
+
+	switch {
+	case op.Stream:
+		m.Returns = "(io.ReadCloser, error)"
+	case op.Produces != "":
+		m.Returns = "([]byte, error)"
+	case op.Response != nil:
+		name, err := goType(reflect.TypeOf(op.Response))
+		if err != nil {
+			return method{}, err
+		}
+		m.Data = name
+		m.Returns = "(" + name + ", error)"
+	default:
+		m.Returns = "error"
+	}
+	return m, nil
+}
+
+// goType spells t as the generated code refers to it. Only types of Models
+// and slices of them can be used.
+func goType(t reflect.Type) (string, error) {
+	if t.Kind() == reflect.Slice {
+		elem, err := goType(t.Elem())
+		return "[]" + elem, err
+	}
+	if t.PkgPath() != modelsPath {
+		return "", fmt.Errorf("%s is not a type of Models", t)
+	}
+	return "models." + t.Name(), nil
+}
@@ -0,0 +1,361 @@
+// Package openapi builds the OpenAPI document of the API and the Go client
+// generated from it. Schemas are derived from the types in Models: the
+// DTOs the handlers bind and validate, and the data they respond with.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	models "go_server/Models"
+)
+
+// Version is the OpenAPI version of the document.
+const Version = "3.1.0"
+
+// Operation is one route of the API. Body, Query and Response are zero
+// values of the types the handler binds and answers with in "data"; a nil
+// Response means the answer only carries a message. Routes that do not
+// answer with the JSON envelope name their content type in Produces.
+type Operation struct {
+	ID       string
+	Method   string
+	Path     string
+	Summary  string
+	Auth     bool
+	Body     interface{}
+	Query    interface{}
+	Response interface{}
+	// Status of a success, 200 if unset.
+	Status int
+	// OAuth errors follow RFC 6749 instead of the problem format.
+	OAuth bool
+	// Upload takes the raw request body, a file in one of the Consumes
+	// content types.
+	Upload   bool
+	Consumes []string
+	Produces string
+	// Stream returns the body to the client unread, for large downloads.
+	Stream bool
+	// Extra lists envelope members besides status, message and data.
+	Extra Schema
+}
+
+// Operations lists every route under /api/v1. The routes test fails when
+// it disagrees with routes.SetupRoutes.
+var Operations = []Operation{
+	{ID: "SignUp", Method: "POST", Path: "/api/v1/signup", Summary: "Register a new user", Body: models.SignUpRequest{}, Response: models.TokenSet{}},
+	{ID: "Login", Method: "POST", Path: "/api/v1/login", Summary: "Log in with email and password", Body: models.LoginRequest{}, Response: models.TokenSet{}},
+	{ID: "Refresh", Method: "POST", Path: "/api/v1/refresh", Summary: "Rotate a refresh token", OAuth: true, Body: models.RefreshRequest{}, Response: models.TokenSet{}},
+	{ID: "ForgetPassword", Method: "POST", Path: "/api/v1/forget-password", Summary: "Email a password reset link", Body: models.ForgetPasswordRequest{}},
+	{ID: "ResetPassword", Method: "POST", Path: "/api/v1/reset-password", Summary: "Set a new password with a reset link", Body: models.ResetPasswordRequest{}},
+	{ID: "GoogleLogin", Method: "POST", Path: "/api/v1/google-login", Summary: "Log in with a Google access token", Body: models.GoogleLoginRequest{}, Response: models.TokenSet{}},
+	{ID: "RequestMagicLink", Method: "POST", Path: "/api/v1/magic-link", Summary: "Email a sign-in link", Body: models.MagicLinkRequest{}},
+	{ID: "VerifyMagicLink", Method: "POST", Path: "/api/v1/magic-link/verify", Summary: "Sign in with an emailed link", Body: models.VerifyMagicLinkRequest{}, Response: models.TokenSet{}},
+	{ID: "Logout", Method: "POST", Path: "/api/v1/logout", Summary: "End the session with an app", Auth: true, Body: models.LogoutRequest{}},
+	{ID: "ChangePassword", Method: "POST", Path: "/api/v1/change-password", Summary: "Change the password", Auth: true, Body: models.ChangePasswordRequest{}, Response: models.TokenPair{}},
+	{ID: "DeleteAccount", Method: "DELETE", Path: "/api/v1/account", Summary: "Delete the account", Auth: true, Body: models.DeleteAccountRequest{}},
+	{ID: "GetDeviceLogin", Method: "GET", Path: "/api/v1/device", Summary: "Get the device login of a user code", Auth: true, Query: models.DeviceLoginQuery{}, Response: models.DeviceLogin{}},
+	{ID: "VerifyDevice", Method: "POST", Path: "/api/v1/device", Summary: "Approve or deny a device login", Auth: true, Body: models.DeviceVerificationRequest{}},
+	{ID: "OpenAPI", Method: "GET", Path: "/api/v1/openapi.json", Summary: "Get the OpenAPI document of the API", Produces: "application/json"},
+
+	{ID: "GetApp", Method: "GET", Path: "/api/v1/app/get/{id}", Summary: "Get the public details of an app", Response: models.App{}},
+	{ID: "Home", Method: "GET", Path: "/api/v1/app/", Summary: "Get the dashboard of the logged in user", Auth: true, Response: models.Dashboard{}},
+	{ID: "CreateApp", Method: "POST", Path: "/api/v1/app/create", Summary: "Create an app", Auth: true, Body: models.CreateAppRequest{}, Response: models.AppSummary{}},
+	{ID: "ListApps", Method: "GET", Path: "/api/v1/app/list", Summary: "List the apps of the logged in user", Auth: true, Response: []models.App{}},
+	{ID: "UpdateApp", Method: "PATCH", Path: "/api/v1/app/{id}", Summary: "Update an app", Auth: true, Body: models.UpdateAppRequest{}, Response: models.AppSummary{}},
+	{ID: "DeleteApp", Method: "DELETE", Path: "/api/v1/app/{id}", Summary: "Delete an app", Auth: true},
+	{ID: "UpdateAppBranding", Method: "PATCH", Path: "/api/v1/app/{id}/branding", Summary: "Set the email branding of an app", Auth: true, Body: models.AppBrandingRequest{}, Response: models.AppBranding{}},
+	{ID: "GetAppClient", Method: "GET", Path: "/api/v1/app/{id}/client", Summary: "Get the OAuth client of an app", Auth: true, Response: models.AppClientInfo{}},
+	{ID: "UpdateAppClient", Method: "PUT", Path: "/api/v1/app/{id}/client", Summary: "Set the scopes an app may request for itself", Auth: true, Body: models.AppClientRequest{}, Response: models.AppClientInfo{}},
+	{ID: "ListExchangePolicies", Method: "GET", Path: "/api/v1/app/{id}/exchange-policies", Summary: "List the apps that may exchange tokens for tokens of an app", Auth: true, Response: []models.ExchangePolicy{}},
+	{ID: "SetExchangePolicy", Method: "PUT", Path: "/api/v1/app/{id}/exchange-policies/{sourceId}", Summary: "Let another app exchange tokens for tokens of an app", Auth: true, Body: models.ExchangePolicyRequest{}, Response: models.ExchangePolicy{}},
+	{ID: "DeleteExchangePolicy", Method: "DELETE", Path: "/api/v1/app/{id}/exchange-policies/{sourceId}", Summary: "Stop another app from exchanging tokens for tokens of an app", Auth: true},
+	{ID: "RotateClientSecret", Method: "POST", Path: "/api/v1/app/{id}/client/secret", Summary: "Issue a new client secret for an app", Auth: true, Response: models.ClientSecret{}},
+	{ID: "CreateWebhook", Method: "POST", Path: "/api/v1/app/{id}/webhooks", Summary: "Register a webhook endpoint", Auth: true, Body: models.CreateWebhookRequest{}, Response: models.NewWebhook{}},
+	{ID: "ListWebhooks", Method: "GET", Path: "/api/v1/app/{id}/webhooks", Summary: "List the webhook endpoints of an app", Auth: true, Response: []models.WebhookEndpoint{}},
+	{ID: "UpdateWebhook", Method: "PATCH", Path: "/api/v1/app/{id}/webhooks/{webhookId}", Summary: "Update a webhook endpoint", Auth: true, Body: models.UpdateWebhookRequest{}, Response: models.WebhookEndpoint{}},
+	{ID: "DeleteWebhook", Method: "DELETE", Path: "/api/v1/app/{id}/webhooks/{webhookId}", Summary: "Delete a webhook endpoint", Auth: true},
+	{ID: "RotateWebhookSecret", Method: "POST", Path: "/api/v1/app/{id}/webhooks/{webhookId}/rotate-secret", Summary: "Replace the signing secret of a webhook endpoint", Auth: true, Response: models.WebhookSecret{}},
+	{ID: "ListWebhookDeliveries", Method: "GET", Path: "/api/v1/app/{id}/webhooks/{webhookId}/deliveries", Summary: "List webhook deliveries", Auth: true, Query: models.WebhookDeliveriesQuery{}, Response: []models.WebhookDelivery{}},
+	{ID: "SendTestWebhook", Method: "POST", Path: "/api/v1/app/{id}/webhooks/{webhookId}/test", Summary: "Queue a test event for a webhook endpoint", Auth: true, Status: http.StatusAccepted, Response: models.WebhookEvent{}},
+
+	{ID: "GetPublicKey", Method: "GET", Path: "/api/v1/key/public", Summary: "Get the PEM encoded key that signs access tokens", Produces: "text/plain"},
+	{ID: "GetToken", Method: "GET", Path: "/api/v1/key/token/{id}", Summary: "Exchange a token_id for the tokens of an app login", Response: models.Token{}},
+
+	{ID: "CreateOrganization", Method: "POST", Path: "/api/v1/org/create", Summary: "Create an organization", Auth: true, Body: models.CreateOrganizationRequest{}, Response: models.OrganizationSummary{}},
+	{ID: "ListOrganizations", Method: "GET", Path: "/api/v1/org/list", Summary: "List the organizations of the logged in user", Auth: true, Response: []models.Organization{}},
+	{ID: "CreateScimToken", Method: "POST", Path: "/api/v1/org/{id}/scim-token", Summary: "Issue a SCIM token", Auth: true, Body: models.CreateScimTokenRequest{}, Response: models.NewScimToken{}},
+	{ID: "ListScimTokens", Method: "GET", Path: "/api/v1/org/{id}/scim-token", Summary: "List the SCIM tokens of an organization", Auth: true, Response: []models.ScimToken{}},
+	{ID: "RevokeScimToken", Method: "DELETE", Path: "/api/v1/org/{id}/scim-token/{tokenId}", Summary: "Revoke a SCIM token", Auth: true},
+	{ID: "CreateInitialAccessToken", Method: "POST", Path: "/api/v1/org/{id}/initial-access-token", Summary: "Issue an initial access token for client registration", Auth: true, Body: models.CreateInitialAccessTokenRequest{}, Response: models.NewInitialAccessToken{}},
+	{ID: "ListInitialAccessTokens", Method: "GET", Path: "/api/v1/org/{id}/initial-access-token", Summary: "List the initial access tokens of an organization", Auth: true, Response: []models.InitialAccessToken{}},
+	{ID: "RevokeInitialAccessToken", Method: "DELETE", Path: "/api/v1/org/{id}/initial-access-token/{tokenId}", Summary: "Revoke an initial access token", Auth: true},
+
+	{ID: "ImportUsers", Method: "POST", Path: "/api/v1/admin/users/import", Summary: "Import users from CSV or JSON", Auth: true, Query: models.ImportUsersQuery{}, Response: models.ImportReport{},
+		Upload: true, Consumes: []string{"text/csv", "application/json"},
+		Extra: Schema{"issue_count": Schema{"type": "integer", "description": "Number of issues, of which at most 1000 are listed."}}},
+	{ID: "GetUserImport", Method: "GET", Path: "/api/v1/admin/users/import/{id}", Summary: "Get the progress of an import", Auth: true, Response: models.UserImport{}},
+	{ID: "ExportUsers", Method: "GET", Path: "/api/v1/admin/users/export", Summary: "Export all users", Auth: true, Query: models.ExportUsersQuery{}, Produces: "text/csv", Stream: true},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// PathParams returns the names of the parameters in an OpenAPI path.
+func PathParams(path string) []string {
+	names := []string{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+var (
+	documentOnce sync.Once
+	documentJSON []byte
+	documentErr  error
+)
+
+// JSON returns the encoded document of Operations, built on first use.
+func JSON() ([]byte, error) {
+	documentOnce.Do(func() {
+		doc, err := Document(Operations)
+		if err != nil {
+			documentErr = err
+			return
+		}
+		documentJSON, documentErr = json.Marshal(doc)
+	})
+	return documentJSON, documentErr
+}
+
+// Document builds the OpenAPI document for the operations.
+func Document(operations []Operation) (map[string]interface{}, error) {
+	paths := map[string]map[string]interface{}{}
+	s := newSchemas()
+	for _, op := range operations {
+		operation, err := s.operation(op)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Method, op.Path, err)
+		}
+
+		method := strings.ToLower(op.Method)
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		if _, exists := paths[op.Path][method]; exists {
+			return nil, fmt.Errorf("%s %s is listed twice", op.Method, op.Path)
+		}
+		paths[op.Path][method] = operation
+	}
+
+	schemas := s.components
+	schemas["FieldError"] = Schema{
+		"type":     "object",
+		"required": []string{"field", "code", "message"},
+		"properties": Schema{
+			"field":   Schema{"type": "string"},
+			"code":    Schema{"type": "string"},
+			"message": Schema{"type": "string"},
+			"params":  Schema{"type": "object"},
+		},
+	}
+	schemas["Problem"] = Schema{
+		"type":     "object",
+		"required": []string{"type", "title", "status", "code"},
+		"properties": Schema{
+			"type":       Schema{"type": "string", "format": "uri"},
+			"title":      Schema{"type": "string"},
+			"status":     Schema{"type": "integer"},
+			"detail":     Schema{"type": "string"},
+			"instance":   Schema{"type": "string"},
+			"code":       Schema{"type": "string"},
+			"message":    Schema{"type": "string"},
+			"request_id": Schema{"type": "string"},
+			"errors":     Schema{"type": "array", "items": Schema{"$ref": "#/components/schemas/FieldError"}},
+		},
+	}
+	schemas["OAuthError"] = Schema{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": Schema{
+			"error":             Schema{"type": "string"},
+			"error_description": Schema{"type": "string"},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": Version,
+		"info": map[string]interface{}{
+			"title":   "GoAuth SSO API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"responses": map[string]interface{}{
+				"ValidationFailed": errorResponse("The request is malformed or has invalid fields", "application/problem+json", "Problem"),
+				"Error":            errorResponse("The request failed", "application/problem+json", "Problem"),
+				"OAuthError":       errorResponse("The grant was rejected", "application/json", "OAuthError"),
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}, nil
+}
+
+func (s *schemas) operation(op Operation) (map[string]interface{}, error) {
+	operation := map[string]interface{}{"operationId": op.ID, "summary": op.Summary}
+	parameters := []interface{}{}
+	for _, name := range PathParams(op.Path) {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": Schema{"type": "integer"},
+		})
+	}
+	if op.Query != nil {
+		query, err := SchemaOf(op.Query)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, queryParameters(query)...)
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	switch {
+	case op.Upload:
+		content := map[string]interface{}{
+			"multipart/form-data": map[string]interface{}{"schema": Schema{
+				"type":       "object",
+				"required":   []string{"file"},
+				"properties": Schema{"file": Schema{"type": "string", "contentMediaType": "application/octet-stream"}},
+			}},
+		}
+		for _, contentType := range op.Consumes {
+			content[contentType] = map[string]interface{}{"schema": Schema{"type": "string"}}
+		}
+		operation["requestBody"] = map[string]interface{}{"required": true, "content": content}
+	case op.Body != nil:
+		schema, err := SchemaOf(op.Body)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := s.ref(reflect.TypeOf(op.Body))
+		if err != nil {
+			return nil, err
+		}
+		_, hasRequired := schema["required"]
+		operation["requestBody"] = map[string]interface{}{
+			"required": hasRequired,
+			"content": map[string]interface{}{
+				"application/json":                  map[string]interface{}{"schema": ref},
+				"application/x-www-form-urlencoded": map[string]interface{}{"schema": ref},
+			},
+		}
+	}
+	if op.Auth {
+		operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+
+	success, err := s.success(op)
+	if err != nil {
+		return nil, err
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	responses := map[string]interface{}{
+		fmt.Sprint(status): success,
+		"default":          map[string]interface{}{"$ref": "#/components/responses/Error"},
+	}
+	if op.Body != nil || op.Query != nil {
+		responses["400"] = map[string]interface{}{"$ref": "#/components/responses/ValidationFailed"}
+	}
+	if op.OAuth {
+		responses["default"] = map[string]interface{}{"$ref": "#/components/responses/OAuthError"}
+		delete(responses, "400")
+	}
+	operation["responses"] = responses
+	return operation, nil
+}
+
+// success describes the answer of an operation: the raw content it
+// produces, or the {status, message, data} envelope.
+func (s *schemas) success(op Operation) (map[string]interface{}, error) {
+	if op.Produces != "" {
+		return map[string]interface{}{
+			"description": "Success",
+			"content": map[string]interface{}{
+				op.Produces: map[string]interface{}{"schema": Schema{"type": "string"}},
+			},
+		}, nil
+	}
+
+	properties := Schema{
+		"status":  Schema{"const": "success"},
+		"message": Schema{"type": "string"},
+	}
+	for name, schema := range op.Extra {
+		properties[name] = schema
+	}
+	if op.Response != nil {
+		data, err := s.ref(reflect.TypeOf(op.Response))
+		if err != nil {
+			return nil, err
+		}
+		properties["data"] = data
+	}
+	return map[string]interface{}{
+		"description": "Success",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": Schema{
+				"type":       "object",
+				"required":   []string{"status"},
+				"properties": properties,
+			}},
+		},
+	}, nil
+}
+
+func errorResponse(description, contentType, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{"schema": Schema{"$ref": "#/components/schemas/" + schema}},
+		},
+	}
+}
+
+// queryParameters turns the properties of a query DTO schema into query
+// parameters, in name order.
+func queryParameters(query Schema) []interface{} {
+	properties := query["properties"].(Schema)
+	required := map[string]bool{}
+	if names, ok := query["required"].([]string); ok {
+		for _, name := range names {
+			required[name] = true
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parameters := []interface{}{}
+	for _, name := range names {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "query", "required": required[name], "schema": properties[name],
+		})
+	}
+	return parameters
+}
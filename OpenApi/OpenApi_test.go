@@ -0,0 +1,116 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	models "go_server/Models"
+)
+
+func TestSchemaOfFollowsBindingRules(t *testing.T) {
+	schema, err := SchemaOf(models.SignUpRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema["required"], []string{"email", "password"}) {
+		t.Fatalf("required = %v", schema["required"])
+	}
+	properties := schema["properties"].(Schema)
+	email := properties["email"].(Schema)
+	if email["type"] != "string" || email["format"] != "email" || email["maxLength"] != 254 {
+		t.Fatalf("email = %v", email)
+	}
+	if appId := properties["app_id"].(Schema); appId["type"] != "integer" || appId["minimum"] != 0 {
+		t.Fatalf("app_id = %v", appId)
+	}
+
+	query, err := SchemaOf(models.WebhookDeliveriesQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := query["properties"].(Schema)["limit"].(Schema)
+	if limit["default"] != 50 || limit["maximum"] != 200 {
+		t.Fatalf("limit = %v", limit)
+	}
+}
+
+func TestSchemaOfRejectsUnknownRules(t *testing.T) {
+	type request struct {
+		Code string `json:"code" binding:"required,uuid"`
+	}
+	if _, err := SchemaOf(request{}); err == nil {
+		t.Fatal("a rule the schema cannot express was accepted")
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := Document(Operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+	paths := doc["paths"].(map[string]map[string]interface{})
+	login := paths["/api/v1/login"]["post"].(map[string]interface{})
+	content := login["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+	if _, ok := content["application/json"]; !ok {
+		t.Fatalf("login does not accept JSON: %v", content)
+	}
+	schemas := doc["components"].(map[string]interface{})["schemas"].(Schema)
+	if _, ok := schemas["LoginRequest"]; !ok {
+		t.Fatal("LoginRequest schema missing")
+	}
+	update := paths["/api/v1/app/{id}/webhooks/{webhookId}"]["patch"].(map[string]interface{})
+	if params := update["parameters"].([]interface{}); len(params) != 2 {
+		t.Fatalf("parameters = %v", params)
+	}
+}
+
+func TestDocumentDescribesResponses(t *testing.T) {
+	doc, err := Document(Operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := doc["paths"].(map[string]map[string]interface{})
+	responses := paths["/api/v1/app/{id}/webhooks/{webhookId}/test"]["post"].(map[string]interface{})["responses"].(map[string]interface{})
+	accepted, ok := responses["202"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("responses = %v", responses)
+	}
+	envelope := accepted["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(Schema)
+	if data := envelope["properties"].(Schema)["data"]; !reflect.DeepEqual(data, Schema{"$ref": "#/components/schemas/WebhookEvent"}) {
+		t.Fatalf("data = %v", data)
+	}
+
+	refresh := paths["/api/v1/refresh"]["post"].(map[string]interface{})["responses"].(map[string]interface{})
+	if !reflect.DeepEqual(refresh["default"], map[string]interface{}{"$ref": "#/components/responses/OAuthError"}) {
+		t.Fatalf("refresh errors = %v", refresh["default"])
+	}
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(Schema)
+	delivery := schemas["WebhookDelivery"].(Schema)["properties"].(Schema)
+	if status := delivery["last_status_code"].(Schema); !reflect.DeepEqual(status["type"], []string{"integer", "null"}) {
+		t.Fatalf("last_status_code = %v", status)
+	}
+	if _, ok := schemas["TokenSet"].(Schema)["properties"].(Schema)["refresh_token"]; !ok {
+		t.Fatal("TokenSet does not flatten TokenPair")
+	}
+}
+
+func TestGeneratedClientIsCurrent(t *testing.T) {
+	source, err := GenerateClient(Operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("../Client/Operations.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, committed) {
+		t.Fatal("Client/Operations.go is out of date, run go generate ./Client")
+	}
+}
@@ -0,0 +1,247 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "go_server/Validation"
+)
+
+// Schema describes a value in the JSON Schema dialect of OpenAPI 3.1.
+type Schema map[string]interface{}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas collects the named structs the document refers to, so each is
+// described once under components.
+type schemas struct {
+	components Schema
+	types      map[string]reflect.Type
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: Schema{}, types: map[string]reflect.Type{}}
+}
+
+// SchemaOf derives the schema of a request DTO from its field types and
+// binding rules, so the document cannot disagree with what the handlers
+// accept. A rule it does not know is an error rather than a silent gap.
+func SchemaOf(v interface{}) (Schema, error) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", t)
+	}
+	return newSchemas().object(t)
+}
+
+// ref returns a reference to the component describing t, adding it on
+// first use. Types without a name are described inline.
+func (s *schemas) ref(t reflect.Type) (Schema, error) {
+	if t.Kind() != reflect.Struct || t.Name() == "" || t == timeType {
+		return s.schema(t)
+	}
+	if known, ok := s.types[t.Name()]; !ok {
+		// registered before describing it, so recursive types terminate
+		s.types[t.Name()] = t
+		object, err := s.object(t)
+		if err != nil {
+			return nil, err
+		}
+		s.components[t.Name()] = object
+	} else if known != t {
+		return nil, fmt.Errorf("%s and %s share a schema name", known, t)
+	}
+	return Schema{"$ref": "#/components/schemas/" + t.Name()}, nil
+}
+
+func (s *schemas) schema(t reflect.Type) (Schema, error) {
+	if t == rawMessageType || t.Kind() == reflect.Interface {
+		return Schema{}, nil
+	}
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(inner), nil
+	case reflect.String:
+		return Schema{"type": "string"}, nil
+	case reflect.Int:
+		return Schema{"type": "integer"}, nil
+	case reflect.Int64:
+		return Schema{"type": "integer", "format": "int64"}, nil
+	case reflect.Bool:
+		return Schema{"type": "boolean"}, nil
+	case reflect.Slice:
+		items, err := s.ref(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Schema{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := s.ref(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Schema{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return s.ref(t)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// nullable lets a pointer field be null. References cannot carry a type,
+// so they are wrapped.
+func nullable(inner Schema) Schema {
+	if _, isRef := inner["$ref"]; isRef {
+		return Schema{"oneOf": []interface{}{inner, Schema{"type": "null"}}}
+	}
+	if kind, ok := inner["type"].(string); ok {
+		inner["type"] = []string{kind, "null"}
+	}
+	return inner
+}
+
+func (s *schemas) object(t reflect.Type) (Schema, error) {
+	properties := Schema{}
+	required := []string{}
+	if err := s.fields(t, properties, &required); err != nil {
+		return nil, err
+	}
+	object := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object, nil
+}
+
+// fields adds the properties of t, flattening embedded structs as
+// encoding/json does.
+func (s *schemas) fields(t reflect.Type, properties Schema, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := s.fields(field.Type, properties, required); err != nil {
+				return err
+			}
+			continue
+		}
+		name := validation.FieldName(field)
+		if name == "" || !field.IsExported() {
+			continue
+		}
+		property, isRequired, err := s.field(field)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		properties[name] = property
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+func (s *schemas) field(field reflect.StructField) (Schema, bool, error) {
+	schema, err := s.schema(field.Type)
+	if err != nil {
+		return nil, false, err
+	}
+	kind := field.Type.Kind()
+	if kind == reflect.Ptr {
+		kind = field.Type.Elem().Kind()
+	}
+
+	if _, options, _ := strings.Cut(field.Tag.Get("form"), ","); strings.HasPrefix(options, "default=") {
+		schema["default"] = typedValue(kind, strings.TrimPrefix(options, "default="))
+	}
+
+	isRequired := false
+	// rules after dive apply to the items of a slice
+	target := schema
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		schema := target
+		switch name {
+		case "", "omitempty":
+		case "dive":
+			items, ok := target["items"].(Schema)
+			if kind != reflect.Slice || !ok || items["$ref"] != nil {
+				return nil, false, fmt.Errorf("rule %q needs a slice of plain values", rule)
+			}
+			target, kind = items, field.Type.Elem().Kind()
+		case "required":
+			isRequired = true
+		case "required_without":
+			schema["description"] = "Required unless " + param + " is set."
+		case "email":
+			schema["format"] = "email"
+		case "http_url":
+			schema["format"] = "uri"
+			schema["pattern"] = "^https?://"
+		case "https_url":
+			schema["format"] = "uri"
+			schema["pattern"] = "^https://"
+		case "hex_color":
+			schema["pattern"] = "^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"
+		case "scope_token":
+			schema["pattern"] = validation.ScopeTokenPattern.String()
+		case "oneof":
+			values := []interface{}{}
+			for _, value := range strings.Fields(param) {
+				values = append(values, typedValue(kind, value))
+			}
+			schema["enum"] = values
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				return nil, false, fmt.Errorf("rule %q: %w", rule, err)
+			}
+			schema[boundKeyword(kind, name)] = n
+		default:
+			return nil, false, fmt.Errorf("unsupported rule %q", rule)
+		}
+	}
+	return schema, isRequired, nil
+}
+
+// boundKeyword is the JSON Schema keyword of a min or max rule, which
+// validator applies to the length of strings and slices.
+func boundKeyword(kind reflect.Kind, rule string) string {
+	suffix := "imum"
+	switch kind {
+	case reflect.String:
+		suffix = "Length"
+	case reflect.Slice:
+		suffix = "Items"
+	}
+	return rule[:3] + suffix
+}
+
+func typedValue(kind reflect.Kind, value string) interface{} {
+	switch kind {
+	case reflect.Int, reflect.Int64:
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
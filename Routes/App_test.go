@@ -0,0 +1,343 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	config "go_server/Config"
+	logging "go_server/Logging"
+	models "go_server/Models"
+	services "go_server/Services"
+)
+
+func TestAppCRUD(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	stranger := ts.signUp("mallory@example.com", "Mallory", 0)
+
+	rec := ts.post("/api/v1/app/create", owner.Token, url.Values{"name": {"notes"}})
+	expectInvalidFields(t, rec, "callback_url")
+	expectStatus(t, ts.post("/api/v1/app/create", "", url.Values{"name": {"notes"}, "callback_url": {"https://notes.example.com"}}), http.StatusUnauthorized)
+
+	appId := ts.createApp(owner.Token, "notes")
+	path := "/api/v1/app/" + strconv.Itoa(appId)
+
+	// apps are public by id
+	rec = ts.get("/api/v1/app/get/"+strconv.Itoa(appId), "")
+	expectStatus(t, rec, http.StatusOK)
+	var got struct {
+		Data models.App `json:"data"`
+	}
+	decode(t, rec, &got)
+	if got.Data.Name != "notes" || got.Data.CallbackUrl != "https://notes.example.com/callback" {
+		t.Fatalf("app = %+v", got.Data)
+	}
+	expectMessage(t, ts.get("/api/v1/app/get/999", ""), http.StatusNotFound, "App not found")
+	expectMessage(t, ts.get("/api/v1/app/get/notes", ""), http.StatusBadRequest, "Invalid app ID")
+
+	rec = ts.get("/api/v1/app/list", owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var list struct {
+		Data []models.App `json:"data"`
+	}
+	decode(t, rec, &list)
+	if len(list.Data) != 1 || list.Data[0].ID != appId {
+		t.Fatalf("owner apps = %s", rec.Body.String())
+	}
+	rec = ts.get("/api/v1/app/list", stranger.Token)
+	decode(t, rec, &list)
+	if len(list.Data) != 0 {
+		t.Fatalf("stranger apps = %s", rec.Body.String())
+	}
+
+	t.Run("update", func(t *testing.T) {
+		expectInvalidFields(t, ts.request(http.MethodPatch, path, owner.Token, url.Values{}), "name")
+		expectInvalidFields(t, ts.request(http.MethodPatch, path, owner.Token, url.Values{"callback_url": {"notes.example.com"}}), "callback_url")
+		expectMessage(t, ts.request(http.MethodPatch, "/api/v1/app/notes", owner.Token, url.Values{"name": {"x"}}), http.StatusBadRequest, "Invalid app ID")
+		expectMessage(t, ts.request(http.MethodPatch, path, stranger.Token, url.Values{"name": {"stolen"}}), http.StatusNotFound, "App not found")
+		expectStatus(t, ts.request(http.MethodPatch, path, owner.Token, url.Values{"name": {"notebook"}}), http.StatusOK)
+
+		rec := ts.get("/api/v1/app/get/"+strconv.Itoa(appId), "")
+		decode(t, rec, &got)
+		if got.Data.Name != "notebook" || got.Data.CallbackUrl != "https://notes.example.com/callback" {
+			t.Fatalf("app after update = %+v", got.Data)
+		}
+	})
+
+	t.Run("branding", func(t *testing.T) {
+		rec := ts.request(http.MethodPatch, path+"/branding", owner.Token, url.Values{"brand_color": {"purple"}})
+		expectInvalidFields(t, rec, "brand_color")
+		rec = ts.request(http.MethodPatch, path+"/branding", owner.Token, url.Values{"logo_url": {"http://notes.example.com/logo.png"}})
+		expectInvalidFields(t, rec, "logo_url")
+		rec = ts.request(http.MethodPatch, path+"/branding", stranger.Token, url.Values{"brand_color": {"#000000"}})
+		expectMessage(t, rec, http.StatusNotFound, "App not found")
+		rec = ts.request(http.MethodPatch, path+"/branding", owner.Token, url.Values{"brand_color": {"#4f46e5"}})
+		expectStatus(t, rec, http.StatusOK)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		expectMessage(t, ts.request(http.MethodDelete, path, stranger.Token, nil), http.StatusNotFound, "App not found")
+		expectStatus(t, ts.get("/api/v1/app/get/"+strconv.Itoa(appId), ""), http.StatusOK)
+		expectMessage(t, ts.request(http.MethodDelete, path, owner.Token, nil), http.StatusOK, "App deleted successfully")
+		expectMessage(t, ts.get("/api/v1/app/get/"+strconv.Itoa(appId), ""), http.StatusNotFound, "App not found")
+		expectMessage(t, ts.request(http.MethodDelete, path, owner.Token, nil), http.StatusNotFound, "App not found")
+	})
+}
+
+func TestTokenExchange(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	user := ts.signUp("ada@example.com", "Ada", appId)
+	if user.TokenId == 0 {
+		t.Fatalf("app login without token_id: %+v", user)
+	}
+
+	rec := ts.get("/api/v1/key/token/"+strconv.Itoa(user.TokenId), "")
+	expectStatus(t, rec, http.StatusOK)
+	var exchanged struct {
+		Data models.Token `json:"data"`
+	}
+	decode(t, rec, &exchanged)
+	if exchanged.Data.Token != user.Token || exchanged.Data.RefreshToken != user.RefreshToken || exchanged.Data.AppId != appId {
+		t.Fatalf("exchanged token = %+v", exchanged.Data)
+	}
+	expectStatus(t, ts.get("/api/v1/app/list", exchanged.Data.Token), http.StatusOK)
+
+	expectMessage(t, ts.get("/api/v1/key/token/999", ""), http.StatusNotFound, "Token not found")
+	expectMessage(t, ts.get("/api/v1/key/token/abc", ""), http.StatusBadRequest, "Invalid token ID")
+
+	// apps verify access tokens with the published key
+	rec = ts.get("/api/v1/key/public", "")
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.HasPrefix(rec.Body.String(), "-----BEGIN PUBLIC KEY-----") {
+		t.Fatalf("public key = %q", rec.Body.String())
+	}
+}
+
+// runWebhookWorker delivers the due webhooks with client.
+func (ts *testServer) runWebhookWorker(client *http.Client) {
+	ts.t.Helper()
+	if _, err := services.RunWebhookWorkerOnce(context.Background(), ts.handler.Logs.For(logging.ComponentWebhooks), ts.store, client); err != nil {
+		ts.t.Fatal(err)
+	}
+}
+
+// webhookDelivery returns the only delivery of the endpoint.
+func (ts *testServer) webhookDelivery(endpointId int) models.WebhookDelivery {
+	ts.t.Helper()
+	deliveries, err := ts.store.GetWebhookDeliveries(context.Background(), endpointId, 10, 0)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		ts.t.Fatalf("deliveries = %+v", deliveries)
+	}
+	return deliveries[0]
+}
+
+func TestWebhooks(t *testing.T) {
+	// the receiver listens on plain http on the loopback interface
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.Webhooks.AllowInsecure = true })
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	stranger := ts.signUp("mallory@example.com", "Mallory", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	path := "/api/v1/app/" + strconv.Itoa(appId) + "/webhooks"
+
+	var mu sync.Mutex
+	var received []models.WebhookEvent
+	var signatures []string
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event models.WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil || r.Header.Get("X-Webhook-Signature") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, event)
+		signatures = append(signatures, r.Header.Get("X-Webhook-Signature"))
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	form := url.Values{"url": {receiver.URL}, "events": {services.EventUserSignedUp}}
+	expectMessage(t, ts.post(path, stranger.Token, form), http.StatusNotFound, "App not found")
+	expectInvalidFields(t, ts.post(path, owner.Token, url.Values{"url": {"ftp://example.com"}, "events": {services.EventUserSignedUp}}), "url")
+	expectMessage(t, ts.post(path, owner.Token, url.Values{"url": {receiver.URL}, "events": {"user.unknown"}}), http.StatusBadRequest, "At least one valid event is required")
+	rec := ts.post(path, owner.Token, form)
+	expectStatus(t, rec, http.StatusOK)
+	var created struct {
+		Data models.NewWebhook `json:"data"`
+	}
+	decode(t, rec, &created)
+	webhookPath := path + "/" + strconv.Itoa(created.Data.Id)
+
+	rec = ts.get(path, owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var list struct {
+		Data []models.WebhookEndpoint `json:"data"`
+	}
+	decode(t, rec, &list)
+	if len(list.Data) != 1 || list.Data[0].Url != receiver.URL {
+		t.Fatalf("webhooks = %s", rec.Body.String())
+	}
+	expectStatus(t, ts.get(path, stranger.Token), http.StatusNotFound)
+
+	ts.signUp("ada@example.com", "Ada", appId)
+	ts.runWebhookWorker(services.NewWebhookClient(true))
+	mu.Lock()
+	if len(received) != 1 || received[0].Type != services.EventUserSignedUp {
+		t.Fatalf("received = %+v", received)
+	}
+	mu.Unlock()
+
+	// a rotated secret signs the following deliveries, such as a test event
+	expectMessage(t, ts.post(webhookPath+"/rotate-secret", stranger.Token, nil), http.StatusNotFound, "App not found")
+	expectMessage(t, ts.post(path+"/999/rotate-secret", owner.Token, nil), http.StatusNotFound, "Webhook not found")
+	rec = ts.post(webhookPath+"/rotate-secret", owner.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	var rotated struct {
+		Data models.WebhookSecret `json:"data"`
+	}
+	decode(t, rec, &rotated)
+	if rotated.Data.Id != created.Data.Id || rotated.Data.Secret == "" || rotated.Data.Secret == created.Data.Secret {
+		t.Fatalf("rotated = %s", rec.Body.String())
+	}
+	expectMessage(t, ts.post(webhookPath+"/test", stranger.Token, nil), http.StatusNotFound, "App not found")
+	expectStatus(t, ts.post(webhookPath+"/test", owner.Token, nil), http.StatusAccepted)
+	ts.runWebhookWorker(services.NewWebhookClient(true))
+	mu.Lock()
+	if len(received) != 2 || received[1].Type != services.EventWebhookTest {
+		t.Fatalf("received = %+v", received)
+	}
+	timestamp, _, _ := strings.Cut(strings.TrimPrefix(signatures[1], "t="), ",")
+	unix, _ := strconv.ParseInt(timestamp, 10, 64)
+	if signatures[1] != services.SignWebhookPayload(rotated.Data.Secret, time.Unix(unix, 0), bodies[1]) {
+		t.Fatalf("signature %q is not made with the rotated secret", signatures[1])
+	}
+	mu.Unlock()
+
+	expectMessage(t, ts.request(http.MethodDelete, webhookPath, stranger.Token, nil), http.StatusNotFound, "App not found")
+	expectMessage(t, ts.request(http.MethodDelete, webhookPath, owner.Token, nil), http.StatusOK, "Webhook deleted successfully")
+	expectMessage(t, ts.request(http.MethodDelete, webhookPath, owner.Token, nil), http.StatusNotFound, "Webhook not found")
+	rec = ts.get(path, owner.Token)
+	decode(t, rec, &list)
+	if len(list.Data) != 0 {
+		t.Fatalf("webhooks after delete = %s", rec.Body.String())
+	}
+}
+
+func TestWebhookURLRestrictions(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	path := "/api/v1/app/" + strconv.Itoa(appId) + "/webhooks"
+
+	for _, webhookUrl := range []string{
+		"http://hooks.example.com/events",
+		"https://127.0.0.1/events",
+		"https://localhost:8443/events",
+		"https://[::1]/events",
+		"https://10.0.0.7/events",
+		"https://169.254.169.254/latest/meta-data",
+		"https://0.0.0.0/events",
+		"https://0.1.2.3/events",
+		"https://100.100.100.200/latest/meta-data",
+		"https://[64:ff9b::a9fe:a9fe]/latest/meta-data",
+		"https://[::ffff:100.64.0.1]/events",
+		"https://[fd00::1]/events",
+	} {
+		rec := ts.post(path, owner.Token, url.Values{"url": {webhookUrl}, "events": {services.EventUserSignedUp}})
+		expectMessage(t, rec, http.StatusBadRequest, "The webhook URL must use https and must not point to a private address")
+	}
+	rec := ts.post(path, owner.Token, url.Values{"url": {"https://hooks.example.com/events"}, "events": {services.EventUserSignedUp}})
+	expectStatus(t, rec, http.StatusOK)
+	var created struct {
+		Data models.NewWebhook `json:"data"`
+	}
+	decode(t, rec, &created)
+	expectMessage(t, ts.request(http.MethodPatch, path+"/"+strconv.Itoa(created.Data.Id), owner.Token, url.Values{"url": {"https://127.0.0.1/events"}}),
+		http.StatusBadRequest, "The webhook URL must use https and must not point to a private address")
+}
+
+func TestWebhookDeliveryRefusesLoopback(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+
+	var hits int
+	var mu sync.Mutex
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	// a public name resolving to the loopback interface passes the check
+	// at creation; the endpoint is stored directly to stand in for it
+	endpointId, err := ts.store.InsertWebhookEndpoint(context.Background(), appId, receiver.URL, "whsec_test", []string{services.EventUserSignedUp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.signUp("ada@example.com", "Ada", appId)
+	ts.runWebhookWorker(services.NewWebhookClient(false))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 0 {
+		t.Fatalf("receiver was hit %d times", hits)
+	}
+	delivery := ts.webhookDelivery(endpointId)
+	if delivery.Status == "succeeded" || delivery.Attempts != 1 || !strings.Contains(delivery.LastError, "private") {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+}
+
+func TestWebhookDeliveryRefusesRedirect(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.Webhooks.AllowInsecure = true })
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+
+	var hits int
+	var mu sync.Mutex
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+	}))
+	defer target.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirector.Close()
+
+	rec := ts.post("/api/v1/app/"+strconv.Itoa(appId)+"/webhooks", owner.Token, url.Values{"url": {redirector.URL}, "events": {services.EventUserSignedUp}})
+	expectStatus(t, rec, http.StatusOK)
+	var created struct {
+		Data models.NewWebhook `json:"data"`
+	}
+	decode(t, rec, &created)
+	ts.signUp("ada@example.com", "Ada", appId)
+	ts.runWebhookWorker(services.NewWebhookClient(true))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 0 {
+		t.Fatalf("redirect was followed %d times", hits)
+	}
+	delivery := ts.webhookDelivery(created.Data.Id)
+	if delivery.Status == "succeeded" || delivery.LastStatusCode == nil || *delivery.LastStatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+}
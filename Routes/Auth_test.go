@@ -0,0 +1,321 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	models "go_server/Models"
+	services "go_server/Services"
+)
+
+func TestSignUp(t *testing.T) {
+	ts := newTestServer(t)
+
+	user := ts.signUp("ada@example.com", "Ada", 0)
+	if user.Id == 0 || user.Email != "ada@example.com" || user.Name != "Ada" || user.TokenId != 0 {
+		t.Fatalf("signup = %+v", user)
+	}
+	expectStatus(t, ts.get("/api/v1/app/list", user.Token), http.StatusOK)
+
+	t.Run("missing fields", func(t *testing.T) {
+		rec := ts.post("/api/v1/signup", "", url.Values{"email": {"bob@example.com"}})
+		expectInvalidFields(t, rec, "password")
+		rec = ts.post("/api/v1/signup", "", url.Values{"email": {"bob"}, "password": {strongPassword}, "name": {strings.Repeat("x", 101)}})
+		expectInvalidFields(t, rec, "email", "name")
+	})
+	t.Run("existing email", func(t *testing.T) {
+		rec := ts.post("/api/v1/signup", "", url.Values{"email": {"ada@example.com"}, "password": {strongPassword}})
+		expectMessage(t, rec, http.StatusConflict, "User already exists")
+	})
+	t.Run("weak password", func(t *testing.T) {
+		rec := ts.post("/api/v1/signup", "", url.Values{"email": {"bob@example.com"}, "name": {"Bob"}, "password": {"bob"}})
+		expectMessage(t, rec, http.StatusBadRequest, "Password does not meet the requirements")
+		var body struct {
+			Code   string `json:"code"`
+			Errors []struct {
+				Code    string                 `json:"code"`
+				Message string                 `json:"message"`
+				Params  map[string]interface{} `json:"params"`
+			} `json:"errors"`
+		}
+		decode(t, rec, &body)
+		codes := []string{}
+		for _, violation := range body.Errors {
+			codes = append(codes, violation.Code)
+		}
+		if body.Code != "weak_password" || strings.Join(codes, ",") != "too_short,contains_email,contains_name" ||
+			body.Errors[0].Message != "Password must be at least 8 characters long" || body.Errors[0].Params["min"] != float64(8) {
+			t.Fatalf("weak password = %s", rec.Body.String())
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		ts.handler.Config.Features.SignUp = false
+		defer func() { ts.handler.Config.Features.SignUp = true }()
+		rec := ts.post("/api/v1/signup", "", url.Values{"email": {"bob@example.com"}, "password": {strongPassword}})
+		expectMessage(t, rec, http.StatusNotFound, "Sign up is disabled")
+	})
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signUp("ada@example.com", "Ada", 0)
+
+	user := ts.login("ada@example.com", strongPassword, 0)
+	rec := ts.get("/api/v1/app/", user.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var home struct {
+		Data struct {
+			User struct {
+				Id    int    `json:"id"`
+				Email string `json:"email"`
+			} `json:"user"`
+		} `json:"data"`
+	}
+	decode(t, rec, &home)
+	if home.Data.User.Id != user.Id || home.Data.User.Email != "ada@example.com" {
+		t.Fatalf("home = %s", rec.Body.String())
+	}
+
+	cases := []struct {
+		name    string
+		form    url.Values
+		status  int
+		message string
+	}{
+		{"unknown email", url.Values{"email": {"bob@example.com"}, "password": {strongPassword}}, http.StatusUnauthorized, "Invalid email or password"},
+		{"wrong password", url.Values{"email": {"ada@example.com"}, "password": {"Wrong-Horse-9-Battery"}}, http.StatusUnauthorized, "Invalid email or password"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expectMessage(t, ts.post("/api/v1/login", "", tc.form), tc.status, tc.message)
+		})
+	}
+}
+
+// TestLoginUpgradesPasswordHash checks that a login with a hash made by an
+// outdated algorithm, as after an import, stores a hash of the configured
+// one.
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signUp("ada@example.com", "Ada", 0)
+	ctx := context.Background()
+	user, err := ts.store.GetUserByEmail(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := services.PasswordHasher{Algorithm: services.AlgorithmBcrypt, BcryptCost: 4}.Hash(strongPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.store.UpdatePasswordHash(ctx, user.ID, user.Password, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed login leaves the hash alone
+	expectMessage(t, ts.post("/api/v1/login", "", url.Values{"email": {"ada@example.com"}, "password": {"Wrong-Horse-9-Battery"}}), http.StatusUnauthorized, "Invalid email or password")
+	if user, _ = ts.store.GetUserByEmail(ctx, "ada@example.com"); user.Password != legacy {
+		t.Fatalf("hash after a failed login = %s", user.Password)
+	}
+
+	ts.login("ada@example.com", strongPassword, 0)
+	user, _ = ts.store.GetUserByEmail(ctx, "ada@example.com")
+	if !strings.HasPrefix(user.Password, "$argon2id$") || ts.handler.Passwords.Hasher.NeedsRehash(user.Password) {
+		t.Fatalf("hash after the login = %s", user.Password)
+	}
+	ts.login("ada@example.com", strongPassword, 0)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signUp("ada@example.com", "Ada", 0)
+
+	expectMessage(t, ts.get("/api/v1/app/list", ""), http.StatusUnauthorized, "Authorization header is required")
+	expectMessage(t, ts.get("/api/v1/app/list", "not-a-jwt"), http.StatusUnauthorized, "Invalid token")
+
+	// a header without the scheme is rejected instead of crashing the handler
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/app/list", nil)
+	req.Header.Set("Authorization", user.Token)
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	expectMessage(t, rec, http.StatusUnauthorized, "Invalid token")
+
+	// a refresh token is signed with the same key but is no access token
+	expectMessage(t, ts.get("/api/v1/app/list", user.RefreshToken), http.StatusUnauthorized, "Invalid token")
+	expectMessage(t, ts.post("/api/v1/app/create", user.RefreshToken, url.Values{"name": {"app"}, "callback_url": {"https://app.example.com"}}), http.StatusUnauthorized, "Invalid token")
+	expectStatus(t, ts.get("/api/v1/app/list", user.Token), http.StatusOK)
+}
+
+func TestRefreshRotation(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	otherAppId := ts.createApp(owner.Token, "tasks")
+	user := ts.signUp("ada@example.com", "Ada", appId)
+	app := strconv.Itoa(appId)
+
+	rec := ts.post("/api/v1/refresh", "", url.Values{"token": {user.RefreshToken}, "id": {app}})
+	expectStatus(t, rec, http.StatusOK)
+	rotated := decodeSession(t, rec)
+	if rotated.Id != user.Id || rotated.Email != "ada@example.com" {
+		t.Fatalf("refresh = %+v", rotated)
+	}
+	if rotated.RefreshToken == user.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	expectStatus(t, ts.get("/api/v1/app/list", rotated.Token), http.StatusOK)
+
+	// the replaced refresh token cannot be used again
+	rec = ts.post("/api/v1/refresh", "", url.Values{"token": {user.RefreshToken}, "id": {app}})
+	expectOAuthError(t, rec, http.StatusBadRequest, "invalid_grant")
+
+	rec = ts.post("/api/v1/refresh", "", url.Values{"token": {rotated.RefreshToken}, "id": {app}})
+	expectStatus(t, rec, http.StatusOK)
+	rotated = decodeSession(t, rec)
+
+	cases := []struct {
+		name string
+		form url.Values
+		code string
+	}{
+		{"invalid app id", url.Values{"token": {rotated.RefreshToken}, "id": {"notes"}}, "invalid_request"},
+		{"missing token", url.Values{"id": {app}}, "invalid_request"},
+		{"malformed token", url.Values{"token": {"not-a-jwt"}, "id": {app}}, "invalid_grant"},
+		{"app without a session", url.Values{"token": {rotated.RefreshToken}, "id": {strconv.Itoa(otherAppId)}}, "invalid_grant"},
+		{"access token", url.Values{"token": {rotated.Token}, "id": {app}}, "invalid_grant"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expectOAuthError(t, ts.post("/api/v1/refresh", "", tc.form), http.StatusBadRequest, tc.code)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	user := ts.signUp("ada@example.com", "Ada", appId)
+	app := strconv.Itoa(appId)
+
+	expectMessage(t, ts.post("/api/v1/logout", "", url.Values{"app_id": {app}}), http.StatusUnauthorized, "Authorization header is required")
+	expectMessage(t, ts.post("/api/v1/logout", user.Token, url.Values{"app_id": {"notes"}}), http.StatusBadRequest, "The request body is malformed")
+	expectInvalidFields(t, ts.post("/api/v1/logout", user.Token, nil), "app_id")
+
+	expectMessage(t, ts.post("/api/v1/logout", user.Token, url.Values{"app_id": {app}}), http.StatusOK, "Logout successful")
+	// the session is gone, so its refresh token is dead
+	rec := ts.post("/api/v1/refresh", "", url.Values{"token": {user.RefreshToken}, "id": {app}})
+	expectOAuthError(t, rec, http.StatusBadRequest, "invalid_grant")
+}
+
+func TestChangePassword(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	ts.signUp("ada@example.com", "Ada", 0)
+	other := ts.login("ada@example.com", strongPassword, appId)
+	user := ts.login("ada@example.com", strongPassword, 0)
+
+	rec := ts.post("/api/v1/change-password", user.Token, url.Values{"old_password": {"Wrong-Horse-9-Battery"}, "new_password": {"Staple-Horse-7-Battery"}})
+	expectMessage(t, rec, http.StatusForbidden, "Invalid password")
+	rec = ts.post("/api/v1/change-password", user.Token, url.Values{"old_password": {strongPassword}, "new_password": {"short"}})
+	expectMessage(t, rec, http.StatusBadRequest, "Password does not meet the requirements")
+
+	rec = ts.post("/api/v1/change-password", user.Token, url.Values{"old_password": {strongPassword}, "new_password": {"Staple-Horse-7-Battery"}})
+	expectStatus(t, rec, http.StatusOK)
+	fresh := decodeSession(t, rec)
+	expectStatus(t, ts.get("/api/v1/app/list", fresh.Token), http.StatusOK)
+
+	// every other session is revoked
+	rec = ts.post("/api/v1/refresh", "", url.Values{"token": {other.RefreshToken}, "id": {strconv.Itoa(appId)}})
+	expectOAuthError(t, rec, http.StatusBadRequest, "invalid_grant")
+
+	expectMessage(t, ts.post("/api/v1/login", "", url.Values{"email": {"ada@example.com"}, "password": {strongPassword}}), http.StatusUnauthorized, "Invalid email or password")
+	ts.login("ada@example.com", "Staple-Horse-7-Battery", 0)
+
+	emails := ts.emails()
+	if len(emails) != 1 || emails[0].To != "ada@example.com" {
+		t.Fatalf("emails = %+v", emails)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	user := ts.signUp("ada@example.com", "Ada", 0)
+	confirm := url.Values{"password": {strongPassword}}
+
+	rec := ts.request(http.MethodDelete, "/api/v1/account", user.Token, url.Values{"password": {"Wrong-Horse-9-Battery"}})
+	expectMessage(t, rec, http.StatusForbidden, "Invalid password")
+
+	// the token an app holds cannot delete the account, nor can an old login
+	appToken := ts.login("ada@example.com", strongPassword, appId).Token
+	expectMessage(t, ts.request(http.MethodDelete, "/api/v1/account", appToken, confirm), http.StatusForbidden, "Tokens issued to an app cannot delete the account")
+	stale := ts.dashboardToken(user, time.Now().Add(-time.Hour))
+	expectMessage(t, ts.request(http.MethodDelete, "/api/v1/account", stale, confirm), http.StatusUnauthorized, "Please log in again to delete your account")
+
+	rec = ts.request(http.MethodDelete, "/api/v1/account", user.Token, confirm)
+	expectMessage(t, rec, http.StatusOK, "Account deleted successfully")
+
+	// tokens of a deleted user are no longer accepted
+	expectMessage(t, ts.get("/api/v1/app/list", user.Token), http.StatusUnauthorized, "Invalid token")
+	expectMessage(t, ts.post("/api/v1/login", "", url.Values{"email": {"ada@example.com"}, "password": {strongPassword}}), http.StatusUnauthorized, "Invalid email or password")
+
+	t.Run("google account", func(t *testing.T) {
+		ts.google["google-bob"] = models.GoogleUser{Email: "bob@example.com", Name: "Bob"}
+		rec := ts.post("/api/v1/google-login", "", url.Values{"google_token": {"google-bob"}})
+		expectStatus(t, rec, http.StatusOK)
+		bob := decodeSession(t, rec)
+
+		// without a password to confirm with, a recent login is what
+		// guards the deletion
+		stale := ts.dashboardToken(bob, time.Now().Add(-time.Hour))
+		expectMessage(t, ts.request(http.MethodDelete, "/api/v1/account", stale, nil), http.StatusUnauthorized, "Please log in again to delete your account")
+		expectMessage(t, ts.request(http.MethodDelete, "/api/v1/account", bob.Token, nil), http.StatusOK, "Account deleted successfully")
+		expectMessage(t, ts.get("/api/v1/app/list", bob.Token), http.StatusUnauthorized, "Invalid token")
+	})
+}
+
+func TestGoogleLogin(t *testing.T) {
+	ts := newTestServer(t)
+	ts.google["google-ada"] = models.GoogleUser{Email: "ada@example.com", Name: "Ada"}
+	ts.google["google-bob"] = models.GoogleUser{Email: "bob@example.com", Name: "Bob"}
+	bob := ts.signUp("bob@example.com", "Bob", 0)
+
+	expectInvalidFields(t, ts.post("/api/v1/google-login", "", nil), "google_token")
+	expectMessage(t, ts.post("/api/v1/google-login", "", url.Values{"google_token": {"forged"}}), http.StatusUnauthorized, "Invalid google token")
+
+	// an unknown Google account signs up
+	rec := ts.post("/api/v1/google-login", "", url.Values{"google_token": {"google-ada"}})
+	expectStatus(t, rec, http.StatusOK)
+	ada := decodeSession(t, rec)
+	if ada.Id == 0 || ada.Email != "ada@example.com" {
+		t.Fatalf("google signup = %+v", ada)
+	}
+	rec = ts.post("/api/v1/google-login", "", url.Values{"google_token": {"google-ada"}})
+	expectStatus(t, rec, http.StatusOK)
+	if again := decodeSession(t, rec); again.Id != ada.Id {
+		t.Fatalf("second google login created user %d, want %d", again.Id, ada.Id)
+	}
+
+	// Google accounts have no password to log in with
+	rec = ts.post("/api/v1/login", "", url.Values{"email": {"ada@example.com"}, "password": {"GOOGLE"}})
+	expectMessage(t, rec, http.StatusUnauthorized, "This account signs in with Google")
+
+	// a Google login with the email of a password account signs into it
+	rec = ts.post("/api/v1/google-login", "", url.Values{"google_token": {"google-bob"}})
+	expectStatus(t, rec, http.StatusOK)
+	if linked := decodeSession(t, rec); linked.Id != bob.Id {
+		t.Fatalf("google login for bob = %d, want %d", linked.Id, bob.Id)
+	}
+
+	ts.handler.Config.Features.GoogleLogin = false
+	rec = ts.post("/api/v1/google-login", "", url.Values{"google_token": {"google-ada"}})
+	expectMessage(t, rec, http.StatusNotFound, "Google login is disabled")
+}
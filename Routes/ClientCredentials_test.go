@@ -0,0 +1,221 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	config "go_server/Config"
+	sdk "go_server/Sdk"
+)
+
+// tokenRequest posts form to the token endpoint, authenticating with HTTP
+// Basic when clientId is set.
+func (ts *testServer) tokenRequest(clientId, secret string, form url.Values) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientId != "" {
+		req.SetBasicAuth(url.QueryEscape(clientId), url.QueryEscape(secret))
+	}
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.ClientTokenTTL = config.Duration{Duration: 10 * time.Minute}
+		cfg.Auth.ClientScopes = []string{"notes:read", "notes:write"}
+	})
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "jobs")
+	clientId := strconv.Itoa(appId)
+	clientPath := "/api/v1/app/" + clientId + "/client"
+	grant := url.Values{"grant_type": {"client_credentials"}}
+
+	// an app has no secret until the owner issues one
+	rec := ts.get(clientPath, owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var info struct {
+		Data struct {
+			ClientId  string   `json:"client_id"`
+			Scopes    []string `json:"scopes"`
+			HasSecret bool     `json:"has_secret"`
+		} `json:"data"`
+	}
+	decode(t, rec, &info)
+	if info.Data.ClientId != clientId || info.Data.HasSecret || len(info.Data.Scopes) != 0 {
+		t.Fatalf("client = %s", rec.Body.String())
+	}
+	expectOAuthError(t, ts.tokenRequest(clientId, "guess", grant), http.StatusUnauthorized, "invalid_client")
+
+	expectInvalidFields(t, ts.request(http.MethodPut, clientPath, owner.Token, url.Values{"scopes": {"notes read"}}), "scopes[0]")
+	// owners pick among the scopes the server allows
+	rec = ts.request(http.MethodPut, clientPath, owner.Token, url.Values{"scopes": {"notes:read", "billing:admin"}})
+	expectMessage(t, rec, http.StatusBadRequest, "The scope billing:admin is not allowed on this server")
+	var unapproved struct {
+		AllowedScopes []string `json:"allowed_scopes"`
+	}
+	decode(t, rec, &unapproved)
+	if strings.Join(unapproved.AllowedScopes, " ") != "notes:read notes:write" {
+		t.Fatalf("unapproved scope = %s", rec.Body.String())
+	}
+	rec = ts.get(clientPath, owner.Token)
+	decode(t, rec, &info)
+	if len(info.Data.Scopes) != 0 {
+		t.Fatalf("scopes after a refused update = %v", info.Data.Scopes)
+	}
+	rec = ts.request(http.MethodPut, clientPath, owner.Token, url.Values{"scopes": {"notes:read", "notes:write", "notes:read"}})
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &info)
+	if strings.Join(info.Data.Scopes, " ") != "notes:read notes:write" {
+		t.Fatalf("scopes = %v", info.Data.Scopes)
+	}
+
+	rec = ts.post(clientPath+"/secret", owner.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	var secret struct {
+		Data struct {
+			ClientId     string `json:"client_id"`
+			ClientSecret string `json:"client_secret"`
+		} `json:"data"`
+	}
+	decode(t, rec, &secret)
+	if secret.Data.ClientId != clientId || secret.Data.ClientSecret == "" {
+		t.Fatalf("secret = %s", rec.Body.String())
+	}
+
+	// other users cannot manage the client
+	other := ts.signUp("mallory@example.com", "Mallory", 0)
+	expectStatus(t, ts.post(clientPath+"/secret", other.Token, nil), http.StatusNotFound)
+
+	// Basic authentication, asking for one of the scopes
+	rec = ts.tokenRequest(clientId, secret.Data.ClientSecret, url.Values{"grant_type": {"client_credentials"}, "scope": {"notes:read"}})
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+	}
+	var token map[string]interface{}
+	decode(t, rec, &token)
+	if token["token_type"] != "Bearer" || token["expires_in"] != float64(600) || token["scope"] != "notes:read" {
+		t.Fatalf("token response = %s", rec.Body.String())
+	}
+	if _, ok := token["refresh_token"]; ok {
+		t.Fatal("a refresh token was issued for the client credentials grant")
+	}
+	accessToken := token["access_token"].(string)
+
+	// credentials in the body, getting every allowed scope
+	rec = ts.post("/oauth/token", "", url.Values{"grant_type": {"client_credentials"}, "client_id": {clientId}, "client_secret": {secret.Data.ClientSecret}})
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &token)
+	if token["scope"] != "notes:read notes:write" {
+		t.Fatalf("token response = %s", rec.Body.String())
+	}
+
+	// the token verifies with the published keys
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+	claims, err := sdk.NewVerifier(sdk.Options{BaseURL: server.URL, Audience: clientId}).Verify(context.Background(), accessToken, "notes:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ClientId != clientId || claims.Subject != clientId || claims.AppId != appId || claims.UserId != 0 ||
+		len(claims.Audience) != 1 || claims.Audience[0] != clientId {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// but it does not act for a user
+	expectStatus(t, ts.get("/api/v1/app/", accessToken), http.StatusUnauthorized)
+
+	expectOAuthError(t, ts.tokenRequest(clientId, "wrong", grant), http.StatusUnauthorized, "invalid_client")
+	expectOAuthError(t, ts.tokenRequest("999", secret.Data.ClientSecret, grant), http.StatusUnauthorized, "invalid_client")
+	expectOAuthError(t, ts.tokenRequest(clientId, secret.Data.ClientSecret, url.Values{"grant_type": {"client_credentials"}, "scope": {"billing"}}), http.StatusBadRequest, "invalid_scope")
+	expectOAuthError(t, ts.tokenRequest(clientId, secret.Data.ClientSecret, url.Values{"grant_type": {"password"}}), http.StatusBadRequest, "unsupported_grant_type")
+	expectOAuthError(t, ts.tokenRequest(clientId, secret.Data.ClientSecret, url.Values{"grant_type": {"client_credentials"}, "client_id": {clientId}}), http.StatusBadRequest, "invalid_request")
+
+	// a scope the server stops allowing is no longer granted
+	ts.handler.Config.Auth.ClientScopes = []string{"notes:read"}
+	rec = ts.tokenRequest(clientId, secret.Data.ClientSecret, grant)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &token)
+	if token["scope"] != "notes:read" {
+		t.Fatalf("token response = %s", rec.Body.String())
+	}
+	expectOAuthError(t, ts.tokenRequest(clientId, secret.Data.ClientSecret, url.Values{"grant_type": {"client_credentials"}, "scope": {"notes:write"}}), http.StatusBadRequest, "invalid_scope")
+
+	// rotating the secret retires the previous one
+	expectStatus(t, ts.post(clientPath+"/secret", owner.Token, nil), http.StatusOK)
+	expectOAuthError(t, ts.tokenRequest(clientId, secret.Data.ClientSecret, grant), http.StatusUnauthorized, "invalid_client")
+}
+
+// TestClientCredentialsForAnotherApp has the jobs of one app call another
+// app, which accepts the token once it allows the caller with an exchange
+// policy.
+func TestClientCredentialsForAnotherApp(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.ClientScopes = []string{"jobs:run"}
+	})
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	jobs := ts.createApp(owner.Token, "jobs")
+	notes := ts.createApp(owner.Token, "notes")
+	jobsId, notesId := strconv.Itoa(jobs), strconv.Itoa(notes)
+	secret := ts.clientSecret(owner.Token, jobs)
+	grant := func(audience, scope string) url.Values {
+		form := url.Values{"grant_type": {"client_credentials"}, "audience": {audience}}
+		if scope != "" {
+			form.Set("scope", scope)
+		}
+		return form
+	}
+
+	// without a policy of the audience the grant is refused
+	expectOAuthError(t, ts.tokenRequest(jobsId, secret, grant(notesId, "")), http.StatusBadRequest, "invalid_target")
+	expectOAuthError(t, ts.tokenRequest(jobsId, secret, grant("notes", "")), http.StatusBadRequest, "invalid_target")
+
+	expectStatus(t, ts.setExchangePolicy(owner.Token, notes, jobs, "notes:read", "notes:write"), http.StatusOK)
+	// the scopes are those of the policy, not of the client
+	expectOAuthError(t, ts.tokenRequest(jobsId, secret, grant(notesId, "jobs:run")), http.StatusBadRequest, "invalid_scope")
+	rec := ts.tokenRequest(jobsId, secret, grant(notesId, "notes:read"))
+	expectStatus(t, rec, http.StatusOK)
+	var token map[string]interface{}
+	decode(t, rec, &token)
+	if token["scope"] != "notes:read" {
+		t.Fatalf("token response = %s", rec.Body.String())
+	}
+	accessToken := token["access_token"].(string)
+
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+	claims, err := sdk.NewVerifier(sdk.Options{BaseURL: server.URL, Audience: notesId}).Verify(context.Background(), accessToken, "notes:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ClientId != jobsId || claims.Subject != jobsId || claims.UserId != 0 ||
+		len(claims.Audience) != 1 || claims.Audience[0] != notesId {
+		t.Fatalf("claims = %+v", claims)
+	}
+	// the caller's own verifier does not take it
+	if _, err := sdk.NewVerifier(sdk.Options{BaseURL: server.URL, Audience: jobsId}).Verify(context.Background(), accessToken); !errors.Is(err, sdk.ErrInvalidToken) {
+		t.Fatalf("Verify for the caller = %v", err)
+	}
+
+	// without a scope every scope of the policy is granted
+	rec = ts.tokenRequest(jobsId, secret, grant(notesId, ""))
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &token)
+	if token["scope"] != "notes:read notes:write" {
+		t.Fatalf("token response = %s", rec.Body.String())
+	}
+
+	// removing the policy stops the grant
+	expectStatus(t, ts.request(http.MethodDelete, "/api/v1/app/"+notesId+"/exchange-policies/"+jobsId, owner.Token, nil), http.StatusOK)
+	expectOAuthError(t, ts.tokenRequest(jobsId, secret, grant(notesId, "")), http.StatusBadRequest, "invalid_target")
+}
@@ -0,0 +1,219 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	config "go_server/Config"
+	models "go_server/Models"
+)
+
+type clientRegistration struct {
+	ClientId                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret"`
+	ClientIdIssuedAt        int64    `json:"client_id_issued_at"`
+	RegistrationAccessToken string   `json:"registration_access_token"`
+	RegistrationClientUri   string   `json:"registration_client_uri"`
+	ClientName              string   `json:"client_name"`
+	RedirectUris            []string `json:"redirect_uris"`
+	LogoUri                 string   `json:"logo_uri"`
+	Scope                   string   `json:"scope"`
+	GrantTypes              []string `json:"grant_types"`
+}
+
+// registrationRequest sends client metadata as JSON, as RFC 7591 clients
+// do.
+func (ts *testServer) registrationRequest(method, path, token string, metadata map[string]interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	body := []byte{}
+	if metadata != nil {
+		var err error
+		if body, err = json.Marshal(metadata); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDynamicClientRegistration(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.Auth.ClientScopes = []string{"reports:read"} })
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	stranger := ts.signUp("mallory@example.com", "Mallory", 0)
+	rec := ts.post("/api/v1/org/create", owner.Token, url.Values{"name": {"Acme"}})
+	expectStatus(t, rec, http.StatusOK)
+	var org struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	decode(t, rec, &org)
+	tokensPath := "/api/v1/org/" + strconv.Itoa(org.Data.Id) + "/initial-access-token"
+
+	// only the owner of the organization issues initial access tokens
+	expectMessage(t, ts.post(tokensPath, stranger.Token, nil), http.StatusNotFound, "Organization not found")
+	rec = ts.post(tokensPath, owner.Token, url.Values{"description": {"preview environments"}})
+	expectStatus(t, rec, http.StatusOK)
+	var initial struct {
+		Data struct {
+			Id    int    `json:"id"`
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	decode(t, rec, &initial)
+	expectMessage(t, ts.get(tokensPath, stranger.Token), http.StatusNotFound, "Organization not found")
+	rec = ts.get(tokensPath, owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var tokens struct {
+		Data []models.InitialAccessToken `json:"data"`
+	}
+	decode(t, rec, &tokens)
+	if len(tokens.Data) != 1 || tokens.Data[0].ID != initial.Data.Id || tokens.Data[0].Description != "preview environments" ||
+		strings.Contains(rec.Body.String(), initial.Data.Token) {
+		t.Fatalf("tokens = %s", rec.Body.String())
+	}
+
+	metadata := map[string]interface{}{
+		"client_name":   "preview-42",
+		"redirect_uris": []string{"https://pr-42.example.com/callback"},
+		"scope":         "reports:read reports:read",
+		"grant_types":   []string{"client_credentials"},
+	}
+	expectOAuthError(t, ts.registrationRequest(http.MethodPost, "/oauth/register", "", metadata), http.StatusUnauthorized, "invalid_token")
+	expectOAuthError(t, ts.registrationRequest(http.MethodPost, "/oauth/register", "forged", metadata), http.StatusUnauthorized, "invalid_token")
+
+	rec = ts.registrationRequest(http.MethodPost, "/oauth/register", initial.Data.Token, metadata)
+	expectStatus(t, rec, http.StatusCreated)
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+	}
+	var registered clientRegistration
+	decode(t, rec, &registered)
+	if registered.ClientId == "" || registered.ClientSecret == "" || registered.RegistrationAccessToken == "" || registered.ClientIdIssuedAt == 0 ||
+		registered.RegistrationClientUri != "https://auth.example.com/oauth/register/"+registered.ClientId ||
+		registered.ClientName != "preview-42" || registered.Scope != "reports:read" || len(registered.GrantTypes) == 0 {
+		t.Fatalf("registration = %s", rec.Body.String())
+	}
+	configPath := "/oauth/register/" + registered.ClientId
+
+	// the client is an app of the organization's owner and gets tokens
+	// for itself right away
+	rec = ts.get("/api/v1/app/list", owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var apps struct {
+		Data []struct {
+			Id   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	decode(t, rec, &apps)
+	if len(apps.Data) != 1 || strconv.Itoa(apps.Data[0].Id) != registered.ClientId {
+		t.Fatalf("apps of the owner = %s", rec.Body.String())
+	}
+	rec = ts.tokenRequest(registered.ClientId, registered.ClientSecret, url.Values{"grant_type": {"client_credentials"}})
+	expectStatus(t, rec, http.StatusOK)
+
+	// the registration access token reads, updates and deletes the client
+	expectOAuthError(t, ts.registrationRequest(http.MethodGet, configPath, initial.Data.Token, nil), http.StatusUnauthorized, "invalid_token")
+	expectOAuthError(t, ts.registrationRequest(http.MethodGet, "/oauth/register/999", registered.RegistrationAccessToken, nil), http.StatusUnauthorized, "invalid_token")
+	rec = ts.registrationRequest(http.MethodGet, configPath, registered.RegistrationAccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	var read clientRegistration
+	decode(t, rec, &read)
+	if read.ClientId != registered.ClientId || read.ClientSecret != "" || read.RegistrationAccessToken != registered.RegistrationAccessToken ||
+		read.ClientName != "preview-42" || len(read.RedirectUris) != 1 || read.RedirectUris[0] != "https://pr-42.example.com/callback" {
+		t.Fatalf("read = %s", rec.Body.String())
+	}
+
+	update := map[string]interface{}{
+		"client_id":     registered.ClientId,
+		"client_name":   "preview-43",
+		"redirect_uris": []string{"https://pr-43.example.com/callback"},
+		"logo_uri":      "https://pr-43.example.com/logo.png",
+	}
+	update["client_id"] = "999"
+	expectOAuthError(t, ts.registrationRequest(http.MethodPut, configPath, registered.RegistrationAccessToken, update), http.StatusBadRequest, "invalid_request")
+	update["client_id"] = registered.ClientId
+	rec = ts.registrationRequest(http.MethodPut, configPath, registered.RegistrationAccessToken, update)
+	expectStatus(t, rec, http.StatusOK)
+	var updated clientRegistration
+	decode(t, rec, &updated)
+	if updated.ClientName != "preview-43" || updated.LogoUri != "https://pr-43.example.com/logo.png" || updated.Scope != "" {
+		t.Fatalf("updated = %s", rec.Body.String())
+	}
+	rec = ts.get("/api/v1/app/get/"+registered.ClientId, "")
+	expectStatus(t, rec, http.StatusOK)
+	var app struct {
+		Data struct {
+			Name        string `json:"name"`
+			CallbackUrl string `json:"callback_url"`
+		} `json:"data"`
+	}
+	decode(t, rec, &app)
+	if app.Data.Name != "preview-43" || app.Data.CallbackUrl != "https://pr-43.example.com/callback" {
+		t.Fatalf("updated app = %s", rec.Body.String())
+	}
+	// the scopes were cleared with the update
+	expectOAuthError(t, ts.tokenRequest(registered.ClientId, registered.ClientSecret, url.Values{"grant_type": {"client_credentials"}, "scope": {"reports:read"}}), http.StatusBadRequest, "invalid_scope")
+
+	rec = ts.registrationRequest(http.MethodDelete, configPath, registered.RegistrationAccessToken, nil)
+	expectStatus(t, rec, http.StatusNoContent)
+	expectOAuthError(t, ts.registrationRequest(http.MethodGet, configPath, registered.RegistrationAccessToken, nil), http.StatusUnauthorized, "invalid_token")
+	expectStatus(t, ts.get("/api/v1/app/get/"+registered.ClientId, ""), http.StatusNotFound)
+
+	// a revoked initial access token registers nothing more
+	revoke := tokensPath + "/" + strconv.Itoa(initial.Data.Id)
+	expectMessage(t, ts.request(http.MethodDelete, revoke, owner.Token, nil), http.StatusOK, "Token revoked successfully")
+	expectOAuthError(t, ts.registrationRequest(http.MethodPost, "/oauth/register", initial.Data.Token, metadata), http.StatusUnauthorized, "invalid_token")
+}
+
+func TestDynamicClientRegistrationRejectsMetadata(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.Auth.ClientScopes = []string{"reports:read"} })
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	rec := ts.post("/api/v1/org/create", owner.Token, url.Values{"name": {"Acme"}})
+	var org struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	decode(t, rec, &org)
+	rec = ts.post("/api/v1/org/"+strconv.Itoa(org.Data.Id)+"/initial-access-token", owner.Token, nil)
+	var initial struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	decode(t, rec, &initial)
+
+	cases := map[string]struct {
+		metadata map[string]interface{}
+		code     string
+	}{
+		"no redirect uri":   {map[string]interface{}{"client_name": "cli"}, "invalid_redirect_uri"},
+		"two redirect uris": {map[string]interface{}{"client_name": "cli", "redirect_uris": []string{"https://a.example.com", "https://b.example.com"}}, "invalid_redirect_uri"},
+		"bad redirect uri":  {map[string]interface{}{"client_name": "cli", "redirect_uris": []string{"not a url"}}, "invalid_redirect_uri"},
+		"no name":           {map[string]interface{}{"redirect_uris": []string{"https://a.example.com"}}, "invalid_client_metadata"},
+		"unsupported grant": {map[string]interface{}{"client_name": "cli", "redirect_uris": []string{"https://a.example.com"}, "grant_types": []string{"password"}}, "invalid_client_metadata"},
+		"unsupported auth":  {map[string]interface{}{"client_name": "cli", "redirect_uris": []string{"https://a.example.com"}, "token_endpoint_auth_method": "private_key_jwt"}, "invalid_client_metadata"},
+		"invalid scope":     {map[string]interface{}{"client_name": "cli", "redirect_uris": []string{"https://a.example.com"}, "scope": `reports:"read"`}, "invalid_client_metadata"},
+		"unapproved scope":  {map[string]interface{}{"client_name": "cli", "redirect_uris": []string{"https://a.example.com"}, "scope": "reports:read billing:admin"}, "invalid_client_metadata"},
+		"insecure logo":     {map[string]interface{}{"client_name": "cli", "redirect_uris": []string{"https://a.example.com"}, "logo_uri": "http://a.example.com/logo.png"}, "invalid_client_metadata"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			expectOAuthError(t, ts.registrationRequest(http.MethodPost, "/oauth/register", initial.Data.Token, tc.metadata), http.StatusBadRequest, tc.code)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	config "go_server/Config"
+)
+
+const dashboardOrigin = "https://dashboard.example.com"
+
+func withDashboard(cfg *config.Config) {
+	cfg.CORS.AllowedOrigins = []string{dashboardOrigin}
+}
+
+// cors sends a request with an Origin header; a non-empty preflight method
+// turns it into a preflight request.
+func (ts *testServer) cors(method, path, origin, preflight string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	if preflight != "" {
+		req.Header.Set("Access-Control-Request-Method", preflight)
+	}
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectAllowed(t *testing.T, rec *httptest.ResponseRecorder, origin string, credentials bool) {
+	t.Helper()
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != origin {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want %q", got, origin)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); (got == "true") != credentials {
+		t.Fatalf("Access-Control-Allow-Credentials = %q, want credentials %v", got, credentials)
+	}
+}
+
+func TestCorsDashboardRoutes(t *testing.T) {
+	ts := newTestServer(t, withDashboard)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	ts.createApp(owner.Token, "shop")
+
+	rec := ts.cors(http.MethodOptions, "/api/v1/app/list", dashboardOrigin, "GET")
+	expectStatus(t, rec, http.StatusNoContent)
+	expectAllowed(t, rec, dashboardOrigin, true)
+	if rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("Access-Control-Max-Age = %q", rec.Header().Get("Access-Control-Max-Age"))
+	}
+
+	// app origins may log in but not manage apps
+	rec = ts.cors(http.MethodOptions, "/api/v1/app/list", "https://shop.example.com", "GET")
+	expectStatus(t, rec, http.StatusForbidden)
+	expectAllowed(t, rec, "", false)
+	expectAllowed(t, ts.cors(http.MethodGet, "/api/v1/org/list", "https://evil.example.com", ""), "", false)
+}
+
+func TestCorsLoginRoutesFollowApps(t *testing.T) {
+	ts := newTestServer(t, withDashboard)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+
+	expectStatus(t, ts.cors(http.MethodOptions, "/api/v1/login", "https://shop.example.com", "POST"), http.StatusForbidden)
+	appId := ts.createApp(owner.Token, "shop")
+
+	rec := ts.cors(http.MethodOptions, "/api/v1/login", "https://shop.example.com", "POST")
+	expectStatus(t, rec, http.StatusNoContent)
+	expectAllowed(t, rec, "https://shop.example.com", true)
+	expectAllowed(t, ts.cors(http.MethodOptions, "/api/v1/refresh", dashboardOrigin, "POST"), dashboardOrigin, true)
+
+	expectStatus(t, ts.request(http.MethodDelete, "/api/v1/app/"+strconv.Itoa(appId), owner.Token, nil), http.StatusOK)
+	expectStatus(t, ts.cors(http.MethodOptions, "/api/v1/login", "https://shop.example.com", "POST"), http.StatusForbidden)
+}
+
+func TestCorsPublicRoutes(t *testing.T) {
+	ts := newTestServer(t)
+
+	rec := ts.cors(http.MethodGet, "/api/v1/key/public", "https://anyone.example.com", "")
+	expectStatus(t, rec, http.StatusOK)
+	expectAllowed(t, rec, "*", false)
+
+	// without configured dashboard origins the dashboard API is same-origin only
+	expectAllowed(t, ts.cors(http.MethodOptions, "/api/v1/app/list", "https://anyone.example.com", "GET"), "", false)
+	expectAllowed(t, ts.cors(http.MethodGet, "/scim/v2/Users", "https://anyone.example.com", ""), "", false)
+}
+
+func TestCorsWildcardNeverAllowsCredentials(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = []string{"*"}
+	})
+	rec := ts.cors(http.MethodOptions, "/api/v1/app/list", "https://anyone.example.com", "GET")
+	expectStatus(t, rec, http.StatusNoContent)
+	expectAllowed(t, rec, "*", false)
+}
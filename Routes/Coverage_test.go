@@ -0,0 +1,56 @@
+package routes_test
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"testing"
+
+	config "go_server/Config"
+	controller "go_server/Controllers"
+	database "go_server/Database"
+	logging "go_server/Logging"
+	routes "go_server/Routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMain fails a full run of the suite that left a route without a
+// single request. Runs narrowed with -run are not checked.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if code == 0 && flag.Lookup("test.run").Value.String() == "" {
+		missing, err := unrequestedRoutes()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		} else if len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "FAIL: routes no test requests: %v\n", missing)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func unrequestedRoutes() ([]string, error) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	h, err := controller.NewHandler(database.NewMemoryStore(), cfg, logging.New(cfg.Log, io.Discard))
+	if err != nil {
+		return nil, err
+	}
+	router := gin.New()
+	routes.SetupRoutes(router, h)
+
+	var missing []string
+	for _, route := range router.Routes() {
+		if _, ok := requestedRoutes.Load(route.Method + " " + route.Path); !ok {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
@@ -0,0 +1,176 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	config "go_server/Config"
+)
+
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+func (ts *testServer) startDeviceLogin(appId int) deviceAuthorization {
+	ts.t.Helper()
+	rec := ts.post("/oauth/device_authorization", "", url.Values{"client_id": {strconv.Itoa(appId)}})
+	expectStatus(ts.t, rec, http.StatusOK)
+	var auth deviceAuthorization
+	decode(ts.t, rec, &auth)
+	return auth
+}
+
+func (ts *testServer) pollDevice(appId int, deviceCode string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.post("/oauth/token", "", url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"client_id":   {strconv.Itoa(appId)},
+		"device_code": {deviceCode},
+	})
+}
+
+func TestDeviceAuthorizationGrant(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "cli")
+	ada := ts.signUp("ada@example.com", "Ada", 0)
+
+	expectOAuthError(t, ts.post("/oauth/device_authorization", "", url.Values{"client_id": {"999"}}), http.StatusUnauthorized, "invalid_client")
+
+	auth := ts.startDeviceLogin(appId)
+	if auth.DeviceCode == "" || len(auth.UserCode) != 9 || auth.VerificationUri != "https://auth.example.com/device" ||
+		auth.VerificationUriComplete != auth.VerificationUri+"?user_code="+auth.UserCode || auth.ExpiresIn != 600 || auth.Interval != 5 {
+		t.Fatalf("device authorization = %+v", auth)
+	}
+
+	// the device waits, and is told to slow down when it polls too often
+	expectOAuthError(t, ts.pollDevice(appId, auth.DeviceCode), http.StatusBadRequest, "authorization_pending")
+	rec := ts.pollDevice(appId, auth.DeviceCode)
+	expectOAuthError(t, rec, http.StatusBadRequest, "slow_down")
+	expectOAuthError(t, ts.pollDevice(appId+1, auth.DeviceCode), http.StatusBadRequest, "invalid_grant")
+	expectOAuthError(t, ts.pollDevice(appId, "unknown"), http.StatusBadRequest, "invalid_grant")
+
+	// the user looks the code up, typed loosely, and approves it
+	expectStatus(t, ts.get("/api/v1/device?user_code="+auth.UserCode, ""), http.StatusUnauthorized)
+	rec = ts.get("/api/v1/device?user_code="+url.QueryEscape(strings.ToLower(strings.Replace(auth.UserCode, "-", " ", 1))), ada.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var login struct {
+		Data struct {
+			UserCode string `json:"user_code"`
+			App      struct {
+				Id   int    `json:"id"`
+				Name string `json:"name"`
+			} `json:"app"`
+		} `json:"data"`
+	}
+	decode(t, rec, &login)
+	if login.Data.UserCode != auth.UserCode || login.Data.App.Id != appId || login.Data.App.Name != "cli" {
+		t.Fatalf("device login = %s", rec.Body.String())
+	}
+	expectStatus(t, ts.get("/api/v1/device?user_code=BBBB-BBBB", ada.Token), http.StatusNotFound)
+	expectInvalidFields(t, ts.post("/api/v1/device", ada.Token, url.Values{"user_code": {auth.UserCode}, "action": {"maybe"}}), "action")
+	expectStatus(t, ts.post("/api/v1/device", ada.Token, url.Values{"user_code": {auth.UserCode}, "action": {"approve"}}), http.StatusOK)
+	expectStatus(t, ts.post("/api/v1/device", ada.Token, url.Values{"user_code": {auth.UserCode}, "action": {"deny"}}), http.StatusNotFound)
+
+	rec = ts.pollDevice(appId, auth.DeviceCode)
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+	}
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	decode(t, rec, &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.TokenType != "Bearer" || tokens.ExpiresIn != 900 {
+		t.Fatalf("tokens = %s", rec.Body.String())
+	}
+	// they open a session with the app, so the refresh token rotates
+	rec = ts.post("/api/v1/refresh", "", url.Values{"token": {tokens.RefreshToken}, "id": {strconv.Itoa(appId)}})
+	expectStatus(t, rec, http.StatusOK)
+	if refreshed := decodeSession(t, rec); refreshed.Email != "ada@example.com" {
+		t.Fatalf("refreshed session = %+v", refreshed)
+	}
+	// and are handed out once
+	expectOAuthError(t, ts.pollDevice(appId, auth.DeviceCode), http.StatusBadRequest, "invalid_grant")
+
+	denied := ts.startDeviceLogin(appId)
+	expectStatus(t, ts.post("/api/v1/device", ada.Token, url.Values{"user_code": {denied.UserCode}, "action": {"deny"}}), http.StatusOK)
+	expectOAuthError(t, ts.pollDevice(appId, denied.DeviceCode), http.StatusBadRequest, "access_denied")
+}
+
+func TestDeviceCodeExpires(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.DeviceCodeTTL = config.Duration{Duration: time.Millisecond}
+	})
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "cli")
+	auth := ts.startDeviceLogin(appId)
+	time.Sleep(5 * time.Millisecond)
+
+	expectOAuthError(t, ts.pollDevice(appId, auth.DeviceCode), http.StatusBadRequest, "expired_token")
+	expectStatus(t, ts.post("/api/v1/device", owner.Token, url.Values{"user_code": {auth.UserCode}, "action": {"approve"}}), http.StatusNotFound)
+}
+
+// TestDeviceApprovalNeedsDashboardLogin checks that an app holding a
+// user's token cannot approve a device login, for itself or another app,
+// and that the approval needs a recent login.
+func TestDeviceApprovalNeedsDashboardLogin(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	notes := ts.createApp(owner.Token, "notes")
+	cli := ts.createApp(owner.Token, "cli")
+	ts.signUp("ada@example.com", "Ada", 0)
+	appToken := ts.login("ada@example.com", strongPassword, notes).Token
+	dashboard := ts.login("ada@example.com", strongPassword, 0).Token
+
+	auth := ts.startDeviceLogin(cli)
+	approve := url.Values{"user_code": {auth.UserCode}, "action": {"approve"}}
+	expectMessage(t, ts.post("/api/v1/device", appToken, approve), http.StatusForbidden, "Tokens issued to an app cannot approve device logins")
+	expectOAuthError(t, ts.pollDevice(cli, auth.DeviceCode), http.StatusBadRequest, "authorization_pending")
+
+	maxAge := ts.handler.Config.Auth.RecentAuthMaxAge
+	ts.handler.Config.Auth.RecentAuthMaxAge = config.Duration{Duration: time.Nanosecond}
+	rec := ts.post("/api/v1/device", dashboard, approve)
+	ts.handler.Config.Auth.RecentAuthMaxAge = maxAge
+	expectMessage(t, rec, http.StatusUnauthorized, "Please log in again to approve the device")
+
+	expectStatus(t, ts.post("/api/v1/device", dashboard, approve), http.StatusOK)
+}
+
+// TestDeviceTokensKeepApproverAuthTime checks that the device is issued
+// tokens with the time the approver entered credentials, not the time of
+// the approval, so they fail recent login checks just as the approver's
+// own token would.
+func TestDeviceTokensKeepApproverAuthTime(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	cli := ts.createApp(owner.Token, "cli")
+	ada := ts.signUp("ada@example.com", "Ada", 0)
+	authTime := time.Now().Add(-5 * time.Minute).Truncate(time.Second)
+
+	auth := ts.startDeviceLogin(cli)
+	rec := ts.post("/api/v1/device", ts.dashboardToken(ada, authTime), url.Values{"user_code": {auth.UserCode}, "action": {"approve"}})
+	expectStatus(t, rec, http.StatusOK)
+	rec = ts.pollDevice(cli, auth.DeviceCode)
+	expectStatus(t, rec, http.StatusOK)
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(t, rec, &tokens)
+	if claims := accessClaims(t, tokens.AccessToken); claims.AuthTime == nil || !claims.AuthTime.Time.Equal(authTime) {
+		t.Fatalf("auth_time = %v, want %v", claims.AuthTime, authTime)
+	}
+}
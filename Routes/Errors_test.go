@@ -0,0 +1,68 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestErrorsAreProblemDetails(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+
+	rec := ts.post("/api/v1/signup", "", url.Values{"email": {"owner@example.com"}, "password": {strongPassword}})
+	expectStatus(t, rec, http.StatusConflict)
+	if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	var problem struct {
+		Type      string `json:"type"`
+		Title     string `json:"title"`
+		Status    int    `json:"status"`
+		Detail    string `json:"detail"`
+		Instance  string `json:"instance"`
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	decode(t, rec, &problem)
+	if problem.Type != "urn:goauth:error:user_exists" || problem.Title != "Conflict" || problem.Status != 409 ||
+		problem.Detail != "User already exists" || problem.Instance != "/api/v1/signup" || problem.Code != "user_exists" ||
+		problem.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Fatalf("problem = %+v", problem)
+	}
+
+	// a missing app is a single 404 body
+	rec = ts.request(http.MethodPatch, "/api/v1/app/999", owner.Token, url.Values{"name": {"notes"}})
+	expectStatus(t, rec, http.StatusNotFound)
+	decode(t, rec, &problem)
+	if problem.Code != "not_found" {
+		t.Fatalf("code = %q, want not_found", problem.Code)
+	}
+}
+
+func TestBearerErrorsCarryChallenge(t *testing.T) {
+	ts := newTestServer(t)
+
+	rec := ts.get("/api/v1/app/list", "")
+	expectMessage(t, rec, http.StatusUnauthorized, "Authorization header is required")
+	if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="goauth"` {
+		t.Fatalf("WWW-Authenticate = %q", got)
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	decode(t, ts.get("/api/v1/app/list", "not-a-jwt"), &body)
+	if body.Code != "invalid_token" {
+		t.Fatalf("code = %q, want invalid_token", body.Code)
+	}
+}
+
+func TestRefreshErrorsUseOAuthFormat(t *testing.T) {
+	ts := newTestServer(t)
+
+	rec := ts.post("/api/v1/refresh", "", url.Values{"token": {"not-a-jwt"}, "id": {"0"}})
+	expectOAuthError(t, rec, http.StatusBadRequest, "invalid_grant")
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Cache-Control = %q, want no-store", rec.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,363 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	config "go_server/Config"
+	controller "go_server/Controllers"
+	database "go_server/Database"
+	logging "go_server/Logging"
+	models "go_server/Models"
+	routes "go_server/Routes"
+	services "go_server/Services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// strongPassword passes the default password policy and is used for every
+// account unless a test is about the policy itself.
+const strongPassword = "Correct-Horse-9-Battery"
+
+// fakeMailer records the emails the outbox worker hands to it.
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []services.EmailMessage
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg services.EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// logBuffer collects the server's log output so tests can inspect it.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// testServer serves the API from a MemoryStore, delivering mail to a
+// fakeMailer and resolving Google tokens from a fixed table, so the suite
+// runs offline.
+type testServer struct {
+	t       *testing.T
+	router  *gin.Engine
+	handler *controller.Handler
+	store   *database.MemoryStore
+	mailer  *fakeMailer
+	google  map[string]models.GoogleUser
+	logs    *logBuffer
+}
+
+// newTestServer builds a server from the default configuration; configure
+// functions may adjust it before the routes are set up.
+func newTestServer(t *testing.T, configure ...func(*config.Config)) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	ts := &testServer{
+		t:      t,
+		store:  database.NewMemoryStore(),
+		mailer: &fakeMailer{},
+		google: map[string]models.GoogleUser{},
+		logs:   &logBuffer{},
+	}
+	cfg := config.Default()
+	cfg.PublicBaseURL = "https://auth.example.com"
+	for _, f := range configure {
+		f(cfg)
+	}
+	h, err := controller.NewHandler(ts.store, cfg, logging.New(cfg.Log, ts.logs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.VerifyGoogle = func(ctx context.Context, token string) (models.GoogleUser, error) {
+		user, ok := ts.google[token]
+		if !ok {
+			return models.GoogleUser{}, errors.New("unknown google token")
+		}
+		return user, nil
+	}
+	ts.handler = h
+	ts.router = gin.New()
+	ts.router.Use(recordRoute)
+	routes.SetupRoutes(ts.router, h)
+	return ts
+}
+
+// requestedRoutes collects the routes the suite sent a request to, as
+// "METHOD /path/:param", for TestMain to check that none was left out.
+var requestedRoutes sync.Map
+
+func recordRoute(c *gin.Context) {
+	if path := c.FullPath(); path != "" {
+		requestedRoutes.Store(c.Request.Method+" "+path, true)
+	}
+}
+
+// request sends a form encoded request. token, when set, is sent as a
+// bearer token.
+func (ts *testServer) request(method, path, token string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, path, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (ts *testServer) post(path, token string, form url.Values) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	if form == nil {
+		form = url.Values{}
+	}
+	return ts.request(http.MethodPost, path, token, form)
+}
+
+// postJSON sends body encoded as JSON.
+func (ts *testServer) postJSON(path, token string, body interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (ts *testServer) get(path, token string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.request(http.MethodGet, path, token, nil)
+}
+
+// emails runs the outbox worker once and returns what it sent since the
+// last call.
+func (ts *testServer) emails() []services.EmailMessage {
+	ts.t.Helper()
+	if _, err := services.RunEmailWorkerOnce(context.Background(), ts.handler.Logs.For(logging.ComponentEmail), ts.store, ts.mailer); err != nil {
+		ts.t.Fatalf("email worker: %v", err)
+	}
+	ts.mailer.mu.Lock()
+	defer ts.mailer.mu.Unlock()
+	sent := ts.mailer.sent
+	ts.mailer.sent = nil
+	return sent
+}
+
+// session is the data part of a login response.
+type session struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenId      int    `json:"token_id"`
+	Id           int    `json:"id"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+}
+
+func (ts *testServer) signUp(email, name string, appId int) session {
+	ts.t.Helper()
+	form := url.Values{"email": {email}, "name": {name}, "password": {strongPassword}}
+	if appId != 0 {
+		form.Set("app_id", strconv.Itoa(appId))
+	}
+	rec := ts.post("/api/v1/signup", "", form)
+	expectStatus(ts.t, rec, http.StatusOK)
+	return decodeSession(ts.t, rec)
+}
+
+func (ts *testServer) login(email, password string, appId int) session {
+	ts.t.Helper()
+	form := url.Values{"email": {email}, "password": {password}}
+	if appId != 0 {
+		form.Set("app_id", strconv.Itoa(appId))
+	}
+	rec := ts.post("/api/v1/login", "", form)
+	expectStatus(ts.t, rec, http.StatusOK)
+	return decodeSession(ts.t, rec)
+}
+
+// dashboardToken signs a dashboard access token for the user as a login
+// at authTime would have.
+func (ts *testServer) dashboardToken(user session, authTime time.Time) string {
+	ts.t.Helper()
+	token, err := ts.handler.Tokens.GenerateTyped(controller.AccessTokenType, controller.AcessTokenClaim{
+		Id:       user.Id,
+		Name:     user.Name,
+		Email:    user.Email,
+		AuthTime: jwt.NewNumericDate(authTime),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ts.handler.Tokens.Issuer(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return token
+}
+
+// accessClaims reads the claims of an access token without verifying it.
+func accessClaims(t *testing.T, token string) controller.AcessTokenClaim {
+	t.Helper()
+	var claims controller.AcessTokenClaim
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func (ts *testServer) createApp(token, name string) int {
+	ts.t.Helper()
+	rec := ts.post("/api/v1/app/create", token, url.Values{"name": {name}, "callback_url": {"https://" + name + ".example.com/callback"}})
+	expectStatus(ts.t, rec, http.StatusOK)
+	var body struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	decode(ts.t, rec, &body)
+	return body.Data.Id
+}
+
+func decodeSession(t *testing.T, rec *httptest.ResponseRecorder) session {
+	t.Helper()
+	var body struct {
+		Data session `json:"data"`
+	}
+	decode(t, rec, &body)
+	if body.Data.Token == "" || body.Data.RefreshToken == "" {
+		t.Fatalf("login response without tokens: %s", rec.Body.String())
+	}
+	return body.Data
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, status, rec.Body.String())
+	}
+}
+
+// expectMessage checks the status and the "message" field of an error
+// response.
+func expectMessage(t *testing.T, rec *httptest.ResponseRecorder, status int, message string) {
+	t.Helper()
+	expectStatus(t, rec, status)
+	var body struct {
+		Message string `json:"message"`
+	}
+	decode(t, rec, &body)
+	if body.Message != message {
+		t.Fatalf("message = %q, want %q", body.Message, message)
+	}
+}
+
+// expectInvalidFields checks a validation failure naming exactly the given
+// fields.
+func expectInvalidFields(t *testing.T, rec *httptest.ResponseRecorder, fields ...string) {
+	t.Helper()
+	expectStatus(t, rec, http.StatusBadRequest)
+	var body struct {
+		Code   string `json:"code"`
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	decode(t, rec, &body)
+	got := []string{}
+	for _, e := range body.Errors {
+		got = append(got, e.Field)
+	}
+	if body.Code != "validation_failed" || strings.Join(got, ",") != strings.Join(fields, ",") {
+		t.Fatalf("invalid fields = %s %v, want %v", body.Code, got, fields)
+	}
+}
+
+// expectOAuthError checks an error in the OAuth 2.0 format of the token
+// endpoints.
+func expectOAuthError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	expectStatus(t, rec, status)
+	var body struct {
+		Error string `json:"error"`
+	}
+	decode(t, rec, &body)
+	if body.Error != code {
+		t.Fatalf("error = %q, want %q", body.Error, code)
+	}
+}
+
+var linkTokenPattern = regexp.MustCompile(`[?&]token=([A-Za-z0-9_-]+)`)
+
+// linkToken extracts the token of the single link in an email.
+func linkToken(t *testing.T, msg services.EmailMessage) string {
+	t.Helper()
+	match := linkTokenPattern.FindStringSubmatch(msg.TextBody)
+	if match == nil {
+		t.Fatalf("no link in email %q", msg.TextBody)
+	}
+	return match[1]
+}
+
+func findCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	metrics "go_server/Metrics"
+)
+
+func TestHealthProbes(t *testing.T) {
+	ts := newTestServer(t)
+	expectStatus(t, ts.get("/healthz", ""), http.StatusOK)
+
+	rec := ts.get("/readyz", "")
+	expectStatus(t, rec, http.StatusOK)
+	var body struct {
+		Checks map[string]string `json:"checks"`
+	}
+	decode(t, rec, &body)
+	for _, check := range []string{"database", "migrations", "signing_key"} {
+		if body.Checks[check] != "ok" {
+			t.Fatalf("checks = %v", body.Checks)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "shop")
+	app := strconv.Itoa(appId)
+
+	logins := metrics.Logins.Value("password", app, "success")
+	failures := metrics.Logins.Value("password", app, "failure")
+	strangers := metrics.Logins.Value("password", "none", "failure")
+	issued := metrics.TokensIssued.Value("refresh")
+
+	ts.login("owner@example.com", strongPassword, appId)
+	ts.post("/api/v1/login", "", url.Values{"email": {"owner@example.com"}, "password": {"wrong"}, "app_id": {app}})
+	ts.post("/api/v1/login", "", url.Values{"email": {"owner@example.com"}, "password": {"wrong"}, "app_id": {"987654"}})
+
+	if got := metrics.Logins.Value("password", app, "success"); got != logins+1 {
+		t.Fatalf("successful logins = %v, want %v", got, logins+1)
+	}
+	if got := metrics.Logins.Value("password", app, "failure"); got != failures+1 {
+		t.Fatalf("failed logins = %v, want %v", got, failures+1)
+	}
+	// unknown apps are not used as label values
+	if got := metrics.Logins.Value("password", "none", "failure"); got != strangers+1 {
+		t.Fatalf("failed logins without app = %v, want %v", got, strangers+1)
+	}
+	if got := metrics.TokensIssued.Value("refresh"); got != issued+1 {
+		t.Fatalf("refresh tokens issued = %v, want %v", got, issued+1)
+	}
+
+	// without an admin listener the metrics need an admin token
+	expectStatus(t, ts.get("/metrics", ""), http.StatusUnauthorized)
+	expectMessage(t, ts.get("/metrics", owner.Token), http.StatusForbidden, "Admin access required")
+	if err := ts.store.SetUserAdmin(context.Background(), "owner@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	rec := ts.get("/metrics", owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	for _, line := range []string{
+		`http_request_duration_seconds_count{method="POST",route="/api/v1/login",status="200"}`,
+		`auth_logins_total{method="password",app="` + app + `",result="success"}`,
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Fatalf("metrics lack %q", line)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	database "go_server/Database"
+	models "go_server/Models"
+)
+
+// brokenAppStore fails to list apps, to exercise the internal error path.
+type brokenAppStore struct {
+	database.Store
+}
+
+func (brokenAppStore) GetAllAppsOfUser(ctx context.Context, userId int) ([]models.App, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func TestRequestIDIsEchoedOrGenerated(t *testing.T) {
+	ts := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "edge-1234")
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got != "edge-1234" {
+		t.Fatalf("X-Request-ID = %q, want the incoming one", got)
+	}
+
+	// IDs that could inject content into logs are replaced
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "bad id\nlevel=ERROR")
+	rec = httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	got := rec.Header().Get("X-Request-ID")
+	if got == "" || strings.ContainsAny(got, " \n") {
+		t.Fatalf("X-Request-ID = %q, want a generated one", got)
+	}
+}
+
+func TestServerErrorCarriesRequestID(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	ts.handler.Store = brokenAppStore{ts.store}
+
+	rec := ts.get("/api/v1/app/list", owner.Token)
+	expectStatus(t, rec, http.StatusInternalServerError)
+	var body struct {
+		RequestID string `json:"request_id"`
+	}
+	decode(t, rec, &body)
+	if body.RequestID == "" || body.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Fatalf("request_id = %q, header = %q", body.RequestID, rec.Header().Get("X-Request-ID"))
+	}
+
+	logs := ts.logs.String()
+	if !strings.Contains(logs, `"request_id":"`+body.RequestID+`"`) || !strings.Contains(logs, "connection reset by peer") {
+		t.Fatalf("error was not logged with the request ID:\n%s", logs)
+	}
+	if strings.Contains(logs, owner.Token) {
+		t.Fatal("logs contain the bearer token")
+	}
+	if strings.Contains(logs, "owner@example.com") {
+		t.Fatal("logs contain an unredacted email address")
+	}
+}
@@ -0,0 +1,126 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	client "go_server/Client"
+	config "go_server/Config"
+	models "go_server/Models"
+	openapi "go_server/OpenApi"
+)
+
+var ginParamPattern = regexp.MustCompile(`:(\w+)`)
+
+// TestOpenAPICoversRoutes fails when a route under /api/v1 is added,
+// moved or removed without updating openapi.Operations.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	ts := newTestServer(t)
+
+	routes := map[string]bool{}
+	for _, route := range ts.router.Routes() {
+		if strings.HasPrefix(route.Path, "/api/v1/") {
+			routes[route.Method+" "+ginParamPattern.ReplaceAllString(route.Path, "{$1}")] = true
+		}
+	}
+	documented := map[string]bool{}
+	for _, op := range openapi.Operations {
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	var missing, stale []string
+	for route := range routes {
+		if !documented[route] {
+			missing = append(missing, route)
+		}
+	}
+	for route := range documented {
+		if !routes[route] {
+			stale = append(stale, route)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	if len(missing) > 0 || len(stale) > 0 {
+		t.Fatalf("routes without an operation: %v\noperations without a route: %v", missing, stale)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = []string{"https://dashboard.example.com"}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+	req.Header.Set("Origin", "https://docs.example.org")
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+	var doc struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	decode(t, rec, &doc)
+	if doc.OpenAPI != "3.1.0" || doc.Paths["/api/v1/login"] == nil {
+		t.Fatalf("document = %s", rec.Body.String())
+	}
+}
+
+func TestGeneratedClient(t *testing.T) {
+	ts := newTestServer(t)
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+	ctx := context.Background()
+	c := client.New(server.URL)
+
+	session, err := c.SignUp(ctx, models.SignUpRequest{Email: "ada@example.com", Name: "Ada", Password: strongPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Email != "ada@example.com" || session.RefreshToken == "" {
+		t.Fatalf("signup = %+v", session)
+	}
+	c.Token = session.Token
+
+	app, err := c.CreateApp(ctx, models.CreateAppRequest{Name: "notes", CallbackUrl: "https://notes.example.com/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	apps, err := c.ListApps(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].ID != app.Id {
+		t.Fatalf("apps = %+v, created %+v", apps, app)
+	}
+	deliveries, err := c.ListWebhookDeliveries(ctx, app.Id, 1, models.WebhookDeliveriesQuery{})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Fatalf("deliveries of a missing webhook = %v, %v", deliveries, err)
+	}
+
+	_, err = c.CreateApp(ctx, models.CreateAppRequest{Name: "notes", CallbackUrl: "javascript:alert(1)"})
+	if !errors.As(err, &apiErr) || apiErr.Code != "validation_failed" || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "callback_url" {
+		t.Fatalf("invalid app = %v", err)
+	}
+
+	// the token endpoint answers in the OAuth format
+	_, err = c.Refresh(ctx, models.RefreshRequest{Token: "not-a-token"})
+	if !errors.As(err, &apiErr) || apiErr.Code != "invalid_grant" {
+		t.Fatalf("refresh = %v", err)
+	}
+
+	key, err := c.GetPublicKey(ctx)
+	if err != nil || !strings.Contains(string(key), "PUBLIC KEY") {
+		t.Fatalf("public key = %q, %v", key, err)
+	}
+}
@@ -0,0 +1,162 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	config "go_server/Config"
+	models "go_server/Models"
+	routes "go_server/Routes"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestOrganizationScimTokens(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	stranger := ts.signUp("mallory@example.com", "Mallory", 0)
+
+	expectInvalidFields(t, ts.post("/api/v1/org/create", owner.Token, nil), "name")
+	rec := ts.post("/api/v1/org/create", owner.Token, url.Values{"name": {"Acme"}})
+	expectStatus(t, rec, http.StatusOK)
+	var org struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	decode(t, rec, &org)
+	path := "/api/v1/org/" + strconv.Itoa(org.Data.Id) + "/scim-token"
+
+	expectMessage(t, ts.post(path, stranger.Token, nil), http.StatusNotFound, "Organization not found")
+	rec = ts.post(path, owner.Token, url.Values{"description": {"okta"}})
+	expectStatus(t, rec, http.StatusOK)
+	var created struct {
+		Data struct {
+			Id    int    `json:"id"`
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	decode(t, rec, &created)
+
+	// the list shows the tokens without their secret
+	expectMessage(t, ts.get(path, stranger.Token), http.StatusNotFound, "Organization not found")
+	rec = ts.get(path, owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	decode(t, rec, &list)
+	if len(list.Data) != 1 || list.Data[0]["id"] != float64(created.Data.Id) || list.Data[0]["description"] != "okta" || strings.Contains(rec.Body.String(), created.Data.Token) {
+		t.Fatalf("tokens = %s", rec.Body.String())
+	}
+
+	expectStatus(t, ts.get("/scim/v2/Users", created.Data.Token), http.StatusOK)
+	expectStatus(t, ts.get("/scim/v2/Users", "forged"), http.StatusUnauthorized)
+	expectStatus(t, ts.get("/scim/v2/Users", ""), http.StatusUnauthorized)
+
+	revoke := path + "/" + strconv.Itoa(created.Data.Id)
+	expectMessage(t, ts.request(http.MethodDelete, revoke, stranger.Token, nil), http.StatusNotFound, "Organization not found")
+	expectMessage(t, ts.request(http.MethodDelete, revoke, owner.Token, nil), http.StatusOK, "Token revoked successfully")
+	expectStatus(t, ts.get("/scim/v2/Users", created.Data.Token), http.StatusUnauthorized)
+}
+
+func TestAdminExport(t *testing.T) {
+	ts := newTestServer(t)
+	admin := ts.signUp("admin@example.com", "Admin", 0)
+	ts.signUp("ada@example.com", "Ada", 0)
+
+	expectMessage(t, ts.get("/api/v1/admin/users/export", admin.Token), http.StatusForbidden, "Admin access required")
+	if err := ts.store.SetUserAdmin(context.Background(), "admin@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := ts.get("/api/v1/admin/users/export?format=csv", admin.Token)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatalf("export = %q", rec.Body.String())
+	}
+	expectInvalidFields(t, ts.get("/api/v1/admin/users/export?format=xml", admin.Token), "format")
+}
+
+func TestAdminImport(t *testing.T) {
+	ts := newTestServer(t)
+	admin := ts.signUp("admin@example.com", "Admin", 0)
+	hash, err := bcrypt.GenerateFromPassword([]byte(strongPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := []map[string]interface{}{
+		{"email": "grace@example.com", "name": "Grace", "password_hash": string(hash)},
+		{"email": "admin@example.com", "name": "Admin", "password_hash": string(hash)},
+	}
+
+	expectMessage(t, ts.postJSON("/api/v1/admin/users/import", admin.Token, users), http.StatusForbidden, "Admin access required")
+	expectMessage(t, ts.get("/api/v1/admin/users/import/1", admin.Token), http.StatusForbidden, "Admin access required")
+	if err := ts.store.SetUserAdmin(context.Background(), "admin@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// a dry run reports without writing
+	rec := ts.postJSON("/api/v1/admin/users/import?dry_run=true", admin.Token, users)
+	expectStatus(t, rec, http.StatusOK)
+	var report struct {
+		Data       models.ImportReport `json:"data"`
+		IssueCount int                 `json:"issue_count"`
+	}
+	decode(t, rec, &report)
+	if !report.Data.DryRun || report.Data.Imported != 1 || report.Data.Skipped != 1 || report.IssueCount != 1 {
+		t.Fatalf("dry run = %s", rec.Body.String())
+	}
+	expectStatus(t, ts.post("/api/v1/login", "", url.Values{"email": {"grace@example.com"}, "password": {strongPassword}}), http.StatusUnauthorized)
+
+	rec = ts.postJSON("/api/v1/admin/users/import", admin.Token, users)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &report)
+	if report.Data.DryRun || report.Data.JobId == 0 || report.Data.Imported != 1 || report.Data.Skipped != 1 {
+		t.Fatalf("import = %s", rec.Body.String())
+	}
+	// the imported hash is kept, so the user logs in with the old password
+	ts.login("grace@example.com", strongPassword, 0)
+
+	rec = ts.get("/api/v1/admin/users/import/"+strconv.Itoa(report.Data.JobId), admin.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var job struct {
+		Data models.UserImport `json:"data"`
+	}
+	decode(t, rec, &job)
+	if job.Data.ID != report.Data.JobId || job.Data.Total != 2 || job.Data.Imported != 1 || job.Data.Skipped != 1 {
+		t.Fatalf("import job = %s", rec.Body.String())
+	}
+	expectMessage(t, ts.get("/api/v1/admin/users/import/999", admin.Token), http.StatusNotFound, "Import not found")
+	expectMessage(t, ts.get("/api/v1/admin/users/import/abc", admin.Token), http.StatusBadRequest, "Invalid import ID")
+	expectStatus(t, ts.postJSON("/api/v1/admin/users/import", admin.Token, map[string]string{"email": "grace@example.com"}), http.StatusBadRequest)
+}
+
+func TestAdminRoutesMoveToAdminListener(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.Admin.Port = "9443"
+	})
+	admin := ts.signUp("admin@example.com", "Admin", 0)
+	if err := ts.store.SetUserAdmin(context.Background(), "admin@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, ts.get("/api/v1/admin/users/export", admin.Token), http.StatusNotFound)
+	expectStatus(t, ts.get("/metrics", admin.Token), http.StatusNotFound)
+
+	adminRouter := routes.NewAdminRouter(ts.handler)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/users/export", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.Token)
+	rec := httptest.NewRecorder()
+	adminRouter.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+
+	// scrapers read the metrics of the admin listener without a token
+	rec = httptest.NewRecorder()
+	adminRouter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	expectStatus(t, rec, http.StatusOK)
+}
@@ -0,0 +1,91 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestPasswordReset(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	ts.signUp("ada@example.com", "Ada", 0)
+	user := ts.login("ada@example.com", strongPassword, appId)
+	ts.emails()
+
+	expectInvalidFields(t, ts.post("/api/v1/forget-password", "", nil), "email")
+
+	// unknown addresses get the same answer but no email
+	expectStatus(t, ts.post("/api/v1/forget-password", "", url.Values{"email": {"nobody@example.com"}}), http.StatusOK)
+	if emails := ts.emails(); len(emails) != 0 {
+		t.Fatalf("email sent for an unknown address: %+v", emails)
+	}
+
+	expectStatus(t, ts.post("/api/v1/forget-password", "", url.Values{"email": {"ada@example.com"}}), http.StatusOK)
+	emails := ts.emails()
+	if len(emails) != 1 || emails[0].To != "ada@example.com" {
+		t.Fatalf("emails = %+v", emails)
+	}
+	token := linkToken(t, emails[0])
+
+	reset := func(token, password string) *httptest.ResponseRecorder {
+		return ts.post("/api/v1/reset-password", "", url.Values{"token": {token}, "password": {password}})
+	}
+	expectInvalidFields(t, reset("", "Staple-Horse-7-Battery"), "token")
+	expectMessage(t, reset("forged", "Staple-Horse-7-Battery"), http.StatusBadRequest, "The reset link is invalid or has expired")
+	expectMessage(t, reset(token, "short"), http.StatusBadRequest, "Password does not meet the requirements")
+	expectMessage(t, reset(token, "Staple-Horse-7-Battery"), http.StatusOK, "Password updated, please log in again")
+	// the link is single use
+	expectMessage(t, reset(token, "Another-Horse-5-Battery"), http.StatusBadRequest, "The reset link is invalid or has expired")
+
+	expectMessage(t, ts.post("/api/v1/login", "", url.Values{"email": {"ada@example.com"}, "password": {strongPassword}}), http.StatusUnauthorized, "Invalid email or password")
+	ts.login("ada@example.com", "Staple-Horse-7-Battery", 0)
+
+	// sessions opened before the reset are revoked
+	rec := ts.post("/api/v1/refresh", "", url.Values{"token": {user.RefreshToken}, "id": {strconv.Itoa(appId)}})
+	expectOAuthError(t, rec, http.StatusBadRequest, "invalid_grant")
+
+	if emails := ts.emails(); len(emails) != 1 || emails[0].To != "ada@example.com" {
+		t.Fatalf("password changed emails = %+v", emails)
+	}
+}
+
+func TestMagicLink(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signUp("ada@example.com", "Ada", 0)
+
+	ts.handler.Config.Features.MagicLink = false
+	expectMessage(t, ts.post("/api/v1/magic-link", "", url.Values{"email": {"ada@example.com"}}), http.StatusNotFound, "Magic link login is disabled")
+
+	ts.handler.Config.Features.MagicLink = true
+	expectInvalidFields(t, ts.post("/api/v1/magic-link", "", nil), "email")
+	expectMessage(t, ts.post("/api/v1/magic-link", "", url.Values{"email": {"ada@example.com"}, "app_id": {"999"}}), http.StatusBadRequest, "Invalid app ID")
+
+	rec := ts.post("/api/v1/magic-link", "", url.Values{"email": {"ada@example.com"}})
+	expectStatus(t, rec, http.StatusOK)
+	binding := findCookie(rec, "magic_link_binding")
+	if binding == nil {
+		t.Fatal("no binding cookie")
+	}
+	emails := ts.emails()
+	if len(emails) != 1 {
+		t.Fatalf("emails = %+v", emails)
+	}
+	token := linkToken(t, emails[0])
+
+	verify := url.Values{"token": {token}}
+	expectMessage(t, ts.request(http.MethodPost, "/api/v1/magic-link/verify", "", verify), http.StatusForbidden, "Open the link in the browser where you requested it")
+	other := &http.Cookie{Name: binding.Name, Value: "another-browser"}
+	expectMessage(t, ts.request(http.MethodPost, "/api/v1/magic-link/verify", "", verify, other), http.StatusBadRequest, "The link is invalid, expired or was opened in another browser")
+
+	rec = ts.request(http.MethodPost, "/api/v1/magic-link/verify", "", verify, binding)
+	expectStatus(t, rec, http.StatusOK)
+	if session := decodeSession(t, rec); session.Email != "ada@example.com" {
+		t.Fatalf("magic link session = %+v", session)
+	}
+	// the link is single use
+	expectMessage(t, ts.request(http.MethodPost, "/api/v1/magic-link/verify", "", verify, binding), http.StatusBadRequest, "The link is invalid, expired or was opened in another browser")
+}
@@ -0,0 +1,513 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+const scimPatchSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+
+// scimToken creates an organization owned by ownerToken's user and returns
+// a SCIM token for it.
+func (ts *testServer) scimToken(ownerToken, name string) string {
+	ts.t.Helper()
+	rec := ts.post("/api/v1/org/create", ownerToken, url.Values{"name": {name}})
+	expectStatus(ts.t, rec, http.StatusOK)
+	var org struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	decode(ts.t, rec, &org)
+	rec = ts.post("/api/v1/org/"+strconv.Itoa(org.Data.Id)+"/scim-token", ownerToken, nil)
+	expectStatus(ts.t, rec, http.StatusOK)
+	var created struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	decode(ts.t, rec, &created)
+	return created.Data.Token
+}
+
+// scim sends body as SCIM JSON. ifMatch, when set, is sent as If-Match.
+func (ts *testServer) scim(method, path, token string, body interface{}, ifMatch string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/scim+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func scimPatch(operations ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"schemas": []string{scimPatchSchema}, "Operations": operations}
+}
+
+// expectScimError checks the status and scimType of a SCIM error response.
+func expectScimError(t *testing.T, rec *httptest.ResponseRecorder, status int, scimType string) {
+	t.Helper()
+	expectStatus(t, rec, status)
+	var body struct {
+		Status   string `json:"status"`
+		ScimType string `json:"scimType"`
+	}
+	decode(t, rec, &body)
+	if body.Status != strconv.Itoa(status) || body.ScimType != scimType {
+		t.Fatalf("error = %s, want scimType %q", rec.Body.String(), scimType)
+	}
+}
+
+type scimUser struct {
+	Id          string `json:"id"`
+	UserName    string `json:"userName"`
+	DisplayName string `json:"displayName"`
+	ExternalId  string `json:"externalId"`
+	Active      bool   `json:"active"`
+	Meta        struct {
+		Version string `json:"version"`
+	} `json:"meta"`
+}
+
+type scimGroup struct {
+	Id          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	ExternalId  string `json:"externalId"`
+	Members     []struct {
+		Value string `json:"value"`
+	} `json:"members"`
+	Meta struct {
+		Version string `json:"version"`
+	} `json:"meta"`
+}
+
+type scimList struct {
+	TotalResults int               `json:"totalResults"`
+	Resources    []json.RawMessage `json:"Resources"`
+}
+
+func scimUserIds(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+	expectStatus(t, rec, http.StatusOK)
+	var list scimList
+	decode(t, rec, &list)
+	ids := []string{}
+	for _, raw := range list.Resources {
+		var user scimUser
+		if err := json.Unmarshal(raw, &user); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.Id)
+	}
+	if list.TotalResults != len(ids) {
+		t.Fatalf("totalResults = %d for %v", list.TotalResults, ids)
+	}
+	return ids
+}
+
+func (ts *testServer) createScimUser(token string, body map[string]interface{}) scimUser {
+	ts.t.Helper()
+	rec := ts.scim(http.MethodPost, "/scim/v2/Users", token, body, "")
+	expectStatus(ts.t, rec, http.StatusCreated)
+	var user scimUser
+	decode(ts.t, rec, &user)
+	if rec.Header().Get("ETag") != user.Meta.Version || rec.Header().Get("Location") != "/scim/v2/Users/"+user.Id {
+		ts.t.Fatalf("headers = %v for %s", rec.Header(), rec.Body.String())
+	}
+	return user
+}
+
+func TestScimUsers(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	token := ts.scimToken(owner.Token, "Acme")
+	otherToken := ts.scimToken(owner.Token, "Globex")
+
+	rec := ts.get("/scim/v2/ServiceProviderConfig", token)
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Content-Type") != "application/scim+json; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	ada := ts.createScimUser(token, map[string]interface{}{
+		"userName":   "ada@example.com",
+		"name":       map[string]string{"givenName": "Ada", "familyName": "Lovelace"},
+		"externalId": "okta-1",
+	})
+	if ada.UserName != "ada@example.com" || ada.DisplayName != "Ada Lovelace" || ada.ExternalId != "okta-1" || !ada.Active {
+		t.Fatalf("created = %+v", ada)
+	}
+	// the primary email stands in for a userName that is not one
+	grace := ts.createScimUser(token, map[string]interface{}{
+		"userName": "grace",
+		"emails":   []map[string]interface{}{{"value": "other@example.com"}, {"value": "grace@example.com", "primary": true}},
+		"active":   false,
+	})
+	if grace.UserName != "grace@example.com" || grace.DisplayName != "grace@example.com" || grace.Active {
+		t.Fatalf("created = %+v", grace)
+	}
+	expectScimError(t, ts.scim(http.MethodPost, "/scim/v2/Users", token, map[string]interface{}{"userName": "ada"}, ""), http.StatusBadRequest, "invalidValue")
+	expectScimError(t, ts.scim(http.MethodPost, "/scim/v2/Users", token, map[string]interface{}{"userName": "ada@example.com"}, ""), http.StatusConflict, "uniqueness")
+	expectScimError(t, ts.scim(http.MethodPost, "/scim/v2/Users", token, "not an object", ""), http.StatusBadRequest, "invalidSyntax")
+
+	adaPath := "/scim/v2/Users/" + ada.Id
+	rec = ts.get(adaPath, token)
+	expectStatus(t, rec, http.StatusOK)
+	var got scimUser
+	decode(t, rec, &got)
+	if got.Id != ada.Id || got.UserName != "ada@example.com" || rec.Header().Get("ETag") != ada.Meta.Version {
+		t.Fatalf("get = %s", rec.Body.String())
+	}
+	// another organization does not see the user
+	expectScimError(t, ts.get(adaPath, otherToken), http.StatusNotFound, "")
+	expectScimError(t, ts.get("/scim/v2/Users/999", token), http.StatusNotFound, "")
+	expectScimError(t, ts.get("/scim/v2/Users/ada", token), http.StatusNotFound, "")
+	expectStatus(t, ts.get(adaPath, ""), http.StatusUnauthorized)
+	if ids := scimUserIds(t, ts.get("/scim/v2/Users", otherToken)); len(ids) != 0 {
+		t.Fatalf("users of the other organization = %v", ids)
+	}
+
+	// a matching If-None-Match answers 304
+	req := httptest.NewRequest(http.MethodGet, adaPath, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-None-Match", ada.Meta.Version)
+	rec = httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusNotModified)
+
+	// replace
+	rec = ts.scim(http.MethodPut, adaPath, token, map[string]interface{}{"userName": "ada@example.com", "displayName": "Ada Byron"}, ada.Meta.Version)
+	expectStatus(t, rec, http.StatusOK)
+	var replaced scimUser
+	decode(t, rec, &replaced)
+	// a full replacement drops the attributes it leaves out
+	if replaced.DisplayName != "Ada Byron" || replaced.ExternalId != "" || replaced.Meta.Version == ada.Meta.Version {
+		t.Fatalf("replaced = %+v", replaced)
+	}
+
+	// patch, as sent by identity providers: with a path, and as an object
+	// without one with booleans in strings
+	rec = ts.scim(http.MethodPatch, adaPath, token, scimPatch(
+		map[string]interface{}{"op": "replace", "path": "name.familyName", "value": "Lovelace"},
+		map[string]interface{}{"op": "add", "path": "externalId", "value": "okta-2"},
+		map[string]interface{}{"op": "Replace", "value": map[string]interface{}{"active": "False"}},
+	), replaced.Meta.Version)
+	expectStatus(t, rec, http.StatusOK)
+	var patched scimUser
+	decode(t, rec, &patched)
+	if patched.DisplayName != "Ada Lovelace" || patched.ExternalId != "okta-2" || patched.Active || patched.Meta.Version == replaced.Meta.Version {
+		t.Fatalf("patched = %+v", patched)
+	}
+	rec = ts.scim(http.MethodPatch, adaPath, token, scimPatch(
+		map[string]interface{}{"op": "replace", "path": "active", "value": true},
+		map[string]interface{}{"op": "remove", "path": "externalId"},
+	), "")
+	expectStatus(t, rec, http.StatusOK)
+	patched = scimUser{}
+	decode(t, rec, &patched)
+	if !patched.Active || patched.ExternalId != "" {
+		t.Fatalf("patched = %+v", patched)
+	}
+
+	invalidPatches := []struct {
+		name     string
+		body     interface{}
+		scimType string
+	}{
+		{"without the schema", map[string]interface{}{"Operations": []map[string]interface{}{{"op": "replace", "path": "active", "value": true}}}, "invalidSyntax"},
+		{"without operations", scimPatch(), "invalidSyntax"},
+		{"unknown op", scimPatch(map[string]interface{}{"op": "move", "path": "active", "value": true}), "invalidSyntax"},
+		{"unknown attribute", scimPatch(map[string]interface{}{"op": "replace", "path": "nickName", "value": "Ada"}), "invalidPath"},
+		{"removing the userName", scimPatch(map[string]interface{}{"op": "remove", "path": "userName"}), "mutability"},
+		{"not a boolean", scimPatch(map[string]interface{}{"op": "replace", "path": "active", "value": "maybe"}), "invalidValue"},
+		{"not an email", scimPatch(map[string]interface{}{"op": "replace", "path": "userName", "value": "ada"}), "invalidValue"},
+		{"object without path", scimPatch(map[string]interface{}{"op": "replace", "value": "Ada"}), "invalidSyntax"},
+	}
+	for _, tc := range invalidPatches {
+		t.Run(tc.name, func(t *testing.T) {
+			expectScimError(t, ts.scim(http.MethodPatch, adaPath, token, tc.body, ""), http.StatusBadRequest, tc.scimType)
+		})
+	}
+	// a refused patch changes nothing
+	rec = ts.get(adaPath, token)
+	decode(t, rec, &got)
+	if got.Meta.Version != patched.Meta.Version || got.UserName != "ada@example.com" {
+		t.Fatalf("after refused patches = %s", rec.Body.String())
+	}
+
+	// delete takes the user out of the organization
+	expectScimError(t, ts.scim(http.MethodDelete, adaPath, otherToken, nil, ""), http.StatusNotFound, "")
+	expectStatus(t, ts.scim(http.MethodDelete, adaPath, token, nil, patched.Meta.Version), http.StatusNoContent)
+	expectScimError(t, ts.get(adaPath, token), http.StatusNotFound, "")
+	expectScimError(t, ts.scim(http.MethodDelete, adaPath, token, nil, ""), http.StatusNotFound, "")
+	if ids := scimUserIds(t, ts.get("/scim/v2/Users", token)); len(ids) != 1 || ids[0] != grace.Id {
+		t.Fatalf("users after delete = %v", ids)
+	}
+}
+
+// TestScimVersionConflicts checks that writes with an If-Match naming an
+// older version are refused with 412 and change nothing.
+func TestScimVersionConflicts(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	token := ts.scimToken(owner.Token, "Acme")
+
+	ada := ts.createScimUser(token, map[string]interface{}{"userName": "ada@example.com"})
+	path := "/scim/v2/Users/" + ada.Id
+	rec := ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Ada"}), ada.Meta.Version)
+	expectStatus(t, rec, http.StatusOK)
+	var current scimUser
+	decode(t, rec, &current)
+
+	stale := ada.Meta.Version
+	expectScimError(t, ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Stale"}), stale), http.StatusPreconditionFailed, "")
+	expectScimError(t, ts.scim(http.MethodPut, path, token, map[string]interface{}{"userName": "ada@example.com", "displayName": "Stale"}, stale), http.StatusPreconditionFailed, "")
+	expectScimError(t, ts.scim(http.MethodDelete, path, token, nil, stale), http.StatusPreconditionFailed, "")
+	rec = ts.get(path, token)
+	var got scimUser
+	decode(t, rec, &got)
+	if got.DisplayName != "Ada" || got.Meta.Version != current.Meta.Version {
+		t.Fatalf("after stale writes = %s", rec.Body.String())
+	}
+	// the strong form, lists and * match as well
+	strong := current.Meta.Version[len(`W/`):]
+	rec = ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Ada L"}), stale+", "+strong)
+	expectStatus(t, rec, http.StatusOK)
+	expectStatus(t, ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Ada"}), "*"), http.StatusOK)
+
+	rec = ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{"displayName": "Engineering"}, "")
+	expectStatus(t, rec, http.StatusCreated)
+	var group scimGroup
+	decode(t, rec, &group)
+	groupPath := "/scim/v2/Groups/" + group.Id
+	rec = ts.scim(http.MethodPatch, groupPath, token, scimPatch(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Research"}), group.Meta.Version)
+	expectStatus(t, rec, http.StatusOK)
+	expectScimError(t, ts.scim(http.MethodPatch, groupPath, token, scimPatch(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Stale"}), group.Meta.Version), http.StatusPreconditionFailed, "")
+	expectScimError(t, ts.scim(http.MethodPut, groupPath, token, map[string]interface{}{"displayName": "Stale"}, group.Meta.Version), http.StatusPreconditionFailed, "")
+	expectScimError(t, ts.scim(http.MethodDelete, groupPath, token, nil, group.Meta.Version), http.StatusPreconditionFailed, "")
+	var gotGroup scimGroup
+	decode(t, ts.get(groupPath, token), &gotGroup)
+	if gotGroup.DisplayName != "Research" {
+		t.Fatalf("group after stale writes = %+v", gotGroup)
+	}
+}
+
+func TestScimGroups(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	token := ts.scimToken(owner.Token, "Acme")
+	otherToken := ts.scimToken(owner.Token, "Globex")
+	ada := ts.createScimUser(token, map[string]interface{}{"userName": "ada@example.com"})
+	grace := ts.createScimUser(token, map[string]interface{}{"userName": "grace@example.com"})
+	outsider := ts.createScimUser(otherToken, map[string]interface{}{"userName": "mallory@example.com"})
+
+	memberIds := func(group scimGroup) []string {
+		ids := []string{}
+		for _, member := range group.Members {
+			ids = append(ids, member.Value)
+		}
+		return ids
+	}
+	decodeGroup := func(rec *httptest.ResponseRecorder, status int) scimGroup {
+		t.Helper()
+		expectStatus(t, rec, status)
+		var group scimGroup
+		decode(t, rec, &group)
+		return group
+	}
+
+	rec := ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{
+		"displayName": "Engineering",
+		"externalId":  "okta-eng",
+		"members":     []map[string]string{{"value": ada.Id}},
+	}, "")
+	group := decodeGroup(rec, http.StatusCreated)
+	if group.DisplayName != "Engineering" || group.ExternalId != "okta-eng" || len(group.Members) != 1 || group.Members[0].Value != ada.Id ||
+		rec.Header().Get("Location") != "/scim/v2/Groups/"+group.Id || rec.Header().Get("ETag") != group.Meta.Version {
+		t.Fatalf("created = %s", rec.Body.String())
+	}
+	expectScimError(t, ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{"displayName": " "}, ""), http.StatusBadRequest, "invalidValue")
+	expectScimError(t, ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{"displayName": "Engineering"}, ""), http.StatusConflict, "uniqueness")
+	// members must be users of the organization
+	expectScimError(t, ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{"displayName": "Ops", "members": []map[string]string{{"value": outsider.Id}}}, ""), http.StatusBadRequest, "invalidValue")
+	expectScimError(t, ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{"displayName": "Ops", "members": []map[string]string{{"value": "ada"}}}, ""), http.StatusBadRequest, "invalidValue")
+
+	path := "/scim/v2/Groups/" + group.Id
+	got := decodeGroup(ts.get(path, token), http.StatusOK)
+	if got.Id != group.Id || got.DisplayName != "Engineering" {
+		t.Fatalf("get = %+v", got)
+	}
+	expectScimError(t, ts.get(path, otherToken), http.StatusNotFound, "")
+	expectScimError(t, ts.get("/scim/v2/Groups/999", token), http.StatusNotFound, "")
+
+	// patch: add a member, then remove one by filter and rename
+	group = decodeGroup(ts.scim(http.MethodPatch, path, token, scimPatch(
+		map[string]interface{}{"op": "add", "path": "members", "value": []map[string]string{{"value": grace.Id}}},
+	), group.Meta.Version), http.StatusOK)
+	if ids := memberIds(group); len(ids) != 2 {
+		t.Fatalf("members after add = %v", ids)
+	}
+	group = decodeGroup(ts.scim(http.MethodPatch, path, token, scimPatch(
+		map[string]interface{}{"op": "remove", "path": `members[value eq "` + ada.Id + `"]`},
+		map[string]interface{}{"op": "replace", "value": map[string]interface{}{"displayName": "Research"}},
+		map[string]interface{}{"op": "remove", "path": "externalId"},
+	), ""), http.StatusOK)
+	if ids := memberIds(group); len(ids) != 1 || ids[0] != grace.Id || group.DisplayName != "Research" || group.ExternalId != "" {
+		t.Fatalf("patched = %+v", group)
+	}
+	expectScimError(t, ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "add", "path": `members[value eq "` + ada.Id + `"]`}), ""), http.StatusBadRequest, "invalidPath")
+	expectScimError(t, ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "remove", "path": "displayName"}), ""), http.StatusBadRequest, "mutability")
+	expectScimError(t, ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "add", "path": "members", "value": []map[string]string{{"value": outsider.Id}}}), ""), http.StatusBadRequest, "invalidValue")
+	expectScimError(t, ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "add", "path": "members", "value": "nobody"}), ""), http.StatusBadRequest, "invalidValue")
+	// removing all members
+	group = decodeGroup(ts.scim(http.MethodPatch, path, token, scimPatch(map[string]interface{}{"op": "remove", "path": "members"}), ""), http.StatusOK)
+	if ids := memberIds(group); len(ids) != 0 {
+		t.Fatalf("members after removing all = %v", ids)
+	}
+
+	// replace
+	group = decodeGroup(ts.scim(http.MethodPut, path, token, map[string]interface{}{
+		"displayName": "Engineering",
+		"members":     []map[string]string{{"value": ada.Id}, {"value": grace.Id}},
+	}, group.Meta.Version), http.StatusOK)
+	if ids := memberIds(group); len(ids) != 2 || group.DisplayName != "Engineering" {
+		t.Fatalf("replaced = %+v", group)
+	}
+
+	// removing a user from the organization removes it from its groups
+	expectStatus(t, ts.scim(http.MethodDelete, "/scim/v2/Users/"+ada.Id, token, nil, ""), http.StatusNoContent)
+	group = decodeGroup(ts.get(path, token), http.StatusOK)
+	if ids := memberIds(group); len(ids) != 1 || ids[0] != grace.Id {
+		t.Fatalf("members after the user was removed = %v", ids)
+	}
+
+	expectScimError(t, ts.scim(http.MethodDelete, path, otherToken, nil, ""), http.StatusNotFound, "")
+	expectStatus(t, ts.scim(http.MethodDelete, path, token, nil, group.Meta.Version), http.StatusNoContent)
+	expectScimError(t, ts.get(path, token), http.StatusNotFound, "")
+	var list scimList
+	rec = ts.get("/scim/v2/Groups", token)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &list)
+	if list.TotalResults != 0 {
+		t.Fatalf("groups after delete = %s", rec.Body.String())
+	}
+}
+
+func TestScimFilters(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	token := ts.scimToken(owner.Token, "Acme")
+	ada := ts.createScimUser(token, map[string]interface{}{"userName": "ada@example.com", "displayName": "Ada Lovelace", "externalId": "okta-1"})
+	grace := ts.createScimUser(token, map[string]interface{}{"userName": "grace@example.org", "displayName": "Grace Hopper", "active": false})
+	rec := ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{"displayName": "Engineering", "members": []map[string]string{{"value": grace.Id}}}, "")
+	expectStatus(t, rec, http.StatusCreated)
+	var group scimGroup
+	decode(t, rec, &group)
+	expectStatus(t, ts.scim(http.MethodPost, "/scim/v2/Groups", token, map[string]interface{}{"displayName": "Sales"}, ""), http.StatusCreated)
+
+	users := func(filter string) *httptest.ResponseRecorder {
+		return ts.get("/scim/v2/Users?filter="+url.QueryEscape(filter), token)
+	}
+	userCases := []struct {
+		filter string
+		want   []string
+	}{
+		{`userName eq "ada@example.com"`, []string{ada.Id}},
+		// attribute names, operators and string values ignore case
+		{`USERNAME EQ "ADA@EXAMPLE.COM"`, []string{ada.Id}},
+		{`userName ne "ada@example.com"`, []string{grace.Id}},
+		{`displayName co "hop"`, []string{grace.Id}},
+		{`emails.value ew ".org"`, []string{grace.Id}},
+		{`name.formatted sw "Ada"`, []string{ada.Id}},
+		{`externalId pr`, []string{ada.Id}},
+		{`active eq false`, []string{grace.Id}},
+		{`id eq "` + ada.Id + `"`, []string{ada.Id}},
+		{`userName sw "a" and active eq true`, []string{ada.Id}},
+		{`userName sw "g" and active eq true`, []string{}},
+		// quotes keep spaces and escaped quotes in a value
+		{`displayName eq "Ada Lovelace"`, []string{ada.Id}},
+		{`displayName eq "Ada \"Lovelace\""`, []string{}},
+		// percent signs are matched literally
+		{`userName co "%"`, []string{}},
+	}
+	for _, tc := range userCases {
+		t.Run(tc.filter, func(t *testing.T) {
+			ids := scimUserIds(t, users(tc.filter))
+			if len(ids) != len(tc.want) || (len(ids) > 0 && ids[0] != tc.want[0]) {
+				t.Fatalf("users = %v, want %v", ids, tc.want)
+			}
+		})
+	}
+
+	invalid := []string{
+		`userName eq`,
+		`userName`,
+		`userName eq "ada@example.com" or active eq true`,
+		`not (userName eq "ada@example.com")`,
+		`(userName eq "ada@example.com")`,
+		`emails[type eq "work"]`,
+		`userName like "ada"`,
+		`userName eq "unterminated`,
+		`password eq "secret"`,
+		`active eq maybe`,
+		`active co "t"`,
+		`id eq "ada"`,
+		`userName gt "a"`,
+	}
+	for _, filter := range invalid {
+		t.Run("invalid "+filter, func(t *testing.T) {
+			expectScimError(t, users(filter), http.StatusBadRequest, "invalidFilter")
+		})
+	}
+
+	groups := func(filter string) scimList {
+		t.Helper()
+		rec := ts.get("/scim/v2/Groups?filter="+url.QueryEscape(filter), token)
+		expectStatus(t, rec, http.StatusOK)
+		var list scimList
+		decode(t, rec, &list)
+		return list
+	}
+	if list := groups(`displayName eq "engineering"`); list.TotalResults != 1 {
+		t.Fatalf("groups by name = %d", list.TotalResults)
+	}
+	if list := groups(`members.value eq "` + grace.Id + `"`); list.TotalResults != 1 {
+		t.Fatalf("groups of a member = %d", list.TotalResults)
+	}
+	if list := groups(`members eq "` + ada.Id + `"`); list.TotalResults != 0 {
+		t.Fatalf("groups of a user in none = %d", list.TotalResults)
+	}
+	for _, filter := range []string{`members.value co "1"`, `userName eq "ada@example.com"`, `displayName eq "Sales" or displayName eq "Engineering"`} {
+		expectScimError(t, ts.get("/scim/v2/Groups?filter="+url.QueryEscape(filter), token), http.StatusBadRequest, "invalidFilter")
+	}
+
+	// paging
+	rec = ts.get("/scim/v2/Users?startIndex=2&count=1", token)
+	expectStatus(t, rec, http.StatusOK)
+	var page struct {
+		TotalResults int               `json:"totalResults"`
+		StartIndex   int               `json:"startIndex"`
+		ItemsPerPage int               `json:"itemsPerPage"`
+		Resources    []json.RawMessage `json:"Resources"`
+	}
+	decode(t, rec, &page)
+	if page.TotalResults != 2 || page.StartIndex != 2 || page.ItemsPerPage != 1 || len(page.Resources) != 1 {
+		t.Fatalf("page = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,109 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	config "go_server/Config"
+	sdk "go_server/Sdk"
+)
+
+func TestSdkAgainstServer(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.Issuer = "https://auth.example.com"
+	})
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+	ctx := context.Background()
+
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	appId := ts.createApp(owner.Token, "notes")
+	ts.signUp("ada@example.com", "Ada", 0)
+	login := ts.login("ada@example.com", strongPassword, appId)
+
+	rec := ts.get("/.well-known/jwks.json", "")
+	expectStatus(t, rec, http.StatusOK)
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	decode(t, rec, &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != ts.handler.Tokens.KeyId() {
+		t.Fatalf("jwks = %s", rec.Body.String())
+	}
+
+	opts := sdk.Options{BaseURL: server.URL, Issuer: "https://auth.example.com"}
+	if _, err := sdk.NewSessions(opts, appId+1).Exchange(ctx, login.TokenId); err == nil {
+		t.Fatal("a login for another app was exchanged")
+	}
+	sessions := sdk.NewSessions(opts, appId)
+	tokens, err := sessions.Exchange(ctx, login.TokenId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a downstream service of the app guarded by the middleware
+	verifierOpts := opts
+	verifierOpts.Audience = strconv.Itoa(appId)
+	verifier := sdk.NewVerifier(verifierOpts)
+	downstream := verifier.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := sdk.FromContext(r.Context())
+		if claims.Email != "ada@example.com" || claims.AppId != appId {
+			t.Errorf("claims = %+v", claims)
+		}
+	}))
+	call := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/notes", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		downstream.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if status := call(tokens.Token); status != http.StatusOK {
+		t.Fatalf("access token: status %d", status)
+	}
+	if status := call(tokens.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("refresh token used as access token: status %d", status)
+	}
+
+	// the tokens the server issues for anything but this app are refused
+	otherAppId := ts.createApp(owner.Token, "billing")
+	otherLogin := ts.login("ada@example.com", strongPassword, otherAppId)
+	otherTokens, err := sdk.NewSessions(opts, otherAppId).Exchange(ctx, otherLogin.TokenId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := call(otherTokens.Token); status != http.StatusUnauthorized {
+		t.Fatalf("token of another app: status %d", status)
+	}
+	dashboard := ts.login("ada@example.com", strongPassword, 0)
+	if status := call(dashboard.Token); status != http.StatusUnauthorized {
+		t.Fatalf("dashboard token: status %d", status)
+	}
+	otherSecret := ts.clientSecret(owner.Token, otherAppId)
+	rec = ts.tokenRequest(strconv.Itoa(otherAppId), otherSecret, url.Values{"grant_type": {"client_credentials"}})
+	expectStatus(t, rec, http.StatusOK)
+	var clientToken struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(t, rec, &clientToken)
+	if status := call(clientToken.AccessToken); status != http.StatusUnauthorized {
+		t.Fatalf("client credentials token of another app: status %d", status)
+	}
+
+	refreshed, err := sessions.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := call(refreshed.Token); status != http.StatusOK {
+		t.Fatalf("refreshed access token: status %d", status)
+	}
+	if _, err := sessions.Refresh(ctx, tokens.RefreshToken); err == nil {
+		t.Fatal("a rotated refresh token was accepted")
+	}
+}
@@ -0,0 +1,158 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	sdk "go_server/Sdk"
+)
+
+// clientSecret issues a client secret for the app.
+func (ts *testServer) clientSecret(ownerToken string, appId int) string {
+	ts.t.Helper()
+	rec := ts.post("/api/v1/app/"+strconv.Itoa(appId)+"/client/secret", ownerToken, nil)
+	expectStatus(ts.t, rec, http.StatusOK)
+	var body struct {
+		Data struct {
+			ClientSecret string `json:"client_secret"`
+		} `json:"data"`
+	}
+	decode(ts.t, rec, &body)
+	return body.Data.ClientSecret
+}
+
+func (ts *testServer) setExchangePolicy(ownerToken string, targetId, sourceId int, scopes ...string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	path := "/api/v1/app/" + strconv.Itoa(targetId) + "/exchange-policies/" + strconv.Itoa(sourceId)
+	return ts.request(http.MethodPut, path, ownerToken, url.Values{"scopes": scopes})
+}
+
+func exchangeForm(subjectToken string, audience int, scope string) url.Values {
+	form := url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"audience":           {strconv.Itoa(audience)},
+	}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	return form
+}
+
+func TestOAuthTokenExchange(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+	gateway := ts.createApp(owner.Token, "gateway")
+	notes := ts.createApp(owner.Token, "notes")
+	search := ts.createApp(owner.Token, "search")
+	gatewaySecret := ts.clientSecret(owner.Token, gateway)
+	notesSecret := ts.clientSecret(owner.Token, notes)
+	gatewayId, notesId := strconv.Itoa(gateway), strconv.Itoa(notes)
+
+	ts.signUp("ada@example.com", "Ada", 0)
+	ada := ts.login("ada@example.com", strongPassword, gateway)
+
+	// without a policy of the audience the exchange is refused
+	expectOAuthError(t, ts.tokenRequest(gatewayId, gatewaySecret, exchangeForm(ada.Token, notes, "")), http.StatusBadRequest, "invalid_target")
+
+	// only the owner of the audience sets its policies
+	mallory := ts.signUp("mallory@example.com", "Mallory", 0)
+	expectStatus(t, ts.setExchangePolicy(mallory.Token, notes, gateway, "notes:read"), http.StatusNotFound)
+	expectStatus(t, ts.setExchangePolicy(owner.Token, notes, 999, "notes:read"), http.StatusNotFound)
+	rec := ts.setExchangePolicy(owner.Token, notes, gateway, "notes:read", "notes:write")
+	expectStatus(t, rec, http.StatusOK)
+	rec = ts.get("/api/v1/app/"+notesId+"/exchange-policies", owner.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var policies struct {
+		Data []struct {
+			SourceAppId int      `json:"source_app_id"`
+			Scopes      []string `json:"scopes"`
+		} `json:"data"`
+	}
+	decode(t, rec, &policies)
+	if len(policies.Data) != 1 || policies.Data[0].SourceAppId != gateway || len(policies.Data[0].Scopes) != 2 {
+		t.Fatalf("policies = %s", rec.Body.String())
+	}
+
+	rec = ts.tokenRequest(gatewayId, gatewaySecret, exchangeForm(ada.Token, notes, "notes:read"))
+	expectStatus(t, rec, http.StatusOK)
+	var exchanged struct {
+		AccessToken     string `json:"access_token"`
+		IssuedTokenType string `json:"issued_token_type"`
+		TokenType       string `json:"token_type"`
+		ExpiresIn       int    `json:"expires_in"`
+		Scope           string `json:"scope"`
+		RefreshToken    string `json:"refresh_token"`
+	}
+	decode(t, rec, &exchanged)
+	if exchanged.IssuedTokenType != "urn:ietf:params:oauth:token-type:access_token" || exchanged.TokenType != "Bearer" ||
+		exchanged.Scope != "notes:read" || exchanged.ExpiresIn <= 0 || exchanged.ExpiresIn > 900 || exchanged.RefreshToken != "" {
+		t.Fatalf("exchange = %s", rec.Body.String())
+	}
+
+	// the notes service accepts the token for its audience, acted on by
+	// the gateway
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+	ctx := context.Background()
+	claims, err := sdk.NewVerifier(sdk.Options{BaseURL: server.URL, Audience: notesId}).Verify(ctx, exchanged.AccessToken, "notes:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "ada@example.com" || claims.AppId != notes || claims.Act == nil || claims.Act.Subject != gatewayId || claims.Act.Act != nil {
+		t.Fatalf("claims = %+v", claims)
+	}
+	if _, err := sdk.NewVerifier(sdk.Options{BaseURL: server.URL, Audience: strconv.Itoa(search)}).Verify(ctx, exchanged.AccessToken); err == nil {
+		t.Fatal("the token was accepted by another audience")
+	}
+	// and this API does not
+	expectStatus(t, ts.get("/api/v1/app/", exchanged.AccessToken), http.StatusUnauthorized)
+
+	// the notes service passes the user on to search: the actors nest and
+	// the scopes stay within those of the token it holds
+	expectStatus(t, ts.setExchangePolicy(owner.Token, search, notes, "notes:read", "notes:write"), http.StatusOK)
+	expectOAuthError(t, ts.tokenRequest(notesId, notesSecret, exchangeForm(exchanged.AccessToken, search, "notes:write")), http.StatusBadRequest, "invalid_scope")
+	rec = ts.tokenRequest(notesId, notesSecret, exchangeForm(exchanged.AccessToken, search, ""))
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &exchanged)
+	if exchanged.Scope != "notes:read" {
+		t.Fatalf("chained exchange = %s", rec.Body.String())
+	}
+	claims, err = sdk.NewVerifier(sdk.Options{BaseURL: server.URL, Audience: strconv.Itoa(search)}).Verify(ctx, exchanged.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Act == nil || claims.Act.Subject != notesId || claims.Act.Act == nil || claims.Act.Act.Subject != gatewayId {
+		t.Fatalf("chained claims = %+v", claims.Act)
+	}
+
+	cases := map[string]struct {
+		form url.Values
+		code string
+	}{
+		"scope outside the policy": {exchangeForm(ada.Token, notes, "notes:admin"), "invalid_scope"},
+		"token of another app":     {exchangeForm(ts.login("ada@example.com", strongPassword, notes).Token, notes, ""), "invalid_request"},
+		"dashboard token":          {exchangeForm(ts.login("ada@example.com", strongPassword, 0).Token, notes, ""), "invalid_request"},
+		"refresh token":            {exchangeForm(ada.RefreshToken, notes, ""), "invalid_request"},
+		"garbage":                  {exchangeForm("not-a-jwt", notes, ""), "invalid_request"},
+		"unknown audience":         {exchangeForm(ada.Token, 999, ""), "invalid_target"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			expectOAuthError(t, ts.tokenRequest(gatewayId, gatewaySecret, tc.form), http.StatusBadRequest, tc.code)
+		})
+	}
+	wrongType := exchangeForm(ada.Token, notes, "")
+	wrongType.Set("subject_token_type", "urn:ietf:params:oauth:token-type:refresh_token")
+	expectOAuthError(t, ts.tokenRequest(gatewayId, gatewaySecret, wrongType), http.StatusBadRequest, "invalid_request")
+	expectOAuthError(t, ts.tokenRequest(gatewayId, "wrong", exchangeForm(ada.Token, notes, "")), http.StatusUnauthorized, "invalid_client")
+
+	// removing the policy stops the exchange
+	expectStatus(t, ts.request(http.MethodDelete, "/api/v1/app/"+notesId+"/exchange-policies/"+gatewayId, owner.Token, nil), http.StatusOK)
+	expectOAuthError(t, ts.tokenRequest(gatewayId, gatewaySecret, exchangeForm(ada.Token, notes, "")), http.StatusBadRequest, "invalid_target")
+}
@@ -0,0 +1,73 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that keeps every span for the
+// duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestRequestSpansContinueIncomingTrace(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signUp("ada@example.com", "Ada", 0)
+	recorder := recordSpans(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	form := url.Values{"email": {"ada@example.com"}, "password": {strongPassword}}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login?trace=me", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, ok := spans["POST /api/v1/login"]
+	if !ok {
+		t.Fatalf("no server span among %v", spanNames(recorder.Ended()))
+	}
+	if server.SpanKind() != trace.SpanKindServer || server.SpanContext().TraceID().String() != traceID {
+		t.Fatalf("server span kind = %v, trace = %s", server.SpanKind(), server.SpanContext().TraceID())
+	}
+	for _, attr := range server.Attributes() {
+		if strings.Contains(attr.Value.Emit(), "trace=me") {
+			t.Fatalf("span records the query string in %s", attr.Key)
+		}
+	}
+	verify, ok := spans["password.verify"]
+	if !ok || verify.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("password.verify is not a child of the request span: %v", spanNames(recorder.Ended()))
+	}
+
+	if !strings.Contains(ts.logs.String(), `"trace_id":"`+traceID+`"`) {
+		t.Fatalf("request log lacks the trace ID:\n%s", ts.logs.String())
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
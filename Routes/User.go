@@ -2,41 +2,209 @@ package routes
 
 import (
 	controller "go_server/Controllers"
+	logging "go_server/Logging"
+	metrics "go_server/Metrics"
 	middleware "go_server/Middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine) {
-	auth := router.Group("/api/v1")
-	auth.POST("/signup", controller.SignUp)
-	auth.POST("/login", controller.Login)
-	auth.POST("/refresh", controller.Refresh)
-	auth.POST("/change-password",controller.ChangePassword)
-	auth.POST("/forget-password", controller.InitiateForgetPassword)
-	auth.POST("/reset-password", controller.CompleteForgetPassword)
-	auth.POST("/google-login", controller.ContinueWithGoogle)
+func SetupRoutes(router *gin.Engine, h *controller.Handler) {
+	authLog := h.Logs.For(logging.ComponentAuth)
+	router.Use(
+		middleware.TracingMiddleware(),
+		middleware.RequestIDMiddleware(),
+		middleware.RequestLogMiddleware(h.Logs.For(logging.ComponentHTTP)),
+		middleware.MetricsMiddleware(),
+		middleware.ErrorMiddleware(h.Log),
+		middleware.RecoveryMiddleware(h.Log),
+		middleware.CorsMiddleware(corsRules(h)),
+	)
+
+	// Probes for the orchestrator
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
 
+	auth := router.Group("/api/v1")
+	auth.POST("/signup", h.SignUp)
+	auth.POST("/login", h.Login)
+	auth.POST("/refresh", middleware.OAuthErrorFormat(), h.Refresh)
+	auth.POST("/forget-password", h.InitiateForgetPassword)
+	auth.POST("/reset-password", h.CompleteForgetPassword)
+	auth.POST("/google-login", h.ContinueWithGoogle)
+	auth.POST("/magic-link", h.RequestMagicLink)
+	auth.POST("/magic-link/verify", h.VerifyMagicLink)
+	auth.GET("/openapi.json", h.OpenAPI)
 
 	// Protected user routes with JWT
-	auth.Use(middleware.JWTAuthMiddleware())
-	auth.POST("/logout", controller.Logout)
+	auth.Use(middleware.JWTAuthMiddleware(h.Store, h.Tokens, authLog))
+	auth.POST("/logout", h.Logout)
+	auth.POST("/change-password", h.ChangePassword)
+	auth.DELETE("/account", h.DeleteAccount)
+	auth.GET("/device", h.GetDeviceLogin)
+	auth.POST("/device", h.VerifyDevice)
 
 	// Public app routes with API key middleware
 	publicApp := router.Group("/api/v1/app")
-	publicApp.GET("/get/:id", controller.GetApp)
+	publicApp.GET("/get/:id", h.GetApp)
 
 	// Protected app routes with JWT
 	app := router.Group("/api/v1/app")
-	app.Use(middleware.JWTAuthMiddleware())
-	app.GET("/", controller.Home)
-	app.POST("/create", controller.CreateApp)
-	app.GET("/list", controller.GetUserApps)
-	app.PATCH("/:id", controller.UpdateApp)
-	app.DELETE("/:id", controller.DeleteApp)
+	app.Use(middleware.JWTAuthMiddleware(h.Store, h.Tokens, authLog))
+	app.GET("/", h.Home)
+	app.POST("/create", h.CreateApp)
+	app.GET("/list", h.GetUserApps)
+	app.PATCH("/:id", h.UpdateApp)
+	app.DELETE("/:id", h.DeleteApp)
+	app.PATCH("/:id/branding", h.UpdateAppBranding)
+	app.GET("/:id/client", h.GetAppClient)
+	app.PUT("/:id/client", h.UpdateAppClient)
+	app.POST("/:id/client/secret", h.RotateClientSecret)
+	app.GET("/:id/exchange-policies", h.GetExchangePolicies)
+	app.PUT("/:id/exchange-policies/:sourceId", h.SetExchangePolicy)
+	app.DELETE("/:id/exchange-policies/:sourceId", h.DeleteExchangePolicy)
+	app.POST("/:id/webhooks", h.CreateWebhook)
+	app.GET("/:id/webhooks", h.GetWebhooks)
+	app.PATCH("/:id/webhooks/:webhookId", h.UpdateWebhook)
+	app.DELETE("/:id/webhooks/:webhookId", h.DeleteWebhook)
+	app.POST("/:id/webhooks/:webhookId/rotate-secret", h.RotateWebhookSecret)
+	app.GET("/:id/webhooks/:webhookId/deliveries", h.GetWebhookDeliveries)
+	app.POST("/:id/webhooks/:webhookId/test", h.SendTestWebhook)
 
 	key := router.Group("/api/v1/key")
-	key.GET("/public", controller.GetPublicKey)
-	key.GET("/token/:id", controller.GetToken)
+	key.GET("/public", h.GetPublicKey)
+	key.GET("/token/:id", h.GetToken)
+
+	// Signing keys for relying parties that pick the key by kid
+	router.GET("/.well-known/jwks.json", h.JWKS)
+
+	// OAuth token endpoint, where apps authenticate with their client
+	// credentials, devices poll for the login they started and apps
+	// exchange their users' tokens for tokens of other apps
+	router.POST("/oauth/token", middleware.OAuthErrorFormat(), h.Token)
+	router.POST("/oauth/device_authorization", middleware.OAuthErrorFormat(), h.DeviceAuthorization)
+
+	// Dynamic client registration with an organization's initial access
+	// token, then management of the client with its registration access
+	// token
+	router.POST("/oauth/register", middleware.OAuthErrorFormat(), middleware.InitialAccessTokenMiddleware(h.Store), h.RegisterClient)
+	registration := router.Group("/oauth/register/:clientId")
+	registration.Use(middleware.OAuthErrorFormat(), middleware.RegistrationTokenMiddleware(h.Store))
+	registration.GET("", h.GetClientRegistration)
+	registration.PUT("", h.UpdateClientRegistration)
+	registration.DELETE("", h.DeleteClientRegistration)
+
+	// Organization management with JWT
+	org := router.Group("/api/v1/org")
+	org.Use(middleware.JWTAuthMiddleware(h.Store, h.Tokens, authLog))
+	org.POST("/create", h.CreateOrganization)
+	org.GET("/list", h.GetUserOrganizations)
+	org.POST("/:id/scim-token", h.CreateScimToken)
+	org.GET("/:id/scim-token", h.GetScimTokens)
+	org.DELETE("/:id/scim-token/:tokenId", h.RevokeScimToken)
+	org.POST("/:id/initial-access-token", h.CreateInitialAccessToken)
+	org.GET("/:id/initial-access-token", h.GetInitialAccessTokens)
+	org.DELETE("/:id/initial-access-token/:tokenId", h.RevokeInitialAccessToken)
+
+	// Administration moves to its own listener when an admin port is set.
+	// Without one the metrics share the public listener and need an admin
+	// token like the admin API.
+	if h.Config.Server.Admin.Port == "" {
+		router.GET("/metrics", append(adminAuth(h), gin.WrapH(metrics.Handler()))...)
+		SetupAdminRoutes(router, h)
+	}
+
+	// SCIM 2.0 provisioning with organization bearer tokens
+	scim := router.Group("/scim/v2")
+	scim.Use(middleware.ScimAuthMiddleware(h.Store))
+	scim.GET("/ServiceProviderConfig", h.ScimServiceProviderConfig)
+	scim.GET("/Users", h.ScimListUsers)
+	scim.POST("/Users", h.ScimCreateUser)
+	scim.GET("/Users/:id", h.ScimGetUser)
+	scim.PUT("/Users/:id", h.ScimReplaceUser)
+	scim.PATCH("/Users/:id", h.ScimPatchUser)
+	scim.DELETE("/Users/:id", h.ScimDeleteUser)
+	scim.GET("/Groups", h.ScimListGroups)
+	scim.POST("/Groups", h.ScimCreateGroup)
+	scim.GET("/Groups/:id", h.ScimGetGroup)
+	scim.PUT("/Groups/:id", h.ScimReplaceGroup)
+	scim.PATCH("/Groups/:id", h.ScimPatchGroup)
+	scim.DELETE("/Groups/:id", h.ScimDeleteGroup)
+
+}
+
+// NewAdminRouter builds the router of the separate admin listener, which
+// serves the Prometheus metrics without authentication.
+func NewAdminRouter(h *controller.Handler) *gin.Engine {
+	router := gin.New()
+	router.Use(
+		middleware.TracingMiddleware(),
+		middleware.RequestIDMiddleware(),
+		middleware.RequestLogMiddleware(h.Logs.For(logging.ComponentHTTP)),
+		middleware.ErrorMiddleware(h.Log),
+		middleware.RecoveryMiddleware(h.Log),
+	)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	SetupAdminRoutes(router, h)
+	return router
+}
+
+// SetupAdminRoutes registers the API for users granted admin access.
+func SetupAdminRoutes(router *gin.Engine, h *controller.Handler) {
+	admin := router.Group("/api/v1/admin")
+	admin.Use(adminAuth(h)...)
+	admin.POST("/users/import", h.ImportUsers)
+	admin.GET("/users/import/:id", h.GetUserImport)
+	admin.GET("/users/export", h.ExportUsers)
+}
+
+// adminAuth requires the access token of a user granted admin access.
+func adminAuth(h *controller.Handler) []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.JWTAuthMiddleware(h.Store, h.Tokens, h.Logs.For(logging.ComponentAuth)),
+		middleware.AdminMiddleware(h.Store),
+	}
+}
 
+// corsRules keeps the dashboard API to the configured dashboard origins,
+// opens the login routes to the origins of registered apps as well, and
+// lets anyone read the public key, app details and the OpenAPI document.
+// SCIM is called by servers, never browsers.
+func corsRules(h *controller.Handler) []middleware.CorsRule {
+	cfg := h.Config.CORS
+	headers := []string{"Origin", "Authorization", "Content-Type"}
+	public := &middleware.CorsPolicy{
+		Origins: []string{"*"},
+		Methods: []string{"GET", "OPTIONS"},
+		Headers: headers,
+		MaxAge:  cfg.MaxAge.Duration,
+	}
+	dashboard := &middleware.CorsPolicy{
+		Origins:          cfg.AllowedOrigins,
+		AllowCredentials: true,
+		Methods:          []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		Headers:          headers,
+		MaxAge:           cfg.MaxAge.Duration,
+	}
+	login := &middleware.CorsPolicy{
+		Origins:          cfg.AllowedOrigins,
+		AllowOrigin:      h.AppOrigins.Contains,
+		AllowCredentials: true,
+		Methods:          []string{"GET", "POST", "OPTIONS"},
+		Headers:          headers,
+		MaxAge:           cfg.MaxAge.Duration,
+	}
+	return []middleware.CorsRule{
+		{Prefix: "/api/v1/key/public", Policy: public},
+		{Prefix: "/api/v1/app/get/", Policy: public},
+		{Prefix: "/api/v1/openapi.json", Policy: public},
+		{Prefix: "/.well-known/", Policy: public},
+		{Prefix: "/api/v1/app", Policy: dashboard},
+		{Prefix: "/api/v1/org", Policy: dashboard},
+		{Prefix: "/api/v1/admin", Policy: dashboard},
+		{Prefix: "/api/v1/account", Policy: dashboard},
+		{Prefix: "/api/v1/change-password", Policy: dashboard},
+		{Prefix: "/api/v1/", Policy: login},
+		{Prefix: "/scim/", Policy: nil},
+	}
 }
@@ -0,0 +1,61 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestJSONBodies(t *testing.T) {
+	ts := newTestServer(t)
+
+	rec := ts.postJSON("/api/v1/signup", "", map[string]interface{}{"email": "ada@example.com", "name": "Ada", "password": strongPassword})
+	expectStatus(t, rec, http.StatusOK)
+	user := decodeSession(t, rec)
+	if user.Email != "ada@example.com" || user.Name != "Ada" {
+		t.Fatalf("signup = %+v", user)
+	}
+
+	rec = ts.postJSON("/api/v1/app/create", user.Token, map[string]string{"name": "notes", "callback_url": "https://notes.example.com/callback"})
+	expectStatus(t, rec, http.StatusOK)
+	var app struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	decode(t, rec, &app)
+
+	rec = ts.postJSON("/api/v1/login", "", map[string]interface{}{"email": "ada@example.com", "password": strongPassword, "app_id": app.Data.Id})
+	expectStatus(t, rec, http.StatusOK)
+	session := decodeSession(t, rec)
+	if session.TokenId == 0 {
+		t.Fatalf("login for app %d without token_id", app.Data.Id)
+	}
+	rec = ts.postJSON("/api/v1/refresh", "", map[string]interface{}{"token": session.RefreshToken, "id": app.Data.Id})
+	expectStatus(t, rec, http.StatusOK)
+}
+
+func TestValidationErrors(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.signUp("owner@example.com", "Owner", 0)
+
+	rec := ts.postJSON("/api/v1/app/create", owner.Token, map[string]string{"name": "notes", "callback_url": "javascript:alert(1)"})
+	expectInvalidFields(t, rec, "callback_url")
+	var body struct {
+		Errors []struct {
+			Field   string `json:"field"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	decode(t, rec, &body)
+	if body.Errors[0].Code != "url" || body.Errors[0].Message != "callback_url must be an http(s) URL" {
+		t.Fatalf("errors = %+v", body.Errors)
+	}
+
+	// a field of the wrong type is reported by name
+	rec = ts.postJSON("/api/v1/login", "", map[string]interface{}{"email": "owner@example.com", "password": strongPassword, "app_id": "notes"})
+	expectInvalidFields(t, rec, "app_id")
+
+	rec = ts.postJSON("/api/v1/forget-password", "", map[string]string{"email": "not an email"})
+	expectInvalidFields(t, rec, "email")
+}
@@ -0,0 +1,169 @@
+package sdk
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jwksPath      = "/.well-known/jwks.json"
+	publicKeyPath = "/api/v1/key/public"
+)
+
+// keySet caches the signing keys of the server by kid. It refetches them
+// when they are older than ttl, or when a token names a kid it does not
+// know, at most once per minRefresh so forged kids cannot flood the
+// server.
+type keySet struct {
+	baseURL    string
+	http       *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// key returns the key with the kid. A token without a kid can only be
+// verified while the server has a single key, and the PEM key of a server
+// without a JWKS verifies tokens of any kid.
+func (k *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	stale := now.Sub(k.fetchedAt) >= k.ttl
+	key, known := k.lookup(kid)
+	if stale || (!known && now.Sub(k.fetchedAt) >= k.minRefresh) {
+		if err := k.refresh(ctx); err != nil {
+			// keep serving the keys we have rather than failing every request
+			if k.keys == nil {
+				return nil, err
+			}
+		} else {
+			key, known = k.lookup(kid)
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+func (k *keySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	if pem, ok := k.keys[""]; ok && len(k.keys) == 1 {
+		return pem, true
+	}
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the JWKS, falling back to the PEM public key of servers
+// that do not publish one.
+func (k *keySet) refresh(ctx context.Context) error {
+	keys, err := k.fetchJWKS(ctx)
+	if errors.Is(err, errNoJWKS) {
+		keys, err = k.fetchPEM(ctx)
+	}
+	// failures count as a fetch too, so an unreachable server is not
+	// asked again on every request
+	k.fetchedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	k.keys = keys
+	return nil
+}
+
+var errNoJWKS = errors.New("the server publishes no JWKS")
+
+func (k *keySet) fetchJWKS(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	body, status, err := k.get(ctx, jwksPath)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, errNoJWKS
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetching the JWKS: status %d", status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("decoding the JWKS: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %q: %w", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("the JWKS has no RSA signing keys")
+	}
+	return keys, nil
+}
+
+func (k *keySet) fetchPEM(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	body, status, err := k.get(ctx, publicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetching the public key: status %d", status)
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the public key: %w", err)
+	}
+	return map[string]*rsa.PublicKey{"": key}, nil
+}
+
+func (k *keySet) get(ctx context.Context, path string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.baseURL+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return body, resp.StatusCode, err
+}
@@ -0,0 +1,94 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type claimsKey struct{}
+
+// NewContext returns ctx carrying the claims of a verified token.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the claims the middleware verified for the request.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// Middleware lets requests with a valid bearer access token granting
+// scopes through to next, with the claims on the request context. Others
+// are answered as RFC 6750 describes.
+func (v *Verifier) Middleware(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, status, challenge, err := v.authenticate(r, scopes)
+			if err != nil {
+				writeRejection(w, status, challenge, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+		})
+	}
+}
+
+// Gin is Middleware for Gin routers.
+func (v *Verifier) Gin(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, status, challenge, err := v.authenticate(c.Request, scopes)
+		if err != nil {
+			writeRejection(c.Writer, status, challenge, err)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// rejection is the OAuth error of a refused request.
+type rejection struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// authenticate verifies the bearer token of r. On failure it returns the
+// status, the WWW-Authenticate challenge and the error to answer with.
+func (v *Verifier) authenticate(r *http.Request, scopes []string) (*Claims, int, string, *rejection) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		// no error code when the request carried no credentials at all
+		return nil, http.StatusUnauthorized, `Bearer`, &rejection{Error: "invalid_request", Description: "A bearer token is required"}
+	}
+
+	claims, err := v.Verify(r.Context(), token, scopes...)
+	switch {
+	case err == nil:
+		return claims, 0, "", nil
+	case errors.Is(err, ErrInsufficientScope):
+		scope := strings.Join(scopes, " ")
+		return nil, http.StatusForbidden, fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope),
+			&rejection{Error: "insufficient_scope", Description: "The token does not grant " + scope}
+	case errors.Is(err, ErrInvalidToken):
+		return nil, http.StatusUnauthorized, `Bearer error="invalid_token"`, &rejection{Error: "invalid_token", Description: "The token is invalid or expired"}
+	}
+	return nil, http.StatusServiceUnavailable, "", &rejection{Error: "temporarily_unavailable", Description: "The signing keys could not be fetched"}
+}
+
+func writeRejection(w http.ResponseWriter, status int, challenge string, body *rejection) {
+	if challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,224 @@
+package sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyServer publishes the keys of a fake SSO server and counts the
+// fetches.
+type keyServer struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	noJWKS  bool
+	fetches int
+}
+
+func newKeyServer(t *testing.T, kids ...string) (*keyServer, *httptest.Server) {
+	t.Helper()
+	ks := &keyServer{keys: map[string]*rsa.PrivateKey{}}
+	for _, kid := range kids {
+		ks.add(t, kid)
+	}
+	server := httptest.NewServer(http.HandlerFunc(ks.serve))
+	t.Cleanup(server.Close)
+	return ks, server
+}
+
+func (ks *keyServer) add(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = key
+}
+
+func (ks *keyServer) serve(w http.ResponseWriter, r *http.Request) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.fetches++
+	switch {
+	case r.URL.Path == jwksPath && !ks.noJWKS:
+		keys := []map[string]string{}
+		for kid, key := range ks.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "use": "sig", "kid": kid,
+				"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	case r.URL.Path == publicKeyPath:
+		for _, key := range ks.keys {
+			der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			w.Write(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// sign issues an access token with the key of kid; typ is left out when
+// empty.
+func (ks *keyServer) sign(t *testing.T, kid, typ string, claims Claims) string {
+	t.Helper()
+	ks.mu.Lock()
+	key := ks.keys[kid]
+	ks.mu.Unlock()
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	}
+	if claims.Audience == nil {
+		claims.Audience = jwt.ClaimStrings{"notes"}
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyRefetchesKeysForUnknownKid(t *testing.T) {
+	ks, server := newKeyServer(t, "one")
+	v := NewVerifier(Options{BaseURL: server.URL, Audience: "notes"})
+	ctx := context.Background()
+
+	claims, err := v.Verify(ctx, ks.sign(t, "one", "at+jwt", Claims{UserId: 7, Email: "ada@example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserId != 7 || claims.Email != "ada@example.com" {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// a rotated key is picked up on first sight of its kid
+	v.keys.fetchedAt = v.keys.fetchedAt.Add(-time.Minute)
+	ks.add(t, "two")
+	if _, err := v.Verify(ctx, ks.sign(t, "two", "at+jwt", Claims{UserId: 7})); err != nil {
+		t.Fatal(err)
+	}
+	if ks.fetches != 2 {
+		t.Fatalf("fetches = %d, want 2", ks.fetches)
+	}
+
+	// but unknown kids do not refetch again within MinKeyRefresh
+	ks.add(t, "three")
+	if _, err := v.Verify(ctx, ks.sign(t, "three", "at+jwt", Claims{UserId: 7})); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of an unknown kid = %v", err)
+	}
+	if ks.fetches != 2 {
+		t.Fatalf("fetches = %d, want 2", ks.fetches)
+	}
+}
+
+func TestVerifyFallsBackToPEM(t *testing.T) {
+	ks, server := newKeyServer(t, "only")
+	ks.noJWKS = true
+	v := NewVerifier(Options{BaseURL: server.URL, Audience: "notes"})
+	if _, err := v.Verify(context.Background(), ks.sign(t, "only", "at+jwt", Claims{UserId: 1})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	ks, server := newKeyServer(t, "one")
+	v := NewVerifier(Options{BaseURL: server.URL, Issuer: "https://auth.example.com", Audience: "notes"})
+	valid := Claims{UserId: 1, Scope: "notes:read", RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:   "https://auth.example.com",
+		Audience: jwt.ClaimStrings{"notes"},
+	}}
+	ctx := context.Background()
+	if _, err := v.Verify(ctx, ks.sign(t, "one", "at+jwt", valid), "notes:read"); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	otherIssuer := valid
+	otherIssuer.Issuer = "https://evil.example.com"
+	otherAudience := valid
+	otherAudience.Audience = jwt.ClaimStrings{"billing"}
+	noAudience := valid
+	noAudience.Audience = jwt.ClaimStrings{}
+	cases := map[string]string{
+		"expired":        ks.sign(t, "one", "at+jwt", expired),
+		"other issuer":   ks.sign(t, "one", "at+jwt", otherIssuer),
+		"other audience": ks.sign(t, "one", "at+jwt", otherAudience),
+		"no audience":    ks.sign(t, "one", "at+jwt", noAudience),
+		"refresh token":  ks.sign(t, "one", "", valid),
+		"garbage":        "not-a-jwt",
+	}
+	for name, token := range cases {
+		if _, err := v.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+	if _, err := v.Verify(ctx, ks.sign(t, "one", "at+jwt", valid), "notes:write"); !errors.Is(err, ErrInsufficientScope) {
+		t.Fatalf("missing scope: err = %v", err)
+	}
+}
+
+func TestNewVerifierRequiresAudience(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a verifier without an audience was created")
+		}
+	}()
+	NewVerifier(Options{BaseURL: "https://auth.example.com"})
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ks, server := newKeyServer(t, "one")
+	v := NewVerifier(Options{BaseURL: server.URL, Audience: "notes"})
+	router := gin.New()
+	router.GET("/notes", v.Gin("notes:read"), func(c *gin.Context) {
+		claims, _ := FromContext(c.Request.Context())
+		c.String(200, claims.Email)
+	})
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/notes", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("without token: %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	rec = get(ks.sign(t, "one", "at+jwt", Claims{Email: "ada@example.com"}))
+	if rec.Code != http.StatusForbidden || rec.Header().Get("WWW-Authenticate") != `Bearer error="insufficient_scope", scope="notes:read"` {
+		t.Fatalf("without scope: %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	rec = get(ks.sign(t, "one", "at+jwt", Claims{Email: "ada@example.com", Scope: "notes:read notes:write"}))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ada@example.com" {
+		t.Fatalf("with scope: %d %s", rec.Code, rec.Body.String())
+	}
+}
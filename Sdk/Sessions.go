@@ -0,0 +1,46 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+
+	client "go_server/Client"
+	models "go_server/Models"
+)
+
+// Sessions completes the logins of an app and keeps their tokens fresh.
+// After a login for the app the server redirects to its callback URL with
+// a token_id, which Exchange turns into the tokens.
+type Sessions struct {
+	AppId  int
+	client *client.Client
+}
+
+// NewSessions returns Sessions of the app calling the server at
+// opts.BaseURL.
+func NewSessions(opts Options, appId int) *Sessions {
+	c := client.New(opts.baseURL())
+	c.HTTP = opts.httpClient()
+	return &Sessions{AppId: appId, client: c}
+}
+
+// Exchange returns the tokens of the login with tokenId. A login for
+// another app is refused, so a token_id planted in the callback by someone
+// else cannot log the user in as them.
+func (s *Sessions) Exchange(ctx context.Context, tokenId int) (models.TokenPair, error) {
+	token, err := s.client.GetToken(ctx, tokenId)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if token.AppId != s.AppId {
+		return models.TokenPair{}, fmt.Errorf("token %d was issued for app %d, not %d", tokenId, token.AppId, s.AppId)
+	}
+	return models.TokenPair{Token: token.Token, RefreshToken: token.RefreshToken}, nil
+}
+
+// Refresh rotates a refresh token of the app. The one passed in stops
+// working; errors from the server are *client.Error with an OAuth code
+// such as invalid_grant.
+func (s *Sessions) Refresh(ctx context.Context, refreshToken string) (models.TokenSet, error) {
+	return s.client.Refresh(ctx, models.RefreshRequest{Token: refreshToken, Id: s.AppId})
+}
@@ -0,0 +1,190 @@
+// Package sdk lets relying parties trust the tokens of the SSO server:
+// a Verifier checks access tokens against the server's published keys,
+// its middleware guards net/http and Gin handlers, and Sessions completes
+// app logins and refreshes their tokens.
+//
+//	verifier := sdk.NewVerifier(sdk.Options{BaseURL: "https://auth.example.com", Issuer: "https://auth.example.com", Audience: "17"})
+//	router.Use(verifier.Gin("notes:read"))
+//	...
+//	claims, _ := sdk.FromContext(c.Request.Context())
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// accessTokenTypes are the typ headers of access tokens; refresh tokens
+// carry none and are refused.
+var accessTokenTypes = []string{"at+jwt", "application/at+jwt"}
+
+var (
+	// ErrInvalidToken is wrapped by the errors of tokens that are
+	// malformed, expired, not signed by the server or not meant for
+	// this service.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrInsufficientScope is returned for a valid token that lacks a
+	// required scope.
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// Options configures a Verifier and Sessions.
+type Options struct {
+	// BaseURL is where the SSO server is served, such as
+	// https://auth.example.com.
+	BaseURL string
+	// Issuer, when set, must be the iss claim of tokens; it is the
+	// server's JWT_ISSUER.
+	Issuer string
+	// Audience is the id of this service's app and must be listed in
+	// the aud claim of tokens. The server sets it to the app a user
+	// logged in to, the audience of an exchanged token and the app a
+	// client credentials token was asked for, so tokens of other apps
+	// and of the dashboard are refused. A Verifier requires it; Sessions ignore it.
+	Audience string
+	// HTTP defaults to http.DefaultClient.
+	HTTP *http.Client
+	// Leeway tolerates clock skew when checking expiry, 30s by default.
+	Leeway time.Duration
+	// KeyCacheTTL is how long keys are used before they are fetched
+	// again, an hour by default.
+	KeyCacheTTL time.Duration
+	// MinKeyRefresh spaces the fetches caused by unknown kids, 30s by
+	// default.
+	MinKeyRefresh time.Duration
+}
+
+func (o Options) httpClient() *http.Client {
+	if o.HTTP != nil {
+		return o.HTTP
+	}
+	return http.DefaultClient
+}
+
+func (o Options) baseURL() string {
+	return strings.TrimSuffix(o.BaseURL, "/")
+}
+
+// Claims are the claims of an access token.
+type Claims struct {
+	// UserId is the user the token was issued to, 0 in the token of an
+	// app acting for itself.
+	UserId int    `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	// AppId is the app the user logged in to, 0 for the dashboard.
+	AppId int `json:"app_id,omitempty"`
+	// ClientId is set instead of UserId in tokens an app obtained for
+	// itself with the client credentials grant; it is also the subject.
+	ClientId string `json:"client_id,omitempty"`
+	// AuthTime is when the user last entered credentials.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// Scope lists the granted scopes, separated by spaces.
+	Scope string `json:"scope,omitempty"`
+	// Act is set in tokens another app obtained for the user with the
+	// token exchange; the audience is then this service's app.
+	Act *Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Actor is the app that exchanged a token (RFC 8693 section 4.1). Act
+// names the previous one when the app was itself called with an exchanged
+// token.
+type Actor struct {
+	Subject string `json:"sub"`
+	Act     *Actor `json:"act,omitempty"`
+}
+
+// Scopes returns the granted scopes.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether scope was granted.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// Verifier checks access tokens issued by the SSO server.
+type Verifier struct {
+	opts Options
+	keys *keySet
+}
+
+// NewVerifier returns a Verifier fetching keys from opts.BaseURL on first
+// use. It panics without opts.Audience, which would let the tokens of
+// every app through.
+func NewVerifier(opts Options) *Verifier {
+	if opts.Audience == "" {
+		panic("sdk: Options.Audience is required to verify tokens")
+	}
+	if opts.Leeway == 0 {
+		opts.Leeway = 30 * time.Second
+	}
+	if opts.KeyCacheTTL == 0 {
+		opts.KeyCacheTTL = time.Hour
+	}
+	if opts.MinKeyRefresh == 0 {
+		opts.MinKeyRefresh = 30 * time.Second
+	}
+	return &Verifier{
+		opts: opts,
+		keys: &keySet{
+			baseURL:    opts.baseURL(),
+			http:       opts.httpClient(),
+			ttl:        opts.KeyCacheTTL,
+			minRefresh: opts.MinKeyRefresh,
+		},
+	}
+}
+
+// Verify checks the signature, type, issuer, audience and expiry of an
+// access token and that it grants every one of scopes. Errors about the
+// token wrap ErrInvalidToken or ErrInsufficientScope; any other error
+// means the keys could not be fetched.
+func (v *Verifier) Verify(ctx context.Context, token string, scopes ...string) (*Claims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.opts.Leeway),
+		jwt.WithAudience(v.opts.Audience),
+	}
+	if v.opts.Issuer != "" {
+		options = append(options, jwt.WithIssuer(v.opts.Issuer))
+	}
+
+	var keyErr error
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		typ, _ := token.Header["typ"].(string)
+		if !slices.Contains(accessTokenTypes, strings.ToLower(typ)) {
+			return nil, fmt.Errorf("not an access token")
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, err := v.keys.key(ctx, kid)
+		if err != nil && !errors.Is(err, ErrInvalidToken) {
+			keyErr = err
+		}
+		return key, err
+	}, options...)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	for _, scope := range scopes {
+		if !claims.HasScope(scope) {
+			return nil, fmt.Errorf("%w: %s is required", ErrInsufficientScope, scope)
+		}
+	}
+	return claims, nil
+}
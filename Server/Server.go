@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	config "go_server/Config"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// New builds the public HTTP server. certs is nil when TLS is disabled.
+func New(cfg config.ServerConfig, handler http.Handler, certs *Certificates) *http.Server {
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if certs != nil {
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+	return srv
+}
+
+// NewAdmin builds the server for the admin listener. With a client CA file
+// it requires and verifies client certificates.
+func NewAdmin(cfg config.ServerConfig, handler http.Handler, certs *Certificates) (*http.Server, error) {
+	srv := New(cfg, handler, certs)
+	srv.Addr = ":" + cfg.Admin.Port
+	if cfg.Admin.ClientCAFile != "" {
+		pool, err := loadCertPool(cfg.Admin.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig.ClientCAs = pool
+		srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return srv, nil
+}
+
+// Serve listens on srv.Addr, over TLS when srv.TLSConfig is set, until the
+// server is shut down. A shutdown is not reported as an error.
+func Serve(srv *http.Server) error {
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return serve(srv, listener)
+}
+
+func serve(srv *http.Server, listener net.Listener) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ServeTLS(listener, "", "")
+	} else {
+		err = srv.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// ReloadOnHangup reloads certs whenever the process receives SIGHUP, until
+// ctx is cancelled.
+func ReloadOnHangup(ctx context.Context, log *slog.Logger, certs *Certificates) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				if err := certs.Reload(); err != nil {
+					log.Error("reloading the TLS certificate", "err", err)
+				} else {
+					log.Info("reloaded the TLS certificate")
+				}
+			}
+		}
+	}()
+}
+
+// Run serves every server until ctx is cancelled or one of them fails,
+// then shuts them all down, giving in-flight requests up to timeout to
+// finish.
+func Run(ctx context.Context, log *slog.Logger, timeout time.Duration, servers ...*http.Server) error {
+	for _, srv := range servers {
+		log.Info("listening", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+	}
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			errs <- Serve(srv)
+		}(srv)
+	}
+
+	var failed error
+	select {
+	case <-ctx.Done():
+		log.Info("shutting down, draining requests", "timeout", timeout.String())
+	case failed = <-errs:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error("shutting down", "addr", srv.Addr, "err", err)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	config "go_server/Config"
+)
+
+// testCert is a certificate with its key, signed by parent or self-signed.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write stores the certificate and key as PEM files in dir.
+func (c *testCert) write(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", c.der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificatesReload(t *testing.T) {
+	dir := t.TempDir()
+	first := newTestCert(t, "first", nil, false)
+	certFile, keyFile := first.write(t, dir)
+	certs, err := NewCertificates(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := newTestCert(t, "second", nil, false)
+	second.write(t, dir)
+	if err := certs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	served, _ := certs.GetCertificate(nil)
+	if string(served.Certificate[0]) != string(second.der) {
+		t.Fatal("reload did not pick up the new certificate")
+	}
+
+	// a broken renewal keeps serving the last good certificate
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := certs.Reload(); err == nil {
+		t.Fatal("reloading a broken certificate succeeded")
+	}
+	served, _ = certs.GetCertificate(nil)
+	if string(served.Certificate[0]) != string(second.der) {
+		t.Fatal("failed reload replaced the certificate")
+	}
+}
+
+func TestAdminRequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, true)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.der)
+	certFile, keyFile := newTestCert(t, "server", ca, false).write(t, dir)
+	client := newTestCert(t, "client", ca, false)
+	stranger := newTestCert(t, "stranger", nil, false)
+
+	certs, err := NewCertificates(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default().Server
+	cfg.Admin = config.AdminConfig{Port: "9443", ClientCAFile: filepath.Join(dir, "ca.pem")}
+	srv, err := NewAdmin(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serve(srv, listener)
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(clientCerts ...tls.Certificate) error {
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: clientCerts,
+		}}}
+		resp, err := httpClient.Get("https://" + listener.Addr().String() + "/")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if err := get(); err == nil {
+		t.Fatal("request without a client certificate succeeded")
+	}
+	if err := get(stranger.tlsCertificate()); err == nil {
+		t.Fatal("request with an unknown client certificate succeeded")
+	}
+	if err := get(client.tlsCertificate()); err != nil {
+		t.Fatalf("request with a valid client certificate failed: %v", err)
+	}
+}
+
+func TestRunShutsDownWhenCancelled(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.Port = "0"
+	srv := New(cfg, http.NotFoundHandler(), nil)
+	srv.Addr = "127.0.0.1:0"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second, srv)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
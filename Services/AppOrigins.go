@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	database "go_server/Database"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AppOrigins is the set of browser origins the registered apps live on,
+// derived from their callback URLs. It is loaded from the store at most
+// once per TTL, and Invalidate forces a reload after an app changes.
+type AppOrigins struct {
+	store database.AppStore
+	ttl   time.Duration
+	log   *slog.Logger
+
+	mu       sync.Mutex
+	origins  map[string]bool
+	loadedAt time.Time
+}
+
+func NewAppOrigins(store database.AppStore, ttl time.Duration, log *slog.Logger) *AppOrigins {
+	return &AppOrigins{store: store, ttl: ttl, log: log}
+}
+
+// Contains reports whether origin belongs to a registered app. If the
+// store cannot be read the last known set is used.
+func (a *AppOrigins) Contains(origin string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.origins == nil || time.Since(a.loadedAt) > a.ttl {
+		a.reload()
+	}
+	return a.origins[origin]
+}
+
+// Invalidate drops the cached set so the next lookup reloads it.
+func (a *AppOrigins) Invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.origins = nil
+}
+
+func (a *AppOrigins) reload() {
+	// the set is shared by all requests, so it is not bound to the
+	// context of the one that happened to trigger the reload
+	urls, err := a.store.GetAppCallbackUrls(context.Background())
+	if err != nil {
+		a.log.Error("loading app origins", "err", err)
+		if a.origins == nil {
+			return
+		}
+		// retry after the next TTL instead of on every request
+		a.loadedAt = time.Now()
+		return
+	}
+	origins := map[string]bool{}
+	for _, raw := range urls {
+		if origin, ok := OriginOf(raw); ok {
+			origins[origin] = true
+		}
+	}
+	a.origins = origins
+	a.loadedAt = time.Now()
+}
+
+// OriginOf returns the scheme://host[:port] origin of an http(s) URL, the
+// form browsers send in the Origin header.
+func OriginOf(raw string) (string, bool) {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", false
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host), true
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// retryBackoff returns the delay before the given (1-based) retry of a
+// background job, growing exponentially from base up to max with up to 20%
+// jitter so failing jobs do not retry in lockstep.
+func retryBackoff(base, max time.Duration, attempt int) time.Duration {
+	delay := time.Duration(float64(base) * math.Pow(2, float64(attempt-1)))
+	if delay > max || delay <= 0 {
+		delay = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	return delay + jitter
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BreachedPasswords screens passwords against a local copy of a breached
+// password corpus in the k-anonymity range format: the upper-case SHA-1 of
+// a password is split into a 5 character prefix and a 35 character suffix,
+// and each range lists "SUFFIX:COUNT" lines. Two layouts are supported:
+//
+//   - a directory with one file per prefix, named "<PREFIX>" or
+//     "<PREFIX>.txt", as written by the range downloader. Only the range of
+//     the password being checked is read, so the full corpus can be used.
+//   - a single file of "<FULL SHA-1>:COUNT" lines, loaded into memory. This
+//     suits small, curated lists.
+//
+// The zero value (no path) accepts every password. A list that cannot be
+// loaded is tried again on the next check, so a mount that appears late
+// does not need a restart.
+type BreachedPasswords struct {
+	Path     string
+	MinCount int
+
+	mu     sync.Mutex
+	loaded bool
+	isDir  bool
+	hashes map[string]int
+}
+
+// load reads the list on first use. Once it succeeds isDir and hashes no
+// longer change.
+func (b *BreachedPasswords) load() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.loaded {
+		return nil
+	}
+	info, err := os.Stat(b.Path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		b.isDir, b.loaded = true, true
+		return nil
+	}
+
+	hashes := map[string]int{}
+	err = scanRange(b.Path, "", func(hash string, count int) {
+		hashes[hash] = count
+	})
+	if err != nil {
+		return err
+	}
+	b.hashes, b.loaded = hashes, true
+	return nil
+}
+
+// scanRange reads "HASH:COUNT" lines, prefixing each hash with prefix. A
+// missing count is treated as 1 so plain hash lists work too.
+func scanRange(path, prefix string, fn func(hash string, count int)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, rawCount, found := strings.Cut(line, ":")
+		count := 1
+		if found {
+			if parsed, err := strconv.Atoi(strings.TrimSpace(rawCount)); err == nil {
+				count = parsed
+			}
+		}
+		fn(prefix+strings.ToUpper(hash), count)
+	}
+	return scanner.Err()
+}
+
+// Contains reports whether the password appears in the corpus at least
+// MinCount times.
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	if b == nil || b.Path == "" {
+		return false, nil
+	}
+	if err := b.load(); err != nil {
+		return false, err
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	minCount := b.MinCount
+	if minCount < 1 {
+		minCount = 1
+	}
+
+	if !b.isDir {
+		return b.hashes[hash] >= minCount, nil
+	}
+
+	prefix := hash[:5]
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		count := 0
+		err := scanRange(filepath.Join(b.Path, name), prefix, func(candidate string, n int) {
+			if candidate == hash {
+				count = n
+			}
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return count >= minCount, nil
+	}
+	// no range file means no breached password shares the prefix
+	return false, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// rangeLine is the line of a single file list: the full hash and a count.
+func rangeLine(password string, count int) string {
+	return sha1Hex(password) + ":" + strconv.Itoa(count)
+}
+
+func writeHashes(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedPasswordsSingleFile(t *testing.T) {
+	path := writeHashes(t, t.TempDir(), "breached.txt",
+		"# curated list",
+		"",
+		rangeLine("password1", 24230577),
+		rangeLine("Correct-Horse-9", 2),
+		// lower case hashes and missing counts are accepted
+		strings.ToLower(sha1Hex("letmein")),
+	)
+
+	cases := []struct {
+		name     string
+		minCount int
+		password string
+		want     bool
+	}{
+		{"listed", 1, "password1", true},
+		{"not listed", 1, "Correct-Horse-9-Battery", false},
+		{"lower case hash without count", 1, "letmein", true},
+		{"zero min count means one", 0, "letmein", true},
+		{"below min count", 3, "Correct-Horse-9", false},
+		{"at min count", 2, "Correct-Horse-9", true},
+		{"without count below min count", 2, "letmein", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			breached := &BreachedPasswords{Path: path, MinCount: tc.minCount}
+			got, err := breached.Contains(tc.password)
+			if err != nil || got != tc.want {
+				t.Fatalf("Contains(%q) = %v, %v, want %v", tc.password, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestBreachedPasswordsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// each range file is named after the prefix and lists the suffixes
+	suffixLine := func(password string, count int) string {
+		return sha1Hex(password)[5:] + ":" + strconv.Itoa(count)
+	}
+	writeHashes(t, dir, sha1Hex("password1")[:5], suffixLine("password1", 24230577))
+	writeHashes(t, dir, sha1Hex("Correct-Horse-9")[:5]+".txt", suffixLine("Correct-Horse-9", 2))
+	writeHashes(t, dir, strings.ToLower(sha1Hex("letmein")[:5]), suffixLine("letmein", 1))
+
+	cases := []struct {
+		name     string
+		minCount int
+		password string
+		want     bool
+	}{
+		{"range without extension", 1, "password1", true},
+		{"range with extension", 1, "Correct-Horse-9", true},
+		{"lower case range", 1, "letmein", true},
+		{"no range file", 1, "Correct-Horse-9-Battery", false},
+		{"below min count", 3, "Correct-Horse-9", false},
+		{"at min count", 2, "Correct-Horse-9", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			breached := &BreachedPasswords{Path: dir, MinCount: tc.minCount}
+			got, err := breached.Contains(tc.password)
+			if err != nil || got != tc.want {
+				t.Fatalf("Contains(%q) = %v, %v, want %v", tc.password, got, err, tc.want)
+			}
+		})
+	}
+
+	// only the full hash counts, not a password sharing the prefix
+	writeHashes(t, dir, sha1Hex("Correct-Horse-9-Battery")[:5], strings.Repeat("0", 35)+":5")
+	if got, err := (&BreachedPasswords{Path: dir}).Contains("Correct-Horse-9-Battery"); err != nil || got {
+		t.Fatalf("Contains with another suffix = %v, %v", got, err)
+	}
+}
+
+func TestBreachedPasswordsFailures(t *testing.T) {
+	dir := t.TempDir()
+
+	// without a path every password is accepted
+	for _, breached := range []*BreachedPasswords{nil, {}} {
+		if got, err := breached.Contains("password1"); err != nil || got {
+			t.Fatalf("Contains without a list = %v, %v", got, err)
+		}
+	}
+
+	missing := &BreachedPasswords{Path: filepath.Join(dir, "missing.txt")}
+	if _, err := missing.Contains("password1"); err == nil {
+		t.Fatal("a missing list was read")
+	}
+	// the failure is not kept: the list is read once it appears
+	writeHashes(t, dir, "missing.txt", rangeLine("password1", 1))
+	if got, err := missing.Contains("password1"); err != nil || !got {
+		t.Fatalf("Contains after the list appeared = %v, %v", got, err)
+	}
+
+	// a range that cannot be read is an error, not a pass
+	ranges := t.TempDir()
+	if err := os.Mkdir(filepath.Join(ranges, sha1Hex("password1")[:5]), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&BreachedPasswords{Path: ranges}).Contains("password1"); err == nil {
+		t.Fatal("an unreadable range was accepted")
+	}
+}
@@ -1,42 +1,152 @@
 package services
 
 import (
-	"fmt"
-	"net/smtp"
-	"os"
+	"context"
+	database "go_server/Database"
+	metrics "go_server/Metrics"
+	tracing "go_server/Tracing"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func SendForgetPasswordEmail(to string, link string) error {
-	// SMTP server configuration
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
+var tracer = otel.Tracer("go_server/Services")
+
+const (
+	emailMaxAttempts  = 10
+	emailBaseBackoff  = time.Minute
+	emailMaxBackoff   = time.Hour
+	emailPollInterval = 2 * time.Second
+	emailBatchSize    = 20
+	emailLease        = 2 * time.Minute
+	emailSendTimeout  = 30 * time.Second
+	// sent and dead emails are deleted once they are older than
+	// emailRetention; the worker checks every emailPurgeInterval
+	emailRetention     = 7 * 24 * time.Hour
+	emailPurgeInterval = time.Hour
+)
 
-	// Sender data
-	from := os.Getenv("EMAIL_FROM")
-	password := os.Getenv("EMAIL_PASSWORD")
+// QueueEmail renders a template and stores the result in the outbox. The
+// email worker sends it, so the caller never waits on SMTP and queued mail
+// survives restarts.
+func QueueEmail(ctx context.Context, store database.OutboxStore, template, locale, to string, brand EmailBranding, data map[string]interface{}) error {
+	msg, err := RenderEmail(template, locale, to, brand, data)
+	if err != nil {
+		return err
+	}
+	_, err = store.InsertOutboxEmail(ctx, msg.To, msg.Subject, msg.TextBody, msg.HTMLBody)
+	return err
+}
 
-	// Email content
-	subject := "Subject: Forget password link\r\n"
-	mime := "MIME-version: 1.0;\nContent-Type: text/plain; charset=\"UTF-8\";\r\n"
-	body := fmt.Sprintf("Click <a href=\"%s\">here</a> to reset your password\r\n", link)
+func SendForgetPasswordEmail(ctx context.Context, store database.OutboxStore, to, link, locale string, brand EmailBranding, expiresIn time.Duration) error {
+	return QueueEmail(ctx, store, "reset_password", locale, to, brand, map[string]interface{}{
+		"Link":             link,
+		"ExpiresInMinutes": int(expiresIn.Minutes()),
+	})
+}
 
-	// Combine email parts
-	message := []byte(subject + mime + "\r\n" + body)
+// SendMagicLinkEmail sends a single-use passwordless sign-in link.
+func SendMagicLinkEmail(ctx context.Context, store database.OutboxStore, to, link, locale string, brand EmailBranding, expiresIn time.Duration) error {
+	return QueueEmail(ctx, store, "magic_link", locale, to, brand, map[string]interface{}{
+		"Link":             link,
+		"ExpiresInMinutes": int(expiresIn.Minutes()),
+	})
+}
 
-	// Authentication
-	auth := smtp.PlainAuth("", from, password, smtpHost)
+// SendPasswordChangedEmail notifies the account owner that their password
+// was changed, whether through a reset or from their settings.
+func SendPasswordChangedEmail(ctx context.Context, store database.OutboxStore, to, locale string, brand EmailBranding) error {
+	return QueueEmail(ctx, store, "password_changed", locale, to, brand, map[string]interface{}{
+		"ChangedAt": time.Now().UTC().Format("2006-01-02 15:04 UTC"),
+	})
+}
 
-	// Send email
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, message)
+func sendOutboxEmail(ctx context.Context, log *slog.Logger, store database.OutboxStore, mailer Mailer, id int64, attempts int, msg EmailMessage) {
+	sendCtx, cancel := context.WithTimeout(ctx, emailSendTimeout)
+	defer cancel()
+
+	attempts++
+	sendCtx, span := tracer.Start(sendCtx, "email.send", trace.WithAttributes(
+		attribute.Int64("email.id", id),
+		attribute.Int("email.attempt", attempts),
+	))
+	err := mailer.Send(sendCtx, msg)
+	tracing.End(span, err)
+	if err == nil {
+		metrics.EmailSends.Inc("sent")
+		if err := store.MarkOutboxEmailSent(ctx, id, attempts); err != nil {
+			log.Error("recording sent email", "email_id", id, "err", err)
+		}
+		return
+	}
+
+	log.Warn("sending email failed", "email_id", id, "attempt", attempts, "to", msg.To, "err", err)
+	dead := attempts >= emailMaxAttempts
+	if dead {
+		metrics.EmailSends.Inc("dead")
+	} else {
+		metrics.EmailSends.Inc("retry")
+	}
+	err = store.MarkOutboxEmailFailed(ctx, id, attempts, err.Error(),
+		time.Now().Add(retryBackoff(emailBaseBackoff, emailMaxBackoff, attempts)), dead)
 	if err != nil {
-		fmt.Println("Error sending email:", err)
-		return err
+		log.Error("recording failed email", "email_id", id, "err", err)
 	}
+}
 
-	fmt.Println("Email sent successfully!")
-	return nil
+// RunEmailWorkerOnce sends one batch of due outbox emails and returns how
+// many were processed.
+func RunEmailWorkerOnce(ctx context.Context, log *slog.Logger, store database.OutboxStore, mailer Mailer) (int, error) {
+	emails, err := store.ClaimDueOutboxEmails(ctx, emailBatchSize, emailLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, email := range emails {
+		sendOutboxEmail(ctx, log, store, mailer, email.ID, email.Attempts, EmailMessage{
+			To:       email.Recipient,
+			Subject:  email.Subject,
+			TextBody: email.TextBody,
+			HTMLBody: email.HTMLBody,
+		})
+	}
+	return len(emails), nil
 }
 
-func SendResetPasswordEmail(to string, code string) {
-	// TODO: Send email
+// StartEmailWorker sends queued emails in the background until ctx is
+// cancelled, and purges the old sent and dead ones. The returned channel
+// is closed once the worker has stopped.
+func StartEmailWorker(ctx context.Context, log *slog.Logger, store database.OutboxStore, mailer Mailer) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(emailPollInterval)
+		defer ticker.Stop()
+		var purgedAt time.Time
+		for {
+			if time.Since(purgedAt) >= emailPurgeInterval {
+				purgedAt = time.Now()
+				if _, err := store.PurgeOutboxEmails(ctx, purgedAt.Add(-emailRetention)); err != nil {
+					log.Error("purging the email outbox", "err", err)
+				}
+			}
+			for {
+				processed, err := RunEmailWorkerOnce(ctx, log, store, mailer)
+				if err != nil {
+					log.Error("processing the email outbox", "err", err)
+				}
+				if err != nil || processed < emailBatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
 }
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var emailTemplateFS embed.FS
+
+const defaultEmailLocale = "en"
+
+// EmailBranding customises the look of emails sent on behalf of an app.
+type EmailBranding struct {
+	AppName string
+	LogoUrl string
+	Color   string
+}
+
+// DefaultBranding is used for emails that are not tied to an app.
+func DefaultBranding(appName string) EmailBranding {
+	if appName == "" {
+		appName = "GoAuth SSO"
+	}
+	return EmailBranding{AppName: appName, Color: "#4f46e5"}
+}
+
+// ForApp overrides the branding with the app's settings.
+func (b EmailBranding) ForApp(name, logoUrl, color string) EmailBranding {
+	if name != "" {
+		b.AppName = name
+	}
+	if logoUrl != "" {
+		b.LogoUrl = logoUrl
+	}
+	if color != "" {
+		b.Color = color
+	}
+	return b
+}
+
+type compiledEmailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var emailTemplateCache sync.Map
+
+var emailTemplateFuncs = htmltemplate.FuncMap{
+	"button": func(link, label string, brand EmailBranding) map[string]interface{} {
+		return map[string]interface{}{"Link": link, "Label": label, "Brand": brand}
+	},
+}
+
+// EmailLocales lists the locales that have templates, sorted.
+func EmailLocales() []string {
+	entries, err := fs.ReadDir(emailTemplateFS, "templates")
+	if err != nil {
+		return []string{defaultEmailLocale}
+	}
+	var locales []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			locales = append(locales, entry.Name())
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// ResolveEmailLocale picks the best supported locale from an
+// Accept-Language style list such as "es-MX,es;q=0.9,en;q=0.8". Quality
+// values are not weighed; the list order is trusted.
+func ResolveEmailLocale(acceptLanguage string) string {
+	supported := map[string]bool{}
+	for _, locale := range EmailLocales() {
+		supported[locale] = true
+	}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.Split(part, ";")[0]))
+		if supported[tag] {
+			return tag
+		}
+		if base, _, found := strings.Cut(tag, "-"); found && supported[base] {
+			return base
+		}
+	}
+	return defaultEmailLocale
+}
+
+func loadEmailTemplate(name, locale string) (*compiledEmailTemplate, error) {
+	key := locale + "/" + name
+	if cached, ok := emailTemplateCache.Load(key); ok {
+		return cached.(*compiledEmailTemplate), nil
+	}
+
+	text, err := texttemplate.ParseFS(emailTemplateFS, "templates/"+key+".txt")
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New("layout.html").Funcs(emailTemplateFuncs).
+		ParseFS(emailTemplateFS, "templates/layout.html", "templates/"+key+".html")
+	if err != nil {
+		return nil, err
+	}
+	compiled := &compiledEmailTemplate{text: text, html: html}
+	emailTemplateCache.Store(key, compiled)
+	return compiled, nil
+}
+
+// RenderEmail renders the named template in the requested locale, falling
+// back to English when the locale has no translation of it.
+func RenderEmail(name, locale, to string, brand EmailBranding, data map[string]interface{}) (EmailMessage, error) {
+	tmpl, err := loadEmailTemplate(name, locale)
+	if err != nil && locale != defaultEmailLocale {
+		locale = defaultEmailLocale
+		tmpl, err = loadEmailTemplate(name, locale)
+	}
+	if err != nil {
+		return EmailMessage{}, fmt.Errorf("loading email template %s: %w", name, err)
+	}
+
+	values := map[string]interface{}{}
+	for key, value := range data {
+		values[key] = value
+	}
+	values["Brand"] = brand
+	values["Locale"] = locale
+	values["Email"] = to
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return EmailMessage{}, err
+	}
+	values["Subject"] = strings.TrimSpace(subject.String())
+	if err := tmpl.text.ExecuteTemplate(&text, "body", values); err != nil {
+		return EmailMessage{}, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", values); err != nil {
+		return EmailMessage{}, err
+	}
+
+	return EmailMessage{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: strings.TrimSpace(text.String()) + "\n",
+		HTMLBody: html.String(),
+	}, nil
+}
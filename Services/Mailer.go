@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	config "go_server/Config"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EmailMessage is a rendered email with both an HTML and a plain text body.
+type EmailMessage struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Mailer delivers a single message. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg EmailMessage) error
+}
+
+// SMTPMailer sends mail through an SMTP relay. Port 465 uses implicit TLS;
+// any other port upgrades with STARTTLS when the server offers it. The
+// context bounds the whole exchange, from dialing to QUIT.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg EmailMessage) error {
+	body, err := BuildMIMEMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	// a relay that stops answering must not hold the worker past the
+	// deadline, nor after the context is cancelled
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if m.Port != "465" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if m.Password != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (m *SMTPMailer) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(m.Host, m.Port)
+	if m.Port == "465" {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: m.Host}}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// FileMailer writes every message as an .eml file into Dir, which is handy
+// for local development and inspecting templates.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg EmailMessage) error {
+	body, err := BuildMIMEMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitizeFileName(msg.To))
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0o644)
+}
+
+// LogMailer logs messages instead of sending them. The log is redacted, so
+// links carrying tokens are not usable from it; use FileMailer to follow
+// them during development.
+type LogMailer struct {
+	Log *slog.Logger
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg EmailMessage) error {
+	m.Log.InfoContext(ctx, "email not sent, log transport", "to", msg.To, "subject", msg.Subject, "body", msg.TextBody)
+	return nil
+}
+
+func sanitizeFileName(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, value)
+}
+
+// NewMailer returns the transport selected in the configuration: "smtp",
+// "file" or "log".
+func NewMailer(cfg config.EmailConfig, log *slog.Logger) Mailer {
+	switch cfg.Transport {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	case "file":
+		return &FileMailer{Dir: cfg.OutboxDir, From: cfg.From}
+	default:
+		return &LogMailer{Log: log}
+	}
+}
+
+// headerValue strips line breaks so values cannot inject extra headers.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// BuildMIMEMessage renders msg as a multipart/alternative RFC 5322 message.
+func BuildMIMEMessage(from string, msg EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	idBytes := make([]byte, 12)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+
+	headers := []string{
+		"From: " + headerValue(from),
+		"To: " + headerValue(msg.To),
+		"Subject: " + mime.QEncoding.Encode("utf-8", headerValue(msg.Subject)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + hex.EncodeToString(idBytes) + "@" + domain + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+	// the writer only emits parts, so the headers can go first
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.TextBody},
+		{"text/html; charset=UTF-8", msg.HTMLBody},
+	}
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRelay accepts SMTP connections on a local port and hands each one to
+// serve.
+func fakeRelay(t *testing.T, serve func(conn net.Conn)) *SMTPMailer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return &SMTPMailer{Host: host, Port: port, From: "noreply@example.com"}
+}
+
+func TestSMTPMailerSends(t *testing.T) {
+	received := make(chan string, 1)
+	mailer := fakeRelay(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 relay.example.com ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 queued")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250 relay.example.com")
+			case command == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg := EmailMessage{To: "ada@example.com", Subject: "Welcome", TextBody: "Hello Ada", HTMLBody: "<p>Hello Ada</p>"}
+	if err := mailer.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	body := <-received
+	if !strings.Contains(body, "To: ada@example.com") || !strings.Contains(body, "Hello Ada") {
+		t.Fatalf("message = %s", body)
+	}
+}
+
+// TestSMTPMailerHonorsContext checks that a relay which accepts the
+// connection but never answers does not hold the sender past the deadline
+// of the context or after it is cancelled.
+func TestSMTPMailerHonorsContext(t *testing.T) {
+	mailer := fakeRelay(t, func(conn net.Conn) {
+		// read and drop whatever arrives, never answering
+		buf := make([]byte, 1024)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := mailer.Send(ctx, EmailMessage{To: "ada@example.com", Subject: "Welcome", TextBody: "Hello"}); err == nil {
+			t.Fatal("Send to a silent relay succeeded")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("Send returned after %v", elapsed)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start := time.Now()
+		if err := mailer.Send(ctx, EmailMessage{To: "ada@example.com", Subject: "Welcome", TextBody: "Hello"}); err == nil {
+			t.Fatal("Send to a silent relay succeeded")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("Send returned after %v", elapsed)
+		}
+	})
+}
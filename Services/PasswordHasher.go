@@ -0,0 +1,277 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Password hashes are stored in PHC string format
+// ("$<id>$<param>=<value>,...$<salt>$<hash>", unpadded standard base64) so
+// every hash records the algorithm and parameters it was made with. bcrypt
+// keeps its own "$2a$<cost>$..." format, which existing hashes already use.
+// New passwords are always hashed with the preferred algorithm; argon2id
+// and bcrypt can be preferred, while PBKDF2 and scrypt hashes are only
+// verified so accounts imported from other systems can still log in and get
+// rehashed on their first login.
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+	// ErrPasswordHashLimits rejects hashes whose parameters exceed the
+	// limits below.
+	ErrPasswordHashLimits = errors.New("password hash parameters exceed the allowed limits")
+)
+
+// Limits on the parameters of stored hashes. Verifying a hash costs what
+// its parameters say, so an imported hash with huge ones would let anyone
+// burn memory and CPU by trying to log in to that account.
+const (
+	maxBcryptCost        = 16
+	maxArgon2Memory      = 1 << 20 // KiB, 1 GiB
+	maxArgon2Iterations  = 16
+	maxArgon2Parallelism = 16
+	maxPBKDF2Iterations  = 5000000
+	maxScryptLogN        = 20
+	maxScryptR           = 32
+	maxScryptP           = 16
+	maxScryptMemory      = 1 << 30 // bytes, 128 * r * 2^ln
+	maxSaltBytes         = 64
+	maxKeyBytes          = 64
+)
+
+// PasswordHasher hashes new passwords and verifies stored hashes of any
+// supported format.
+type PasswordHasher struct {
+	Algorithm string
+	// bcrypt
+	BcryptCost int
+	// argon2id, memory in KiB
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// Hash hashes a new password with the preferred algorithm.
+func (h PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == AlgorithmBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hashed), err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Argon2Iterations, h.Argon2Memory, h.Argon2Parallelism, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		h.Argon2Memory, h.Argon2Iterations, h.Argon2Parallelism, b64(salt), b64(key)), nil
+}
+
+// Verify reports whether password matches the stored hash.
+func (h PasswordHasher) Verify(password, encoded string) (bool, error) {
+	parsed, err := parsePasswordHash(encoded)
+	if err != nil {
+		return false, err
+	}
+	return parsed.verify(password)
+}
+
+// NeedsRehash reports whether a stored hash was made with another
+// algorithm or with different parameters than the preferred ones.
+func (h PasswordHasher) NeedsRehash(encoded string) bool {
+	parsed, err := parsePasswordHash(encoded)
+	if err != nil {
+		return true
+	}
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		return parsed.id != AlgorithmBcrypt || parsed.params["cost"] != h.BcryptCost
+	default:
+		return parsed.id != AlgorithmArgon2id ||
+			parsed.params["m"] != int(h.Argon2Memory) ||
+			parsed.params["t"] != int(h.Argon2Iterations) ||
+			parsed.params["p"] != int(h.Argon2Parallelism) ||
+			len(parsed.hash) != 32
+	}
+}
+
+// ValidatePasswordHash checks that an imported hash is in a format that can
+// be verified, without knowing the password, and that its parameters are
+// within the limits.
+func ValidatePasswordHash(encoded string) error {
+	_, err := parsePasswordHash(encoded)
+	return err
+}
+
+type passwordHash struct {
+	id      string
+	encoded string
+	params  map[string]int
+	salt    []byte
+	hash    []byte
+}
+
+func b64(value []byte) string {
+	return base64.RawStdEncoding.EncodeToString(value)
+}
+
+// unb64 accepts the unpadded PHC encoding as well as padded base64, which
+// some exporting systems produce.
+func unb64(value string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func parsePHCParams(value string) (map[string]int, error) {
+	params := map[string]int{}
+	for _, pair := range strings.Split(value, ",") {
+		name, raw, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, ErrUnknownPasswordHash
+		}
+		number, err := strconv.Atoi(raw)
+		if err != nil || number <= 0 {
+			return nil, ErrUnknownPasswordHash
+		}
+		params[name] = number
+	}
+	return params, nil
+}
+
+func parsePasswordHash(encoded string) (passwordHash, error) {
+	if strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$") {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		if cost > maxBcryptCost {
+			return passwordHash{}, ErrPasswordHashLimits
+		}
+		return passwordHash{id: AlgorithmBcrypt, encoded: encoded, params: map[string]int{"cost": cost}}, nil
+	}
+	// Django stores "pbkdf2_sha256$<iterations>$<salt>$<base64 hash>" with a
+	// plain text salt
+	if strings.HasPrefix(encoded, "pbkdf2_sha256$") || strings.HasPrefix(encoded, "pbkdf2_sha1$") {
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 4 {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		iterations, err := strconv.Atoi(parts[1])
+		if err != nil || iterations <= 0 {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[3])
+		if err != nil {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		if iterations > maxPBKDF2Iterations || len(parts[2]) > maxSaltBytes || len(key) > maxKeyBytes {
+			return passwordHash{}, ErrPasswordHashLimits
+		}
+		id := "pbkdf2-" + strings.TrimPrefix(parts[0], "pbkdf2_")
+		return passwordHash{id: id, encoded: encoded, params: map[string]int{"i": iterations}, salt: []byte(parts[2]), hash: key}, nil
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 5 || parts[0] != "" {
+		return passwordHash{}, ErrUnknownPasswordHash
+	}
+	id := parts[1]
+	fields := parts[2:]
+	if id == AlgorithmArgon2id {
+		// the optional version field comes before the parameters
+		if len(fields) != 4 || fields[0] != fmt.Sprintf("v=%d", argon2.Version) {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return passwordHash{}, ErrUnknownPasswordHash
+	}
+	params, err := parsePHCParams(fields[0])
+	if err != nil {
+		return passwordHash{}, err
+	}
+	salt, err := unb64(fields[1])
+	if err != nil {
+		return passwordHash{}, ErrUnknownPasswordHash
+	}
+	key, err := unb64(fields[2])
+	if err != nil || len(key) == 0 {
+		return passwordHash{}, ErrUnknownPasswordHash
+	}
+
+	parsed := passwordHash{id: id, encoded: encoded, params: params, salt: salt, hash: key}
+	var withinLimits bool
+	switch id {
+	case AlgorithmArgon2id:
+		if params["m"] == 0 || params["t"] == 0 || params["p"] == 0 {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		withinLimits = params["m"] <= maxArgon2Memory && params["t"] <= maxArgon2Iterations && params["p"] <= maxArgon2Parallelism
+	case "pbkdf2-sha1", "pbkdf2-sha256", "pbkdf2-sha512":
+		if params["i"] == 0 {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		withinLimits = params["i"] <= maxPBKDF2Iterations
+	case "scrypt":
+		if params["ln"] == 0 || params["r"] == 0 || params["p"] == 0 {
+			return passwordHash{}, ErrUnknownPasswordHash
+		}
+		withinLimits = params["ln"] <= maxScryptLogN && params["r"] <= maxScryptR && params["p"] <= maxScryptP &&
+			128*params["r"]<<params["ln"] <= maxScryptMemory
+	default:
+		return passwordHash{}, ErrUnknownPasswordHash
+	}
+	if !withinLimits || len(salt) > maxSaltBytes || len(key) > maxKeyBytes {
+		return passwordHash{}, ErrPasswordHashLimits
+	}
+	return parsed, nil
+}
+
+func (p passwordHash) verify(password string) (bool, error) {
+	var key []byte
+	switch p.id {
+	case AlgorithmBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(p.encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case AlgorithmArgon2id:
+		key = argon2.IDKey([]byte(password), p.salt, uint32(p.params["t"]), uint32(p.params["m"]),
+			uint8(p.params["p"]), uint32(len(p.hash)))
+	case "pbkdf2-sha1", "pbkdf2-sha256", "pbkdf2-sha512":
+		digests := map[string]func() hash.Hash{
+			"pbkdf2-sha1":   sha1.New,
+			"pbkdf2-sha256": sha256.New,
+			"pbkdf2-sha512": sha512.New,
+		}
+		key = pbkdf2.Key([]byte(password), p.salt, p.params["i"], len(p.hash), digests[p.id])
+	case "scrypt":
+		var err error
+		key, err = scrypt.Key([]byte(password), p.salt, 1<<p.params["ln"], p.params["r"], p.params["p"], len(p.hash))
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, ErrUnknownPasswordHash
+	}
+	return subtle.ConstantTimeCompare(key, p.hash) == 1, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	models "go_server/Models"
+)
+
+// Known answers: the argon2id vector of the reference implementation, RFC
+// 6070 and RFC 7914 for PBKDF2 and scrypt, and a crypt_blowfish vector for
+// bcrypt.
+var passwordHashVectors = []struct {
+	name     string
+	password string
+	encoded  string
+}{
+	{"argon2id", "password", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+	{"bcrypt", "U*U", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+	{"pbkdf2-sha1", "password", "$pbkdf2-sha1$i=4096$c2FsdA$SwB5AbdlSJq+rUnZJvch0GWkKcE"},
+	{"pbkdf2-sha256", "passwd", "$pbkdf2-sha256$i=1$c2FsdA$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLxJypzM8Xm2RZkWZLOdd+8xfHG4RbHjC9UJESBB06GXgw"},
+	{"pbkdf2-sha512", "password", "$pbkdf2-sha512$i=1000$c2FsdA$r+bFUweFtsxrHGRTOEcxvV7kMu5Un9QvtmlXea2KHFv1neacSPd078QAfVKY+QM8AkHVq2kwXntk7O642DTP7A"},
+	{"pbkdf2 django", "correct horse", "pbkdf2_sha256$1000$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso="},
+	{"scrypt", "password", "$scrypt$ln=10,r=8,p=16$TmFDbA$/bq+HJ00cgB4VucZDQHp/nxq18vII3gw53N2Y0s3MWIurzDZLiKjiG/xCSedmDDaxyevuUqD7m2DYMvfoswGQA"},
+}
+
+func TestPasswordHashVectors(t *testing.T) {
+	hasher := PasswordHasher{Algorithm: AlgorithmArgon2id}
+	for _, tc := range passwordHashVectors {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidatePasswordHash(tc.encoded); err != nil {
+				t.Fatalf("ValidatePasswordHash = %v", err)
+			}
+			ok, err := hasher.Verify(tc.password, tc.encoded)
+			if err != nil || !ok {
+				t.Fatalf("Verify(%q) = %v, %v", tc.password, ok, err)
+			}
+			ok, err = hasher.Verify(tc.password+"!", tc.encoded)
+			if err != nil || ok {
+				t.Fatalf("Verify of a wrong password = %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestPasswordHashLimits(t *testing.T) {
+	cases := map[string]string{
+		"bcrypt cost":          "$2a$31$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		"argon2id memory":      "$argon2id$v=19$m=4194304,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"argon2id iterations":  "$argon2id$v=19$m=65536,t=1000,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"argon2id parallelism": "$argon2id$v=19$m=65536,t=2,p=64$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"pbkdf2 iterations":    "$pbkdf2-sha256$i=100000000$c2FsdA$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLxJypzM8Xm2RZkWZLOdd+8xfHG4RbHjC9UJESBB06GXgw",
+		"django iterations":    "pbkdf2_sha256$100000000$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso=",
+		"scrypt ln":            "$scrypt$ln=24,r=8,p=1$TmFDbA$/bq+HJ00cgB4VucZDQHp/nxq18vII3gw53N2Y0s3MWIurzDZLiKjiG/xCSedmDDaxyevuUqD7m2DYMvfoswGQA",
+		"scrypt memory":        "$scrypt$ln=20,r=32,p=1$TmFDbA$/bq+HJ00cgB4VucZDQHp/nxq18vII3gw53N2Y0s3MWIurzDZLiKjiG/xCSedmDDaxyevuUqD7m2DYMvfoswGQA",
+		"scrypt p":             "$scrypt$ln=10,r=8,p=64$TmFDbA$/bq+HJ00cgB4VucZDQHp/nxq18vII3gw53N2Y0s3MWIurzDZLiKjiG/xCSedmDDaxyevuUqD7m2DYMvfoswGQA",
+		"key length":           "$pbkdf2-sha256$i=1$c2FsdA$" + b64(make([]byte, 4096)),
+	}
+	hasher := PasswordHasher{Algorithm: AlgorithmArgon2id}
+	for name, encoded := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidatePasswordHash(encoded); !errors.Is(err, ErrPasswordHashLimits) {
+				t.Fatalf("ValidatePasswordHash = %v", err)
+			}
+			if ok, err := hasher.Verify("password", encoded); ok || !errors.Is(err, ErrPasswordHashLimits) {
+				t.Fatalf("Verify = %v, %v", ok, err)
+			}
+			// imports refuse the hash before it reaches the database
+			record := models.TransferUser{Email: "ada@example.com", PasswordHash: encoded}
+			if problem := validateImportRecord(record); problem != ErrPasswordHashLimits.Error() {
+				t.Fatalf("validateImportRecord = %q", problem)
+			}
+		})
+	}
+
+	for _, encoded := range []string{"", "plain", "$md5$abc", "$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFh", "$scrypt$ln=0,r=8,p=1$TmFDbA$/bq+"} {
+		if err := ValidatePasswordHash(encoded); !errors.Is(err, ErrUnknownPasswordHash) {
+			t.Errorf("ValidatePasswordHash(%q) = %v", encoded, err)
+		}
+	}
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	hashers := map[string]PasswordHasher{
+		AlgorithmArgon2id: {Algorithm: AlgorithmArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1},
+		AlgorithmBcrypt:   {Algorithm: AlgorithmBcrypt, BcryptCost: 4},
+	}
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := hasher.Hash("Correct-Horse-9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := hasher.Verify("Correct-Horse-9", encoded); err != nil || !ok {
+				t.Fatalf("Verify = %v, %v", ok, err)
+			}
+			if hasher.NeedsRehash(encoded) {
+				t.Fatalf("a fresh hash needs rehashing: %s", encoded)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2Memory: 65536, Argon2Iterations: 2, Argon2Parallelism: 1}
+	bcryptHasher := PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: 5}
+	vector := func(name string) string {
+		for _, tc := range passwordHashVectors {
+			if tc.name == name {
+				return tc.encoded
+			}
+		}
+		t.Fatalf("no vector %s", name)
+		return ""
+	}
+
+	cases := []struct {
+		name    string
+		hasher  PasswordHasher
+		encoded string
+		want    bool
+	}{
+		{"argon2id with the preferred parameters", argon, vector("argon2id"), false},
+		{"argon2id with other memory", PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2Memory: 19456, Argon2Iterations: 2, Argon2Parallelism: 1}, vector("argon2id"), true},
+		{"argon2id with other iterations", PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2Memory: 65536, Argon2Iterations: 3, Argon2Parallelism: 1}, vector("argon2id"), true},
+		{"bcrypt when argon2id is preferred", argon, vector("bcrypt"), true},
+		{"pbkdf2", argon, vector("pbkdf2-sha256"), true},
+		{"django pbkdf2", argon, vector("pbkdf2 django"), true},
+		{"scrypt", argon, vector("scrypt"), true},
+		{"bcrypt with the preferred cost", bcryptHasher, vector("bcrypt"), false},
+		{"bcrypt with another cost", PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: 12}, vector("bcrypt"), true},
+		{"argon2id when bcrypt is preferred", bcryptHasher, vector("argon2id"), true},
+		{"unparsable", argon, "plain", true},
+	}
+	for _, tc := range cases {
+		if got := tc.hasher.NeedsRehash(tc.encoded); got != tc.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
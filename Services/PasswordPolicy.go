@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	config "go_server/Config"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Stable violation codes the frontend can map to its own messages.
+const (
+	PasswordTooShort      = "too_short"
+	PasswordTooLong       = "too_long"
+	PasswordMissingUpper  = "missing_uppercase"
+	PasswordMissingLower  = "missing_lowercase"
+	PasswordMissingDigit  = "missing_digit"
+	PasswordMissingSymbol = "missing_symbol"
+	PasswordContainsEmail = "contains_email"
+	PasswordContainsName  = "contains_name"
+	PasswordBreached      = "breached"
+	PasswordCheckFailed   = "check_failed"
+)
+
+// PasswordPolicy describes what a new password must look like.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxBytes         int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowPersonal bool
+}
+
+// PasswordViolation is one failed rule. Params carries the values the
+// message was built from, e.g. {"min": 8}.
+type PasswordViolation struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// Check returns every rule the password breaks; an empty result means the
+// password is acceptable. email and name are the account's own details,
+// which must not appear in the password.
+func (p PasswordPolicy) Check(password, email, name string) []PasswordViolation {
+	violations := []PasswordViolation{}
+	add := func(code, message string, params map[string]interface{}) {
+		violations = append(violations, PasswordViolation{Code: code, Message: message, Params: params})
+	}
+
+	if length := utf8.RuneCountInString(password); length < p.MinLength {
+		add(PasswordTooShort, fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+			map[string]interface{}{"min": p.MinLength})
+	}
+	if len(password) > p.MaxBytes {
+		add(PasswordTooLong, fmt.Sprintf("Password must be at most %d bytes long", p.MaxBytes),
+			map[string]interface{}{"max": p.MaxBytes})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add(PasswordMissingUpper, "Password must contain an uppercase letter", nil)
+	}
+	if p.RequireLower && !lower {
+		add(PasswordMissingLower, "Password must contain a lowercase letter", nil)
+	}
+	if p.RequireDigit && !digit {
+		add(PasswordMissingDigit, "Password must contain a digit", nil)
+	}
+	if p.RequireSymbol && !symbol {
+		add(PasswordMissingSymbol, "Password must contain a symbol", nil)
+	}
+
+	if p.DisallowPersonal {
+		lowered := strings.ToLower(password)
+		local, _, _ := strings.Cut(strings.ToLower(email), "@")
+		if len(local) >= 3 && strings.Contains(lowered, local) {
+			add(PasswordContainsEmail, "Password must not contain your email address", nil)
+		}
+		for _, part := range strings.Fields(strings.ToLower(name)) {
+			if len(part) >= 3 && strings.Contains(lowered, part) {
+				add(PasswordContainsName, "Password must not contain your name", nil)
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// Passwords checks and hashes passwords with the configured policy,
+// breached password list and hashing parameters.
+type Passwords struct {
+	Policy   PasswordPolicy
+	Breached *BreachedPasswords
+	Hasher   PasswordHasher
+
+	log *slog.Logger
+}
+
+// NewPasswords builds the password services from a validated configuration.
+func NewPasswords(cfg config.PasswordConfig, log *slog.Logger) *Passwords {
+	return &Passwords{
+		Policy: PasswordPolicy{
+			MinLength:        cfg.MinLength,
+			MaxBytes:         cfg.MaxBytes,
+			RequireUpper:     cfg.RequireUpper,
+			RequireLower:     cfg.RequireLower,
+			RequireDigit:     cfg.RequireDigit,
+			RequireSymbol:    cfg.RequireSymbol,
+			DisallowPersonal: cfg.DisallowPersonal,
+		},
+		Breached: &BreachedPasswords{Path: cfg.BreachedPath, MinCount: cfg.BreachedMinCount},
+		Hasher: PasswordHasher{
+			Algorithm:         cfg.HashAlgorithm,
+			BcryptCost:        cfg.BcryptCost,
+			Argon2Memory:      uint32(cfg.Argon2Memory),
+			Argon2Iterations:  uint32(cfg.Argon2Iterations),
+			Argon2Parallelism: uint8(cfg.Argon2Parallelism),
+		},
+		log: log,
+	}
+}
+
+// CheckNew applies the policy and the breached password list. Lookup
+// failures are reported as a violation rather than letting an unchecked
+// password through.
+func (p *Passwords) CheckNew(password, email, name string) []PasswordViolation {
+	violations := p.Policy.Check(password, email, name)
+	breached, err := p.Breached.Contains(password)
+	if err != nil {
+		p.log.Error("checking the breached password list", "err", err)
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordCheckFailed,
+			Message: "Password could not be checked, please try again",
+		})
+	} else if breached {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordBreached,
+			Message: "Password has appeared in a data breach, please choose another one",
+		})
+	}
+	return violations
+}
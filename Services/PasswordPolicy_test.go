@@ -0,0 +1,98 @@
+package services
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func violationCodes(violations []PasswordViolation) []string {
+	codes := []string{}
+	for _, violation := range violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:        10,
+		MaxBytes:         72,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowPersonal: true,
+	}
+	lenient := PasswordPolicy{MinLength: 8, MaxBytes: 72}
+
+	cases := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{"acceptable", strict, "Correct-Horse-9-Battery", []string{}},
+		{"too short", strict, "Aa-9", []string{PasswordTooShort}},
+		// the minimum counts characters, not bytes
+		{"multibyte length", PasswordPolicy{MinLength: 4, MaxBytes: 72}, "ééé", []string{PasswordTooShort}},
+		{"too long", lenient, string(make([]byte, 73)), []string{PasswordTooLong}},
+		{"missing classes", strict, "correcthorsebattery", []string{PasswordMissingUpper, PasswordMissingDigit, PasswordMissingSymbol}},
+		{"missing lowercase", strict, "CORRECT-HORSE-9", []string{PasswordMissingLower}},
+		{"spaces are no symbol", strict, "Correct Horse 9 Battery", []string{PasswordMissingSymbol}},
+		{"several violations", strict, "ada", []string{PasswordTooShort, PasswordMissingUpper, PasswordMissingDigit, PasswordMissingSymbol, PasswordContainsName}},
+		{"contains the email", strict, "Ada.Lovelace-1815", []string{PasswordContainsEmail, PasswordContainsName}},
+		{"contains the name", strict, "Byron-Was-Here-1", []string{PasswordContainsName}},
+		{"personal details allowed", lenient, "ada.lovelace", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := violationCodes(tc.policy.Check(tc.password, "ada.lovelace@example.com", "Ada Byron"))
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("violations = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// short parts of the email and name are too common to refuse
+	if got := violationCodes(strict.Check("Al-Jo-Correct-9", "al@example.com", "Al Jo")); len(got) != 0 {
+		t.Fatalf("violations = %v", got)
+	}
+
+	violations := strict.Check("Aa-9", "", "")
+	if violations[0].Params["min"] != 10 || violations[0].Message != "Password must be at least 10 characters long" {
+		t.Fatalf("violation = %+v", violations[0])
+	}
+}
+
+func TestPasswordsCheckNew(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy := PasswordPolicy{MinLength: 8, MaxBytes: 72}
+	dir := t.TempDir()
+	list := writeHashes(t, dir, "breached.txt", rangeLine("Correct-Horse-9", 3))
+
+	cases := []struct {
+		name     string
+		breached *BreachedPasswords
+		password string
+		want     []string
+	}{
+		{"no list", nil, "Correct-Horse-9", []string{}},
+		{"not breached", &BreachedPasswords{Path: list}, "Correct-Horse-10", []string{}},
+		{"breached", &BreachedPasswords{Path: list}, "Correct-Horse-9", []string{PasswordBreached}},
+		// an unreadable list refuses the password rather than letting it
+		// through unchecked
+		{"lookup failure", &BreachedPasswords{Path: filepath.Join(dir, "missing.txt")}, "Correct-Horse-9", []string{PasswordCheckFailed}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			passwords := &Passwords{Policy: policy, Breached: tc.breached, log: log}
+			got := violationCodes(passwords.CheckNew(tc.password, "ada@example.com", "Ada"))
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("violations = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	database "go_server/Database"
+	models "go_server/Models"
+	"io"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+const (
+	TransferFormatCSV  = "csv"
+	TransferFormatJSON = "json"
+
+	defaultImportBatchSize = 500
+	exportPageSize         = 1000
+	// googleOnlyPassword marks accounts that sign in with Google only; it is
+	// exported and imported as is.
+	googleOnlyPassword = "GOOGLE"
+)
+
+// transferColumns is the CSV layout of imports and exports. Identities are
+// written as "provider:subject" pairs separated by ";".
+var transferColumns = []string{"email", "name", "password_hash", "verified", "active", "identities"}
+
+// ImportRecord is one user of an import file; Line is its line in a CSV
+// file or its index in a JSON array, counted from 1.
+type ImportRecord struct {
+	Line int
+	User models.TransferUser
+}
+
+type ImportOptions struct {
+	DryRun    bool
+	BatchSize int
+	// Source names the file in the import job, for operators.
+	Source string
+}
+
+// ImportChecksum identifies an import file so an interrupted import of the
+// same content can be resumed.
+func ImportChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseUserImport reads users from a CSV file with a header row (see
+// transferColumns; only email is required) or a JSON array of
+// models.TransferUser. Records missing "active" are imported as active.
+func ParseUserImport(data []byte, format string) ([]ImportRecord, error) {
+	switch format {
+	case TransferFormatJSON:
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		records := make([]ImportRecord, 0, len(raw))
+		for i, item := range raw {
+			user := models.TransferUser{Active: true}
+			if err := json.Unmarshal(item, &user); err != nil {
+				return nil, fmt.Errorf("record %d: %w", i+1, err)
+			}
+			records = append(records, ImportRecord{Line: i + 1, User: user})
+		}
+		return records, nil
+	case TransferFormatCSV:
+		return parseCSVImport(data)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func parseCSVImport(data []byte) ([]ImportRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("missing column %q", "email")
+	}
+
+	records := []ImportRecord{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		user := models.TransferUser{
+			Name:         field("name"),
+			Email:        field("email"),
+			PasswordHash: field("password_hash"),
+			Active:       true,
+		}
+		if value := field("verified"); value != "" {
+			if user.Verified, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("line %d: invalid verified flag %q", line, value)
+			}
+		}
+		if value := field("active"); value != "" {
+			if user.Active, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("line %d: invalid active flag %q", line, value)
+			}
+		}
+		for _, pair := range strings.Split(field("identities"), ";") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			provider, subject, _ := strings.Cut(pair, ":")
+			user.Identities = append(user.Identities, models.UserIdentity{Provider: provider, Subject: subject})
+		}
+		records = append(records, ImportRecord{Line: line, User: user})
+	}
+}
+
+// validateImportRecord returns why a record cannot be imported, or "".
+func validateImportRecord(user models.TransferUser) string {
+	if user.Email == "" {
+		return "missing email"
+	}
+	if address, err := mail.ParseAddress(user.Email); err != nil || address.Address != user.Email {
+		return "invalid email"
+	}
+	if len(user.Name) > 255 {
+		return "name is longer than 255 characters"
+	}
+	if user.PasswordHash == "" && len(user.Identities) == 0 {
+		return "a password hash or an external identity is required"
+	}
+	if user.PasswordHash != "" && user.PasswordHash != googleOnlyPassword {
+		if err := ValidatePasswordHash(user.PasswordHash); err != nil {
+			return err.Error()
+		}
+	}
+	for _, identity := range user.Identities {
+		if identity.Provider == "" || identity.Subject == "" {
+			return "identities need a provider and a subject"
+		}
+	}
+	return ""
+}
+
+// ImportUsers validates the records, reports duplicates within the file and
+// against existing accounts, and unless DryRun is set inserts the valid
+// ones in batches, each in its own transaction. When an earlier import of
+// the same checksum was interrupted it continues after its last committed
+// batch.
+func ImportUsers(ctx context.Context, store database.ImportStore, records []ImportRecord, checksum string, opts ImportOptions) (models.ImportReport, error) {
+	report := models.ImportReport{DryRun: opts.DryRun, Total: len(records), Issues: []models.ImportIssue{}}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	var job models.UserImport
+	if !opts.DryRun {
+		var err error
+		job, err = store.GetRunningUserImport(ctx, checksum)
+		if err == sql.ErrNoRows {
+			job, err = store.InsertUserImport(ctx, checksum, opts.Source, len(records))
+		}
+		if err != nil {
+			return report, err
+		}
+		report.JobId = job.ID
+		report.ResumedAt = job.NextRecord
+		report.Imported, report.Skipped, report.Failed = job.Imported, job.Skipped, job.Failed
+	}
+
+	// duplicates inside the file are found across all records, also the
+	// ones already imported by an interrupted run
+	firstLine := map[string]int{}
+	duplicate := make([]string, len(records))
+	for i, record := range records {
+		email := strings.ToLower(record.User.Email)
+		if email == "" {
+			continue
+		}
+		if line, seen := firstLine[email]; seen {
+			duplicate[i] = fmt.Sprintf("duplicate email, first seen on line %d", line)
+			continue
+		}
+		firstLine[email] = record.Line
+	}
+
+	for start := report.ResumedAt; start < len(records); start += batchSize {
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		batch, failed, err := checkImportBatch(ctx, store, records[start:end], duplicate[start:end], &report)
+		if err != nil {
+			return report, err
+		}
+		report.Failed += failed
+		if opts.DryRun {
+			continue
+		}
+
+		users := make([]models.TransferUser, len(batch))
+		for i, record := range batch {
+			users[i] = record.User
+		}
+		outcomes, err := store.ImportUserBatch(ctx, job.ID, users, end, 0, failed)
+		if err != nil {
+			return report, fmt.Errorf("importing records %d-%d: %w", start+1, end, err)
+		}
+		for i, outcome := range outcomes {
+			if !outcome.Inserted {
+				report.Skipped++
+				report.Issues = append(report.Issues, models.ImportIssue{Line: batch[i].Line, Email: batch[i].User.Email, Reason: "email already registered"})
+				continue
+			}
+			report.Imported++
+			for _, identity := range outcome.IdentityConflicts {
+				report.Issues = append(report.Issues, models.ImportIssue{
+					Line:   batch[i].Line,
+					Email:  batch[i].User.Email,
+					Reason: fmt.Sprintf("identity %s:%s is linked to another account and was not imported", identity.Provider, identity.Subject),
+				})
+			}
+		}
+	}
+
+	if !opts.DryRun {
+		if err := store.FinishUserImport(ctx, job.ID); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// checkImportBatch drops invalid and duplicate records from a batch and
+// records why. In a dry run, records that would be skipped because the
+// email or an identity is already registered are reported as well.
+func checkImportBatch(ctx context.Context, store database.ImportStore, records []ImportRecord, duplicate []string, report *models.ImportReport) ([]ImportRecord, int, error) {
+	valid := []ImportRecord{}
+	failed := 0
+	for i, record := range records {
+		reason := validateImportRecord(record.User)
+		if reason == "" {
+			reason = duplicate[i]
+		}
+		if reason != "" {
+			failed++
+			report.Issues = append(report.Issues, models.ImportIssue{Line: record.Line, Email: record.User.Email, Reason: reason})
+			continue
+		}
+		valid = append(valid, record)
+	}
+	if !report.DryRun || len(valid) == 0 {
+		return valid, failed, nil
+	}
+
+	emails := make([]string, len(valid))
+	identities := []models.UserIdentity{}
+	for i, record := range valid {
+		emails[i] = record.User.Email
+		identities = append(identities, record.User.Identities...)
+	}
+	existing, err := store.GetExistingEmails(ctx, emails)
+	if err != nil {
+		return nil, 0, err
+	}
+	linked := map[string]bool{}
+	if len(identities) > 0 {
+		if linked, err = store.GetLinkedIdentities(ctx, identities); err != nil {
+			return nil, 0, err
+		}
+	}
+	for _, record := range valid {
+		if existing[strings.ToLower(record.User.Email)] {
+			report.Skipped++
+			report.Issues = append(report.Issues, models.ImportIssue{Line: record.Line, Email: record.User.Email, Reason: "email already registered"})
+			continue
+		}
+		report.Imported++
+		for _, identity := range record.User.Identities {
+			if linked[identity.Provider+":"+identity.Subject] {
+				report.Issues = append(report.Issues, models.ImportIssue{
+					Line:   record.Line,
+					Email:  record.User.Email,
+					Reason: fmt.Sprintf("identity %s:%s is linked to another account and would not be imported", identity.Provider, identity.Subject),
+				})
+			}
+		}
+	}
+	return valid, failed, nil
+}
+
+// ExportUsers writes every user, including password hashes and external
+// identities, in a format ParseUserImport reads back.
+func ExportUsers(ctx context.Context, store database.ImportStore, w io.Writer, format string) (int, error) {
+	var csvWriter *csv.Writer
+	switch format {
+	case TransferFormatCSV:
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(transferColumns); err != nil {
+			return 0, err
+		}
+	case TransferFormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unsupported format %q", format)
+	}
+
+	count := 0
+	afterId := 0
+	for {
+		users, lastId, err := store.GetTransferUsers(ctx, afterId, exportPageSize)
+		if err != nil {
+			return count, err
+		}
+		for _, user := range users {
+			if csvWriter != nil {
+				identities := make([]string, len(user.Identities))
+				for i, identity := range user.Identities {
+					identities[i] = identity.Provider + ":" + identity.Subject
+				}
+				err = csvWriter.Write([]string{user.Email, user.Name, user.PasswordHash,
+					strconv.FormatBool(user.Verified), strconv.FormatBool(user.Active), strings.Join(identities, ";")})
+			} else {
+				separator := ",\n"
+				if count == 0 {
+					separator = "\n"
+				}
+				var item []byte
+				if item, err = json.Marshal(user); err == nil {
+					_, err = io.WriteString(w, separator+string(item))
+				}
+			}
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(users) < exportPageSize {
+			break
+		}
+		afterId = lastId
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return count, csvWriter.Error()
+	}
+	_, err := io.WriteString(w, "\n]\n")
+	return count, err
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	database "go_server/Database"
+	models "go_server/Models"
+)
+
+// importHash is a valid bcrypt hash, as exports of other systems carry.
+const importHash = "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"
+
+func TestParseUserImportCSV(t *testing.T) {
+	data := "Email, Name ,password_hash,verified,active,identities\n" +
+		"ada@example.com,Ada," + importHash + ",true,,google:123; github:ada\n" +
+		"bob@example.com,,,false,false,google:456\n" +
+		"carol@example.com\n"
+	records, err := ParseUserImport([]byte(data), TransferFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %+v", records)
+	}
+	ada, bob, carol := records[0], records[1], records[2]
+	if ada.Line != 2 || ada.User.Email != "ada@example.com" || ada.User.Name != "Ada" || ada.User.PasswordHash != importHash ||
+		!ada.User.Verified || !ada.User.Active || len(ada.User.Identities) != 2 ||
+		ada.User.Identities[1] != (models.UserIdentity{Provider: "github", Subject: "ada"}) {
+		t.Fatalf("ada = %+v", ada)
+	}
+	if bob.Line != 3 || bob.User.Verified || bob.User.Active || bob.User.Identities[0] != (models.UserIdentity{Provider: "google", Subject: "456"}) {
+		t.Fatalf("bob = %+v", bob)
+	}
+	// short rows leave the missing columns empty
+	if carol.Line != 4 || carol.User.Email != "carol@example.com" || !carol.User.Active || carol.User.PasswordHash != "" {
+		t.Fatalf("carol = %+v", carol)
+	}
+
+	failures := map[string]string{
+		"no email column": "name,password_hash\nAda," + importHash + "\n",
+		"invalid flag":    "email,verified\nada@example.com,yes\n",
+		"empty file":      "",
+		"bad quoting":     "email\n\"ada@example.com\n",
+	}
+	for name, data := range failures {
+		t.Run(name, func(t *testing.T) {
+			if records, err := ParseUserImport([]byte(data), TransferFormatCSV); err == nil {
+				t.Fatalf("records = %+v", records)
+			}
+		})
+	}
+	if _, err := ParseUserImport([]byte("email,active\nada@example.com,1\nbob@example.com,maybe\n"), TransferFormatCSV); err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestParseUserImportJSON(t *testing.T) {
+	data := `[
+		{"email": "ada@example.com", "name": "Ada", "password_hash": "` + importHash + `", "verified": true, "identities": [{"provider": "google", "subject": "123"}]},
+		{"email": "bob@example.com", "active": false}
+	]`
+	records, err := ParseUserImport([]byte(data), TransferFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Line != 1 || records[1].Line != 2 {
+		t.Fatalf("records = %+v", records)
+	}
+	ada, bob := records[0].User, records[1].User
+	// records without "active" are imported as active
+	if ada.Name != "Ada" || ada.PasswordHash != importHash || !ada.Verified || !ada.Active || len(ada.Identities) != 1 {
+		t.Fatalf("ada = %+v", ada)
+	}
+	if bob.Active {
+		t.Fatalf("bob = %+v", bob)
+	}
+
+	if _, err := ParseUserImport([]byte(`{"email": "ada@example.com"}`), TransferFormatJSON); err == nil {
+		t.Fatal("an object was read as a list of users")
+	}
+	if _, err := ParseUserImport([]byte(`[{"email": "ada@example.com"}, {"email": 42}]`), TransferFormatJSON); err == nil || !strings.HasPrefix(err.Error(), "record 2:") {
+		t.Fatalf("error = %v", err)
+	}
+	if _, err := ParseUserImport([]byte(data), "xml"); err == nil {
+		t.Fatal("an unsupported format was read")
+	}
+}
+
+func TestValidateImportRecord(t *testing.T) {
+	cases := map[string]struct {
+		user models.TransferUser
+		want string
+	}{
+		"password":            {models.TransferUser{Email: "ada@example.com", PasswordHash: importHash}, ""},
+		"google only":         {models.TransferUser{Email: "ada@example.com", PasswordHash: googleOnlyPassword, Identities: []models.UserIdentity{{Provider: "google", Subject: "123"}}}, ""},
+		"identity only":       {models.TransferUser{Email: "ada@example.com", Identities: []models.UserIdentity{{Provider: "google", Subject: "123"}}}, ""},
+		"missing email":       {models.TransferUser{PasswordHash: importHash}, "missing email"},
+		"invalid email":       {models.TransferUser{Email: "Ada <ada@example.com>", PasswordHash: importHash}, "invalid email"},
+		"long name":           {models.TransferUser{Email: "ada@example.com", Name: strings.Repeat("x", 256), PasswordHash: importHash}, "name is longer than 255 characters"},
+		"no credentials":      {models.TransferUser{Email: "ada@example.com"}, "a password hash or an external identity is required"},
+		"unknown hash":        {models.TransferUser{Email: "ada@example.com", PasswordHash: "5f4dcc3b5aa765d61d8327deb882cf99"}, ErrUnknownPasswordHash.Error()},
+		"incomplete identity": {models.TransferUser{Email: "ada@example.com", Identities: []models.UserIdentity{{Provider: "google"}}}, "identities need a provider and a subject"},
+	}
+	for name, tc := range cases {
+		if got := validateImportRecord(tc.user); got != tc.want {
+			t.Errorf("%s: validateImportRecord = %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func importRecords(users ...models.TransferUser) []ImportRecord {
+	records := make([]ImportRecord, len(users))
+	for i, user := range users {
+		records[i] = ImportRecord{Line: i + 2, User: user}
+	}
+	return records
+}
+
+func importUser(email string, identities ...models.UserIdentity) models.TransferUser {
+	return models.TransferUser{Email: email, PasswordHash: importHash, Active: true, Identities: identities}
+}
+
+func issueReasons(report models.ImportReport) []string {
+	reasons := []string{}
+	for _, issue := range report.Issues {
+		reasons = append(reasons, fmt.Sprintf("%d %s: %s", issue.Line, issue.Email, issue.Reason))
+	}
+	return reasons
+}
+
+func expectReasons(t *testing.T, report models.ImportReport, want ...string) {
+	t.Helper()
+	got := issueReasons(report)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("issues =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestImportUsersReportsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	if _, err := store.InsertUser(ctx, "taken@example.com", "Taken", importHash); err != nil {
+		t.Fatal(err)
+	}
+	records := importRecords(
+		importUser("ada@example.com"),
+		importUser("ADA@example.com"),
+		importUser("Taken@example.com"),
+		models.TransferUser{Email: "bob@example.com"},
+		importUser("carol@example.com"),
+	)
+
+	report, err := ImportUsers(ctx, store, records, "checksum", ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DryRun || report.JobId == 0 || report.Total != 5 || report.Imported != 2 || report.Skipped != 1 || report.Failed != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	expectReasons(t, report,
+		"3 ADA@example.com: duplicate email, first seen on line 2",
+		"5 bob@example.com: a password hash or an external identity is required",
+		"4 Taken@example.com: email already registered",
+	)
+	if _, err := store.GetUserByEmail(ctx, "carol@example.com"); err != nil {
+		t.Fatalf("carol was not imported: %v", err)
+	}
+	job, err := store.GetUserImport(ctx, report.JobId)
+	if err != nil || job.Status != database.UserImportFinished || job.NextRecord != 5 || job.Imported != 2 || job.Skipped != 1 || job.Failed != 2 {
+		t.Fatalf("job = %+v, %v", job, err)
+	}
+}
+
+func TestImportUsersDryRun(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	google := models.UserIdentity{Provider: "google", Subject: "123"}
+	if _, err := ImportUsers(ctx, store, importRecords(importUser("linked@example.com", google)), "first", ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := importRecords(
+		importUser("ada@example.com"),
+		importUser("linked@example.com"),
+		importUser("bob@example.com", google),
+		importUser("ada@example.com"),
+	)
+	report, err := ImportUsers(ctx, store, records, "second", ImportOptions{DryRun: true, BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.JobId != 0 || report.Imported != 2 || report.Skipped != 1 || report.Failed != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	expectReasons(t, report,
+		"3 linked@example.com: email already registered",
+		"5 ada@example.com: duplicate email, first seen on line 2",
+		"4 bob@example.com: identity google:123 is linked to another account and would not be imported",
+	)
+
+	// nothing was written, not even a job to resume
+	if _, err := store.GetUserByEmail(ctx, "ada@example.com"); err == nil {
+		t.Fatal("the dry run imported ada")
+	}
+	if _, err := store.GetRunningUserImport(ctx, "second"); err == nil {
+		t.Fatal("the dry run started an import job")
+	}
+}
+
+// failingImportStore fails a batch the way a lost connection does: the
+// transaction is rolled back and the batch leaves no trace.
+type failingImportStore struct {
+	*database.MemoryStore
+	failAt int
+	calls  int
+}
+
+func (s *failingImportStore) ImportUserBatch(ctx context.Context, jobId int, users []models.TransferUser, nextRecord, skipped, failed int) ([]database.UserImportOutcome, error) {
+	s.calls++
+	if s.calls == s.failAt {
+		return nil, errors.New("connection reset")
+	}
+	return s.MemoryStore.ImportUserBatch(ctx, jobId, users, nextRecord, skipped, failed)
+}
+
+func TestImportUsersResumesAfterFailedBatch(t *testing.T) {
+	ctx := context.Background()
+	memory := database.NewMemoryStore()
+	store := &failingImportStore{MemoryStore: memory, failAt: 2}
+	records := importRecords(
+		importUser("u1@example.com"),
+		importUser("u2@example.com"),
+		models.TransferUser{Email: "u3"},
+		importUser("u4@example.com"),
+		importUser("u5@example.com"),
+		importUser("u1@example.com"),
+	)
+	opts := ImportOptions{BatchSize: 2, Source: "users.csv"}
+
+	report, err := ImportUsers(ctx, store, records, "checksum", opts)
+	if err == nil || !strings.Contains(err.Error(), "importing records 3-4") {
+		t.Fatalf("error = %v", err)
+	}
+	if report.Imported != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	// the failed batch was rolled back; the job stops after the last
+	// committed one
+	if _, err := memory.GetUserByEmail(ctx, "u4@example.com"); err == nil {
+		t.Fatal("a user of the failed batch was imported")
+	}
+	job, err := memory.GetRunningUserImport(ctx, "checksum")
+	if err != nil || job.ID != report.JobId || job.NextRecord != 2 || job.Imported != 2 || job.Failed != 0 {
+		t.Fatalf("job = %+v, %v", job, err)
+	}
+
+	// the same file continues at the first record not committed; the
+	// duplicate of a record imported by the first run is still found
+	resumed, err := ImportUsers(ctx, store, records, "checksum", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.JobId != report.JobId || resumed.ResumedAt != 2 || resumed.Imported != 4 || resumed.Skipped != 0 || resumed.Failed != 2 {
+		t.Fatalf("resumed = %+v", resumed)
+	}
+	expectReasons(t, resumed,
+		"4 u3: invalid email",
+		"7 u1@example.com: duplicate email, first seen on line 2",
+	)
+	for _, email := range []string{"u1@example.com", "u2@example.com", "u4@example.com", "u5@example.com"} {
+		if _, err := memory.GetUserByEmail(ctx, email); err != nil {
+			t.Fatalf("%s was not imported: %v", email, err)
+		}
+	}
+
+	// a finished import is not resumed: running it again starts over and
+	// skips every account it created
+	again, err := ImportUsers(ctx, memory, records, "checksum", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.JobId == report.JobId || again.ResumedAt != 0 || again.Imported != 0 || again.Skipped != 4 || again.Failed != 2 {
+		t.Fatalf("again = %+v", again)
+	}
+}
+
+func TestExportUsersRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	records := importRecords(
+		models.TransferUser{Email: "ada@example.com", Name: "Ada, Countess", PasswordHash: importHash, Verified: true, Active: true,
+			Identities: []models.UserIdentity{{Provider: "github", Subject: "ada"}, {Provider: "google", Subject: "123"}}},
+		models.TransferUser{Email: "bob@example.com", PasswordHash: importHash},
+	)
+	if _, err := ImportUsers(ctx, store, records, "checksum", ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{TransferFormatCSV, TransferFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			count, err := ExportUsers(ctx, store, &buf, format)
+			if err != nil || count != 2 {
+				t.Fatalf("ExportUsers = %d, %v", count, err)
+			}
+			exported, err := ParseUserImport(buf.Bytes(), format)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, buf.String())
+			}
+			if len(exported) != 2 {
+				t.Fatalf("exported = %+v", exported)
+			}
+			for i, record := range exported {
+				want := records[i].User
+				got := record.User
+				if got.Email != want.Email || got.Name != want.Name || got.PasswordHash != want.PasswordHash ||
+					got.Verified != want.Verified || got.Active != want.Active || len(got.Identities) != len(want.Identities) {
+					t.Fatalf("exported %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := ExportUsers(ctx, store, &bytes.Buffer{}, "xml"); err == nil {
+		t.Fatal("an unsupported format was written")
+	}
+}
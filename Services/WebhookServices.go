@@ -0,0 +1,312 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	database "go_server/Database"
+	models "go_server/Models"
+	tracing "go_server/Tracing"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	EventUserSignedUp        = "user.signed_up"
+	EventUserLoggedIn        = "user.logged_in"
+	EventUserPasswordChanged = "user.password_changed"
+	EventUserDeleted         = "user.deleted"
+	EventWebhookTest         = "webhook.test"
+)
+
+// WebhookEvents lists the event types endpoints can subscribe to.
+var WebhookEvents = []string{
+	EventUserSignedUp,
+	EventUserLoggedIn,
+	EventUserPasswordChanged,
+	EventUserDeleted,
+}
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookPollInterval = 2 * time.Second
+	webhookBatchSize    = 20
+	webhookLease        = 2 * time.Minute
+	webhookTimeout      = 10 * time.Second
+)
+
+// ErrWebhookInsecureURL and ErrWebhookPrivateURL are returned by
+// CheckWebhookURL; dials to private addresses fail with the latter.
+var (
+	ErrWebhookInsecureURL = errors.New("webhook URL must use https")
+	ErrWebhookPrivateURL  = errors.New("webhook URL must not point to a loopback, private or link-local address")
+)
+
+// privatePrefixes are the ranges webhooks must not reach.
+var privatePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // this network
+	netip.MustParsePrefix("10.0.0.0/8"),     // private
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT, where some metadata services live
+	netip.MustParsePrefix("127.0.0.0/8"),    // loopback
+	netip.MustParsePrefix("169.254.0.0/16"), // link-local
+	netip.MustParsePrefix("172.16.0.0/12"),  // private
+	netip.MustParsePrefix("192.168.0.0/16"), // private
+	netip.MustParsePrefix("224.0.0.0/24"),   // link-local multicast
+	netip.MustParsePrefix("::/128"),         // unspecified
+	netip.MustParsePrefix("::1/128"),        // loopback
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which embeds IPv4 addresses
+	netip.MustParsePrefix("fc00::/7"),       // unique local
+	netip.MustParsePrefix("fe80::/10"),      // link-local
+	netip.MustParsePrefix("ff02::/16"),      // link-local multicast
+}
+
+// isPrivateAddress reports whether webhooks must not reach addr, which
+// lies in one of privatePrefixes.
+func isPrivateAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range privatePrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckWebhookURL rejects the URLs an endpoint may not be registered with:
+// plain http and hosts that are private addresses or localhost. Names
+// resolving to private addresses are caught again when dialing. With
+// allowInsecure, meant for local development, every http(s) URL passes.
+func CheckWebhookURL(rawURL string, allowInsecure bool) error {
+	if allowInsecure {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" {
+		return ErrWebhookInsecureURL
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookPrivateURL
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && isPrivateAddress(addr) {
+		return ErrWebhookPrivateURL
+	}
+	return nil
+}
+
+// refusePrivateAddress is a net.Dialer Control function. It runs on the
+// resolved address of every connection, so neither DNS nor a name added
+// after the endpoint was registered can point a delivery inward.
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if isPrivateAddress(addrPort.Addr()) {
+		return fmt.Errorf("dialing %s: %w", address, ErrWebhookPrivateURL)
+	}
+	return nil
+}
+
+// NewWebhookClient returns the client deliveries are sent with. Unless
+// allowInsecure is set it refuses to connect to private addresses. It
+// never follows redirects: the 3xx response fails the attempt, so an
+// endpoint cannot bounce a delivery to an address it may not reach.
+func NewWebhookClient(allowInsecure bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowInsecure {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// through a proxy the dialer would only see the proxy's address
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: tracing.Transport(transport),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func newWebhookEvent(appId int, eventType string, data interface{}) (models.WebhookEvent, []byte, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return models.WebhookEvent{}, nil, err
+	}
+	event := models.WebhookEvent{
+		ID:        "evt_" + hex.EncodeToString(buf),
+		Type:      eventType,
+		AppId:     appId,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return models.WebhookEvent{}, nil, err
+	}
+	return event, payload, nil
+}
+
+// IsWebhookEvent reports whether endpoints may subscribe to eventType.
+func IsWebhookEvent(eventType string) bool {
+	for _, event := range WebhookEvents {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EmitWebhookEvent queues an event for every endpoint of the app that is
+// subscribed to it. Delivery happens asynchronously in the webhook worker.
+func EmitWebhookEvent(ctx context.Context, store database.WebhookStore, appId int, eventType string, data interface{}) error {
+	event, payload, err := newWebhookEvent(appId, eventType, data)
+	if err != nil {
+		return err
+	}
+	_, err = store.InsertWebhookDeliveries(ctx, appId, event.ID, eventType, payload)
+	return err
+}
+
+// EmitWebhookEventToApps emits the same event to several apps. Failures are
+// logged and never fail the calling request.
+func EmitWebhookEventToApps(ctx context.Context, log *slog.Logger, store database.WebhookStore, appIds []int, eventType string, data interface{}) {
+	for _, appId := range appIds {
+		if err := EmitWebhookEvent(ctx, store, appId, eventType, data); err != nil {
+			log.ErrorContext(ctx, "queueing webhook event", "app_id", appId, "event", eventType, "err", err)
+		}
+	}
+}
+
+// SendTestWebhookEvent queues a webhook.test event for a single endpoint.
+func SendTestWebhookEvent(ctx context.Context, store database.WebhookStore, endpoint models.WebhookEndpoint) (models.WebhookEvent, error) {
+	event, payload, err := newWebhookEvent(endpoint.AppId, EventWebhookTest, map[string]interface{}{
+		"endpoint_id": endpoint.ID,
+		"message":     "This is a test event",
+	})
+	if err != nil {
+		return models.WebhookEvent{}, err
+	}
+	_, err = store.InsertWebhookDelivery(ctx, endpoint.ID, event.ID, EventWebhookTest, payload)
+	return event, err
+}
+
+// SignWebhookPayload computes the value of the X-Webhook-Signature header:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Receivers should
+// recompute the HMAC with their endpoint secret and reject old timestamps.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook performs one HTTP attempt and returns the response status
+// code (0 when no response was received).
+func deliverWebhook(ctx context.Context, client *http.Client, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoAuth-Webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", delivery.EventId)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Signature", SignWebhookPayload(delivery.Secret, time.Now(), delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func processWebhookDelivery(ctx context.Context, log *slog.Logger, store database.WebhookStore, client *http.Client, delivery models.WebhookDelivery) {
+	attempts := delivery.Attempts + 1
+	statusCode, err := deliverWebhook(ctx, client, delivery)
+	if err == nil {
+		if err := store.MarkWebhookDeliverySucceeded(ctx, delivery.ID, attempts, statusCode); err != nil {
+			log.Error("recording webhook delivery", "delivery_id", delivery.ID, "err", err)
+		}
+		return
+	}
+	log.Warn("webhook delivery failed", "delivery_id", delivery.ID, "attempt", attempts, "status_code", statusCode, "err", err)
+
+	dead := attempts >= webhookMaxAttempts
+	err = store.MarkWebhookDeliveryFailed(ctx, delivery.ID, attempts, statusCode, err.Error(),
+		time.Now().Add(retryBackoff(webhookBaseBackoff, webhookMaxBackoff, attempts)), dead)
+	if err != nil {
+		log.Error("recording webhook delivery", "delivery_id", delivery.ID, "err", err)
+	}
+}
+
+// RunWebhookWorkerOnce claims and delivers one batch of due deliveries and
+// returns how many were processed.
+func RunWebhookWorkerOnce(ctx context.Context, log *slog.Logger, store database.WebhookStore, client *http.Client) (int, error) {
+	deliveries, err := store.ClaimDueWebhookDeliveries(ctx, webhookBatchSize, webhookLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, delivery := range deliveries {
+		processWebhookDelivery(ctx, log, store, client, delivery)
+	}
+	return len(deliveries), nil
+}
+
+// StartWebhookWorker polls the delivery queue in the background until ctx
+// is cancelled. The returned channel is closed once the worker has stopped.
+func StartWebhookWorker(ctx context.Context, log *slog.Logger, store database.WebhookStore, client *http.Client) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			// drain full batches before sleeping again
+			for {
+				processed, err := RunWebhookWorkerOnce(ctx, log, store, client)
+				if err != nil {
+					log.Error("processing webhook deliveries", "err", err)
+				}
+				if err != nil || processed < webhookBatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
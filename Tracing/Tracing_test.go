@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	config "go_server/Config"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransportPropagatesTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL+"/hook?secret=s3cr3t", nil)
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want the client and parent span", len(spans))
+	}
+	span := spans[0]
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("client span is not a child of the caller's span")
+	}
+	if !strings.Contains(traceparent, span.SpanContext().SpanID().String()) {
+		t.Fatalf("traceparent = %q, want the client span %s", traceparent, span.SpanContext().SpanID())
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Fatalf("status = %v, want an error for a 502", span.Status())
+	}
+	for _, attr := range span.Attributes() {
+		if strings.Contains(attr.Value.Emit(), "s3cr3t") {
+			t.Fatalf("span records the query string in %s", attr.Key)
+		}
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	var out bytes.Buffer
+	cfg := config.Default().Tracing
+	cfg.Exporter = "stdout"
+	shutdown, err := Setup(context.Background(), cfg, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "checkout")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"Name": "checkout"`) || !strings.Contains(out.String(), cfg.ServiceName) {
+		t.Fatalf("exported:\n%s", out.String())
+	}
+}
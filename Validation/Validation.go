@@ -0,0 +1,179 @@
+// Package validation binds request bodies to the DTOs in Models and turns
+// validation failures into per-field errors.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	apierror "go_server/ApiError"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one invalid field. Field is the JSON name, Code the
+// rule that failed, such as "required" or "max".
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ScopeTokenPattern matches one OAuth scope (RFC 6749 section 3.3).
+var ScopeTokenPattern = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]+$`)
+
+var setupOnce sync.Once
+
+// setup names fields by their JSON name in errors and registers the rules
+// gin's validator lacks.
+func setup() {
+	setupOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(FieldName)
+		v.RegisterValidation("https_url", func(fl validator.FieldLevel) bool {
+			parsed, err := url.Parse(fl.Field().String())
+			return err == nil && parsed.Scheme == "https" && parsed.Host != ""
+		})
+		v.RegisterValidation("hex_color", func(fl validator.FieldLevel) bool {
+			return hexColorPattern.MatchString(fl.Field().String())
+		})
+		v.RegisterValidation("scope_token", func(fl validator.FieldLevel) bool {
+			return ScopeTokenPattern.MatchString(fl.Field().String())
+		})
+	})
+}
+
+// FieldName is the name a struct field has in requests: its json name, or
+// its form name for query parameters.
+func FieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// Bind fills req from the JSON or form body, picked by the Content-Type,
+// and validates it. An empty body counts as an empty object.
+func Bind(c *gin.Context, req interface{}) error {
+	setup()
+	err := c.ShouldBind(req)
+	if errors.Is(err, io.EOF) {
+		err = binding.Validator.ValidateStruct(req)
+	}
+	return translate(err)
+}
+
+// BindQuery fills req from the query string and validates it.
+func BindQuery(c *gin.Context, req interface{}) error {
+	setup()
+	return translate(c.ShouldBindQuery(req))
+}
+
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fields := make([]FieldError, 0, len(invalid))
+		for _, fe := range invalid {
+			fields = append(fields, fieldError(fe))
+		}
+		return invalidFields(fields)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return invalidFields([]FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: typeErr.Field + " must be " + typeName(typeErr.Type.Kind().String()),
+		}})
+	}
+	// form values that do not parse, such as app_id=abc, and malformed JSON
+	return apierror.BadRequest(apierror.CodeInvalidRequest, "The request body is malformed")
+}
+
+func invalidFields(fields []FieldError) *apierror.Error {
+	return apierror.BadRequest(apierror.CodeValidationFailed, "The request has invalid fields").With("errors", fields)
+}
+
+func fieldError(fe validator.FieldError) FieldError {
+	field := fe.Field()
+	out := FieldError{Field: field, Code: fe.Tag()}
+	switch fe.Tag() {
+	case "required", "required_without":
+		out.Code = "required"
+		out.Message = field + " is required"
+	case "email":
+		out.Message = field + " must be a valid email address"
+	case "http_url":
+		out.Code = "url"
+		out.Message = field + " must be an http(s) URL"
+	case "https_url":
+		out.Code = "url"
+		out.Message = field + " must be an https URL"
+	case "hex_color":
+		out.Message = field + " must be a hex color such as #4f46e5"
+	case "scope_token":
+		out.Message = field + " must be a scope without spaces, quotes or backslashes"
+	case "oneof":
+		out.Params = map[string]interface{}{"values": strings.Fields(fe.Param())}
+		out.Message = field + " must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "max":
+		if n, err := strconv.Atoi(fe.Param()); err == nil {
+			out.Params = map[string]interface{}{fe.Tag(): n}
+		}
+		out.Message = field + " " + boundMessage(fe)
+	default:
+		out.Message = field + " is invalid"
+	}
+	return out
+}
+
+func boundMessage(fe validator.FieldError) string {
+	bound := "at most"
+	if fe.Tag() == "min" {
+		bound = "at least"
+	}
+	switch fe.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("must be %s %s characters long", bound, fe.Param())
+	case reflect.Slice:
+		return fmt.Sprintf("must have %s %s items", bound, fe.Param())
+	}
+	return fmt.Sprintf("must be %s %s", bound, fe.Param())
+}
+
+func typeName(kind string) string {
+	switch kind {
+	case "int", "int64", "float64":
+		return "a number"
+	case "bool":
+		return "a boolean"
+	case "slice":
+		return "an array"
+	}
+	return "a " + kind
+}
@@ -1,52 +1,127 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	_ "go_server/Config"
+	apierror "go_server/ApiError"
+	commands "go_server/Commands"
+	config "go_server/Config"
+	controller "go_server/Controllers"
+	database "go_server/Database"
+	logging "go_server/Logging"
+	metrics "go_server/Metrics"
 	routes "go_server/Routes"
+	server "go_server/Server"
+	services "go_server/Services"
+	tracing "go_server/Tracing"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 	"github.com/gin-gonic/gin"
 )
 
+// tracingFlushTimeout bounds how long exiting waits for buffered spans to
+// reach the collector.
+const tracingFlushTimeout = 5 * time.Second
 
 func main() {
 
-	router := gin.Default()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	logs := logging.New(cfg.Log, os.Stdout)
+	log := logs.For(logging.ComponentServer)
 
-	// Add CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Authorization, Content-Type")
+	db, err := database.Connect(cfg.Database.URL, logs.For(logging.ComponentStore))
+	if err != nil {
+		panic(err)
+	}
+	store := database.NewPostgresStore(db)
+	metrics.RegisterDBStats(db)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	if len(args) > 0 {
+		if err := commands.Run(context.Background(), store, args); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
+		return
+	}
 
-		c.Next()
-	})
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, os.Stdout)
+	if err != nil {
+		panic(err)
+	}
+
+	router := gin.New()
 
-	routes.SetupRoutes(router)
+	handler, err := controller.NewHandler(store, cfg, logs)
+	if err != nil {
+		panic(err)
+	}
+	routes.SetupRoutes(router, handler)
 	router.Static("/dist", "./dist")
 	router.NoRoute(func(c *gin.Context) {
 		path := c.Request.URL.Path
-		fmt.Println(path)
 		if strings.HasPrefix(path, "/api/") {
-			c.JSON(404, gin.H{"error": "Not found"})
+			apierror.Abort(c, apierror.NotFound("Not found"))
 		} else if c.Request.Method == "GET" {
 			c.File("./dist/index.html")
 		} else {
-			c.JSON(404, gin.H{"error": "Not found"})
+			apierror.Abort(c, apierror.NotFound("Not found"))
 		}
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	var certs *server.Certificates
+	if cfg.Server.TLS.CertFile != "" {
+		certs, err = server.NewCertificates(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		if err != nil {
+			panic(err)
+		}
 	}
-	if err := router.Run(":" + port); err != nil {
-		panic(err)
+	servers := []*http.Server{server.New(cfg.Server, router, certs)}
+	if cfg.Server.Admin.Port != "" {
+		admin, err := server.NewAdmin(cfg.Server, routes.NewAdminRouter(handler), certs)
+		if err != nil {
+			panic(err)
+		}
+		servers = append(servers, admin)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if certs != nil {
+		server.ReloadOnHangup(ctx, log, certs)
+	}
+
+	workers, stopWorkers := context.WithCancel(context.Background())
+	webhooksDone := services.StartWebhookWorker(workers, logs.For(logging.ComponentWebhooks), store, services.NewWebhookClient(cfg.Webhooks.AllowInsecure))
+	emailLog := logs.For(logging.ComponentEmail)
+	emailsDone := services.StartEmailWorker(workers, emailLog, store, services.NewMailer(cfg.Email, emailLog))
+
+	err = server.Run(ctx, log, cfg.Server.ShutdownTimeout.Duration, servers...)
+	if err != nil {
+		log.Error("server failed", "err", err)
+	}
+
+	// requests have drained; wait for the workers to stop so nothing uses
+	// the pool once it is closed. Interrupted deliveries are retried later.
+	stopWorkers()
+	<-webhooksDone
+	<-emailsDone
+	db.Close()
+	flushCtx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Error("flushing traces", "err", err)
+	}
+	cancel()
+	log.Info("stopped")
+	if err != nil {
+		os.Exit(1)
 	}
 }